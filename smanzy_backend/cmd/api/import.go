@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// runImport implements "smanzy import": it adds the files of a server-side
+// directory to a user's library. It uses the same DB_DSN and UPLOAD_DIR as
+// the server and exits non-zero if any file failed.
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	userFlag := flags.String("user", "", "ID or email of the user who will own the media (required)")
+	visibility := flags.String("visibility", models.VisibilityInherit, "Visibility of the imported media: private, unlisted, public or inherit")
+	albums := flags.Bool("albums", false, "Create an album per folder and add its files to it")
+	dryRun := flags.Bool("dry-run", false, "Show what would be imported without changing anything")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import -user <id|email> [options] <directory>\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if *userFlag == "" || flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	dir := flags.Arg(0)
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+	dbDSN := os.Getenv("DB_DSN")
+	if dbDSN == "" {
+		log.Fatal("DB_DSN environment variable is required")
+	}
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	if uploadDir == "" {
+		log.Fatal("UPLOAD_DIR environment variable is required")
+	}
+
+	conn, err := db.Connect(dbDSN)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer conn.Close()
+	queries := db.New(conn)
+
+	// Stop cleanly between files on Ctrl-C; a re-run skips what was imported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	userID, userName, err := lookupImportUser(ctx, queries, *userFlag)
+	if err != nil {
+		log.Fatalf("User %q not found: %v", *userFlag, err)
+	}
+
+	mediaService := services.NewMediaService(conn, queries, uploadDir, services.DefaultMaxVersions)
+	importer := services.NewImportService(queries, mediaService, services.NewAlbumService(conn, queries))
+
+	if *dryRun {
+		fmt.Println("Dry run: nothing will be changed")
+	}
+	fmt.Printf("Importing %s for %s (user %d)\n", dir, userName, userID)
+
+	summary, err := importer.Import(ctx, services.ImportOptions{
+		UserID:         userID,
+		UserName:       userName,
+		Dir:            dir,
+		Visibility:     *visibility,
+		AlbumPerFolder: *albums,
+		DryRun:         *dryRun,
+	}, func(p services.ImportProgress) {
+		line := fmt.Sprintf("[%d/%d] %-8s %s", p.Index, p.Total, p.Status, p.Path)
+		if p.Err != nil {
+			line += ": " + p.Err.Error()
+		}
+		fmt.Println(line)
+	})
+
+	fmt.Printf("\n%d file(s): %d imported (%s), %d skipped as duplicates, %d failed",
+		summary.Files, summary.Imported, formatBytes(summary.Bytes), summary.Skipped, summary.Failed)
+	if *albums {
+		fmt.Printf(", %d album(s) created", summary.AlbumsCreated)
+	}
+	fmt.Printf(" in %s\n", summary.Duration.Round(10*time.Millisecond))
+
+	if err != nil {
+		stop()
+		conn.Close()
+		log.Fatalf("Import stopped: %v", err)
+	}
+	if summary.Failed > 0 {
+		conn.Close()
+		os.Exit(1)
+	}
+}
+
+// lookupImportUser resolves the -user flag, a numeric ID or an email address
+func lookupImportUser(ctx context.Context, queries *db.Queries, user string) (uint, string, error) {
+	if id, err := strconv.ParseInt(user, 10, 64); err == nil {
+		row, err := queries.GetUserByID(ctx, id)
+		if err != nil {
+			return 0, "", err
+		}
+		return uint(row.ID), row.Name, nil
+	}
+	row, err := queries.GetUserByEmail(ctx, user)
+	if err != nil {
+		return 0, "", err
+	}
+	return uint(row.ID), row.Name, nil
+}
+
+// formatBytes formats a size in bytes for the import summary
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 
 	// Gin is a web framework for Go (handling HTTP requests/responses)
 	"github.com/gin-gonic/gin"
@@ -18,6 +22,7 @@ import (
 	"github.com/ristep/smanzy_backend/internal/db"
 	"github.com/ristep/smanzy_backend/internal/handlers"
 	"github.com/ristep/smanzy_backend/internal/middleware"
+	"github.com/ristep/smanzy_backend/internal/scanner"
 	"github.com/ristep/smanzy_backend/internal/services"
 	"github.com/ulule/limiter/v3"
 	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
@@ -26,6 +31,13 @@ import (
 
 // main is the entry point of the application
 func main() {
+	// Subcommands: "import" adds files from a server-side directory (see import.go);
+	// without one, the API server starts
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
+
 	// Parse CLI flags
 	migrate := flag.Bool("migrate", false, "Run database migrations")
 	flag.Parse()
@@ -48,6 +60,136 @@ func main() {
 		log.Fatal("JWT_SECRET environment variable is required")
 	}
 
+	// Secret and lifetime for signed media URLs (defaults to the JWT secret and 1 hour)
+	mediaURLSecret := os.Getenv("MEDIA_URL_SECRET")
+	if mediaURLSecret == "" {
+		mediaURLSecret = jwtSecret
+	}
+	mediaURLTTL := time.Hour
+	if ttl := os.Getenv("MEDIA_URL_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("Invalid MEDIA_URL_TTL %q: %v", ttl, err)
+		}
+		mediaURLTTL = parsed
+	}
+
+	// How long trashed media is kept before it is purged for good
+	trashRetentionDays := 30
+	if days := os.Getenv("TRASH_RETENTION_DAYS"); days != "" {
+		parsed, err := strconv.Atoi(days)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid TRASH_RETENTION_DAYS %q", days)
+		}
+		trashRetentionDays = parsed
+	}
+	trashPurgeInterval := time.Hour
+	if interval := os.Getenv("TRASH_PURGE_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid TRASH_PURGE_INTERVAL %q", interval)
+		}
+		trashPurgeInterval = parsed
+	}
+
+	// How many earlier versions of a replaced media file are kept
+	mediaMaxVersions := services.DefaultMaxVersions
+	if n := os.Getenv("MEDIA_MAX_VERSIONS"); n != "" {
+		parsed, err := strconv.Atoi(n)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid MEDIA_MAX_VERSIONS %q", n)
+		}
+		mediaMaxVersions = parsed
+	}
+
+	// How often new media is scanned for perceptual hashes (duplicate detection)
+	// and placeholders
+	hashIndexInterval := time.Minute
+	if interval := os.Getenv("MEDIA_HASH_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid MEDIA_HASH_INTERVAL %q", interval)
+		}
+		hashIndexInterval = parsed
+	}
+
+	// On-demand image rendering: allowed sizes and the disk cache budget
+	renderPresets, err := services.ParseRenderPresets(services.DefaultRenderPresets)
+	if presets := os.Getenv("RENDER_PRESETS"); presets != "" {
+		renderPresets, err = services.ParseRenderPresets(presets)
+	}
+	if err != nil {
+		log.Fatalf("Invalid RENDER_PRESETS: %v", err)
+	}
+	renderCacheDir := os.Getenv("RENDER_CACHE_DIR")
+	if renderCacheDir == "" {
+		renderCacheDir = filepath.Join(os.Getenv("UPLOAD_DIR"), "render-cache")
+	}
+	renderCacheSize := int64(services.DefaultRenderCacheSize)
+	if mb := os.Getenv("RENDER_CACHE_SIZE_MB"); mb != "" {
+		parsed, err := strconv.ParseInt(mb, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid RENDER_CACHE_SIZE_MB %q", mb)
+		}
+		renderCacheSize = parsed << 20
+	}
+	ffmpegPath := os.Getenv("FFMPEG_PATH")
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	ffprobePath := os.Getenv("FFPROBE_PATH")
+	if ffprobePath == "" {
+		ffprobePath = "ffprobe"
+	}
+
+	// How often the transcoder looks for new videos to convert to HLS
+	transcodeInterval := 30 * time.Second
+	if interval := os.Getenv("TRANSCODE_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid TRANSCODE_INTERVAL %q", interval)
+		}
+		transcodeInterval = parsed
+	}
+
+	// Limits for fetching media from remote URLs
+	urlImportMaxSize := int64(services.DefaultURLImportMaxSize)
+	if mb := os.Getenv("URL_IMPORT_MAX_SIZE_MB"); mb != "" {
+		parsed, err := strconv.ParseInt(mb, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid URL_IMPORT_MAX_SIZE_MB %q", mb)
+		}
+		urlImportMaxSize = parsed << 20
+	}
+	urlImportTimeout := services.DefaultURLImportTimeout
+	if timeout := os.Getenv("URL_IMPORT_TIMEOUT"); timeout != "" {
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid URL_IMPORT_TIMEOUT %q", timeout)
+		}
+		urlImportTimeout = parsed
+	}
+
+	// Malware scanner for new files. Without one, files leave quarantine unscanned.
+	var fileScanner scanner.Scanner = scanner.Nop{}
+	if clamdAddress := os.Getenv("CLAMD_ADDRESS"); clamdAddress != "" {
+		clamdTimeout := scanner.DefaultClamdTimeout
+		if timeout := os.Getenv("CLAMD_TIMEOUT"); timeout != "" {
+			parsed, err := time.ParseDuration(timeout)
+			if err != nil || parsed <= 0 {
+				log.Fatalf("Invalid CLAMD_TIMEOUT %q", timeout)
+			}
+			clamdTimeout = parsed
+		}
+		clamd, err := scanner.NewClamd(clamdAddress, clamdTimeout)
+		if err != nil {
+			log.Fatalf("Invalid CLAMD_ADDRESS: %v", err)
+		}
+		fileScanner = clamd
+	} else {
+		log.Println("Warning: CLAMD_ADDRESS not set, new files are released without a malware scan")
+	}
+
 	serverPort := os.Getenv("SERVER_PORT") // Port to run the server on
 	if serverPort == "" {
 		serverPort = "8080" // Default to 8080 if not specified
@@ -89,13 +231,56 @@ func main() {
 	// 6. Service Initialization
 	// Initialize our services and handlers, injecting dependencies (like the DB connection)
 	jwtService := auth.NewJWTService(jwtSecret)
+	urlSigner := auth.NewURLSigner(mediaURLSecret, mediaURLTTL)
 	youtubeService := services.NewYouTubeService(youtubeAPIKey, youtubeChannelID)
+	mediaService := services.NewMediaService(conn, queries, os.Getenv("UPLOAD_DIR"), mediaMaxVersions)
+	albumService := services.NewAlbumService(conn, queries)
+
+	renderService, err := services.NewRenderService(os.Getenv("UPLOAD_DIR"), services.RenderConfig{
+		CacheDir:   renderCacheDir,
+		CacheSize:  renderCacheSize,
+		Presets:    renderPresets,
+		FFmpegPath: ffmpegPath,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize render cache: %v", err)
+	}
+
+	scanService := services.NewScanService(queries, mediaService, fileScanner)
+	watermarkService := services.NewWatermarkService(queries, os.Getenv("UPLOAD_DIR"))
+	urlImportService := services.NewURLImportService(queries, mediaService, urlImportMaxSize, urlImportTimeout)
 
 	authHandler := handlers.NewAuthHandler(conn, queries, jwtService)
 	userHandler := handlers.NewUserHandler(conn, queries)
-	mediaHandler := handlers.NewMediaHandler(conn, queries)
-	albumHandler := handlers.NewAlbumHandler(conn, queries)
+	mediaHandler := handlers.NewMediaHandler(conn, queries, urlSigner, mediaService, albumService, renderService, urlImportService, watermarkService)
+	watermarkHandler := handlers.NewWatermarkHandler(watermarkService)
+	albumHandler := handlers.NewAlbumHandler(albumService, urlSigner)
 	videoHandler := handlers.NewVideoHandler(conn, queries, youtubeService)
+	tagHandler := handlers.NewTagHandler(conn, queries, albumService)
+	searchHandler := handlers.NewSearchHandler(conn, urlSigner)
+
+	// Background job: permanently delete media that has outlived the trash retention period
+	go mediaService.RunTrashPurger(context.Background(), time.Duration(trashRetentionDays)*24*time.Hour, trashPurgeInterval)
+
+	// Background job: scan quarantined files for malware, releasing clean ones
+	// to the upload directory and trashing infected ones
+	go scanService.Run(context.Background(), 30*time.Second)
+
+	// Background job: transcode uploaded videos to HLS. Without ffmpeg videos
+	// stay pending and are served from the original file.
+	if _, err := exec.LookPath(ffmpegPath); err != nil {
+		log.Printf("Warning: ffmpeg not found (%v), video transcoding is disabled", err)
+	} else {
+		transcodeService := services.NewTranscodeService(queries, os.Getenv("UPLOAD_DIR"), ffmpegPath, ffprobePath)
+		go transcodeService.Run(context.Background(), transcodeInterval)
+	}
+
+	// Background job: fetch media queued for import from remote URLs
+	go urlImportService.Run(context.Background(), 30*time.Second)
+
+	// Background job: compute perceptual hashes and placeholders (BlurHash,
+	// dominant color, aspect ratio) of new images and videos once their thumbnails exist
+	go mediaService.RunThumbnailIndexer(context.Background(), hashIndexInterval)
 
 	// 7. Router Setup
 	// Create a new Gin router with default middleware (logger and recovery)
@@ -126,6 +311,10 @@ func main() {
 	limiterInstance := limiter.New(store, rate)
 	rateLimitMiddleware := mgin.NewMiddleware(limiterInstance)
 
+	// Share links get their own limiter, so guessing share passwords does not
+	// use up the login budget of an IP and logins do not block share links
+	shareLimitMiddleware := mgin.NewMiddleware(limiter.New(memory.NewStore(), rate))
+
 	// 8. Define Routes
 	// Group routes under /api
 	api := router.Group("/api")
@@ -149,11 +338,30 @@ func main() {
 		api.GET("/videos", videoHandler.ListVideosHandler)
 		api.GET("/videos/:id", videoHandler.GetVideoHandler)
 
-		// Public media listing
+		// Public media listing (only media that is effectively public)
 		api.GET("/media", mediaHandler.ListPublicMediasHandler)
 
+		// Public albums; shared albums are not listed but can be opened by ID
+		api.GET("/public/albums", mediaHandler.ListPublicAlbumsHandler)   // List public albums
+		api.GET("/public/albums/:id", mediaHandler.GetPublicAlbumHandler) // Public or shared album with its media
+
+		// Album share links; rate limited to slow down password guessing
+		api.GET("/share/:slug", shareLimitMiddleware, mediaHandler.OpenShareLinkHandler) // Album behind a share link (password in X-Share-Password)
+
+		// Full-text search across media, albums and videos.
+		// Works anonymously; a valid token adds the user's own private results.
+		api.GET("/search", middleware.OptionalAuthMiddleware(jwtService, queries), searchHandler.SearchAllHandler)
+		// Resized derivative; public media can be rendered without logging in
+		api.GET("/media/:id/render", middleware.OptionalAuthMiddleware(jwtService, queries), mediaHandler.RenderMediaHandler)
+		// HLS stream; playlist URIs are signed so players can follow them without a token
+		api.GET("/media/:id/stream/*path", middleware.OptionalAuthMiddleware(jwtService, queries), mediaHandler.StreamMediaHandler)
+
+		// Public tag cloud (counts over public media only)
+		api.GET("/tags/cloud", tagHandler.TagCloudHandler)
+
 		// Serve uploaded files directly (for development)
 		// :name is a path parameter that captures the filename.
+		// Files need a signed URL (?expires=&sig=) unless the media is public.
 		// MEDIA_FILES_URL should be "/media/files/" (path under /api group); nginx proxies ^~ /api/media/files/
 		mediaFilesPath := os.Getenv("MEDIA_FILES_URL")
 		if mediaFilesPath == "" {
@@ -181,8 +389,12 @@ func main() {
 		// Authenticated User routes
 		profile := protectedAPI.Group("/profile")
 		{
-			profile.GET("", authHandler.ProfileHandler)       // Get current user profile
-			profile.PUT("", authHandler.UpdateProfileHandler) // Update current user profile
+			profile.GET("", authHandler.ProfileHandler)                                    // Get current user profile
+			profile.PUT("", authHandler.UpdateProfileHandler)                              // Update current user profile
+			profile.GET("/watermark", watermarkHandler.GetWatermarkHandler)                // Get watermark settings
+			profile.PUT("/watermark", watermarkHandler.UpdateWatermarkHandler)             // Update watermark settings
+			profile.POST("/watermark/logo", watermarkHandler.UploadWatermarkLogoHandler)   // Upload watermark logo (PNG)
+			profile.DELETE("/watermark/logo", watermarkHandler.DeleteWatermarkLogoHandler) // Remove watermark logo
 		}
 
 		// Admin-only routes
@@ -208,12 +420,31 @@ func main() {
 		// Media routes (authenticated)
 		media := protectedAPI.Group("/media")
 		{
-			media.POST("", mediaHandler.UploadHandler)                        // Upload a new file
-			media.GET("/:id", mediaHandler.GetMediaHandler)                   // Get file content
-			media.GET("/:id/details", mediaHandler.GetMediaDetailsHandler)    // Get file metadata
-			media.GET("/album/:album_id", mediaHandler.ListAlbumMediaHandler) // List media for an album
-			media.PUT("/:id", mediaHandler.UpdateMediaHandler)                // Edit file (Owner or Admin)
-			media.DELETE("/:id", mediaHandler.DeleteMediaHandler)             // Delete file (Owner or Admin)
+			media.POST("", mediaHandler.UploadHandler)                                          // Upload a new file
+			media.GET("/mine", mediaHandler.ListMyMediaHandler)                                 // List current user's media
+			media.GET("/trash", mediaHandler.ListTrashHandler)                                  // List current user's trashed media
+			media.DELETE("/trash", mediaHandler.EmptyTrashHandler)                              // Permanently delete all trashed media
+			media.POST("/batch", mediaHandler.BatchMediaHandler)                                // Apply one action to many files in one transaction
+			media.POST("/download", mediaHandler.DownloadMediaHandler)                          // Download files as a streamed ZIP (files the caller can open)
+			media.GET("/duplicates", mediaHandler.ListDuplicatesHandler)                        // Groups of look-alike files of the current user
+			media.POST("/import-url", mediaHandler.ImportURLHandler)                            // Queue a remote image or video for import
+			media.GET("/import-url", mediaHandler.ListURLImportsHandler)                        // Recent URL imports of the current user
+			media.GET("/import-url/:id", mediaHandler.GetURLImportHandler)                      // URL import status (Owner or Admin)
+			media.GET("/:id", mediaHandler.GetMediaHandler)                                     // Get file content
+			media.GET("/:id/details", mediaHandler.GetMediaDetailsHandler)                      // Get file metadata
+			media.GET("/album/:album_id", mediaHandler.ListAlbumMediaHandler)                   // List media for an album (anyone who can view it)
+			media.PUT("/:id", mediaHandler.UpdateMediaHandler)                                  // Edit file (Owner or Admin)
+			media.DELETE("/:id", mediaHandler.DeleteMediaHandler)                               // Move file to trash (Owner or Admin)
+			media.POST("/:id/restore", mediaHandler.RestoreMediaHandler)                        // Restore file from trash (Owner or Admin)
+			media.GET("/:id/versions", mediaHandler.ListMediaVersionsHandler)                   // List earlier versions (Owner or Admin)
+			media.GET("/:id/versions/:version", mediaHandler.DownloadMediaVersionHandler)       // Download an earlier version
+			media.POST("/:id/versions/:version/revert", mediaHandler.RevertMediaVersionHandler) // Make an earlier version current
+			media.GET("/:id/similar", mediaHandler.ListSimilarMediaHandler)                     // Look-alike files of the same owner (Owner or Admin)
+			media.POST("/:id/merge", mediaHandler.MergeMediaHandler)                            // Keep this file, trash the given duplicates (Owner or Admin)
+			media.POST("/:id/transcode", mediaHandler.RetranscodeMediaHandler)                  // Queue a video for HLS transcoding again (Owner or Admin)
+			media.GET("/:id/tags", tagHandler.ListMediaTagsHandler)                             // List tags (Owner or Admin)
+			media.POST("/:id/tags", tagHandler.AddMediaTagsHandler)                             // Add tags (Owner or Admin)
+			media.DELETE("/:id/tags/:tag", tagHandler.RemoveMediaTagHandler)                    // Remove a tag (Owner or Admin)
 		}
 
 		// Album routes (authenticated)
@@ -221,13 +452,46 @@ func main() {
 		{
 			albums.POST("", albumHandler.CreateAlbumHandler)       // Create a new album
 			albums.GET("", albumHandler.GetUserAlbumsHandler)      // Get all albums for current user
-			albums.GET("/:id", albumHandler.GetAlbumHandler)       // Get album by ID
-			albums.PUT("/:id", albumHandler.UpdateAlbumHandler)    // Update album details
-			albums.DELETE("/:id", albumHandler.DeleteAlbumHandler) // Delete album (soft delete)
+			albums.GET("/:id", albumHandler.GetAlbumHandler)       // Get album by ID (anyone who can view it)
+			albums.PUT("/:id", albumHandler.UpdateAlbumHandler)    // Update album details (Editor, Owner or Admin)
+			albums.DELETE("/:id", albumHandler.DeleteAlbumHandler) // Delete album (soft delete; Owner or Admin)
+
+			// Copying and merging
+			albums.POST("/merge", albumHandler.MergeAlbumsHandler)            // Merge albums into another and delete them (Editor of the target; Owner or Admin of each source)
+			albums.POST("/:id/duplicate", albumHandler.DuplicateAlbumHandler) // Copy an album (anyone who can view it; admins may copy into another account)
+
+			// Nested albums
+			albums.GET("/:id/tree", albumHandler.GetAlbumTreeHandler) // Album with its visible sub-albums and media counts
+			albums.PUT("/:id/parent", albumHandler.MoveAlbumHandler)  // Move under another album, or to the top level (Owner or Admin)
 
 			// Album media management
-			albums.POST("/:id/media", albumHandler.AddMediaToAlbumHandler)        // Add media to album
-			albums.DELETE("/:id/media", albumHandler.RemoveMediaFromAlbumHandler) // Remove media from album
+			albums.POST("/:id/media", albumHandler.AddMediaToAlbumHandler)        // Add own media to album (Contributor and up)
+			albums.DELETE("/:id/media", albumHandler.RemoveMediaFromAlbumHandler) // Remove media from album (Editor and up; contributors their own)
+			albums.PUT("/:id/media/order", albumHandler.ReorderAlbumMediaHandler) // Set the manual order (Editor and up)
+
+			// Smart albums
+			albums.PUT("/:id/rules", albumHandler.SetSmartRulesHandler)              // Make a smart album or change its rules (Editor, Owner or Admin)
+			albums.DELETE("/:id/rules", albumHandler.ClearSmartRulesHandler)         // Back to a regular album (Editor, Owner or Admin)
+			albums.POST("/:id/rules/preview", mediaHandler.PreviewSmartRulesHandler) // Media the rules would list, without saving (Editor, Owner or Admin)
+
+			// Album download
+			albums.GET("/:id/download", mediaHandler.DownloadAlbumHandler) // Download the media as a streamed ZIP (anyone who can view it)
+
+			// Album share links
+			albums.POST("/:id/shares", albumHandler.CreateShareLinkHandler)             // Create a share link (Owner or Admin)
+			albums.GET("/:id/shares", albumHandler.ListShareLinksHandler)               // List share links with view counts (Owner or Admin)
+			albums.DELETE("/:id/shares/:share_id", albumHandler.RevokeShareLinkHandler) // Revoke a share link (Owner or Admin)
+
+			// Collaborators
+			albums.GET("/:id/members", albumHandler.ListAlbumMembersHandler)              // List collaborators (Owner or Admin)
+			albums.POST("/:id/members", albumHandler.AddAlbumMemberHandler)               // Invite a viewer, contributor or editor (Owner or Admin)
+			albums.PUT("/:id/members/:user_id", albumHandler.UpdateAlbumMemberHandler)    // Change a collaborator's role (Owner or Admin)
+			albums.DELETE("/:id/members/:user_id", albumHandler.RemoveAlbumMemberHandler) // Remove a collaborator (Owner or Admin, or the collaborator leaving)
+
+			// Album tags
+			albums.GET("/:id/tags", tagHandler.ListAlbumTagsHandler)          // List tags (anyone who can view the album)
+			albums.POST("/:id/tags", tagHandler.AddAlbumTagsHandler)          // Add tags (Editor, Owner or Admin)
+			albums.DELETE("/:id/tags/:tag", tagHandler.RemoveAlbumTagHandler) // Remove a tag (Editor, Owner or Admin)
 		}
 
 		// Admin-only album routes
@@ -237,6 +501,22 @@ func main() {
 			adminAlbums.GET("/all", albumHandler.GetAllAlbumsHandler) // Get all albums from all users (admin only)
 		}
 
+		// Tag routes (authenticated)
+		tags := protectedAPI.Group("/tags")
+		{
+			tags.GET("", tagHandler.AutocompleteTagsHandler)        // Autocomplete: ?q=prefix&limit=10
+			tags.GET("/curated", tagHandler.ListCuratedTagsHandler) // List curated tags
+		}
+
+		// Admin-only tag routes
+		adminTags := protectedAPI.Group("/tags")
+		adminTags.Use(middleware.RoleMiddleware("admin"))
+		{
+			adminTags.POST("", tagHandler.CreateCuratedTagHandler) // Create a curated tag
+			adminTags.PUT("/:id", tagHandler.UpdateTagHandler)     // Mark a tag curated or free-form
+			adminTags.DELETE("/:id", tagHandler.DeleteTagHandler)  // Delete a tag everywhere
+		}
+
 		// Video routes (authenticated)
 		videos := protectedAPI.Group("/videos")
 		{
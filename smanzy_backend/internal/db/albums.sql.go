@@ -11,8 +11,11 @@ import (
 )
 
 const addMediaToAlbum = `-- name: AddMediaToAlbum :exec
-INSERT INTO album_media (album_id, media_id)
-VALUES ($1, $2)
+INSERT INTO album_media (album_id, media_id, position)
+VALUES (
+    $1, $2,
+    (SELECT COALESCE(MAX(position), 0) + 1 FROM album_media WHERE album_id = $1)
+)
 ON CONFLICT DO NOTHING
 `
 
@@ -21,21 +24,69 @@ type AddMediaToAlbumParams struct {
 	MediaID int64 `json:"media_id"`
 }
 
+// New media goes to the end of the manual order
 func (q *Queries) AddMediaToAlbum(ctx context.Context, arg AddMediaToAlbumParams) error {
 	_, err := q.db.ExecContext(ctx, addMediaToAlbum, arg.AlbumID, arg.MediaID)
 	return err
 }
 
+const copyAlbumMedia = `-- name: CopyAlbumMedia :execrows
+INSERT INTO album_media (album_id, media_id, position)
+SELECT
+    $1::BIGINT,
+    s.media_id,
+    (SELECT COALESCE(MAX(position), 0) FROM album_media WHERE album_id = $1)
+        + ROW_NUMBER() OVER (ORDER BY s.position, s.media_id)
+FROM album_media s
+WHERE s.album_id = $2
+  AND ($3::TEXT IS NULL
+       OR s.media_id = ANY(string_to_array($3::TEXT, ',')::BIGINT[]))
+  AND NOT EXISTS (
+    SELECT 1 FROM album_media t
+    WHERE t.album_id = $1 AND t.media_id = s.media_id
+  )
+ON CONFLICT DO NOTHING
+`
+
+type CopyAlbumMediaParams struct {
+	TargetID int64          `json:"target_id"`
+	SourceID int64          `json:"source_id"`
+	MediaIds sql.NullString `json:"media_ids"`
+}
+
+// Adds the media of one album to the end of another in its manual order,
+// skipping media already there. media_ids (comma-separated) limits the copy
+// to those media; NULL copies all, trashed media included.
+func (q *Queries) CopyAlbumMedia(ctx context.Context, arg CopyAlbumMediaParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, copyAlbumMedia, arg.TargetID, arg.SourceID, arg.MediaIds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countPublicAlbums = `-- name: CountPublicAlbums :one
+SELECT COUNT(*) FROM album
+WHERE is_public = TRUE AND deleted_at IS NULL
+`
+
+func (q *Queries) CountPublicAlbums(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPublicAlbums)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createAlbum = `-- name: CreateAlbum :one
 INSERT INTO album (
-    title, description, user_id, is_public, is_shared,
+    title, description, user_id, is_public, is_shared, parent_id,
     created_at, updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5,
+    $1, $2, $3, $4, $5, $6,
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 )
-RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at
+RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at, sort_mode, cover_media_id, parent_id, smart_rules
 `
 
 type CreateAlbumParams struct {
@@ -44,6 +95,7 @@ type CreateAlbumParams struct {
 	UserID      int64          `json:"user_id"`
 	IsPublic    sql.NullBool   `json:"is_public"`
 	IsShared    sql.NullBool   `json:"is_shared"`
+	ParentID    sql.NullInt64  `json:"parent_id"`
 }
 
 func (q *Queries) CreateAlbum(ctx context.Context, arg CreateAlbumParams) (Album, error) {
@@ -53,6 +105,7 @@ func (q *Queries) CreateAlbum(ctx context.Context, arg CreateAlbumParams) (Album
 		arg.UserID,
 		arg.IsPublic,
 		arg.IsShared,
+		arg.ParentID,
 	)
 	var i Album
 	err := row.Scan(
@@ -65,12 +118,16 @@ func (q *Queries) CreateAlbum(ctx context.Context, arg CreateAlbumParams) (Album
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.SortMode,
+		&i.CoverMediaID,
+		&i.ParentID,
+		&i.SmartRules,
 	)
 	return i, err
 }
 
 const getAlbumByID = `-- name: GetAlbumByID :one
-SELECT id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at FROM album
+SELECT id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at, sort_mode, cover_media_id, parent_id, smart_rules FROM album
 WHERE id = $1 AND deleted_at IS NULL
 LIMIT 1
 `
@@ -88,18 +145,34 @@ func (q *Queries) GetAlbumByID(ctx context.Context, id int64) (Album, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.SortMode,
+		&i.CoverMediaID,
+		&i.ParentID,
+		&i.SmartRules,
 	)
 	return i, err
 }
 
 const getAlbumMedia = `-- name: GetAlbumMedia :many
-SELECT m.id, m.filename, m.stored_name, m.type, m.mime_type, m.size, m.user_id, m.created_at, m.updated_at, m.deleted_at FROM media m
+SELECT m.id, m.filename, m.stored_name, m.type, m.mime_type, m.size, m.user_id, m.created_at, m.updated_at, m.deleted_at, m.visibility, m.description, m.phash, m.transcode_status, m.transcode_error, m.blurhash, m.dominant_color, m.aspect_ratio, m.content_hash, m.source_url, m.scan_status, m.scan_result, m.scanned_at, m.captured_at, m.scan_claimed_at, m.transcode_claimed_at FROM media m
 JOIN album_media am ON am.media_id = m.id
 WHERE am.album_id = $1 AND m.deleted_at IS NULL
+ORDER BY
+    CASE WHEN $2::TEXT = 'captured' THEN COALESCE(m.captured_at, m.created_at) END,
+    CASE WHEN $2::TEXT = 'uploaded' THEN m.created_at END,
+    CASE WHEN $2::TEXT = 'name' THEN LOWER(m.filename) END,
+    am.position, m.id
 `
 
-func (q *Queries) GetAlbumMedia(ctx context.Context, albumID int64) ([]Medium, error) {
-	rows, err := q.db.QueryContext(ctx, getAlbumMedia, albumID)
+type GetAlbumMediaParams struct {
+	AlbumID  int64  `json:"album_id"`
+	SortMode string `json:"sort_mode"`
+}
+
+// Media of an album in the order of the sort mode: manual, captured (photos
+// without a capture time by upload time), uploaded or name
+func (q *Queries) GetAlbumMedia(ctx context.Context, arg GetAlbumMediaParams) ([]Medium, error) {
+	rows, err := q.db.QueryContext(ctx, getAlbumMedia, arg.AlbumID, arg.SortMode)
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +191,22 @@ func (q *Queries) GetAlbumMedia(ctx context.Context, albumID int64) ([]Medium, e
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.Visibility,
+			&i.Description,
+			&i.Phash,
+			&i.TranscodeStatus,
+			&i.TranscodeError,
+			&i.Blurhash,
+			&i.DominantColor,
+			&i.AspectRatio,
+			&i.ContentHash,
+			&i.SourceUrl,
+			&i.ScanStatus,
+			&i.ScanResult,
+			&i.ScannedAt,
+			&i.CapturedAt,
+			&i.ScanClaimedAt,
+			&i.TranscodeClaimedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -132,25 +221,95 @@ func (q *Queries) GetAlbumMedia(ctx context.Context, albumID int64) ([]Medium, e
 	return items, nil
 }
 
+const isMediaInAlbum = `-- name: IsMediaInAlbum :one
+SELECT EXISTS (
+    SELECT 1 FROM album_media
+    WHERE album_id = $1 AND media_id = $2
+) AS in_album
+`
+
+type IsMediaInAlbumParams struct {
+	AlbumID int64 `json:"album_id"`
+	MediaID int64 `json:"media_id"`
+}
+
+func (q *Queries) IsMediaInAlbum(ctx context.Context, arg IsMediaInAlbumParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isMediaInAlbum, arg.AlbumID, arg.MediaID)
+	var in_album bool
+	err := row.Scan(&in_album)
+	return in_album, err
+}
+
+const listAlbumMediaOrder = `-- name: ListAlbumMediaOrder :many
+SELECT media_id FROM album_media
+WHERE album_id = $1
+ORDER BY position, media_id
+`
+
+// Trashed media keeps its place for when it is restored
+func (q *Queries) ListAlbumMediaOrder(ctx context.Context, albumID int64) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listAlbumMediaOrder, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var media_id int64
+		if err := rows.Scan(&media_id); err != nil {
+			return nil, err
+		}
+		items = append(items, media_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAllAlbums = `-- name: ListAllAlbums :many
-SELECT a.id, a.title, a.description, a.user_id, a.is_public, a.is_shared, a.created_at, a.updated_at, a.deleted_at, u.name as user_name
+SELECT
+    a.id, a.title, a.description, a.user_id, a.is_public, a.is_shared, a.created_at, a.updated_at, a.deleted_at, a.sort_mode, a.cover_media_id, a.parent_id, a.smart_rules, u.name as user_name,
+    cover.id as cover_id, cover.stored_name as cover_stored_name, cover.user_id as cover_user_id
 FROM album a
 JOIN users u ON a.user_id = u.id
+LEFT JOIN LATERAL (
+    SELECT m.id, m.stored_name, m.user_id
+    FROM media m
+    LEFT JOIN album_media x ON x.album_id = a.id AND x.media_id = m.id
+    WHERE m.id IN (
+        SELECT media_id FROM album_media WHERE album_id = a.id
+        UNION ALL
+        SELECT a.cover_media_id WHERE a.smart_rules IS NOT NULL
+    ) AND m.deleted_at IS NULL AND m.scan_status = 'clean'
+    ORDER BY m.id = a.cover_media_id DESC, x.position, m.id
+    LIMIT 1
+) cover ON TRUE
 WHERE a.deleted_at IS NULL
 ORDER BY a.created_at DESC
 `
 
 type ListAllAlbumsRow struct {
-	ID          int64          `json:"id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	UserID      int64          `json:"user_id"`
-	IsPublic    sql.NullBool   `json:"is_public"`
-	IsShared    sql.NullBool   `json:"is_shared"`
-	CreatedAt   int64          `json:"created_at"`
-	UpdatedAt   int64          `json:"updated_at"`
-	DeletedAt   sql.NullTime   `json:"deleted_at"`
-	UserName    string         `json:"user_name"`
+	ID              int64          `json:"id"`
+	Title           string         `json:"title"`
+	Description     sql.NullString `json:"description"`
+	UserID          int64          `json:"user_id"`
+	IsPublic        sql.NullBool   `json:"is_public"`
+	IsShared        sql.NullBool   `json:"is_shared"`
+	CreatedAt       int64          `json:"created_at"`
+	UpdatedAt       int64          `json:"updated_at"`
+	DeletedAt       sql.NullTime   `json:"deleted_at"`
+	SortMode        string         `json:"sort_mode"`
+	CoverMediaID    sql.NullInt64  `json:"cover_media_id"`
+	ParentID        sql.NullInt64  `json:"parent_id"`
+	SmartRules      sql.NullString `json:"smart_rules"`
+	UserName        string         `json:"user_name"`
+	CoverID         sql.NullInt64  `json:"cover_id"`
+	CoverStoredName sql.NullString `json:"cover_stored_name"`
+	CoverUserID     sql.NullInt64  `json:"cover_user_id"`
 }
 
 func (q *Queries) ListAllAlbums(ctx context.Context) ([]ListAllAlbumsRow, error) {
@@ -172,7 +331,14 @@ func (q *Queries) ListAllAlbums(ctx context.Context) ([]ListAllAlbumsRow, error)
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.SortMode,
+			&i.CoverMediaID,
+			&i.ParentID,
+			&i.SmartRules,
 			&i.UserName,
+			&i.CoverID,
+			&i.CoverStoredName,
+			&i.CoverUserID,
 		); err != nil {
 			return nil, err
 		}
@@ -187,28 +353,152 @@ func (q *Queries) ListAllAlbums(ctx context.Context) ([]ListAllAlbumsRow, error)
 	return items, nil
 }
 
-const listUserAlbums = `-- name: ListUserAlbums :many
+const listPublicAlbums = `-- name: ListPublicAlbums :many
 SELECT
-    a.id, a.title, a.description, a.user_id, a.is_public, a.is_shared, a.created_at, a.updated_at, a.deleted_at, u.name as user_name
+    a.id, a.title, a.description, a.user_id, a.is_public, a.is_shared, a.created_at, a.updated_at, a.deleted_at, a.sort_mode, a.cover_media_id, a.parent_id, a.smart_rules, u.name as user_name,
+    (
+        SELECT COUNT(*) FROM album_media am
+        JOIN media m ON m.id = am.media_id
+        WHERE am.album_id = a.id AND m.deleted_at IS NULL
+          AND m.scan_status = 'clean' AND m.visibility <> 'private'
+    ) AS media_count
 FROM album a
 JOIN users u ON a.user_id = u.id
-WHERE a.user_id = $1 AND a.deleted_at IS NULL
+WHERE a.is_public = TRUE AND a.deleted_at IS NULL
+ORDER BY a.updated_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListPublicAlbumsParams struct {
+	PageLimit  int32 `json:"page_limit"`
+	PageOffset int32 `json:"page_offset"`
+}
+
+type ListPublicAlbumsRow struct {
+	ID           int64          `json:"id"`
+	Title        string         `json:"title"`
+	Description  sql.NullString `json:"description"`
+	UserID       int64          `json:"user_id"`
+	IsPublic     sql.NullBool   `json:"is_public"`
+	IsShared     sql.NullBool   `json:"is_shared"`
+	CreatedAt    int64          `json:"created_at"`
+	UpdatedAt    int64          `json:"updated_at"`
+	DeletedAt    sql.NullTime   `json:"deleted_at"`
+	SortMode     string         `json:"sort_mode"`
+	CoverMediaID sql.NullInt64  `json:"cover_media_id"`
+	ParentID     sql.NullInt64  `json:"parent_id"`
+	SmartRules   sql.NullString `json:"smart_rules"`
+	UserName     string         `json:"user_name"`
+	MediaCount   int64          `json:"media_count"`
+}
+
+// Public album directory, most recently updated first. Only the owner's
+// display name is exposed; media_count leaves out private and unscanned media.
+func (q *Queries) ListPublicAlbums(ctx context.Context, arg ListPublicAlbumsParams) ([]ListPublicAlbumsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPublicAlbums, arg.PageLimit, arg.PageOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPublicAlbumsRow
+	for rows.Next() {
+		var i ListPublicAlbumsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.UserID,
+			&i.IsPublic,
+			&i.IsShared,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.SortMode,
+			&i.CoverMediaID,
+			&i.ParentID,
+			&i.SmartRules,
+			&i.UserName,
+			&i.MediaCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserAlbums = `-- name: ListUserAlbums :many
+WITH RECURSIVE reachable AS (
+    SELECT a.id, CASE WHEN a.user_id = $1 THEN 'owner' ELSE am.role END AS role, 0 AS depth
+    FROM album a
+    LEFT JOIN album_members am ON am.album_id = a.id AND am.user_id = $1
+    WHERE (a.user_id = $1 OR am.user_id IS NOT NULL) AND a.deleted_at IS NULL
+    UNION ALL
+    SELECT c.id, CASE WHEN c.user_id = $1 THEN 'owner' ELSE r.role END, r.depth + 1
+    FROM album c
+    JOIN reachable r ON c.parent_id = r.id
+    WHERE c.deleted_at IS NULL AND r.depth < 32
+), roles AS (
+    SELECT id, (ARRAY_AGG(role ORDER BY CASE role
+        WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 WHEN 'contributor' THEN 2 ELSE 3 END))[1] AS role
+    FROM reachable
+    GROUP BY id
+)
+SELECT
+    a.id, a.title, a.description, a.user_id, a.is_public, a.is_shared, a.created_at, a.updated_at, a.deleted_at, a.sort_mode, a.cover_media_id, a.parent_id, a.smart_rules, u.name as user_name,
+    ro.role::TEXT as role,
+    cover.id as cover_id, cover.stored_name as cover_stored_name, cover.user_id as cover_user_id
+FROM roles ro
+JOIN album a ON a.id = ro.id
+JOIN users u ON a.user_id = u.id
+LEFT JOIN LATERAL (
+    SELECT m.id, m.stored_name, m.user_id
+    FROM media m
+    LEFT JOIN album_media x ON x.album_id = a.id AND x.media_id = m.id
+    WHERE m.id IN (
+        SELECT media_id FROM album_media WHERE album_id = a.id
+        UNION ALL
+        SELECT a.cover_media_id WHERE a.smart_rules IS NOT NULL
+    ) AND m.deleted_at IS NULL AND m.scan_status = 'clean'
+      AND (m.visibility <> 'private' OR m.user_id = $1 OR ro.role IN ('owner', 'editor'))
+    ORDER BY m.id = a.cover_media_id DESC, x.position, m.id
+    LIMIT 1
+) cover ON TRUE
 ORDER BY a.created_at DESC
 `
 
 type ListUserAlbumsRow struct {
-	ID          int64          `json:"id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	UserID      int64          `json:"user_id"`
-	IsPublic    sql.NullBool   `json:"is_public"`
-	IsShared    sql.NullBool   `json:"is_shared"`
-	CreatedAt   int64          `json:"created_at"`
-	UpdatedAt   int64          `json:"updated_at"`
-	DeletedAt   sql.NullTime   `json:"deleted_at"`
-	UserName    string         `json:"user_name"`
+	ID              int64          `json:"id"`
+	Title           string         `json:"title"`
+	Description     sql.NullString `json:"description"`
+	UserID          int64          `json:"user_id"`
+	IsPublic        sql.NullBool   `json:"is_public"`
+	IsShared        sql.NullBool   `json:"is_shared"`
+	CreatedAt       int64          `json:"created_at"`
+	UpdatedAt       int64          `json:"updated_at"`
+	DeletedAt       sql.NullTime   `json:"deleted_at"`
+	SortMode        string         `json:"sort_mode"`
+	CoverMediaID    sql.NullInt64  `json:"cover_media_id"`
+	ParentID        sql.NullInt64  `json:"parent_id"`
+	SmartRules      sql.NullString `json:"smart_rules"`
+	UserName        string         `json:"user_name"`
+	Role            string         `json:"role"`
+	CoverID         sql.NullInt64  `json:"cover_id"`
+	CoverStoredName sql.NullString `json:"cover_stored_name"`
+	CoverUserID     sql.NullInt64  `json:"cover_user_id"`
 }
 
+// The user's own albums, the albums they collaborate on and the sub-albums of
+// both, with their best role ('owner' for their own; sub-albums inherit the
+// role on their parent). The cover is the chosen cover media, or else the
+// first media in manual order, among the media the user sees in the album.
+// Smart albums only have a cover when one is chosen.
 func (q *Queries) ListUserAlbums(ctx context.Context, userID int64) ([]ListUserAlbumsRow, error) {
 	rows, err := q.db.QueryContext(ctx, listUserAlbums, userID)
 	if err != nil {
@@ -228,7 +518,15 @@ func (q *Queries) ListUserAlbums(ctx context.Context, userID int64) ([]ListUserA
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.SortMode,
+			&i.CoverMediaID,
+			&i.ParentID,
+			&i.SmartRules,
 			&i.UserName,
+			&i.Role,
+			&i.CoverID,
+			&i.CoverStoredName,
+			&i.CoverUserID,
 		); err != nil {
 			return nil, err
 		}
@@ -258,6 +556,41 @@ func (q *Queries) RemoveMediaFromAlbum(ctx context.Context, arg RemoveMediaFromA
 	return err
 }
 
+const setAlbumMediaPosition = `-- name: SetAlbumMediaPosition :exec
+UPDATE album_media
+SET position = $3
+WHERE album_id = $1 AND media_id = $2
+`
+
+type SetAlbumMediaPositionParams struct {
+	AlbumID  int64 `json:"album_id"`
+	MediaID  int64 `json:"media_id"`
+	Position int32 `json:"position"`
+}
+
+func (q *Queries) SetAlbumMediaPosition(ctx context.Context, arg SetAlbumMediaPositionParams) error {
+	_, err := q.db.ExecContext(ctx, setAlbumMediaPosition, arg.AlbumID, arg.MediaID, arg.Position)
+	return err
+}
+
+const setAlbumSmartRules = `-- name: SetAlbumSmartRules :exec
+UPDATE album
+SET
+    smart_rules = $2,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1
+`
+
+type SetAlbumSmartRulesParams struct {
+	ID         int64          `json:"id"`
+	SmartRules sql.NullString `json:"smart_rules"`
+}
+
+func (q *Queries) SetAlbumSmartRules(ctx context.Context, arg SetAlbumSmartRulesParams) error {
+	_, err := q.db.ExecContext(ctx, setAlbumSmartRules, arg.ID, arg.SmartRules)
+	return err
+}
+
 const softDeleteAlbum = `-- name: SoftDeleteAlbum :exec
 UPDATE album
 SET deleted_at = NOW()
@@ -276,17 +609,21 @@ SET
     description = $3,
     is_public = $4,
     is_shared = $5,
+    sort_mode = $6,
+    cover_media_id = $7,
     updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 WHERE id = $1
-RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at
+RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at, sort_mode, cover_media_id, parent_id, smart_rules
 `
 
 type UpdateAlbumParams struct {
-	ID          int64          `json:"id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	IsPublic    sql.NullBool   `json:"is_public"`
-	IsShared    sql.NullBool   `json:"is_shared"`
+	ID           int64          `json:"id"`
+	Title        string         `json:"title"`
+	Description  sql.NullString `json:"description"`
+	IsPublic     sql.NullBool   `json:"is_public"`
+	IsShared     sql.NullBool   `json:"is_shared"`
+	SortMode     string         `json:"sort_mode"`
+	CoverMediaID sql.NullInt64  `json:"cover_media_id"`
 }
 
 func (q *Queries) UpdateAlbum(ctx context.Context, arg UpdateAlbumParams) (Album, error) {
@@ -296,6 +633,8 @@ func (q *Queries) UpdateAlbum(ctx context.Context, arg UpdateAlbumParams) (Album
 		arg.Description,
 		arg.IsPublic,
 		arg.IsShared,
+		arg.SortMode,
+		arg.CoverMediaID,
 	)
 	var i Album
 	err := row.Scan(
@@ -308,6 +647,10 @@ func (q *Queries) UpdateAlbum(ctx context.Context, arg UpdateAlbumParams) (Album
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.SortMode,
+		&i.CoverMediaID,
+		&i.ParentID,
+		&i.SmartRules,
 	)
 	return i, err
 }
@@ -8,15 +8,57 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 const countPublicMedia = `-- name: CountPublicMedia :one
-SELECT COUNT(*) FROM media
-WHERE deleted_at IS NULL
+SELECT COUNT(*) FROM media m
+WHERE m.deleted_at IS NULL AND m.scan_status = 'clean'
+  AND (
+    m.visibility = 'public'
+    OR (m.visibility = 'inherit' AND EXISTS (
+        SELECT 1 FROM album_media am
+        JOIN album a ON a.id = am.album_id
+        WHERE am.media_id = m.id AND a.is_public = TRUE AND a.deleted_at IS NULL
+    ))
+  )
+  AND ($1::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array($1::TEXT, ','))
+  ) >= $2::INT)
 `
 
-func (q *Queries) CountPublicMedia(ctx context.Context) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countPublicMedia)
+type CountPublicMediaParams struct {
+	Tags       string `json:"tags"`
+	MinMatches int32  `json:"min_matches"`
+}
+
+func (q *Queries) CountPublicMedia(ctx context.Context, arg CountPublicMediaParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPublicMedia, arg.Tags, arg.MinMatches)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUserMedia = `-- name: CountUserMedia :one
+SELECT COUNT(*) FROM media m
+WHERE user_id = $1 AND deleted_at IS NULL
+  AND ($2::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array($2::TEXT, ','))
+  ) >= $3::INT)
+`
+
+type CountUserMediaParams struct {
+	UserID     int64  `json:"user_id"`
+	Tags       string `json:"tags"`
+	MinMatches int32  `json:"min_matches"`
+}
+
+func (q *Queries) CountUserMedia(ctx context.Context, arg CountUserMediaParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserMedia, arg.UserID, arg.Tags, arg.MinMatches)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
@@ -24,10 +66,12 @@ func (q *Queries) CountPublicMedia(ctx context.Context) (int64, error) {
 
 const createMedia = `-- name: CreateMedia :one
 INSERT INTO media (
-    filename, stored_name, type, mime_type, size, user_id,
-    created_at, updated_at
+    filename, stored_name, type, mime_type, size, user_id, visibility, description,
+    content_hash, source_url, captured_at, transcode_status, scan_status, created_at, updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6,
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+    CASE WHEN $4 LIKE 'video/%' OR $2 ~* '\.(mp4|mov|avi|mkv|webm)$' THEN 'pending' ELSE 'none' END,
+    'pending',
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 )
@@ -35,32 +79,52 @@ RETURNING
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at
 `
 
 type CreateMediaParams struct {
-	Filename   string         `json:"filename"`
-	StoredName string         `json:"stored_name"`
-	Type       sql.NullString `json:"type"`
-	MimeType   sql.NullString `json:"mime_type"`
-	Size       int64          `json:"size"`
-	UserID     int64          `json:"user_id"`
+	Filename    string         `json:"filename"`
+	StoredName  string         `json:"stored_name"`
+	Type        sql.NullString `json:"type"`
+	MimeType    sql.NullString `json:"mime_type"`
+	Size        int64          `json:"size"`
+	UserID      int64          `json:"user_id"`
+	Visibility  string         `json:"visibility"`
+	Description sql.NullString `json:"description"`
+	ContentHash sql.NullString `json:"content_hash"`
+	SourceUrl   sql.NullString `json:"source_url"`
+	CapturedAt  sql.NullInt64  `json:"captured_at"`
 }
 
 type CreateMediaRow struct {
-	ID         int64        `json:"id"`
-	Filename   string       `json:"filename"`
-	StoredName string       `json:"stored_name"`
-	Type       string       `json:"type"`
-	MimeType   string       `json:"mime_type"`
-	Size       int64        `json:"size"`
-	UserID     int64        `json:"user_id"`
-	CreatedAt  int64        `json:"created_at"`
-	UpdatedAt  int64        `json:"updated_at"`
-	DeletedAt  sql.NullTime `json:"deleted_at"`
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
 }
 
 func (q *Queries) CreateMedia(ctx context.Context, arg CreateMediaParams) (CreateMediaRow, error) {
@@ -71,6 +135,11 @@ func (q *Queries) CreateMedia(ctx context.Context, arg CreateMediaParams) (Creat
 		arg.MimeType,
 		arg.Size,
 		arg.UserID,
+		arg.Visibility,
+		arg.Description,
+		arg.ContentHash,
+		arg.SourceUrl,
+		arg.CapturedAt,
 	)
 	var i CreateMediaRow
 	err := row.Scan(
@@ -81,6 +150,14 @@ func (q *Queries) CreateMedia(ctx context.Context, arg CreateMediaParams) (Creat
 		&i.MimeType,
 		&i.Size,
 		&i.UserID,
+		&i.Visibility,
+		&i.Description,
+		&i.TranscodeStatus,
+		&i.ScanStatus,
+		&i.ScanResult,
+		&i.Blurhash,
+		&i.DominantColor,
+		&i.AspectRatio,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
@@ -93,7 +170,14 @@ SELECT
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at
@@ -103,16 +187,24 @@ LIMIT 1
 `
 
 type GetMediaByIDRow struct {
-	ID         int64        `json:"id"`
-	Filename   string       `json:"filename"`
-	StoredName string       `json:"stored_name"`
-	Type       string       `json:"type"`
-	MimeType   string       `json:"mime_type"`
-	Size       int64        `json:"size"`
-	UserID     int64        `json:"user_id"`
-	CreatedAt  int64        `json:"created_at"`
-	UpdatedAt  int64        `json:"updated_at"`
-	DeletedAt  sql.NullTime `json:"deleted_at"`
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
 }
 
 func (q *Queries) GetMediaByID(ctx context.Context, id int64) (GetMediaByIDRow, error) {
@@ -126,6 +218,82 @@ func (q *Queries) GetMediaByID(ctx context.Context, id int64) (GetMediaByIDRow,
 		&i.MimeType,
 		&i.Size,
 		&i.UserID,
+		&i.Visibility,
+		&i.Description,
+		&i.TranscodeStatus,
+		&i.ScanStatus,
+		&i.ScanResult,
+		&i.Blurhash,
+		&i.DominantColor,
+		&i.AspectRatio,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getMediaByIDWithDeleted = `-- name: GetMediaByIDWithDeleted :one
+SELECT
+    id, filename, stored_name,
+    COALESCE(type, '') as type,
+    COALESCE(mime_type, '') as mime_type,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
+    COALESCE(created_at, 0)::BIGINT as created_at,
+    COALESCE(updated_at, 0)::BIGINT as updated_at,
+    deleted_at
+FROM media
+WHERE id = $1
+LIMIT 1
+`
+
+type GetMediaByIDWithDeletedRow struct {
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) GetMediaByIDWithDeleted(ctx context.Context, id int64) (GetMediaByIDWithDeletedRow, error) {
+	row := q.db.QueryRowContext(ctx, getMediaByIDWithDeleted, id)
+	var i GetMediaByIDWithDeletedRow
+	err := row.Scan(
+		&i.ID,
+		&i.Filename,
+		&i.StoredName,
+		&i.Type,
+		&i.MimeType,
+		&i.Size,
+		&i.UserID,
+		&i.Visibility,
+		&i.Description,
+		&i.TranscodeStatus,
+		&i.ScanStatus,
+		&i.ScanResult,
+		&i.Blurhash,
+		&i.DominantColor,
+		&i.AspectRatio,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
@@ -133,48 +301,171 @@ func (q *Queries) GetMediaByID(ctx context.Context, id int64) (GetMediaByIDRow,
 	return i, err
 }
 
+const isStoredMediaPublic = `-- name: IsStoredMediaPublic :one
+SELECT EXISTS (
+    SELECT 1 FROM media m
+    WHERE regexp_replace(m.stored_name, '\.[^.]*$', '') = $1::TEXT
+      AND m.deleted_at IS NULL
+      AND m.scan_status = 'clean'
+      AND (
+        m.visibility = 'public'
+        OR (m.visibility = 'inherit' AND EXISTS (
+            SELECT 1 FROM album_media am
+            JOIN album a ON a.id = am.album_id
+            WHERE am.media_id = m.id AND a.is_public = TRUE AND a.deleted_at IS NULL
+        ))
+      )
+) AS is_public
+`
+
+// Whether a stored file may be served without a signed URL: public media,
+// or media inheriting visibility from a public album, once the file passed
+// the malware scan. Unlisted media needs a signed URL, since stored names and
+// IDs can be guessed. The stored name expression must match
+// idx_media_stored_key.
+func (q *Queries) IsStoredMediaPublic(ctx context.Context, storedKey string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isStoredMediaPublic, storedKey)
+	var is_public bool
+	err := row.Scan(&is_public)
+	return is_public, err
+}
+
+const listMediaByIDs = `-- name: ListMediaByIDs :many
+SELECT id, filename, stored_name, type, mime_type, size, user_id, created_at, updated_at, deleted_at, visibility, description, phash, transcode_status, transcode_error, blurhash, dominant_color, aspect_ratio, content_hash, source_url, scan_status, scan_result, scanned_at, captured_at, scan_claimed_at, transcode_claimed_at FROM media
+WHERE id = ANY(string_to_array($1::TEXT, ',')::BIGINT[])
+  AND deleted_at IS NULL
+`
+
+// Media that is not trashed among a comma-separated list of IDs, in no
+// particular order
+func (q *Queries) ListMediaByIDs(ctx context.Context, ids string) ([]Medium, error) {
+	rows, err := q.db.QueryContext(ctx, listMediaByIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Medium
+	for rows.Next() {
+		var i Medium
+		if err := rows.Scan(
+			&i.ID,
+			&i.Filename,
+			&i.StoredName,
+			&i.Type,
+			&i.MimeType,
+			&i.Size,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Visibility,
+			&i.Description,
+			&i.Phash,
+			&i.TranscodeStatus,
+			&i.TranscodeError,
+			&i.Blurhash,
+			&i.DominantColor,
+			&i.AspectRatio,
+			&i.ContentHash,
+			&i.SourceUrl,
+			&i.ScanStatus,
+			&i.ScanResult,
+			&i.ScannedAt,
+			&i.CapturedAt,
+			&i.ScanClaimedAt,
+			&i.TranscodeClaimedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPublicMedia = `-- name: ListPublicMedia :many
 SELECT
     m.id, m.filename, m.stored_name,
     COALESCE(m.type, '') as type,
     COALESCE(m.mime_type, '') as mime_type,
-    m.size, m.user_id,
+    m.size, m.user_id, m.visibility,
+    COALESCE(m.description, '') as description,
+    m.transcode_status,
+    m.scan_status,
+    COALESCE(m.scan_result, '') as scan_result,
+    COALESCE(m.blurhash, '') as blurhash,
+    COALESCE(m.dominant_color, '') as dominant_color,
+    COALESCE(m.aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(m.created_at, 0)::BIGINT as created_at,
     COALESCE(m.updated_at, 0)::BIGINT as updated_at,
     m.deleted_at,
-    u.name as user_name,
-    u.tel as user_tel,
-    u.email as user_email
+    u.name as user_name
 FROM media m
 JOIN users u ON m.user_id = u.id
-WHERE m.deleted_at IS NULL
+WHERE m.deleted_at IS NULL AND m.scan_status = 'clean'
+  AND (
+    m.visibility = 'public'
+    OR (m.visibility = 'inherit' AND EXISTS (
+        SELECT 1 FROM album_media am
+        JOIN album a ON a.id = am.album_id
+        WHERE am.media_id = m.id AND a.is_public = TRUE AND a.deleted_at IS NULL
+    ))
+  )
+  AND ($1::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array($1::TEXT, ','))
+  ) >= $2::INT)
 ORDER BY m.created_at DESC
-LIMIT $1 OFFSET $2
+LIMIT $3 OFFSET $4
 `
 
 type ListPublicMediaParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	Tags       string `json:"tags"`
+	MinMatches int32  `json:"min_matches"`
+	PageLimit  int32  `json:"page_limit"`
+	PageOffset int32  `json:"page_offset"`
 }
 
 type ListPublicMediaRow struct {
-	ID         int64          `json:"id"`
-	Filename   string         `json:"filename"`
-	StoredName string         `json:"stored_name"`
-	Type       string         `json:"type"`
-	MimeType   string         `json:"mime_type"`
-	Size       int64          `json:"size"`
-	UserID     int64          `json:"user_id"`
-	CreatedAt  int64          `json:"created_at"`
-	UpdatedAt  int64          `json:"updated_at"`
-	DeletedAt  sql.NullTime   `json:"deleted_at"`
-	UserName   string         `json:"user_name"`
-	UserTel    sql.NullString `json:"user_tel"`
-	UserEmail  string         `json:"user_email"`
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
+	UserName        string       `json:"user_name"`
 }
 
+// Public feed: media explicitly marked public, or inheriting from a public album,
+// that passed the malware scan.
+// Only the owner's display name is exposed. tags is a comma-separated list of
+// tag names (empty for no filter); min_matches is 1 to match any of them, or
+// the number of tags to match all of them.
 func (q *Queries) ListPublicMedia(ctx context.Context, arg ListPublicMediaParams) ([]ListPublicMediaRow, error) {
-	rows, err := q.db.QueryContext(ctx, listPublicMedia, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listPublicMedia,
+		arg.Tags,
+		arg.MinMatches,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -190,12 +481,18 @@ func (q *Queries) ListPublicMedia(ctx context.Context, arg ListPublicMediaParams
 			&i.MimeType,
 			&i.Size,
 			&i.UserID,
+			&i.Visibility,
+			&i.Description,
+			&i.TranscodeStatus,
+			&i.ScanStatus,
+			&i.ScanResult,
+			&i.Blurhash,
+			&i.DominantColor,
+			&i.AspectRatio,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
 			&i.UserName,
-			&i.UserTel,
-			&i.UserEmail,
 		); err != nil {
 			return nil, err
 		}
@@ -210,35 +507,154 @@ func (q *Queries) ListPublicMedia(ctx context.Context, arg ListPublicMediaParams
 	return items, nil
 }
 
-const listUserMedia = `-- name: ListUserMedia :many
+const listTrashedMedia = `-- name: ListTrashedMedia :many
 SELECT
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at
 FROM media
+WHERE user_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+type ListTrashedMediaRow struct {
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) ListTrashedMedia(ctx context.Context, userID int64) ([]ListTrashedMediaRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrashedMedia, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTrashedMediaRow
+	for rows.Next() {
+		var i ListTrashedMediaRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Filename,
+			&i.StoredName,
+			&i.Type,
+			&i.MimeType,
+			&i.Size,
+			&i.UserID,
+			&i.Visibility,
+			&i.Description,
+			&i.TranscodeStatus,
+			&i.ScanStatus,
+			&i.ScanResult,
+			&i.Blurhash,
+			&i.DominantColor,
+			&i.AspectRatio,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserMedia = `-- name: ListUserMedia :many
+SELECT
+    id, filename, stored_name,
+    COALESCE(type, '') as type,
+    COALESCE(mime_type, '') as mime_type,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
+    COALESCE(created_at, 0)::BIGINT as created_at,
+    COALESCE(updated_at, 0)::BIGINT as updated_at,
+    deleted_at
+FROM media m
 WHERE user_id = $1 AND deleted_at IS NULL
+  AND ($2::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array($2::TEXT, ','))
+  ) >= $3::INT)
 ORDER BY created_at DESC
+LIMIT $4 OFFSET $5
 `
 
+type ListUserMediaParams struct {
+	UserID     int64  `json:"user_id"`
+	Tags       string `json:"tags"`
+	MinMatches int32  `json:"min_matches"`
+	PageLimit  int32  `json:"page_limit"`
+	PageOffset int32  `json:"page_offset"`
+}
+
 type ListUserMediaRow struct {
-	ID         int64        `json:"id"`
-	Filename   string       `json:"filename"`
-	StoredName string       `json:"stored_name"`
-	Type       string       `json:"type"`
-	MimeType   string       `json:"mime_type"`
-	Size       int64        `json:"size"`
-	UserID     int64        `json:"user_id"`
-	CreatedAt  int64        `json:"created_at"`
-	UpdatedAt  int64        `json:"updated_at"`
-	DeletedAt  sql.NullTime `json:"deleted_at"`
-}
-
-func (q *Queries) ListUserMedia(ctx context.Context, userID int64) ([]ListUserMediaRow, error) {
-	rows, err := q.db.QueryContext(ctx, listUserMedia, userID)
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) ListUserMedia(ctx context.Context, arg ListUserMediaParams) ([]ListUserMediaRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUserMedia,
+		arg.UserID,
+		arg.Tags,
+		arg.MinMatches,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -254,6 +670,14 @@ func (q *Queries) ListUserMedia(ctx context.Context, userID int64) ([]ListUserMe
 			&i.MimeType,
 			&i.Size,
 			&i.UserID,
+			&i.Visibility,
+			&i.Description,
+			&i.TranscodeStatus,
+			&i.ScanStatus,
+			&i.ScanResult,
+			&i.Blurhash,
+			&i.DominantColor,
+			&i.AspectRatio,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
@@ -281,6 +705,241 @@ func (q *Queries) PermanentlyDeleteMedia(ctx context.Context, id int64) error {
 	return err
 }
 
+const purgeExpiredTrash = `-- name: PurgeExpiredTrash :many
+WITH purged AS (
+    DELETE FROM media
+    WHERE deleted_at IS NOT NULL AND deleted_at < $1::TIMESTAMPTZ
+    RETURNING id, stored_name
+)
+SELECT p.id AS media_id, p.stored_name FROM purged p
+UNION ALL
+SELECT v.media_id, v.stored_name FROM media_versions v JOIN purged p ON v.media_id = p.id
+`
+
+type PurgeExpiredTrashRow struct {
+	MediaID    int64  `json:"media_id"`
+	StoredName string `json:"stored_name"`
+}
+
+// Permanently removes media that has been in the trash since before the cutoff,
+// returning each item's stored names, including its earlier versions.
+func (q *Queries) PurgeExpiredTrash(ctx context.Context, cutoff time.Time) ([]PurgeExpiredTrashRow, error) {
+	rows, err := q.db.QueryContext(ctx, purgeExpiredTrash, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PurgeExpiredTrashRow
+	for rows.Next() {
+		var i PurgeExpiredTrashRow
+		if err := rows.Scan(
+			&i.MediaID,
+			&i.StoredName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeUserTrash = `-- name: PurgeUserTrash :many
+WITH purged AS (
+    DELETE FROM media
+    WHERE user_id = $1 AND deleted_at IS NOT NULL
+    RETURNING id, stored_name
+)
+SELECT p.id AS media_id, p.stored_name FROM purged p
+UNION ALL
+SELECT v.media_id, v.stored_name FROM media_versions v JOIN purged p ON v.media_id = p.id
+`
+
+type PurgeUserTrashRow struct {
+	MediaID    int64  `json:"media_id"`
+	StoredName string `json:"stored_name"`
+}
+
+// Permanently removes every trashed item of a user, returning each item's
+// stored names (including earlier versions) so the files can be deleted from
+// disk.
+func (q *Queries) PurgeUserTrash(ctx context.Context, userID int64) ([]PurgeUserTrashRow, error) {
+	rows, err := q.db.QueryContext(ctx, purgeUserTrash, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PurgeUserTrashRow
+	for rows.Next() {
+		var i PurgeUserTrashRow
+		if err := rows.Scan(
+			&i.MediaID,
+			&i.StoredName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const renameMedia = `-- name: RenameMedia :exec
+UPDATE media
+SET
+    filename = $2,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1
+`
+
+type RenameMediaParams struct {
+	ID       int64  `json:"id"`
+	Filename string `json:"filename"`
+}
+
+func (q *Queries) RenameMedia(ctx context.Context, arg RenameMediaParams) error {
+	_, err := q.db.ExecContext(ctx, renameMedia, arg.ID, arg.Filename)
+	return err
+}
+
+const replaceMediaFile = `-- name: ReplaceMediaFile :one
+UPDATE media
+SET
+    stored_name = $2,
+    mime_type = $3,
+    size = $4,
+    type = $5,
+    phash = NULL,
+    blurhash = NULL,
+    dominant_color = NULL,
+    aspect_ratio = NULL,
+    content_hash = NULL,
+    transcode_status = CASE WHEN $3 LIKE 'video/%' OR $2 ~* '\.(mp4|mov|avi|mkv|webm)$' THEN 'pending' ELSE 'none' END,
+    transcode_error = NULL,
+    scan_status = 'pending',
+    scan_result = NULL,
+    scanned_at = NULL,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1
+RETURNING
+    id, filename, stored_name,
+    COALESCE(type, '') as type,
+    COALESCE(mime_type, '') as mime_type,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
+    COALESCE(created_at, 0)::BIGINT as created_at,
+    COALESCE(updated_at, 0)::BIGINT as updated_at,
+    deleted_at
+`
+
+type ReplaceMediaFileParams struct {
+	ID         int64          `json:"id"`
+	StoredName string         `json:"stored_name"`
+	MimeType   sql.NullString `json:"mime_type"`
+	Size       int64          `json:"size"`
+	Type       sql.NullString `json:"type"`
+}
+
+type ReplaceMediaFileRow struct {
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
+}
+
+func (q *Queries) ReplaceMediaFile(ctx context.Context, arg ReplaceMediaFileParams) (ReplaceMediaFileRow, error) {
+	row := q.db.QueryRowContext(ctx, replaceMediaFile,
+		arg.ID,
+		arg.StoredName,
+		arg.MimeType,
+		arg.Size,
+		arg.Type,
+	)
+	var i ReplaceMediaFileRow
+	err := row.Scan(
+		&i.ID,
+		&i.Filename,
+		&i.StoredName,
+		&i.Type,
+		&i.MimeType,
+		&i.Size,
+		&i.UserID,
+		&i.Visibility,
+		&i.Description,
+		&i.TranscodeStatus,
+		&i.ScanStatus,
+		&i.ScanResult,
+		&i.Blurhash,
+		&i.DominantColor,
+		&i.AspectRatio,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const restoreMedia = `-- name: RestoreMedia :exec
+UPDATE media
+SET
+    deleted_at = NULL,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1
+`
+
+func (q *Queries) RestoreMedia(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, restoreMedia, id)
+	return err
+}
+
+const setMediaVisibility = `-- name: SetMediaVisibility :exec
+UPDATE media
+SET
+    visibility = $2,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1
+`
+
+type SetMediaVisibilityParams struct {
+	ID         int64  `json:"id"`
+	Visibility string `json:"visibility"`
+}
+
+func (q *Queries) SetMediaVisibility(ctx context.Context, arg SetMediaVisibilityParams) error {
+	_, err := q.db.ExecContext(ctx, setMediaVisibility, arg.ID, arg.Visibility)
+	return err
+}
+
 const softDeleteMedia = `-- name: SoftDeleteMedia :exec
 UPDATE media
 SET deleted_at = NOW()
@@ -299,37 +958,56 @@ SET
     type = $3,
     mime_type = $4,
     size = $5,
+    visibility = $6,
+    description = $7,
     updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 WHERE id = $1
 RETURNING
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at
 `
 
 type UpdateMediaParams struct {
-	ID       int64          `json:"id"`
-	Filename string         `json:"filename"`
-	Type     sql.NullString `json:"type"`
-	MimeType sql.NullString `json:"mime_type"`
-	Size     int64          `json:"size"`
+	ID          int64          `json:"id"`
+	Filename    string         `json:"filename"`
+	Type        sql.NullString `json:"type"`
+	MimeType    sql.NullString `json:"mime_type"`
+	Size        int64          `json:"size"`
+	Visibility  string         `json:"visibility"`
+	Description sql.NullString `json:"description"`
 }
 
 type UpdateMediaRow struct {
-	ID         int64        `json:"id"`
-	Filename   string       `json:"filename"`
-	StoredName string       `json:"stored_name"`
-	Type       string       `json:"type"`
-	MimeType   string       `json:"mime_type"`
-	Size       int64        `json:"size"`
-	UserID     int64        `json:"user_id"`
-	CreatedAt  int64        `json:"created_at"`
-	UpdatedAt  int64        `json:"updated_at"`
-	DeletedAt  sql.NullTime `json:"deleted_at"`
+	ID              int64        `json:"id"`
+	Filename        string       `json:"filename"`
+	StoredName      string       `json:"stored_name"`
+	Type            string       `json:"type"`
+	MimeType        string       `json:"mime_type"`
+	Size            int64        `json:"size"`
+	UserID          int64        `json:"user_id"`
+	Visibility      string       `json:"visibility"`
+	Description     string       `json:"description"`
+	TranscodeStatus string       `json:"transcode_status"`
+	ScanStatus      string       `json:"scan_status"`
+	ScanResult      string       `json:"scan_result"`
+	Blurhash        string       `json:"blurhash"`
+	DominantColor   string       `json:"dominant_color"`
+	AspectRatio     float64      `json:"aspect_ratio"`
+	CreatedAt       int64        `json:"created_at"`
+	UpdatedAt       int64        `json:"updated_at"`
+	DeletedAt       sql.NullTime `json:"deleted_at"`
 }
 
 func (q *Queries) UpdateMedia(ctx context.Context, arg UpdateMediaParams) (UpdateMediaRow, error) {
@@ -339,6 +1017,8 @@ func (q *Queries) UpdateMedia(ctx context.Context, arg UpdateMediaParams) (Updat
 		arg.Type,
 		arg.MimeType,
 		arg.Size,
+		arg.Visibility,
+		arg.Description,
 	)
 	var i UpdateMediaRow
 	err := row.Scan(
@@ -349,6 +1029,14 @@ func (q *Queries) UpdateMedia(ctx context.Context, arg UpdateMediaParams) (Updat
 		&i.MimeType,
 		&i.Size,
 		&i.UserID,
+		&i.Visibility,
+		&i.Description,
+		&i.TranscodeStatus,
+		&i.ScanStatus,
+		&i.ScanResult,
+		&i.Blurhash,
+		&i.DominantColor,
+		&i.AspectRatio,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
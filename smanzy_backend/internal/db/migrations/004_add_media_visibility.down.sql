@@ -0,0 +1,5 @@
+-- Rollback: Add media visibility
+-- Description: Removes the visibility column from the media table
+
+DROP INDEX IF EXISTS idx_media_visibility;
+ALTER TABLE media DROP COLUMN IF EXISTS visibility;
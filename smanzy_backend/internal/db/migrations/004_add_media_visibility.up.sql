@@ -0,0 +1,8 @@
+-- Migration: Add media visibility
+-- Description: Adds a per-media visibility setting (private, unlisted, public, inherit).
+-- Existing media inherits visibility from its albums, matching previous behaviour.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'inherit'
+    CHECK (visibility IN ('private', 'unlisted', 'public', 'inherit'));
+
+CREATE INDEX IF NOT EXISTS idx_media_visibility ON media(visibility) WHERE deleted_at IS NULL;
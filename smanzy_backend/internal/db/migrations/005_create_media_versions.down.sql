@@ -0,0 +1,4 @@
+-- Rollback: Create media versions
+-- Description: Drops the media_versions table
+
+DROP TABLE IF EXISTS media_versions;
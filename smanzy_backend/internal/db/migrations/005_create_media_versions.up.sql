@@ -0,0 +1,15 @@
+-- Migration: Create media versions
+-- Description: Keeps earlier versions of a media file when it is replaced,
+-- so a replacement can be listed, downloaded and reverted.
+
+CREATE TABLE IF NOT EXISTS media_versions (
+    id BIGSERIAL PRIMARY KEY,
+    media_id BIGINT NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    stored_name TEXT NOT NULL,
+    mime_type TEXT NOT NULL DEFAULT '',
+    size BIGINT NOT NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    UNIQUE (media_id, version)
+);
@@ -0,0 +1,6 @@
+-- Rollback: Create tags
+-- Description: Drops the tag tables
+
+DROP TABLE IF EXISTS album_tags;
+DROP TABLE IF EXISTS media_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,26 @@
+-- Migration: Create tags
+-- Description: Adds free-form and admin-curated tags for media and albums.
+
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    is_curated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS media_tags (
+    media_id BIGINT NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (media_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_tags_tag_id ON media_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS album_tags (
+    album_id BIGINT NOT NULL REFERENCES album(id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (album_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_album_tags_tag_id ON album_tags(tag_id);
@@ -0,0 +1,7 @@
+-- Rollback: Add full-text search
+-- Description: Drops the search indexes and the media description column
+
+DROP INDEX IF EXISTS idx_videos_search;
+DROP INDEX IF EXISTS idx_album_search;
+DROP INDEX IF EXISTS idx_media_search;
+ALTER TABLE media DROP COLUMN IF EXISTS description;
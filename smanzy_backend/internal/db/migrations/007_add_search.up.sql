@@ -0,0 +1,20 @@
+-- Migration: Add full-text search
+-- Description: Adds a media description and tsvector expression indexes used
+-- by /api/search. The expressions must match internal/services/search.go.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS description TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_media_search ON media USING GIN ((
+    setweight(to_tsvector('simple', regexp_replace(filename, '[._-]+', ' ', 'g')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+));
+
+CREATE INDEX IF NOT EXISTS idx_album_search ON album USING GIN ((
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+));
+
+CREATE INDEX IF NOT EXISTS idx_videos_search ON videos USING GIN ((
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+));
@@ -0,0 +1,5 @@
+-- Rollback: Add media perceptual hash
+-- Description: Drops the perceptual hash column and its index
+
+DROP INDEX IF EXISTS idx_media_user_phash;
+ALTER TABLE media DROP COLUMN IF EXISTS phash;
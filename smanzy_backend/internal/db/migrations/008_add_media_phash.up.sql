@@ -0,0 +1,7 @@
+-- Migration: Add media perceptual hash
+-- Description: Adds a 64-bit perceptual hash used to find near-duplicate
+-- images and videos. It is filled in by a background indexer.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS phash BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_media_user_phash ON media(user_id) WHERE phash IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Rollback: Add video transcoding status
+-- Description: Drops the transcoding columns and index
+
+DROP INDEX IF EXISTS idx_media_transcode_pending;
+ALTER TABLE media DROP COLUMN IF EXISTS transcode_error;
+ALTER TABLE media DROP COLUMN IF EXISTS transcode_status;
@@ -0,0 +1,14 @@
+-- Migration: Add video transcoding status
+-- Description: Tracks HLS transcoding of uploaded videos. Existing videos are
+-- queued for transcoding.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS transcode_status TEXT NOT NULL DEFAULT 'none'
+    CHECK (transcode_status IN ('none', 'pending', 'processing', 'ready', 'failed'));
+ALTER TABLE media ADD COLUMN IF NOT EXISTS transcode_error TEXT;
+
+UPDATE media
+SET transcode_status = 'pending'
+WHERE deleted_at IS NULL
+  AND (mime_type LIKE 'video/%' OR stored_name ~* '\.(mp4|mov|avi|mkv|webm)$');
+
+CREATE INDEX IF NOT EXISTS idx_media_transcode_pending ON media(id) WHERE transcode_status = 'pending' AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Rollback: Add media placeholders
+-- Description: Drops the placeholder columns
+
+ALTER TABLE media DROP COLUMN IF EXISTS aspect_ratio;
+ALTER TABLE media DROP COLUMN IF EXISTS dominant_color;
+ALTER TABLE media DROP COLUMN IF EXISTS blurhash;
@@ -0,0 +1,8 @@
+-- Migration: Add media placeholders
+-- Description: Adds a BlurHash, dominant color and aspect ratio per image and
+-- video so clients can lay out grids and paint placeholders before thumbnails
+-- load. They are filled in by the background thumbnail indexer.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS blurhash TEXT;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS dominant_color TEXT;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS aspect_ratio DOUBLE PRECISION;
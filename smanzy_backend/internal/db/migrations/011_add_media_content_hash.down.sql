@@ -0,0 +1,5 @@
+-- Rollback: Add media content hash
+-- Description: Drops the content hash column and index
+
+DROP INDEX IF EXISTS idx_media_user_content_hash;
+ALTER TABLE media DROP COLUMN IF EXISTS content_hash;
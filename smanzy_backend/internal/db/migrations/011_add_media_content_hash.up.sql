@@ -0,0 +1,7 @@
+-- Migration: Add media content hash
+-- Description: Records the SHA-256 of uploaded and imported files so imports
+-- can skip files that are already in a user's library.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS content_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_media_user_content_hash ON media(user_id, content_hash) WHERE content_hash IS NOT NULL AND deleted_at IS NULL;
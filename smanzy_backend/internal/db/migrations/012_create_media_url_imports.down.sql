@@ -0,0 +1,5 @@
+-- Rollback: Create media URL imports
+-- Description: Drops the URL import queue and the media source URL
+
+DROP TABLE IF EXISTS media_url_imports;
+ALTER TABLE media DROP COLUMN IF EXISTS source_url;
@@ -0,0 +1,21 @@
+-- Migration: Create media URL imports
+-- Description: Queues media to be fetched from remote URLs in the background
+-- and records the source URL on the media created from them.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS source_url TEXT;
+
+CREATE TABLE IF NOT EXISTS media_url_imports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'inherit',
+    description TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'fetching', 'done', 'failed')),
+    error TEXT,
+    media_id BIGINT REFERENCES media(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    updated_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_url_imports_pending ON media_url_imports(id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_media_url_imports_user ON media_url_imports(user_id, created_at DESC);
@@ -0,0 +1,7 @@
+-- Rollback: Add media scan status
+-- Description: Drops the scan status columns and index
+
+DROP INDEX IF EXISTS idx_media_scan_pending;
+ALTER TABLE media DROP COLUMN IF EXISTS scanned_at;
+ALTER TABLE media DROP COLUMN IF EXISTS scan_result;
+ALTER TABLE media DROP COLUMN IF EXISTS scan_status;
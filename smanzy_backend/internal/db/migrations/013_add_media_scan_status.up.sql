@@ -0,0 +1,10 @@
+-- Migration: Add media scan status
+-- Description: New files are kept in quarantine until a malware scan passes.
+-- Files stored before scanning was introduced are considered clean.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS scan_status TEXT NOT NULL DEFAULT 'clean'
+    CHECK (scan_status IN ('pending', 'scanning', 'clean', 'infected', 'failed'));
+ALTER TABLE media ADD COLUMN IF NOT EXISTS scan_result TEXT;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS scanned_at BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_media_scan_pending ON media(id) WHERE scan_status = 'pending' AND deleted_at IS NULL;
@@ -0,0 +1,4 @@
+-- Rollback: Create user watermarks
+-- Description: Drops the user watermarks table
+
+DROP TABLE IF EXISTS user_watermarks;
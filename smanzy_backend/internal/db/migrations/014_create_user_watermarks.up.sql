@@ -0,0 +1,14 @@
+-- Migration: Create user watermarks
+-- Description: Per-user watermark settings, stamped on the public thumbnails
+-- and renders of the user's media.
+
+CREATE TABLE IF NOT EXISTS user_watermarks (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    text TEXT NOT NULL DEFAULT '',
+    logo_name TEXT,
+    position TEXT NOT NULL DEFAULT 'bottom-right' CHECK (position IN ('top-left', 'top-right', 'bottom-left', 'bottom-right', 'center')),
+    opacity DOUBLE PRECISION NOT NULL DEFAULT 0.5,
+    scale DOUBLE PRECISION NOT NULL DEFAULT 0.2,
+    updated_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+);
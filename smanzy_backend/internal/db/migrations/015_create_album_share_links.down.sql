@@ -0,0 +1,4 @@
+-- Rollback: Create album share links
+-- Description: Drops the album share links table
+
+DROP TABLE IF EXISTS album_share_links;
@@ -0,0 +1,20 @@
+-- Migration: Create album share links
+-- Description: Revocable links that open an album without an account, with an
+-- optional password, expiry and view limit.
+
+CREATE TABLE IF NOT EXISTS album_share_links (
+    id BIGSERIAL PRIMARY KEY,
+    album_id BIGINT NOT NULL REFERENCES album(id) ON DELETE CASCADE,
+    slug TEXT UNIQUE NOT NULL,
+    password_hash TEXT, -- bcrypt; NULL when the link needs no password
+    expires_at BIGINT, -- Unix ms; NULL never expires
+    max_views INT, -- NULL for unlimited views
+    view_count INT NOT NULL DEFAULT 0,
+    allow_download BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    last_viewed_at BIGINT,
+    revoked_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_album_share_links_album_id ON album_share_links(album_id);
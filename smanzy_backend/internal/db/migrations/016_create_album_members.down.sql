@@ -0,0 +1,4 @@
+-- Rollback: Create album members
+-- Description: Drops the album members table
+
+DROP TABLE IF EXISTS album_members;
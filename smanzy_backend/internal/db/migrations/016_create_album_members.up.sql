@@ -0,0 +1,13 @@
+-- Migration: Create album members
+-- Description: Album collaborators with a viewer, contributor or editor role.
+
+CREATE TABLE IF NOT EXISTS album_members (
+    album_id BIGINT NOT NULL REFERENCES album(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'contributor', 'editor')),
+    added_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    PRIMARY KEY (album_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_album_members_user_id ON album_members(user_id);
@@ -0,0 +1,9 @@
+-- Rollback: Add album ordering
+-- Description: Removes album media positions, album sort modes and covers, and
+-- media capture times
+
+ALTER TABLE media DROP COLUMN IF EXISTS captured_at;
+ALTER TABLE album DROP COLUMN IF EXISTS cover_media_id;
+ALTER TABLE album DROP COLUMN IF EXISTS sort_mode;
+DROP INDEX IF EXISTS idx_album_media_position;
+ALTER TABLE album_media DROP COLUMN IF EXISTS position;
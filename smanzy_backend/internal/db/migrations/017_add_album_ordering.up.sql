@@ -0,0 +1,24 @@
+-- Migration: Add album ordering
+-- Description: Adds a manual position to album media, a sort mode and cover to
+-- albums, and the capture time of photos. Existing album media keeps the order
+-- it was uploaded in.
+
+ALTER TABLE album_media ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+
+UPDATE album_media am
+SET position = ordered.position
+FROM (
+    SELECT x.album_id, x.media_id,
+           ROW_NUMBER() OVER (PARTITION BY x.album_id ORDER BY m.created_at, m.id) AS position
+    FROM album_media x
+    JOIN media m ON m.id = x.media_id
+) ordered
+WHERE am.album_id = ordered.album_id AND am.media_id = ordered.media_id;
+
+CREATE INDEX IF NOT EXISTS idx_album_media_position ON album_media(album_id, position);
+
+ALTER TABLE album ADD COLUMN IF NOT EXISTS sort_mode TEXT NOT NULL DEFAULT 'manual'
+    CHECK (sort_mode IN ('manual', 'captured', 'uploaded', 'name'));
+ALTER TABLE album ADD COLUMN IF NOT EXISTS cover_media_id BIGINT REFERENCES media(id) ON DELETE SET NULL;
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS captured_at BIGINT;
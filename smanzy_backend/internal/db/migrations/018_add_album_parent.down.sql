@@ -0,0 +1,5 @@
+-- Rollback: Add nested albums
+-- Description: Removes the parent album column
+
+DROP INDEX IF EXISTS idx_album_parent_id;
+ALTER TABLE album DROP COLUMN IF EXISTS parent_id;
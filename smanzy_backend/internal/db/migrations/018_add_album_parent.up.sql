@@ -0,0 +1,8 @@
+-- Migration: Add nested albums
+-- Description: Adds an optional parent album, so albums can be organised like
+-- Year > Event > Day. Sub-albums inherit the owner and collaborators of their
+-- parents.
+
+ALTER TABLE album ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES album(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_album_parent_id ON album(parent_id) WHERE deleted_at IS NULL;
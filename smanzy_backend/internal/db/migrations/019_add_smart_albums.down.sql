@@ -0,0 +1,4 @@
+-- Rollback: Add smart albums
+-- Description: Removes the smart album rules column
+
+ALTER TABLE album DROP COLUMN IF EXISTS smart_rules;
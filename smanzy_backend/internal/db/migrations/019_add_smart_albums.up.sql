@@ -0,0 +1,5 @@
+-- Migration: Add smart albums
+-- Description: Adds a JSON rule definition to albums. Albums with rules list
+-- the owner's media matching them, computed when read, instead of album_media.
+
+ALTER TABLE album ADD COLUMN IF NOT EXISTS smart_rules TEXT;
@@ -0,0 +1,4 @@
+-- Rollback: Index media by signing key
+-- Description: Drops the index on the stored name without its extension
+
+DROP INDEX IF EXISTS idx_media_stored_key;
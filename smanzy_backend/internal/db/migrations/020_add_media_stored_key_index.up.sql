@@ -0,0 +1,6 @@
+-- Migration: Index media by signing key
+-- Description: Unsigned file and thumbnail requests look media up by its
+-- stored name without the extension (IsStoredMediaPublic). Index that
+-- expression so they no longer scan the whole media table.
+
+CREATE INDEX IF NOT EXISTS idx_media_stored_key ON media ((regexp_replace(stored_name, '\.[^.]*$', ''))) WHERE deleted_at IS NULL;
@@ -0,0 +1,4 @@
+-- Rollback: Add media scan claim time
+-- Description: Drops the scan claim time column
+
+ALTER TABLE media DROP COLUMN IF EXISTS scan_claimed_at;
@@ -0,0 +1,6 @@
+-- Migration: Add media scan claim time
+-- Description: Records when a scanner took a file, so only files left
+-- scanning for longer than a scan can take are requeued, not files another
+-- instance is still scanning.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS scan_claimed_at BIGINT;
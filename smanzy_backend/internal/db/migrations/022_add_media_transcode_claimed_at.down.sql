@@ -0,0 +1,4 @@
+-- Rollback: Add media transcode claim time
+-- Description: Drops the transcode claim time column
+
+ALTER TABLE media DROP COLUMN IF EXISTS transcode_claimed_at;
@@ -0,0 +1,6 @@
+-- Migration: Add media transcode claim time
+-- Description: Records when a transcoder took a video, so only videos left
+-- processing for longer than a transcode can take are requeued, not videos
+-- another instance is still transcoding.
+
+ALTER TABLE media ADD COLUMN IF NOT EXISTS transcode_claimed_at BIGINT;
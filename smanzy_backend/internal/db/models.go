@@ -9,33 +9,111 @@ import (
 )
 
 type Album struct {
+	ID           int64          `json:"id"`
+	Title        string         `json:"title"`
+	Description  sql.NullString `json:"description"`
+	UserID       int64          `json:"user_id"`
+	IsPublic     sql.NullBool   `json:"is_public"`
+	IsShared     sql.NullBool   `json:"is_shared"`
+	CreatedAt    int64          `json:"created_at"`
+	UpdatedAt    int64          `json:"updated_at"`
+	DeletedAt    sql.NullTime   `json:"deleted_at"`
+	SortMode     string         `json:"sort_mode"`
+	CoverMediaID sql.NullInt64  `json:"cover_media_id"`
+	ParentID     sql.NullInt64  `json:"parent_id"`
+	SmartRules   sql.NullString `json:"smart_rules"`
+}
+
+type AlbumMedium struct {
+	AlbumID  int64 `json:"album_id"`
+	MediaID  int64 `json:"media_id"`
+	Position int32 `json:"position"`
+}
+
+type AlbumMember struct {
+	AlbumID   int64         `json:"album_id"`
+	UserID    int64         `json:"user_id"`
+	Role      string        `json:"role"`
+	AddedBy   sql.NullInt64 `json:"added_by"`
+	CreatedAt int64         `json:"created_at"`
+}
+
+type AlbumShareLink struct {
+	ID            int64          `json:"id"`
+	AlbumID       int64          `json:"album_id"`
+	Slug          string         `json:"slug"`
+	PasswordHash  sql.NullString `json:"password_hash"`
+	ExpiresAt     sql.NullInt64  `json:"expires_at"`
+	MaxViews      sql.NullInt32  `json:"max_views"`
+	ViewCount     int32          `json:"view_count"`
+	AllowDownload bool           `json:"allow_download"`
+	CreatedBy     int64          `json:"created_by"`
+	CreatedAt     int64          `json:"created_at"`
+	LastViewedAt  sql.NullInt64  `json:"last_viewed_at"`
+	RevokedAt     sql.NullInt64  `json:"revoked_at"`
+}
+
+type AlbumTag struct {
+	AlbumID int64 `json:"album_id"`
+	TagID   int64 `json:"tag_id"`
+}
+
+type MediaTag struct {
+	MediaID int64 `json:"media_id"`
+	TagID   int64 `json:"tag_id"`
+}
+
+type MediaUrlImport struct {
 	ID          int64          `json:"id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
 	UserID      int64          `json:"user_id"`
-	IsPublic    sql.NullBool   `json:"is_public"`
-	IsShared    sql.NullBool   `json:"is_shared"`
+	Url         string         `json:"url"`
+	Visibility  string         `json:"visibility"`
+	Description string         `json:"description"`
+	Status      string         `json:"status"`
+	Error       sql.NullString `json:"error"`
+	MediaID     sql.NullInt64  `json:"media_id"`
 	CreatedAt   int64          `json:"created_at"`
 	UpdatedAt   int64          `json:"updated_at"`
-	DeletedAt   sql.NullTime   `json:"deleted_at"`
 }
 
-type AlbumMedium struct {
-	AlbumID int64 `json:"album_id"`
-	MediaID int64 `json:"media_id"`
+type MediaVersion struct {
+	ID         int64  `json:"id"`
+	MediaID    int64  `json:"media_id"`
+	Version    int32  `json:"version"`
+	Filename   string `json:"filename"`
+	StoredName string `json:"stored_name"`
+	MimeType   string `json:"mime_type"`
+	Size       int64  `json:"size"`
+	CreatedAt  int64  `json:"created_at"`
 }
 
 type Medium struct {
-	ID         int64          `json:"id"`
-	Filename   string         `json:"filename"`
-	StoredName string         `json:"stored_name"`
-	Type       sql.NullString `json:"type"`
-	MimeType   sql.NullString `json:"mime_type"`
-	Size       int64          `json:"size"`
-	UserID     int64          `json:"user_id"`
-	CreatedAt  int64          `json:"created_at"`
-	UpdatedAt  int64          `json:"updated_at"`
-	DeletedAt  sql.NullTime   `json:"deleted_at"`
+	ID                 int64           `json:"id"`
+	Filename           string          `json:"filename"`
+	StoredName         string          `json:"stored_name"`
+	Type               sql.NullString  `json:"type"`
+	MimeType           sql.NullString  `json:"mime_type"`
+	Size               int64           `json:"size"`
+	UserID             int64           `json:"user_id"`
+	CreatedAt          int64           `json:"created_at"`
+	UpdatedAt          int64           `json:"updated_at"`
+	DeletedAt          sql.NullTime    `json:"deleted_at"`
+	Visibility         string          `json:"visibility"`
+	Description        sql.NullString  `json:"description"`
+	Phash              sql.NullInt64   `json:"phash"`
+	TranscodeStatus    string          `json:"transcode_status"`
+	TranscodeError     sql.NullString  `json:"transcode_error"`
+	Blurhash           sql.NullString  `json:"blurhash"`
+	DominantColor      sql.NullString  `json:"dominant_color"`
+	AspectRatio        sql.NullFloat64 `json:"aspect_ratio"`
+	ContentHash        sql.NullString  `json:"content_hash"`
+	SourceUrl          sql.NullString  `json:"source_url"`
+	ScanStatus         string          `json:"scan_status"`
+	ScanResult         sql.NullString  `json:"scan_result"`
+	ScannedAt          sql.NullInt64   `json:"scanned_at"`
+	CapturedAt         sql.NullInt64   `json:"captured_at"`
+	ScanClaimedAt      sql.NullInt64   `json:"scan_claimed_at"`
+	TranscodeClaimedAt sql.NullInt64   `json:"transcode_claimed_at"`
 }
 
 type Role struct {
@@ -45,6 +123,14 @@ type Role struct {
 	UpdatedAt int64  `json:"updated_at"`
 }
 
+type Tag struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	IsCurated bool          `json:"is_curated"`
+	CreatedBy sql.NullInt64 `json:"created_by"`
+	CreatedAt int64         `json:"created_at"`
+}
+
 type User struct {
 	ID            int64          `json:"id"`
 	Email         string         `json:"email"`
@@ -67,6 +153,17 @@ type UserRole struct {
 	RoleID int64 `json:"role_id"`
 }
 
+type UserWatermark struct {
+	UserID    int64          `json:"user_id"`
+	Enabled   bool           `json:"enabled"`
+	Text      string         `json:"text"`
+	LogoName  sql.NullString `json:"logo_name"`
+	Position  string         `json:"position"`
+	Opacity   float64        `json:"opacity"`
+	Scale     float64        `json:"scale"`
+	UpdatedAt int64          `json:"updated_at"`
+}
+
 type Video struct {
 	ID           int64          `json:"id"`
 	VideoID      string         `json:"video_id"`
@@ -6,43 +6,212 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 type Querier interface {
+	AddAlbumTag(ctx context.Context, arg AddAlbumTagParams) error
+	AddMediaTag(ctx context.Context, arg AddMediaTagParams) error
+	// New media goes to the end of the manual order
 	AddMediaToAlbum(ctx context.Context, arg AddMediaToAlbumParams) error
 	AssignRole(ctx context.Context, arg AssignRoleParams) error
-	CountPublicMedia(ctx context.Context) (int64, error)
+	// Takes the oldest file waiting in quarantine and marks it as being scanned.
+	// SKIP LOCKED lets several API instances run scanners without picking the
+	// same file.
+	ClaimScanJob(ctx context.Context) (ClaimScanJobRow, error)
+	// Takes the oldest pending video and marks it as processing. Videos still in
+	// quarantine wait for the malware scan. SKIP LOCKED lets several API
+	// instances run transcoders without picking the same video.
+	ClaimTranscodeJob(ctx context.Context) (ClaimTranscodeJobRow, error)
+	// Takes the oldest pending import and marks it as fetching. SKIP LOCKED lets
+	// several API instances run importers without picking the same job.
+	ClaimURLImport(ctx context.Context) (MediaUrlImport, error)
+	// Adds the media of one album to the end of another in its manual order,
+	// skipping media already there. media_ids (comma-separated) limits the copy
+	// to those media; NULL copies all, trashed media included.
+	CopyAlbumMedia(ctx context.Context, arg CopyAlbumMediaParams) (int64, error)
+	// Invites the collaborators of one album to another with the same roles,
+	// except the other album's owner
+	CopyAlbumMembers(ctx context.Context, arg CopyAlbumMembersParams) error
+	CopyAlbumTags(ctx context.Context, arg CopyAlbumTagsParams) error
+	CopyMediaTags(ctx context.Context, arg CopyMediaTagsParams) error
+	CountActiveURLImports(ctx context.Context, userID int64) (int64, error)
+	CountPublicAlbums(ctx context.Context) (int64, error)
+	CountPublicMedia(ctx context.Context, arg CountPublicMediaParams) (int64, error)
+	CountUserMedia(ctx context.Context, arg CountUserMediaParams) (int64, error)
 	CreateAlbum(ctx context.Context, arg CreateAlbumParams) (Album, error)
+	// Creates a curated tag, or promotes an existing free-form tag.
+	CreateCuratedTag(ctx context.Context, arg CreateCuratedTagParams) (Tag, error)
 	CreateMedia(ctx context.Context, arg CreateMediaParams) (CreateMediaRow, error)
+	// Archives the current file of a media item as its next version number.
+	CreateMediaVersion(ctx context.Context, arg CreateMediaVersionParams) (MediaVersion, error)
 	CreateRole(ctx context.Context, name string) (Role, error)
+	CreateShareLink(ctx context.Context, arg CreateShareLinkParams) (AlbumShareLink, error)
+	CreateURLImport(ctx context.Context, arg CreateURLImportParams) (MediaUrlImport, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error)
 	CreateVideo(ctx context.Context, arg CreateVideoParams) (Video, error)
+	DeleteTag(ctx context.Context, id int64) (int64, error)
+	// Returns the tag with the given name, creating a free-form tag if needed.
+	EnsureTag(ctx context.Context, arg EnsureTagParams) (Tag, error)
+	// Records the outcome of a scan. Infected media are moved to the trash.
+	// Nothing is updated if the file was replaced while it was being scanned.
+	FinishScanJob(ctx context.Context, arg FinishScanJobParams) (int64, error)
+	// Records the outcome of a transcode. Nothing is updated if the file was
+	// replaced while it was being transcoded.
+	FinishTranscodeJob(ctx context.Context, arg FinishTranscodeJobParams) (int64, error)
+	FinishURLImport(ctx context.Context, arg FinishURLImportParams) error
 	GetAlbumByID(ctx context.Context, id int64) (Album, error)
-	GetAlbumMedia(ctx context.Context, albumID int64) ([]Medium, error)
+	// Media of an album in the order of the sort mode: manual, captured (photos
+	// without a capture time by upload time), uploaded or name
+	GetAlbumMedia(ctx context.Context, arg GetAlbumMediaParams) ([]Medium, error)
 	GetMediaByID(ctx context.Context, id int64) (GetMediaByIDRow, error)
+	GetMediaByIDWithDeleted(ctx context.Context, id int64) (GetMediaByIDWithDeletedRow, error)
+	GetMediaHash(ctx context.Context, id int64) (sql.NullInt64, error)
+	// A live media item of the user with the same file contents, if any
+	GetMediaIDByContentHash(ctx context.Context, arg GetMediaIDByContentHashParams) (int64, error)
+	GetMediaVersion(ctx context.Context, arg GetMediaVersionParams) (MediaVersion, error)
 	GetRoleByName(ctx context.Context, name string) (Role, error)
+	// Revoked links and links to deleted albums are not found
+	GetShareLinkBySlug(ctx context.Context, slug string) (AlbumShareLink, error)
+	GetURLImport(ctx context.Context, id int64) (MediaUrlImport, error)
+	GetUserAlbumIDByTitle(ctx context.Context, arg GetUserAlbumIDByTitleParams) (int64, error)
 	GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error)
 	GetUserByEmailWithDeleted(ctx context.Context, email string) (GetUserByEmailWithDeletedRow, error)
 	GetUserByID(ctx context.Context, id int64) (GetUserByIDRow, error)
 	GetUserRoles(ctx context.Context, userID int64) ([]Role, error)
+	GetUserWatermark(ctx context.Context, userID int64) (UserWatermark, error)
 	GetVideoByID(ctx context.Context, id int64) (Video, error)
+	// The enabled watermark of the owner of a stored file, looked up by its name
+	// without extension like IsStoredMediaPublic
+	GetWatermarkForMediaKey(ctx context.Context, storedKey string) (UserWatermark, error)
+	IsMediaInAlbum(ctx context.Context, arg IsMediaInAlbumParams) (bool, error)
+	// Whether a stored file may be served without a signed URL: public media,
+	// or media inheriting visibility from a public album, once the file passed
+	// the malware scan. Unlisted media needs a signed URL, since stored names and
+	// IDs can be guessed. The stored name expression must match
+	// idx_media_stored_key.
+	IsStoredMediaPublic(ctx context.Context, storedKey string) (bool, error)
+	// The album and its parents up to the top level, nearest first, with the
+	// user's collaborator role on each ('' if none). Deleted parents end the chain.
+	ListAlbumAncestors(ctx context.Context, arg ListAlbumAncestorsParams) ([]ListAlbumAncestorsRow, error)
+	// Trashed media keeps its place for when it is restored
+	ListAlbumMediaOrder(ctx context.Context, albumID int64) ([]int64, error)
+	ListAlbumMembers(ctx context.Context, albumID int64) ([]ListAlbumMembersRow, error)
+	ListAlbumShareLinks(ctx context.Context, albumID int64) ([]AlbumShareLink, error)
+	ListAlbumTags(ctx context.Context, albumID int64) ([]Tag, error)
+	// The album and all its sub-albums, parents before children, with the user's
+	// collaborator role on each ('' if none) and the number of media in each that
+	// is not trashed or someone else's private media
+	ListAlbumTree(ctx context.Context, arg ListAlbumTreeParams) ([]ListAlbumTreeRow, error)
 	ListAllAlbums(ctx context.Context) ([]ListAllAlbumsRow, error)
+	ListCuratedTags(ctx context.Context) ([]Tag, error)
+	// Pairs of a user's media whose hashes are within max_distance bits,
+	// closest first. Each pair is returned once, with media_id < duplicate_id.
+	ListDuplicatePairs(ctx context.Context, arg ListDuplicatePairsParams) ([]ListDuplicatePairsRow, error)
+	// Media that is not trashed among a comma-separated list of IDs, in no
+	// particular order
+	ListMediaByIDs(ctx context.Context, ids string) ([]Medium, error)
+	// Images and videos that still need a perceptual hash, in ID order so the
+	// indexer can page through them with after_id.
+	ListMediaMissingHash(ctx context.Context, arg ListMediaMissingHashParams) ([]ListMediaMissingHashRow, error)
+	// Images and videos that still need a BlurHash placeholder, in ID order so
+	// the indexer can page through them with after_id.
+	ListMediaMissingPlaceholder(ctx context.Context, arg ListMediaMissingPlaceholderParams) ([]ListMediaMissingPlaceholderRow, error)
+	ListMediaTags(ctx context.Context, mediaID int64) ([]Tag, error)
+	ListMediaVersions(ctx context.Context, mediaID int64) ([]MediaVersion, error)
+	// Public album directory, most recently updated first. Only the owner's
+	// display name is exposed; media_count leaves out private and unscanned media.
+	ListPublicAlbums(ctx context.Context, arg ListPublicAlbumsParams) ([]ListPublicAlbumsRow, error)
+	// Public feed: media explicitly marked public, or inheriting from a public album,
+	// that passed the malware scan.
+	// Only the owner's display name is exposed. tags is a comma-separated list of
+	// tag names (empty for no filter); min_matches is 1 to match any of them, or
+	// the number of tags to match all of them.
 	ListPublicMedia(ctx context.Context, arg ListPublicMediaParams) ([]ListPublicMediaRow, error)
+	// Media of the same owner whose hash is within max_distance bits of phash,
+	// closest first.
+	ListSimilarMedia(ctx context.Context, arg ListSimilarMediaParams) ([]ListSimilarMediaRow, error)
+	ListTrashedMedia(ctx context.Context, userID int64) ([]ListTrashedMediaRow, error)
+	// The user's own albums, the albums they collaborate on and the sub-albums of
+	// both, with their best role ('owner' for their own; sub-albums inherit the
+	// role on their parent). The cover is the chosen cover media, or else the
+	// first media in manual order, among the media the user sees in the album.
+	// Smart albums only have a cover when one is chosen.
 	ListUserAlbums(ctx context.Context, userID int64) ([]ListUserAlbumsRow, error)
-	ListUserMedia(ctx context.Context, userID int64) ([]ListUserMediaRow, error)
+	ListUserMedia(ctx context.Context, arg ListUserMediaParams) ([]ListUserMediaRow, error)
+	ListUserURLImports(ctx context.Context, arg ListUserURLImportsParams) ([]MediaUrlImport, error)
 	ListUsers(ctx context.Context) ([]ListUsersRow, error)
 	ListVideos(ctx context.Context, arg ListVideosParams) ([]Video, error)
+	// Serialises moves between parents for the rest of the transaction, so two
+	// concurrent moves cannot create a cycle
+	LockAlbumTree(ctx context.Context) error
+	// Adds the kept media item to every album the duplicate is in, then takes
+	// the duplicate out of those albums.
+	MoveAlbumMemberships(ctx context.Context, arg MoveAlbumMembershipsParams) error
 	PermanentlyDeleteMedia(ctx context.Context, id int64) error
+	// Deletes all but the newest versions of a media item, returning the stored
+	// names so the files can be deleted from disk.
+	PruneMediaVersions(ctx context.Context, arg PruneMediaVersionsParams) ([]string, error)
+	// Permanently removes media that has been in the trash since before the cutoff,
+	// returning each item's stored names, including its earlier versions.
+	PurgeExpiredTrash(ctx context.Context, cutoff time.Time) ([]PurgeExpiredTrashRow, error)
+	// Permanently removes every trashed item of a user, returning each item's
+	// stored names (including earlier versions) so the files can be deleted from
+	// disk.
+	PurgeUserTrash(ctx context.Context, userID int64) ([]PurgeUserTrashRow, error)
+	// Counts a view unless the link expired, reached its view limit or was
+	// revoked meanwhile; the check and the count are one statement so
+	// concurrent views cannot exceed the limit.
+	RecordShareLinkView(ctx context.Context, id int64) (int64, error)
+	RemoveAlbumMember(ctx context.Context, arg RemoveAlbumMemberParams) (int64, error)
+	RemoveAlbumTag(ctx context.Context, arg RemoveAlbumTagParams) (int64, error)
 	RemoveMediaFromAlbum(ctx context.Context, arg RemoveMediaFromAlbumParams) error
+	RemoveMediaTag(ctx context.Context, arg RemoveMediaTagParams) (int64, error)
 	RemoveRole(ctx context.Context, arg RemoveRoleParams) error
+	RenameMedia(ctx context.Context, arg RenameMediaParams) error
+	// Moves the sub-albums of a deleted album up to its parent
+	ReparentChildAlbums(ctx context.Context, arg ReparentChildAlbumsParams) error
+	ReplaceMediaFile(ctx context.Context, arg ReplaceMediaFileParams) (ReplaceMediaFileRow, error)
+	// Puts a file back in the queue after the scanner could not be reached
+	RequeueScanJob(ctx context.Context, arg RequeueScanJobParams) error
+	RequeueTranscode(ctx context.Context, id int64) (int64, error)
+	// Requeues files left in scanning by a server that stopped mid-scan: those
+	// claimed before claimed_before (Unix ms). Files claimed before the claim
+	// time was recorded have none and are requeued too.
+	ResetStaleScans(ctx context.Context, claimedBefore int64) error
+	// Requeues videos left in processing by a server that stopped
+	// mid-transcode: those claimed before claimed_before (Unix ms). Videos
+	// claimed before the claim time was recorded have none and are requeued too.
+	ResetStaleTranscodes(ctx context.Context, claimedBefore int64) error
+	// Requeues imports left in fetching by a server that stopped mid-download
+	ResetStaleURLImports(ctx context.Context) error
+	RestoreMedia(ctx context.Context, id int64) error
 	RestoreUser(ctx context.Context, id int64) error
+	RevokeShareLink(ctx context.Context, arg RevokeShareLinkParams) (int64, error)
+	// Autocomplete: curated tags and tags the user has used, matching a prefix.
+	SearchTags(ctx context.Context, arg SearchTagsParams) ([]Tag, error)
+	SetAlbumMediaPosition(ctx context.Context, arg SetAlbumMediaPositionParams) error
+	SetAlbumParent(ctx context.Context, arg SetAlbumParentParams) error
+	SetAlbumSmartRules(ctx context.Context, arg SetAlbumSmartRulesParams) error
+	SetMediaCreatedAt(ctx context.Context, arg SetMediaCreatedAtParams) error
+	SetMediaHash(ctx context.Context, arg SetMediaHashParams) error
+	SetMediaPlaceholder(ctx context.Context, arg SetMediaPlaceholderParams) error
+	SetMediaVisibility(ctx context.Context, arg SetMediaVisibilityParams) error
+	SetTagCurated(ctx context.Context, arg SetTagCuratedParams) (Tag, error)
+	SetUserWatermarkLogo(ctx context.Context, arg SetUserWatermarkLogoParams) (UserWatermark, error)
 	SoftDeleteAlbum(ctx context.Context, id int64) error
 	SoftDeleteMedia(ctx context.Context, id int64) error
 	SoftDeleteUser(ctx context.Context, id int64) error
 	SoftDeleteVideo(ctx context.Context, id int64) error
+	// Tag usage counts over publicly visible media that passed the malware
+	// scan, most used first.
+	TagCloud(ctx context.Context, limit int32) ([]TagCloudRow, error)
 	UpdateAlbum(ctx context.Context, arg UpdateAlbumParams) (Album, error)
 	UpdateMedia(ctx context.Context, arg UpdateMediaParams) (UpdateMediaRow, error)
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateUserRow, error)
+	UpsertAlbumMember(ctx context.Context, arg UpsertAlbumMemberParams) (AlbumMember, error)
+	UpsertUserWatermark(ctx context.Context, arg UpsertUserWatermarkParams) (UserWatermark, error)
 }
 
 var _ Querier = (*Queries)(nil)
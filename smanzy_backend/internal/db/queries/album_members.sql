@@ -0,0 +1,26 @@
+-- name: ListAlbumMembers :many
+SELECT am.album_id, am.user_id, am.role, am.created_at, u.name as user_name
+FROM album_members am
+JOIN users u ON u.id = am.user_id
+WHERE am.album_id = $1 AND u.deleted_at IS NULL
+ORDER BY am.created_at;
+
+-- name: UpsertAlbumMember :one
+INSERT INTO album_members (album_id, user_id, role, added_by)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (album_id, user_id) DO UPDATE SET role = EXCLUDED.role
+RETURNING *;
+
+-- name: RemoveAlbumMember :execrows
+DELETE FROM album_members
+WHERE album_id = $1 AND user_id = $2;
+
+-- name: CopyAlbumMembers :exec
+-- Invites the collaborators of one album to another with the same roles,
+-- except the other album's owner
+INSERT INTO album_members (album_id, user_id, role, added_by)
+SELECT sqlc.arg(target_id)::BIGINT, am.user_id, am.role, sqlc.narg(added_by)::BIGINT
+FROM album_members am
+WHERE am.album_id = sqlc.arg(source_id)
+  AND am.user_id <> (SELECT user_id FROM album WHERE id = sqlc.arg(target_id))
+ON CONFLICT (album_id, user_id) DO NOTHING;
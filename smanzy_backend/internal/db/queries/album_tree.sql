@@ -0,0 +1,68 @@
+-- name: ListAlbumAncestors :many
+-- The album and its parents up to the top level, nearest first, with the
+-- user's collaborator role on each ('' if none). Deleted parents end the chain.
+WITH RECURSIVE chain AS (
+    SELECT a.id, a.parent_id, 0 AS depth
+    FROM album a
+    WHERE a.id = sqlc.arg(album_id) AND a.deleted_at IS NULL
+    UNION ALL
+    SELECT p.id, p.parent_id, c.depth + 1
+    FROM album p
+    JOIN chain c ON p.id = c.parent_id
+    WHERE p.deleted_at IS NULL AND c.depth < 32
+)
+SELECT
+    a.id, a.title, a.user_id, a.is_public, a.is_shared,
+    COALESCE(am.role, '')::TEXT AS role
+FROM chain c
+JOIN album a ON a.id = c.id
+LEFT JOIN album_members am ON am.album_id = a.id AND am.user_id = sqlc.arg(user_id)
+ORDER BY c.depth;
+
+-- name: ListAlbumTree :many
+-- The album and all its sub-albums, parents before children, with the user's
+-- collaborator role on each ('' if none) and the number of media in each that
+-- is not trashed or someone else's private media
+WITH RECURSIVE tree AS (
+    SELECT a.id, 0 AS depth
+    FROM album a
+    WHERE a.id = sqlc.arg(album_id) AND a.deleted_at IS NULL
+    UNION ALL
+    SELECT c.id, t.depth + 1
+    FROM album c
+    JOIN tree t ON c.parent_id = t.id
+    WHERE c.deleted_at IS NULL AND t.depth < 32
+)
+SELECT
+    a.*, t.depth::INT AS depth,
+    COALESCE(am.role, '')::TEXT AS role,
+    (
+        SELECT COUNT(*) FROM album_media x
+        JOIN media m ON m.id = x.media_id
+        WHERE x.album_id = a.id AND m.deleted_at IS NULL
+          AND (m.visibility <> 'private' OR m.user_id = sqlc.arg(user_id))
+    ) AS media_count
+FROM tree t
+JOIN album a ON a.id = t.id
+LEFT JOIN album_members am ON am.album_id = a.id AND am.user_id = sqlc.arg(user_id)
+ORDER BY t.depth, LOWER(a.title), a.id;
+
+-- name: LockAlbumTree :exec
+-- Serialises moves between parents for the rest of the transaction, so two
+-- concurrent moves cannot create a cycle
+SELECT pg_advisory_xact_lock(hashtext('album_tree'));
+
+-- name: SetAlbumParent :exec
+UPDATE album
+SET
+    parent_id = $2,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1;
+
+-- name: ReparentChildAlbums :exec
+-- Moves the sub-albums of a deleted album up to its parent
+UPDATE album
+SET
+    parent_id = sqlc.narg(new_parent_id),
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE parent_id = sqlc.arg(old_parent_id) AND deleted_at IS NULL;
@@ -1,13 +1,13 @@
 -- name: CreateAlbum :one
 INSERT INTO album (
-    title, description, user_id, is_public, is_shared,
+    title, description, user_id, is_public, is_shared, parent_id,
     created_at, updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5,
+    $1, $2, $3, $4, $5, $6,
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 )
-RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at;
+RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at, sort_mode, cover_media_id, parent_id, smart_rules;
 
 -- name: GetAlbumByID :one
 SELECT * FROM album
@@ -15,17 +15,67 @@ WHERE id = $1 AND deleted_at IS NULL
 LIMIT 1;
 
 -- name: ListUserAlbums :many
+-- The user's own albums, the albums they collaborate on and the sub-albums of
+-- both, with their best role ('owner' for their own; sub-albums inherit the
+-- role on their parent). The cover is the chosen cover media, or else the
+-- first media in manual order, among the media the user sees in the album.
+-- Smart albums only have a cover when one is chosen.
+WITH RECURSIVE reachable AS (
+    SELECT a.id, CASE WHEN a.user_id = sqlc.arg(user_id) THEN 'owner' ELSE am.role END AS role, 0 AS depth
+    FROM album a
+    LEFT JOIN album_members am ON am.album_id = a.id AND am.user_id = sqlc.arg(user_id)
+    WHERE (a.user_id = sqlc.arg(user_id) OR am.user_id IS NOT NULL) AND a.deleted_at IS NULL
+    UNION ALL
+    SELECT c.id, CASE WHEN c.user_id = sqlc.arg(user_id) THEN 'owner' ELSE r.role END, r.depth + 1
+    FROM album c
+    JOIN reachable r ON c.parent_id = r.id
+    WHERE c.deleted_at IS NULL AND r.depth < 32
+), roles AS (
+    SELECT id, (ARRAY_AGG(role ORDER BY CASE role
+        WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 WHEN 'contributor' THEN 2 ELSE 3 END))[1] AS role
+    FROM reachable
+    GROUP BY id
+)
 SELECT
-    a.*, u.name as user_name
-FROM album a
+    a.*, u.name as user_name,
+    ro.role::TEXT as role,
+    cover.id as cover_id, cover.stored_name as cover_stored_name, cover.user_id as cover_user_id
+FROM roles ro
+JOIN album a ON a.id = ro.id
 JOIN users u ON a.user_id = u.id
-WHERE a.user_id = $1 AND a.deleted_at IS NULL
+LEFT JOIN LATERAL (
+    SELECT m.id, m.stored_name, m.user_id
+    FROM media m
+    LEFT JOIN album_media x ON x.album_id = a.id AND x.media_id = m.id
+    WHERE m.id IN (
+        SELECT media_id FROM album_media WHERE album_id = a.id
+        UNION ALL
+        SELECT a.cover_media_id WHERE a.smart_rules IS NOT NULL
+    ) AND m.deleted_at IS NULL AND m.scan_status = 'clean'
+      AND (m.visibility <> 'private' OR m.user_id = sqlc.arg(user_id) OR ro.role IN ('owner', 'editor'))
+    ORDER BY m.id = a.cover_media_id DESC, x.position, m.id
+    LIMIT 1
+) cover ON TRUE
 ORDER BY a.created_at DESC;
 
 -- name: ListAllAlbums :many
-SELECT a.*, u.name as user_name
+SELECT
+    a.*, u.name as user_name,
+    cover.id as cover_id, cover.stored_name as cover_stored_name, cover.user_id as cover_user_id
 FROM album a
 JOIN users u ON a.user_id = u.id
+LEFT JOIN LATERAL (
+    SELECT m.id, m.stored_name, m.user_id
+    FROM media m
+    LEFT JOIN album_media x ON x.album_id = a.id AND x.media_id = m.id
+    WHERE m.id IN (
+        SELECT media_id FROM album_media WHERE album_id = a.id
+        UNION ALL
+        SELECT a.cover_media_id WHERE a.smart_rules IS NOT NULL
+    ) AND m.deleted_at IS NULL AND m.scan_status = 'clean'
+    ORDER BY m.id = a.cover_media_id DESC, x.position, m.id
+    LIMIT 1
+) cover ON TRUE
 WHERE a.deleted_at IS NULL
 ORDER BY a.created_at DESC;
 
@@ -36,9 +86,18 @@ SET
     description = $3,
     is_public = $4,
     is_shared = $5,
+    sort_mode = $6,
+    cover_media_id = $7,
     updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 WHERE id = $1
-RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at;
+RETURNING id, title, description, user_id, is_public, is_shared, created_at, updated_at, deleted_at, sort_mode, cover_media_id, parent_id, smart_rules;
+
+-- name: SetAlbumSmartRules :exec
+UPDATE album
+SET
+    smart_rules = $2,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1;
 
 -- name: SoftDeleteAlbum :exec
 UPDATE album
@@ -46,15 +105,84 @@ SET deleted_at = NOW()
 WHERE id = $1;
 
 -- name: GetAlbumMedia :many
+-- Media of an album in the order of the sort mode: manual, captured (photos
+-- without a capture time by upload time), uploaded or name
 SELECT m.* FROM media m
 JOIN album_media am ON am.media_id = m.id
-WHERE am.album_id = $1 AND m.deleted_at IS NULL;
+WHERE am.album_id = sqlc.arg(album_id) AND m.deleted_at IS NULL
+ORDER BY
+    CASE WHEN sqlc.arg(sort_mode)::TEXT = 'captured' THEN COALESCE(m.captured_at, m.created_at) END,
+    CASE WHEN sqlc.arg(sort_mode)::TEXT = 'uploaded' THEN m.created_at END,
+    CASE WHEN sqlc.arg(sort_mode)::TEXT = 'name' THEN LOWER(m.filename) END,
+    am.position, m.id;
 
 -- name: AddMediaToAlbum :exec
-INSERT INTO album_media (album_id, media_id)
-VALUES ($1, $2)
+-- New media goes to the end of the manual order
+INSERT INTO album_media (album_id, media_id, position)
+VALUES (
+    $1, $2,
+    (SELECT COALESCE(MAX(position), 0) + 1 FROM album_media WHERE album_id = $1)
+)
+ON CONFLICT DO NOTHING;
+
+-- name: ListAlbumMediaOrder :many
+-- Trashed media keeps its place for when it is restored
+SELECT media_id FROM album_media
+WHERE album_id = $1
+ORDER BY position, media_id;
+
+-- name: SetAlbumMediaPosition :exec
+UPDATE album_media
+SET position = $3
+WHERE album_id = $1 AND media_id = $2;
+
+-- name: IsMediaInAlbum :one
+SELECT EXISTS (
+    SELECT 1 FROM album_media
+    WHERE album_id = $1 AND media_id = $2
+) AS in_album;
+
+-- name: CopyAlbumMedia :execrows
+-- Adds the media of one album to the end of another in its manual order,
+-- skipping media already there. media_ids (comma-separated) limits the copy
+-- to those media; NULL copies all, trashed media included.
+INSERT INTO album_media (album_id, media_id, position)
+SELECT
+    sqlc.arg(target_id)::BIGINT,
+    s.media_id,
+    (SELECT COALESCE(MAX(position), 0) FROM album_media WHERE album_id = sqlc.arg(target_id))
+        + ROW_NUMBER() OVER (ORDER BY s.position, s.media_id)
+FROM album_media s
+WHERE s.album_id = sqlc.arg(source_id)
+  AND (sqlc.narg(media_ids)::TEXT IS NULL
+       OR s.media_id = ANY(string_to_array(sqlc.narg(media_ids)::TEXT, ',')::BIGINT[]))
+  AND NOT EXISTS (
+    SELECT 1 FROM album_media t
+    WHERE t.album_id = sqlc.arg(target_id) AND t.media_id = s.media_id
+  )
 ON CONFLICT DO NOTHING;
 
 -- name: RemoveMediaFromAlbum :exec
 DELETE FROM album_media
 WHERE album_id = $1 AND media_id = $2;
+
+-- name: ListPublicAlbums :many
+-- Public album directory, most recently updated first. Only the owner's
+-- display name is exposed; media_count leaves out private and unscanned media.
+SELECT
+    a.*, u.name as user_name,
+    (
+        SELECT COUNT(*) FROM album_media am
+        JOIN media m ON m.id = am.media_id
+        WHERE am.album_id = a.id AND m.deleted_at IS NULL
+          AND m.scan_status = 'clean' AND m.visibility <> 'private'
+    ) AS media_count
+FROM album a
+JOIN users u ON a.user_id = u.id
+WHERE a.is_public = TRUE AND a.deleted_at IS NULL
+ORDER BY a.updated_at DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountPublicAlbums :one
+SELECT COUNT(*) FROM album
+WHERE is_public = TRUE AND deleted_at IS NULL;
@@ -0,0 +1,85 @@
+-- name: ListMediaMissingHash :many
+-- Images and videos that still need a perceptual hash, in ID order so the
+-- indexer can page through them with after_id.
+SELECT id, stored_name
+FROM media
+WHERE phash IS NULL
+  AND deleted_at IS NULL
+  AND (mime_type LIKE 'image/%' OR mime_type LIKE 'video/%')
+  AND id > sqlc.arg(after_id)::BIGINT
+ORDER BY id
+LIMIT sqlc.arg(max_results)::INT;
+
+-- name: SetMediaHash :exec
+UPDATE media
+SET phash = $2
+WHERE id = $1;
+
+-- name: GetMediaHash :one
+SELECT phash
+FROM media
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ListSimilarMedia :many
+-- Media of the same owner whose hash is within max_distance bits of phash,
+-- closest first.
+SELECT
+    m.id, m.filename, m.stored_name,
+    COALESCE(m.type, '') as type,
+    COALESCE(m.mime_type, '') as mime_type,
+    m.size, m.user_id, m.visibility,
+    COALESCE(m.description, '') as description,
+    m.transcode_status,
+    m.scan_status,
+    COALESCE(m.scan_result, '') as scan_result,
+    COALESCE(m.blurhash, '') as blurhash,
+    COALESCE(m.dominant_color, '') as dominant_color,
+    COALESCE(m.aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
+    COALESCE(m.created_at, 0)::BIGINT as created_at,
+    COALESCE(m.updated_at, 0)::BIGINT as updated_at,
+    m.deleted_at,
+    bit_count((m.phash # sqlc.arg(phash)::BIGINT)::BIT(64))::INT as distance
+FROM media m
+WHERE m.user_id = sqlc.arg(user_id)
+  AND m.id <> sqlc.arg(id)
+  AND m.phash IS NOT NULL
+  AND m.deleted_at IS NULL
+  AND bit_count((m.phash # sqlc.arg(phash)::BIGINT)::BIT(64)) <= sqlc.arg(max_distance)::INT
+ORDER BY distance, m.id
+LIMIT sqlc.arg(max_results)::INT;
+
+-- name: ListDuplicatePairs :many
+-- Pairs of a user's media whose hashes are within max_distance bits,
+-- closest first. Each pair is returned once, with media_id < duplicate_id.
+SELECT
+    a.id as media_id,
+    b.id as duplicate_id,
+    bit_count((a.phash # b.phash)::BIT(64))::INT as distance
+FROM media a
+JOIN media b ON b.user_id = a.user_id AND b.id > a.id
+WHERE a.user_id = sqlc.arg(user_id)
+  AND a.phash IS NOT NULL AND a.deleted_at IS NULL
+  AND b.phash IS NOT NULL AND b.deleted_at IS NULL
+  AND bit_count((a.phash # b.phash)::BIT(64)) <= sqlc.arg(max_distance)::INT
+ORDER BY distance, a.id, b.id
+LIMIT sqlc.arg(max_results)::INT;
+
+-- name: MoveAlbumMemberships :exec
+-- Adds the kept media item to every album the duplicate is in, then takes
+-- the duplicate out of those albums.
+WITH moved AS (
+    INSERT INTO album_media (album_id, media_id)
+    SELECT album_id, sqlc.arg(keep_id)::BIGINT
+    FROM album_media
+    WHERE media_id = sqlc.arg(duplicate_id)::BIGINT
+    ON CONFLICT DO NOTHING
+)
+DELETE FROM album_media
+WHERE media_id = sqlc.arg(duplicate_id)::BIGINT;
+
+-- name: CopyMediaTags :exec
+INSERT INTO media_tags (media_id, tag_id)
+SELECT sqlc.arg(keep_id)::BIGINT, tag_id
+FROM media_tags
+WHERE media_id = sqlc.arg(duplicate_id)::BIGINT
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,19 @@
+-- name: GetMediaIDByContentHash :one
+-- A live media item of the user with the same file contents, if any
+SELECT id
+FROM media
+WHERE user_id = $1 AND content_hash = $2 AND deleted_at IS NULL
+ORDER BY id
+LIMIT 1;
+
+-- name: SetMediaCreatedAt :exec
+UPDATE media
+SET created_at = $2
+WHERE id = $1;
+
+-- name: GetUserAlbumIDByTitle :one
+SELECT id
+FROM album
+WHERE user_id = $1 AND title = $2 AND deleted_at IS NULL
+ORDER BY id
+LIMIT 1;
@@ -3,7 +3,14 @@ SELECT
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at
@@ -11,47 +18,155 @@ FROM media
 WHERE id = $1 AND deleted_at IS NULL
 LIMIT 1;
 
+-- name: GetMediaByIDWithDeleted :one
+SELECT
+    id, filename, stored_name,
+    COALESCE(type, '') as type,
+    COALESCE(mime_type, '') as mime_type,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
+    COALESCE(created_at, 0)::BIGINT as created_at,
+    COALESCE(updated_at, 0)::BIGINT as updated_at,
+    deleted_at
+FROM media
+WHERE id = $1
+LIMIT 1;
+
 -- name: ListPublicMedia :many
+-- Public feed: media explicitly marked public, or inheriting from a public album,
+-- that passed the malware scan.
+-- Only the owner's display name is exposed. tags is a comma-separated list of
+-- tag names (empty for no filter); min_matches is 1 to match any of them, or
+-- the number of tags to match all of them.
 SELECT
     m.id, m.filename, m.stored_name,
     COALESCE(m.type, '') as type,
     COALESCE(m.mime_type, '') as mime_type,
-    m.size, m.user_id,
+    m.size, m.user_id, m.visibility,
+    COALESCE(m.description, '') as description,
+    m.transcode_status,
+    m.scan_status,
+    COALESCE(m.scan_result, '') as scan_result,
+    COALESCE(m.blurhash, '') as blurhash,
+    COALESCE(m.dominant_color, '') as dominant_color,
+    COALESCE(m.aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(m.created_at, 0)::BIGINT as created_at,
     COALESCE(m.updated_at, 0)::BIGINT as updated_at,
     m.deleted_at,
-    u.name as user_name,
-    u.tel as user_tel,
-    u.email as user_email
+    u.name as user_name
 FROM media m
 JOIN users u ON m.user_id = u.id
-WHERE m.deleted_at IS NULL
+WHERE m.deleted_at IS NULL AND m.scan_status = 'clean'
+  AND (
+    m.visibility = 'public'
+    OR (m.visibility = 'inherit' AND EXISTS (
+        SELECT 1 FROM album_media am
+        JOIN album a ON a.id = am.album_id
+        WHERE am.media_id = m.id AND a.is_public = TRUE AND a.deleted_at IS NULL
+    ))
+  )
+  AND (sqlc.arg(tags)::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array(sqlc.arg(tags)::TEXT, ','))
+  ) >= sqlc.arg(min_matches)::INT)
 ORDER BY m.created_at DESC
-LIMIT $1 OFFSET $2;
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
 
 -- name: CountPublicMedia :one
-SELECT COUNT(*) FROM media
-WHERE deleted_at IS NULL;
+SELECT COUNT(*) FROM media m
+WHERE m.deleted_at IS NULL AND m.scan_status = 'clean'
+  AND (
+    m.visibility = 'public'
+    OR (m.visibility = 'inherit' AND EXISTS (
+        SELECT 1 FROM album_media am
+        JOIN album a ON a.id = am.album_id
+        WHERE am.media_id = m.id AND a.is_public = TRUE AND a.deleted_at IS NULL
+    ))
+  )
+  AND (sqlc.arg(tags)::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array(sqlc.arg(tags)::TEXT, ','))
+  ) >= sqlc.arg(min_matches)::INT);
+
+-- name: ListMediaByIDs :many
+-- Media that is not trashed among a comma-separated list of IDs, in no
+-- particular order
+SELECT * FROM media
+WHERE id = ANY(string_to_array(sqlc.arg(ids)::TEXT, ',')::BIGINT[])
+  AND deleted_at IS NULL;
+
+-- name: IsStoredMediaPublic :one
+-- Whether a stored file may be served without a signed URL: public media,
+-- or media inheriting visibility from a public album, once the file passed
+-- the malware scan. Unlisted media needs a signed URL, since stored names and
+-- IDs can be guessed. The stored name expression must match
+-- idx_media_stored_key.
+SELECT EXISTS (
+    SELECT 1 FROM media m
+    WHERE regexp_replace(m.stored_name, '\.[^.]*$', '') = sqlc.arg(stored_key)::TEXT
+      AND m.deleted_at IS NULL
+      AND m.scan_status = 'clean'
+      AND (
+        m.visibility = 'public'
+        OR (m.visibility = 'inherit' AND EXISTS (
+            SELECT 1 FROM album_media am
+            JOIN album a ON a.id = am.album_id
+            WHERE am.media_id = m.id AND a.is_public = TRUE AND a.deleted_at IS NULL
+        ))
+      )
+) AS is_public;
 
 -- name: ListUserMedia :many
 SELECT
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at
-FROM media
-WHERE user_id = $1 AND deleted_at IS NULL
-ORDER BY created_at DESC;
+FROM media m
+WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL
+  AND (sqlc.arg(tags)::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array(sqlc.arg(tags)::TEXT, ','))
+  ) >= sqlc.arg(min_matches)::INT)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountUserMedia :one
+SELECT COUNT(*) FROM media m
+WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL
+  AND (sqlc.arg(tags)::TEXT = '' OR (
+    SELECT COUNT(*) FROM media_tags mt
+    JOIN tags t ON t.id = mt.tag_id
+    WHERE mt.media_id = m.id AND t.name = ANY(string_to_array(sqlc.arg(tags)::TEXT, ','))
+  ) >= sqlc.arg(min_matches)::INT);
 
 -- name: CreateMedia :one
 INSERT INTO media (
-    filename, stored_name, type, mime_type, size, user_id,
-    created_at, updated_at
+    filename, stored_name, type, mime_type, size, user_id, visibility, description,
+    content_hash, source_url, captured_at, transcode_status, scan_status, created_at, updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6,
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+    CASE WHEN $4 LIKE 'video/%' OR $2 ~* '\.(mp4|mov|avi|mkv|webm)$' THEN 'pending' ELSE 'none' END,
+    'pending',
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
     (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 )
@@ -59,7 +174,14 @@ RETURNING
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at;
@@ -71,22 +193,132 @@ SET
     type = $3,
     mime_type = $4,
     size = $5,
+    visibility = $6,
+    description = $7,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1
+RETURNING
+    id, filename, stored_name,
+    COALESCE(type, '') as type,
+    COALESCE(mime_type, '') as mime_type,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
+    COALESCE(created_at, 0)::BIGINT as created_at,
+    COALESCE(updated_at, 0)::BIGINT as updated_at,
+    deleted_at;
+
+-- name: ReplaceMediaFile :one
+UPDATE media
+SET
+    stored_name = $2,
+    mime_type = $3,
+    size = $4,
+    type = $5,
+    phash = NULL,
+    blurhash = NULL,
+    dominant_color = NULL,
+    aspect_ratio = NULL,
+    content_hash = NULL,
+    transcode_status = CASE WHEN $3 LIKE 'video/%' OR $2 ~* '\.(mp4|mov|avi|mkv|webm)$' THEN 'pending' ELSE 'none' END,
+    transcode_error = NULL,
+    scan_status = 'pending',
+    scan_result = NULL,
+    scanned_at = NULL,
     updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
 WHERE id = $1
 RETURNING
     id, filename, stored_name,
     COALESCE(type, '') as type,
     COALESCE(mime_type, '') as mime_type,
-    size, user_id,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
     COALESCE(created_at, 0)::BIGINT as created_at,
     COALESCE(updated_at, 0)::BIGINT as updated_at,
     deleted_at;
 
+-- name: RenameMedia :exec
+UPDATE media
+SET
+    filename = $2,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1;
+
+-- name: SetMediaVisibility :exec
+UPDATE media
+SET
+    visibility = $2,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1;
+
 -- name: SoftDeleteMedia :exec
 UPDATE media
 SET deleted_at = NOW()
 WHERE id = $1;
 
+-- name: ListTrashedMedia :many
+SELECT
+    id, filename, stored_name,
+    COALESCE(type, '') as type,
+    COALESCE(mime_type, '') as mime_type,
+    size, user_id, visibility,
+    COALESCE(description, '') as description,
+    transcode_status,
+    scan_status,
+    COALESCE(scan_result, '') as scan_result,
+    COALESCE(blurhash, '') as blurhash,
+    COALESCE(dominant_color, '') as dominant_color,
+    COALESCE(aspect_ratio, 0)::DOUBLE PRECISION as aspect_ratio,
+    COALESCE(created_at, 0)::BIGINT as created_at,
+    COALESCE(updated_at, 0)::BIGINT as updated_at,
+    deleted_at
+FROM media
+WHERE user_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: RestoreMedia :exec
+UPDATE media
+SET
+    deleted_at = NULL,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1;
+
+-- name: PurgeUserTrash :many
+-- Permanently removes every trashed item of a user, returning each item's
+-- stored names (including earlier versions) so the files can be deleted from
+-- disk.
+WITH purged AS (
+    DELETE FROM media
+    WHERE user_id = $1 AND deleted_at IS NOT NULL
+    RETURNING id, stored_name
+)
+SELECT p.id AS media_id, p.stored_name FROM purged p
+UNION ALL
+SELECT v.media_id, v.stored_name FROM media_versions v JOIN purged p ON v.media_id = p.id;
+
+-- name: PurgeExpiredTrash :many
+-- Permanently removes media that has been in the trash since before the cutoff,
+-- returning each item's stored names, including its earlier versions.
+WITH purged AS (
+    DELETE FROM media
+    WHERE deleted_at IS NOT NULL AND deleted_at < sqlc.arg(cutoff)::TIMESTAMPTZ
+    RETURNING id, stored_name
+)
+SELECT p.id AS media_id, p.stored_name FROM purged p
+UNION ALL
+SELECT v.media_id, v.stored_name FROM media_versions v JOIN purged p ON v.media_id = p.id;
+
 -- name: PermanentlyDeleteMedia :exec
 DELETE FROM media
 WHERE id = $1;
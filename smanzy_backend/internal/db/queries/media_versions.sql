@@ -0,0 +1,32 @@
+-- name: CreateMediaVersion :one
+-- Archives the current file of a media item as its next version number.
+INSERT INTO media_versions (
+    media_id, version, filename, stored_name, mime_type, size
+) VALUES (
+    $1,
+    (SELECT COALESCE(MAX(v.version), 0) + 1 FROM media_versions v WHERE v.media_id = $1),
+    $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: ListMediaVersions :many
+SELECT * FROM media_versions
+WHERE media_id = $1
+ORDER BY version DESC;
+
+-- name: GetMediaVersion :one
+SELECT * FROM media_versions
+WHERE media_id = $1 AND version = $2
+LIMIT 1;
+
+-- name: PruneMediaVersions :many
+-- Deletes all but the newest versions of a media item, returning the stored
+-- names so the files can be deleted from disk.
+DELETE FROM media_versions
+WHERE media_id = sqlc.arg(media_id) AND id NOT IN (
+    SELECT v.id FROM media_versions v
+    WHERE v.media_id = sqlc.arg(media_id)
+    ORDER BY v.version DESC
+    LIMIT sqlc.arg(keep)::INT
+)
+RETURNING stored_name;
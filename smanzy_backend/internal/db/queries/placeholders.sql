@@ -0,0 +1,18 @@
+-- name: ListMediaMissingPlaceholder :many
+-- Images and videos that still need a BlurHash placeholder, in ID order so
+-- the indexer can page through them with after_id.
+SELECT id, stored_name
+FROM media
+WHERE blurhash IS NULL
+  AND deleted_at IS NULL
+  AND (mime_type LIKE 'image/%' OR mime_type LIKE 'video/%')
+  AND id > sqlc.arg(after_id)::BIGINT
+ORDER BY id
+LIMIT sqlc.arg(max_results)::INT;
+
+-- name: SetMediaPlaceholder :exec
+UPDATE media
+SET blurhash = $2,
+    dominant_color = $3,
+    aspect_ratio = $4
+WHERE id = $1;
@@ -0,0 +1,40 @@
+-- name: ClaimScanJob :one
+-- Takes the oldest file waiting in quarantine and marks it as being scanned.
+-- SKIP LOCKED lets several API instances run scanners without picking the
+-- same file.
+UPDATE media
+SET scan_status = 'scanning', scan_claimed_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = (
+    SELECT id FROM media
+    WHERE scan_status = 'pending' AND deleted_at IS NULL
+    ORDER BY id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, stored_name;
+
+-- name: FinishScanJob :execrows
+-- Records the outcome of a scan. Infected media are moved to the trash.
+-- Nothing is updated if the file was replaced while it was being scanned.
+UPDATE media
+SET
+    scan_status = sqlc.arg(scan_status),
+    scan_result = sqlc.arg(scan_result),
+    scanned_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    deleted_at = CASE WHEN sqlc.arg(scan_status) = 'infected' THEN NOW() ELSE deleted_at END
+WHERE id = sqlc.arg(id) AND stored_name = sqlc.arg(stored_name) AND scan_status = 'scanning';
+
+-- name: RequeueScanJob :exec
+-- Puts a file back in the queue after the scanner could not be reached
+UPDATE media
+SET scan_status = 'pending', scan_result = sqlc.arg(scan_result)
+WHERE id = sqlc.arg(id) AND stored_name = sqlc.arg(stored_name) AND scan_status = 'scanning';
+
+-- name: ResetStaleScans :exec
+-- Requeues files left in scanning by a server that stopped mid-scan: those
+-- claimed before claimed_before (Unix ms). Files claimed before the claim
+-- time was recorded have none and are requeued too.
+UPDATE media
+SET scan_status = 'pending'
+WHERE scan_status = 'scanning'
+  AND (scan_claimed_at IS NULL OR scan_claimed_at < sqlc.arg(claimed_before));
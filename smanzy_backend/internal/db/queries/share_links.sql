@@ -0,0 +1,36 @@
+-- name: CreateShareLink :one
+INSERT INTO album_share_links (
+    album_id, slug, password_hash, expires_at, max_views, allow_download, created_by
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: ListAlbumShareLinks :many
+SELECT * FROM album_share_links
+WHERE album_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetShareLinkBySlug :one
+-- Revoked links and links to deleted albums are not found
+SELECT l.* FROM album_share_links l
+JOIN album a ON a.id = l.album_id
+WHERE l.slug = $1 AND l.revoked_at IS NULL AND a.deleted_at IS NULL
+LIMIT 1;
+
+-- name: RecordShareLinkView :execrows
+-- Counts a view unless the link expired, reached its view limit or was
+-- revoked meanwhile; the check and the count are one statement so
+-- concurrent views cannot exceed the limit.
+UPDATE album_share_links
+SET view_count = view_count + 1,
+    last_viewed_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT)
+  AND (max_views IS NULL OR view_count < max_views);
+
+-- name: RevokeShareLink :execrows
+UPDATE album_share_links
+SET revoked_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1 AND album_id = $2 AND revoked_at IS NULL;
@@ -0,0 +1,107 @@
+-- name: EnsureTag :one
+-- Returns the tag with the given name, creating a free-form tag if needed.
+INSERT INTO tags (name, created_by)
+VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+RETURNING *;
+
+-- name: CreateCuratedTag :one
+-- Creates a curated tag, or promotes an existing free-form tag.
+INSERT INTO tags (name, is_curated, created_by)
+VALUES ($1, TRUE, $2)
+ON CONFLICT (name) DO UPDATE SET is_curated = TRUE
+RETURNING *;
+
+-- name: SetTagCurated :one
+UPDATE tags
+SET is_curated = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteTag :execrows
+DELETE FROM tags
+WHERE id = $1;
+
+-- name: SearchTags :many
+-- Autocomplete: curated tags and tags the user has used, matching a prefix.
+SELECT t.* FROM tags t
+WHERE starts_with(t.name, sqlc.arg(prefix)::TEXT)
+  AND (
+    t.is_curated
+    OR EXISTS (
+        SELECT 1 FROM media_tags mt
+        JOIN media m ON m.id = mt.media_id
+        WHERE mt.tag_id = t.id AND m.user_id = sqlc.arg(user_id)
+    )
+    OR EXISTS (
+        SELECT 1 FROM album_tags at
+        JOIN album a ON a.id = at.album_id
+        WHERE at.tag_id = t.id AND a.user_id = sqlc.arg(user_id)
+    )
+  )
+ORDER BY t.is_curated DESC, t.name
+LIMIT sqlc.arg(max_results)::INT;
+
+-- name: ListCuratedTags :many
+SELECT * FROM tags
+WHERE is_curated = TRUE
+ORDER BY name;
+
+-- name: TagCloud :many
+-- Tag usage counts over publicly visible media that passed the malware
+-- scan, most used first.
+SELECT t.name, t.is_curated, COUNT(*) AS media_count
+FROM tags t
+JOIN media_tags mt ON mt.tag_id = t.id
+JOIN media m ON m.id = mt.media_id
+WHERE m.deleted_at IS NULL
+  AND m.scan_status = 'clean'
+  AND (
+    m.visibility = 'public'
+    OR (m.visibility = 'inherit' AND EXISTS (
+        SELECT 1 FROM album_media am
+        JOIN album a ON a.id = am.album_id
+        WHERE am.media_id = m.id AND a.is_public = TRUE AND a.deleted_at IS NULL
+    ))
+  )
+GROUP BY t.id, t.name, t.is_curated
+ORDER BY media_count DESC, t.name
+LIMIT $1;
+
+-- name: AddMediaTag :exec
+INSERT INTO media_tags (media_id, tag_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveMediaTag :execrows
+DELETE FROM media_tags mt
+USING tags t
+WHERE mt.tag_id = t.id AND mt.media_id = $1 AND t.name = $2;
+
+-- name: ListMediaTags :many
+SELECT t.* FROM tags t
+JOIN media_tags mt ON mt.tag_id = t.id
+WHERE mt.media_id = $1
+ORDER BY t.name;
+
+-- name: AddAlbumTag :exec
+INSERT INTO album_tags (album_id, tag_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: CopyAlbumTags :exec
+INSERT INTO album_tags (album_id, tag_id)
+SELECT sqlc.arg(target_id)::BIGINT, tag_id FROM album_tags
+WHERE album_id = sqlc.arg(source_id)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveAlbumTag :execrows
+DELETE FROM album_tags at
+USING tags t
+WHERE at.tag_id = t.id AND at.album_id = $1 AND t.name = $2;
+
+-- name: ListAlbumTags :many
+SELECT t.* FROM tags t
+JOIN album_tags at ON at.tag_id = t.id
+WHERE at.album_id = $1
+ORDER BY t.name;
@@ -0,0 +1,36 @@
+-- name: ClaimTranscodeJob :one
+-- Takes the oldest pending video and marks it as processing. Videos still in
+-- quarantine wait for the malware scan. SKIP LOCKED lets several API
+-- instances run transcoders without picking the same video.
+UPDATE media
+SET transcode_status = 'processing', transcode_error = NULL,
+    transcode_claimed_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = (
+    SELECT id FROM media
+    WHERE transcode_status = 'pending' AND scan_status = 'clean' AND deleted_at IS NULL
+    ORDER BY id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, stored_name;
+
+-- name: FinishTranscodeJob :execrows
+-- Records the outcome of a transcode. Nothing is updated if the file was
+-- replaced while it was being transcoded.
+UPDATE media
+SET transcode_status = sqlc.arg(transcode_status), transcode_error = sqlc.arg(transcode_error)
+WHERE id = sqlc.arg(id) AND stored_name = sqlc.arg(stored_name);
+
+-- name: ResetStaleTranscodes :exec
+-- Requeues videos left in processing by a server that stopped
+-- mid-transcode: those claimed before claimed_before (Unix ms). Videos
+-- claimed before the claim time was recorded have none and are requeued too.
+UPDATE media
+SET transcode_status = 'pending'
+WHERE transcode_status = 'processing'
+  AND (transcode_claimed_at IS NULL OR transcode_claimed_at < sqlc.arg(claimed_before));
+
+-- name: RequeueTranscode :execrows
+UPDATE media
+SET transcode_status = 'pending', transcode_error = NULL
+WHERE id = $1 AND transcode_status IN ('ready', 'failed');
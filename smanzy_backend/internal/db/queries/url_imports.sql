@@ -0,0 +1,47 @@
+-- name: CreateURLImport :one
+INSERT INTO media_url_imports (user_id, url, visibility, description)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetURLImport :one
+SELECT * FROM media_url_imports
+WHERE id = $1;
+
+-- name: ListUserURLImports :many
+SELECT * FROM media_url_imports
+WHERE user_id = $1
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: CountActiveURLImports :one
+SELECT COUNT(*) FROM media_url_imports
+WHERE user_id = $1 AND status IN ('pending', 'fetching');
+
+-- name: ClaimURLImport :one
+-- Takes the oldest pending import and marks it as fetching. SKIP LOCKED lets
+-- several API instances run importers without picking the same job.
+UPDATE media_url_imports
+SET status = 'fetching',
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = (
+    SELECT id FROM media_url_imports
+    WHERE status = 'pending'
+    ORDER BY id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishURLImport :exec
+UPDATE media_url_imports
+SET status = $2,
+    error = $3,
+    media_id = $4,
+    updated_at = (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+WHERE id = $1;
+
+-- name: ResetStaleURLImports :exec
+-- Requeues imports left in fetching by a server that stopped mid-download
+UPDATE media_url_imports
+SET status = 'pending'
+WHERE status = 'fetching';
@@ -0,0 +1,33 @@
+-- name: GetUserWatermark :one
+SELECT * FROM user_watermarks
+WHERE user_id = $1;
+
+-- name: UpsertUserWatermark :one
+INSERT INTO user_watermarks (user_id, enabled, text, position, opacity, scale, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT)
+ON CONFLICT (user_id) DO UPDATE SET
+    enabled = EXCLUDED.enabled,
+    text = EXCLUDED.text,
+    position = EXCLUDED.position,
+    opacity = EXCLUDED.opacity,
+    scale = EXCLUDED.scale,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: SetUserWatermarkLogo :one
+INSERT INTO user_watermarks (user_id, logo_name, updated_at)
+VALUES ($1, $2, (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT)
+ON CONFLICT (user_id) DO UPDATE SET
+    logo_name = EXCLUDED.logo_name,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetWatermarkForMediaKey :one
+-- The enabled watermark of the owner of a stored file, looked up by its name
+-- without extension like IsStoredMediaPublic
+SELECT w.*
+FROM user_watermarks w
+JOIN media m ON m.user_id = w.user_id
+WHERE regexp_replace(m.stored_name, '\.[^.]*$', '') = sqlc.arg(stored_key)::TEXT
+  AND w.enabled = TRUE
+LIMIT 1;
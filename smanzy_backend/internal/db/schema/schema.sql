@@ -31,6 +31,18 @@ CREATE TABLE IF NOT EXISTS user_roles (
     PRIMARY KEY (user_id, role_id)
 );
 
+-- Watermark stamped on the thumbnails and renders of a user's media served to others
+CREATE TABLE IF NOT EXISTS user_watermarks (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    text TEXT NOT NULL DEFAULT '',
+    logo_name TEXT, -- PNG in <UPLOAD_DIR>/watermarks, drawn instead of the text when set
+    position TEXT NOT NULL DEFAULT 'bottom-right' CHECK (position IN ('top-left', 'top-right', 'bottom-left', 'bottom-right', 'center')),
+    opacity DOUBLE PRECISION NOT NULL DEFAULT 0.5, -- 0.05 to 1
+    scale DOUBLE PRECISION NOT NULL DEFAULT 0.2, -- Width of the mark as a fraction of the image width
+    updated_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+);
+
 CREATE TABLE IF NOT EXISTS media (
     id BIGSERIAL PRIMARY KEY,
     filename TEXT NOT NULL,
@@ -41,9 +53,69 @@ CREATE TABLE IF NOT EXISTS media (
     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
     updated_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
-    deleted_at TIMESTAMP WITH TIME ZONE -- Soft delete
+    deleted_at TIMESTAMP WITH TIME ZONE, -- Soft delete
+    visibility TEXT NOT NULL DEFAULT 'inherit' CHECK (visibility IN ('private', 'unlisted', 'public', 'inherit')),
+    description TEXT,
+    phash BIGINT, -- 64-bit perceptual hash of the image or video keyframe, NULL until indexed
+    transcode_status TEXT NOT NULL DEFAULT 'none' CHECK (transcode_status IN ('none', 'pending', 'processing', 'ready', 'failed')),
+    transcode_error TEXT,
+    blurhash TEXT, -- BlurHash placeholder computed from the thumbnail, NULL until indexed
+    dominant_color TEXT, -- Most common color as #rrggbb
+    aspect_ratio DOUBLE PRECISION, -- Width / height of the thumbnail
+    content_hash TEXT, -- SHA-256 of the file, hex encoded; NULL for files stored before it was recorded
+    source_url TEXT, -- URL the file was imported from, NULL for uploads
+    scan_status TEXT NOT NULL DEFAULT 'clean' CHECK (scan_status IN ('pending', 'scanning', 'clean', 'infected', 'failed')),
+    scan_result TEXT, -- Signature found, or why the scan failed
+    scanned_at BIGINT,
+    captured_at BIGINT, -- When the photo was taken (EXIF, Unix ms), NULL if unknown
+    scan_claimed_at BIGINT, -- When a scanner took the file (Unix ms), so files left scanning can be requeued
+    transcode_claimed_at BIGINT -- When a transcoder took the video (Unix ms), so videos left processing can be requeued
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_visibility ON media(visibility) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_media_scan_pending ON media(id) WHERE scan_status = 'pending' AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_media_transcode_pending ON media(id) WHERE transcode_status = 'pending' AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_media_user_content_hash ON media(user_id, content_hash) WHERE content_hash IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_media_user_phash ON media(user_id) WHERE phash IS NOT NULL AND deleted_at IS NULL;
+-- Signing key lookups (expression must match IsStoredMediaPublic)
+CREATE INDEX IF NOT EXISTS idx_media_stored_key ON media ((regexp_replace(stored_name, '\.[^.]*$', ''))) WHERE deleted_at IS NULL;
+
+-- Full-text search (expression must match internal/services/search.go)
+CREATE INDEX IF NOT EXISTS idx_media_search ON media USING GIN ((
+    setweight(to_tsvector('simple', regexp_replace(filename, '[._-]+', ' ', 'g')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+));
+
+-- Earlier versions of a media file, kept when the file is replaced
+CREATE TABLE IF NOT EXISTS media_versions (
+    id BIGSERIAL PRIMARY KEY,
+    media_id BIGINT NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    stored_name TEXT NOT NULL,
+    mime_type TEXT NOT NULL DEFAULT '',
+    size BIGINT NOT NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    UNIQUE (media_id, version)
 );
 
+-- Background jobs fetching media from remote URLs
+CREATE TABLE IF NOT EXISTS media_url_imports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'inherit',
+    description TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'fetching', 'done', 'failed')),
+    error TEXT,
+    media_id BIGINT REFERENCES media(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    updated_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_url_imports_pending ON media_url_imports(id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_media_url_imports_user ON media_url_imports(user_id, created_at DESC);
+
 CREATE TABLE IF NOT EXISTS album (
     id BIGSERIAL PRIMARY KEY,
     title TEXT NOT NULL,
@@ -53,15 +125,84 @@ CREATE TABLE IF NOT EXISTS album (
     is_shared BOOLEAN DEFAULT FALSE,
     created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
     updated_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
-    deleted_at TIMESTAMP WITH TIME ZONE -- Soft delete
+    deleted_at TIMESTAMP WITH TIME ZONE, -- Soft delete
+    sort_mode TEXT NOT NULL DEFAULT 'manual' CHECK (sort_mode IN ('manual', 'captured', 'uploaded', 'name')),
+    cover_media_id BIGINT REFERENCES media(id) ON DELETE SET NULL, -- NULL uses the first media
+    parent_id BIGINT REFERENCES album(id) ON DELETE SET NULL, -- NULL for top-level albums
+    smart_rules TEXT -- JSON rule definition of a smart album, NULL for curated albums
 );
 
+CREATE INDEX IF NOT EXISTS idx_album_parent_id ON album(parent_id) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_album_search ON album USING GIN ((
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+));
+
 CREATE TABLE IF NOT EXISTS album_media (
     album_id BIGINT NOT NULL REFERENCES album(id) ON DELETE CASCADE,
     media_id BIGINT NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    position INT NOT NULL DEFAULT 0, -- Manual order within the album
     PRIMARY KEY (album_id, media_id)
 );
 
+CREATE INDEX IF NOT EXISTS idx_album_media_position ON album_media(album_id, position);
+
+-- Collaborators: users other than the owner who can view or change an album
+CREATE TABLE IF NOT EXISTS album_members (
+    album_id BIGINT NOT NULL REFERENCES album(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'contributor', 'editor')),
+    added_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    PRIMARY KEY (album_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_album_members_user_id ON album_members(user_id);
+
+-- Share links open an album for anyone who has the link (and its password)
+CREATE TABLE IF NOT EXISTS album_share_links (
+    id BIGSERIAL PRIMARY KEY,
+    album_id BIGINT NOT NULL REFERENCES album(id) ON DELETE CASCADE,
+    slug TEXT UNIQUE NOT NULL,
+    password_hash TEXT, -- bcrypt; NULL when the link needs no password
+    expires_at BIGINT, -- Unix ms; NULL never expires
+    max_views INT, -- NULL for unlimited views
+    view_count INT NOT NULL DEFAULT 0,
+    allow_download BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
+    last_viewed_at BIGINT,
+    revoked_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_album_share_links_album_id ON album_share_links(album_id);
+
+-- Tags: free-form tags are created on first use, curated tags are managed by admins
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    is_curated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS media_tags (
+    media_id BIGINT NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (media_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_tags_tag_id ON media_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS album_tags (
+    album_id BIGINT NOT NULL REFERENCES album(id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (album_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_album_tags_tag_id ON album_tags(tag_id);
+
 CREATE TABLE IF NOT EXISTS videos (
     id BIGSERIAL PRIMARY KEY,
     video_id TEXT UNIQUE NOT NULL,
@@ -75,3 +216,8 @@ CREATE TABLE IF NOT EXISTS videos (
     updated_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM NOW()) * 1000)::BIGINT,
     deleted_at TIMESTAMP WITH TIME ZONE -- Soft delete
 );
+
+CREATE INDEX IF NOT EXISTS idx_videos_search ON videos USING GIN ((
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'B')
+));
@@ -0,0 +1,147 @@
+// Package exif reads the capture time from the EXIF metadata of JPEG photos.
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// EXIF tags read by DateTaken
+const (
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+)
+
+// maxSegments bounds the number of JPEG segments skipped looking for EXIF
+const maxSegments = 32
+
+// dateLayout is how EXIF writes times. They carry no zone.
+const dateLayout = "2006:01:02 15:04:05"
+
+// DateTaken returns when a JPEG photo was taken, from its DateTimeOriginal
+// tag, falling back to DateTimeDigitized and DateTime. It reports false for
+// files that are not JPEGs or have no usable time. EXIF times have no zone,
+// so they are read as UTC.
+func DateTaken(r io.Reader) (time.Time, bool) {
+	segment, ok := exifSegment(bufio.NewReader(r))
+	if !ok {
+		return time.Time{}, false
+	}
+	return parseTIFF(segment)
+}
+
+// exifSegment returns the TIFF data of the APP1 EXIF segment, which comes
+// before the image data
+func exifSegment(r *bufio.Reader) ([]byte, bool) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, false
+	}
+
+	for i := 0; i < maxSegments; i++ {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0xFF {
+			return nil, false
+		}
+		marker := header[1]
+		if marker == 0xDA || marker == 0xD9 { // Start of scan, end of image
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if length < 0 {
+			return nil, false
+		}
+		if marker != 0xE1 {
+			if _, err := r.Discard(length); err != nil {
+				return nil, false
+			}
+			continue
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, false
+		}
+		if bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+			return data[6:], true
+		}
+	}
+	return nil, false
+}
+
+// parseTIFF reads the capture time from EXIF TIFF data
+func parseTIFF(data []byte) (time.Time, bool) {
+	if len(data) < 8 {
+		return time.Time{}, false
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return time.Time{}, false
+	}
+
+	ifd0 := readIFD(data, order, order.Uint32(data[4:]))
+	if entry, ok := ifd0[tagExifIFD]; ok {
+		exifIFD := readIFD(data, order, order.Uint32(entry[8:]))
+		for _, tag := range []uint16{tagDateTimeOriginal, tagDateTimeDigitized} {
+			if t, ok := parseDate(data, order, exifIFD[tag]); ok {
+				return t, true
+			}
+		}
+	}
+	return parseDate(data, order, ifd0[tagDateTime])
+}
+
+// readIFD returns the entries of the image file directory at offset by tag.
+// Each entry is the 12 raw bytes: tag, type, count and value or offset.
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	if uint64(offset)+2 > uint64(len(data)) {
+		return nil
+	}
+	count := int(order.Uint16(data[offset:]))
+	entries := make(map[uint16][]byte, count)
+	for i := 0; i < count; i++ {
+		start := uint64(offset) + 2 + uint64(i)*12
+		if start+12 > uint64(len(data)) {
+			break
+		}
+		entry := data[start : start+12]
+		entries[order.Uint16(entry)] = entry
+	}
+	return entries
+}
+
+// parseDate reads an ASCII date entry. Values longer than four bytes are
+// stored at an offset.
+func parseDate(data []byte, order binary.ByteOrder, entry []byte) (time.Time, bool) {
+	const typeASCII = 2
+	if len(entry) != 12 || order.Uint16(entry[2:]) != typeASCII {
+		return time.Time{}, false
+	}
+	count := uint64(order.Uint32(entry[4:]))
+	if count < uint64(len(dateLayout)) {
+		return time.Time{}, false
+	}
+	offset := uint64(order.Uint32(entry[8:]))
+	if offset+uint64(len(dateLayout)) > uint64(len(data)) {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(dateLayout, string(data[offset:offset+uint64(len(dateLayout))]))
+	if err != nil || t.Year() < 1900 {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,114 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+// ifdEntry is one entry of a test image file directory
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value uint32
+}
+
+// buildJPEG returns a JPEG whose EXIF segment has DateTime in IFD0 and, when
+// original is not empty, DateTimeOriginal in the EXIF IFD
+func buildJPEG(t *testing.T, order binary.ByteOrder, dateTime, original string) []byte {
+	t.Helper()
+
+	// Layout: header (8), IFD0 with two entries (2+24+4), EXIF IFD with one
+	// entry (2+12+4), then the two strings
+	const ifd0 = 8
+	const exifIFD = ifd0 + 2 + 2*12 + 4
+	const strings = exifIFD + 2 + 12 + 4
+
+	var tiff bytes.Buffer
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	_ = binary.Write(&tiff, order, uint16(42))
+	_ = binary.Write(&tiff, order, uint32(ifd0))
+
+	writeIFD := func(entries ...ifdEntry) {
+		_ = binary.Write(&tiff, order, uint16(len(entries)))
+		for _, e := range entries {
+			_ = binary.Write(&tiff, order, e)
+		}
+		_ = binary.Write(&tiff, order, uint32(0))
+	}
+	writeIFD(
+		ifdEntry{tagDateTime, 2, uint32(len(dateTime) + 1), strings},
+		ifdEntry{tagExifIFD, 4, 1, exifIFD},
+	)
+	if original != "" {
+		writeIFD(ifdEntry{tagDateTimeOriginal, 2, uint32(len(original) + 1), uint32(strings + len(dateTime) + 1)})
+	} else {
+		writeIFD()
+		tiff.Write(make([]byte, 12))
+	}
+	tiff.WriteString(dateTime + "\x00" + original + "\x00")
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(img.Bytes()[:2]) // SOI
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(img.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestDateTaken_PrefersDateTimeOriginal(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := buildJPEG(t, order, "2024:08:01 10:00:00", "2023:07:14 18:30:05")
+		got, ok := DateTaken(bytes.NewReader(data))
+		if !ok {
+			t.Fatalf("%v: no capture time found", order)
+		}
+		want := time.Date(2023, 7, 14, 18, 30, 5, 0, time.UTC)
+		if !got.Equal(want) {
+			t.Errorf("%v: got %v, want %v", order, got, want)
+		}
+	}
+}
+
+func TestDateTaken_FallsBackToDateTime(t *testing.T) {
+	data := buildJPEG(t, binary.LittleEndian, "2024:08:01 10:00:00", "")
+	got, ok := DateTaken(bytes.NewReader(data))
+	if !ok || !got.Equal(time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %v, %v", got, ok)
+	}
+}
+
+func TestDateTaken_NoExif(t *testing.T) {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := DateTaken(bytes.NewReader(img.Bytes())); ok {
+		t.Error("a JPEG without EXIF should have no capture time")
+	}
+	if _, ok := DateTaken(bytes.NewReader([]byte("not an image"))); ok {
+		t.Error("a non-JPEG should have no capture time")
+	}
+}
+
+func TestDateTaken_BlankDate(t *testing.T) {
+	data := buildJPEG(t, binary.BigEndian, "    :  :     :  :  ", "0000:00:00 00:00:00")
+	if _, ok := DateTaken(bytes.NewReader(data)); ok {
+		t.Error("blank EXIF dates should be ignored")
+	}
+}
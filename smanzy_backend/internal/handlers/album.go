@@ -1,41 +1,89 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/auth"
+	"github.com/ristep/smanzy_backend/internal/mappers"
 	"github.com/ristep/smanzy_backend/internal/models"
 	"github.com/ristep/smanzy_backend/internal/services"
 )
 
+// coverThumbnailSize is the thumbnail linked as an album's cover
+const coverThumbnailSize = "320x200"
+
 // AlbumHandler handles album-related HTTP requests
 type AlbumHandler struct {
 	albumService *services.AlbumService
+	signer       *auth.URLSigner
 }
 
 // NewAlbumHandler creates a new album handler
-func NewAlbumHandler(conn *sql.DB, queries *db.Queries) *AlbumHandler {
+func NewAlbumHandler(albumService *services.AlbumService, signer *auth.URLSigner) *AlbumHandler {
 	return &AlbumHandler{
-		albumService: services.NewAlbumService(conn, queries),
+		albumService: albumService,
+		signer:       signer,
+	}
+}
+
+// signCovers fills in the signed cover thumbnail links of album listings.
+// Like media thumbnails, they are watermarked unless the viewer owns the
+// cover or is an admin.
+func (ah *AlbumHandler) signCovers(albums []models.Album, viewer *models.User) {
+	for i := range albums {
+		album := &albums[i]
+		name := album.CoverStoredName
+		if name == "" || !services.HasThumbnails(name) {
+			continue
+		}
+		url := mappers.GetThumbnailURL(name, coverThumbnailSize)
+		if viewer.ID == album.CoverOwnerID || viewer.HasRole("admin") {
+			album.CoverThumbnail = ah.signer.SignURL(url+"?clean=1", auth.CleanMediaKey(name))
+		} else {
+			album.CoverThumbnail = ah.signer.SignURL(url, auth.MediaKey(name))
+		}
+	}
+}
+
+// writeAlbumError maps album service errors to HTTP responses
+func writeAlbumError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAlbumNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Album not found"})
+	case errors.Is(err, services.ErrShareLinkNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Share link not found"})
+	case errors.Is(err, services.ErrShareLinkExpired):
+		c.JSON(http.StatusGone, ErrorResponse{Error: "Share link has expired"})
+	case errors.Is(err, services.ErrSharePassword):
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Password required or wrong"})
+	case errors.Is(err, services.ErrAlbumMemberNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Member not found"})
+	case errors.Is(err, services.ErrMediaNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+	case errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+	case errors.Is(err, services.ErrInvalidAlbum):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
 	}
 }
 
 // CreateAlbumHandler handles creating a new album
 func (ah *AlbumHandler) CreateAlbumHandler(c *gin.Context) {
 	// Get current user
-	authUser, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
-	user := authUser.(*models.User)
 
 	var req struct {
 		Title       string `json:"title" binding:"required"`
 		Description string `json:"description"`
+		ParentID    uint   `json:"parent_id"` // Optional; needs Contributor access to the parent
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -43,16 +91,23 @@ func (ah *AlbumHandler) CreateAlbumHandler(c *gin.Context) {
 		return
 	}
 
-	album, err := ah.albumService.CreateAlbum(c.Request.Context(), user.ID, req.Title, req.Description, user.Name)
+	var album *models.Album
+	var err error
+	if req.ParentID != 0 {
+		album, err = ah.albumService.CreateAlbumIn(c.Request.Context(), user, req.ParentID, req.Title, req.Description)
+	} else {
+		album, err = ah.albumService.CreateAlbum(c.Request.Context(), user.ID, req.Title, req.Description, user.Name)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		writeAlbumError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, album)
 }
 
-// GetAlbumHandler retrieves a specific album by ID
+// GetAlbumHandler retrieves a specific album by ID (Owner, Admin,
+// collaborators, or anyone if the album is public or shared)
 func (ah *AlbumHandler) GetAlbumHandler(c *gin.Context) {
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -60,9 +115,59 @@ func (ah *AlbumHandler) GetAlbumHandler(c *gin.Context) {
 		return
 	}
 
-	album, err := ah.albumService.GetAlbumByID(c.Request.Context(), uint(albumID))
+	album, err := ah.albumService.GetAlbumByID(c.Request.Context(), optionalUser(c), uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// GetAlbumTreeHandler returns an album with the sub-albums the caller can
+// see, nested, with media counts
+func (ah *AlbumHandler) GetAlbumTreeHandler(c *gin.Context) {
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	tree, err := ah.albumService.GetAlbumTree(c.Request.Context(), optionalUser(c), uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tree})
+}
+
+// MoveAlbumHandler moves an album under another album, or to the top level
+// with parent_id 0 (Owner or Admin, and Contributor access to the new parent)
+func (ah *AlbumHandler) MoveAlbumHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	var req struct {
+		ParentID uint `json:"parent_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	album, err := ah.albumService.MoveAlbum(c.Request.Context(), user, uint(albumID), req.ParentID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		writeAlbumError(c, err)
 		return
 	}
 
@@ -72,12 +177,10 @@ func (ah *AlbumHandler) GetAlbumHandler(c *gin.Context) {
 // GetUserAlbumsHandler retrieves all albums for the current user
 func (ah *AlbumHandler) GetUserAlbumsHandler(c *gin.Context) {
 	// Get current user
-	authUser, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
-	user := authUser.(*models.User)
 
 	albums, err := ah.albumService.GetUserAlbums(c.Request.Context(), user.ID)
 
@@ -89,6 +192,7 @@ func (ah *AlbumHandler) GetUserAlbumsHandler(c *gin.Context) {
 	if albums == nil {
 		albums = []models.Album{}
 	}
+	ah.signCovers(albums, user)
 
 	// Return albums
 	c.JSON(http.StatusOK, albums)
@@ -96,6 +200,11 @@ func (ah *AlbumHandler) GetUserAlbumsHandler(c *gin.Context) {
 
 // GetAllAlbumsHandler retrieves all albums from all users (admin only)
 func (ah *AlbumHandler) GetAllAlbumsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	albums, err := ah.albumService.GetAllAlbums(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
@@ -105,12 +214,18 @@ func (ah *AlbumHandler) GetAllAlbumsHandler(c *gin.Context) {
 	if albums == nil {
 		albums = []models.Album{}
 	}
+	ah.signCovers(albums, user)
 
 	c.JSON(http.StatusOK, albums)
 }
 
-// UpdateAlbumHandler updates an album's details
+// UpdateAlbumHandler updates an album's details (Editor, Owner or Admin)
 func (ah *AlbumHandler) UpdateAlbumHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
@@ -118,8 +233,12 @@ func (ah *AlbumHandler) UpdateAlbumHandler(c *gin.Context) {
 	}
 
 	var req struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
+		Title        string  `json:"title"`
+		Description  *string `json:"description"`    // Left out keeps the current description
+		IsPublic     *bool   `json:"is_public"`      // Listed at /api/public/albums (Owner or Admin)
+		IsShared     *bool   `json:"is_shared"`      // Viewable by anyone with its ID (Owner or Admin)
+		SortMode     *string `json:"sort_mode"`      // manual, captured, uploaded or name
+		CoverMediaID *uint   `json:"cover_media_id"` // 0 clears the cover
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -127,17 +246,30 @@ func (ah *AlbumHandler) UpdateAlbumHandler(c *gin.Context) {
 		return
 	}
 
-	album, err := ah.albumService.UpdateAlbum(c.Request.Context(), uint(albumID), req.Title, req.Description)
+	album, err := ah.albumService.UpdateAlbum(c.Request.Context(), user, uint(albumID), services.AlbumUpdate{
+		Title:        req.Title,
+		Description:  req.Description,
+		IsPublic:     req.IsPublic,
+		IsShared:     req.IsShared,
+		SortMode:     req.SortMode,
+		CoverMediaID: req.CoverMediaID,
+	})
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		writeAlbumError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, album)
 }
 
-// AddMediaToAlbumHandler adds a media file to an album
+// AddMediaToAlbumHandler adds one of the caller's media files to an album
+// (Contributor, Editor, Owner or Admin)
 func (ah *AlbumHandler) AddMediaToAlbumHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
@@ -153,16 +285,54 @@ func (ah *AlbumHandler) AddMediaToAlbumHandler(c *gin.Context) {
 		return
 	}
 
-	if err := ah.albumService.AddMediaToAlbum(c.Request.Context(), uint(albumID), req.MediaID); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	if err := ah.albumService.AddMediaToAlbum(c.Request.Context(), user, uint(albumID), req.MediaID); err != nil {
+		writeAlbumError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Media added to album successfully"})
 }
 
-// RemoveMediaFromAlbumHandler removes a media file from an album
+// ReorderAlbumMediaHandler sets the manual order of an album's media (Editor,
+// Owner or Admin). The listed media come first, in the given order, followed
+// by the rest in their current order.
+func (ah *AlbumHandler) ReorderAlbumMediaHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	var req struct {
+		MediaIDs []uint `json:"media_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := ah.albumService.ReorderAlbumMedia(c.Request.Context(), user, uint(albumID), req.MediaIDs); err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Album media reordered successfully"})
+}
+
+// RemoveMediaFromAlbumHandler removes a media file from an album (Editor,
+// Owner or Admin; contributors can remove their own media)
 func (ah *AlbumHandler) RemoveMediaFromAlbumHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
@@ -178,24 +348,29 @@ func (ah *AlbumHandler) RemoveMediaFromAlbumHandler(c *gin.Context) {
 		return
 	}
 
-	if err := ah.albumService.RemoveMediaFromAlbum(c.Request.Context(), uint(albumID), req.MediaID); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	if err := ah.albumService.RemoveMediaFromAlbum(c.Request.Context(), user, uint(albumID), req.MediaID); err != nil {
+		writeAlbumError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Media removed from album successfully"})
 }
 
-// DeleteAlbumHandler soft deletes an album
+// DeleteAlbumHandler soft deletes an album (Owner or Admin)
 func (ah *AlbumHandler) DeleteAlbumHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
 		return
 	}
 
-	if err := ah.albumService.DeleteAlbum(c.Request.Context(), uint(albumID)); err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+	if err := ah.albumService.DeleteAlbum(c.Request.Context(), user, uint(albumID)); err != nil {
+		writeAlbumError(c, err)
 		return
 	}
 
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// DuplicateAlbumHandler copies an album the caller may view. The body is
+// optional: a new title, and for admins the user who gets the copy.
+func (ah *AlbumHandler) DuplicateAlbumHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	var req struct {
+		Title  string `json:"title"`   // Defaults to "<title> (copy)"
+		UserID uint   `json:"user_id"` // Admins only
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	album, err := ah.albumService.DuplicateAlbum(c.Request.Context(), user, uint(albumID), services.AlbumDuplicate{
+		Title:   req.Title,
+		OwnerID: req.UserID,
+	})
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, album)
+}
+
+// MergeAlbumsHandler merges albums into another and deletes them (Editor,
+// Owner or Admin of the target; Owner or Admin of every source)
+func (ah *AlbumHandler) MergeAlbumsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		TargetID  uint   `json:"target_id" binding:"required"`
+		SourceIDs []uint `json:"source_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	album, err := ah.albumService.MergeAlbums(c.Request.Context(), user, req.TargetID, req.SourceIDs)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddAlbumMemberRequest represents an invitation to collaborate on an album.
+// The user is given by ID or by email.
+type AddAlbumMemberRequest struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role" binding:"required"` // viewer, contributor or editor
+}
+
+// UpdateAlbumMemberRequest represents a change of a collaborator's role
+type UpdateAlbumMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ListAlbumMembersHandler lists the collaborators on an album (Owner or Admin)
+func (ah *AlbumHandler) ListAlbumMembersHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	members, err := ah.albumService.ListMembers(c.Request.Context(), user, uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: members})
+}
+
+// AddAlbumMemberHandler invites a user to collaborate on an album (Owner or Admin)
+func (ah *AlbumHandler) AddAlbumMemberHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	var req AddAlbumMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.UserID == 0 && req.Email == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "user_id or email is required"})
+		return
+	}
+
+	member, err := ah.albumService.AddMember(c.Request.Context(), user, uint(albumID), req.UserID, req.Email, req.Role)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{Data: member})
+}
+
+// UpdateAlbumMemberHandler changes the role of a collaborator (Owner or Admin)
+func (ah *AlbumHandler) UpdateAlbumMemberHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+	memberID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid user ID"})
+		return
+	}
+
+	var req UpdateAlbumMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	member, err := ah.albumService.AddMember(c.Request.Context(), user, uint(albumID), uint(memberID), "", req.Role)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: member})
+}
+
+// RemoveAlbumMemberHandler removes a collaborator from an album (Owner or
+// Admin, or the collaborator leaving)
+func (ah *AlbumHandler) RemoveAlbumMemberHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+	memberID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid user ID"})
+		return
+	}
+
+	if err := ah.albumService.RemoveMember(c.Request.Context(), user, uint(albumID), uint(memberID)); err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Member removed"}})
+}
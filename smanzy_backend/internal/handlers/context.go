@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// currentUser returns the authenticated user, writing a 401 if there is none
+func currentUser(c *gin.Context) (*models.User, bool) {
+	authUser, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		return nil, false
+	}
+	return authUser.(*models.User), true
+}
+
+// optionalUser returns the authenticated user, or nil for anonymous requests
+func optionalUser(c *gin.Context) *models.User {
+	if authUser, exists := c.Get("user"); exists {
+		return authUser.(*models.User)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// DownloadMediaRequest lists the media files to download as a ZIP
+type DownloadMediaRequest struct {
+	IDs      []uint `json:"ids" binding:"required"`
+	Manifest *bool  `json:"manifest"` // Add manifest.json; true if left out
+}
+
+// streamArchive sends media files as a ZIP download, built while it is sent.
+// Once streaming has started errors can only cut the download short. Images
+// the user does not own carry their owner's watermark, as with
+// ServeFileHandler; admins get every file as uploaded.
+func (mh *MediaHandler) streamArchive(c *gin.Context, user *models.User, name string, media []db.Medium, opts services.ArchiveOptions) {
+	opts.Source = func(m db.Medium) (string, error) {
+		return mh.mediaFilePath(c.Request.Context(), user, m.UserID, m.StoredName)
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	if err := services.WriteArchive(c.Writer, mh.uploadDir, media, opts); err != nil {
+		log.Printf("Streaming archive %q failed: %v", name, err)
+	}
+}
+
+// DownloadAlbumHandler downloads the media of an album the caller may view
+// as a ZIP, in the album's order. Add manifest=false to leave out
+// manifest.json.
+func (mh *MediaHandler) DownloadAlbumHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+	manifest, err := strconv.ParseBool(c.DefaultQuery("manifest", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "manifest must be true or false"})
+		return
+	}
+
+	album, err := mh.albums.GetAlbumByID(c.Request.Context(), user, uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	media, err := mh.albums.ListAlbumMedia(c.Request.Context(), user, uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	mh.streamArchive(c, user, services.SafeFileName(album.Title, fmt.Sprintf("album-%d", album.ID)), media, services.ArchiveOptions{
+		AlbumID:    album.ID,
+		AlbumTitle: album.Title,
+		Manifest:   manifest,
+	})
+}
+
+// DownloadMediaHandler downloads a list of media files as a ZIP, in the
+// given order. The caller must be able to open every file, as with
+// GetMediaHandler.
+func (mh *MediaHandler) DownloadMediaHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req DownloadMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > services.MaxDownloadMedia {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Give 1 to %d media IDs", services.MaxDownloadMedia)})
+		return
+	}
+
+	// Everything is checked before the download starts
+	ids := make([]string, len(req.IDs))
+	for i, id := range req.IDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	rows, err := mh.queries.ListMediaByIDs(c.Request.Context(), strings.Join(ids, ","))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+	byID := make(map[int64]db.Medium, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+
+	media := make([]db.Medium, 0, len(rows))
+	seen := make(map[uint]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		mediaRow, found := byID[int64(id)]
+		if !found {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("Media %d not found", id)})
+			return
+		}
+		allowed, err := mh.canAccessMedia(c.Request.Context(), user, mediaRow.UserID, mediaRow.StoredName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: fmt.Sprintf("Forbidden: media %d", id)})
+			return
+		}
+		media = append(media, mediaRow)
+	}
+
+	mh.streamArchive(c, user, "media", media, services.ArchiveOptions{
+		Manifest: req.Manifest == nil || *req.Manifest,
+	})
+}
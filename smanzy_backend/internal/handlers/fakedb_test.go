@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeRows are the rows a fake database returns for one query
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// newFakeDB returns a database that answers sqlc queries by name
+// ("GetMediaByID") with fixed rows. Any other query fails the test.
+func newFakeDB(t *testing.T, results map[string]fakeRows) *sql.DB {
+	t.Helper()
+	conn := sql.OpenDB(fakeConnector{t: t, results: results})
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+type fakeConnector struct {
+	t       *testing.T
+	results map[string]fakeRows
+}
+
+func (fc fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(fc), nil }
+func (fc fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn fakeConnector
+
+func (fc fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake database: prepared statements are not supported")
+}
+func (fc fakeConn) Close() error { return nil }
+func (fc fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake database: transactions are not supported")
+}
+
+func (fc fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(query, "-- name: "), " ")
+	result, ok := fc.results[name]
+	if !ok {
+		fc.t.Errorf("fake database: unexpected query %s", name)
+		return nil, fmt.Errorf("fake database: unexpected query %s", name)
+	}
+	return &fakeCursor{fakeRows: result}, nil
+}
+
+type fakeCursor struct {
+	fakeRows
+	next int
+}
+
+func (fc *fakeCursor) Columns() []string { return fc.columns }
+func (fc *fakeCursor) Close() error      { return nil }
+
+func (fc *fakeCursor) Next(dest []driver.Value) error {
+	if fc.next >= len(fc.rows) {
+		return io.EOF
+	}
+	copy(dest, fc.rows[fc.next])
+	fc.next++
+	return nil
+}
@@ -1,59 +1,198 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/auth"
 	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
 	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
 )
 
 // MediaHandler handles media-related HTTP requests
 type MediaHandler struct {
-	conn      *sql.DB
-	queries   *db.Queries
-	uploadDir string
+	conn         *sql.DB
+	queries      *db.Queries
+	signer       *auth.URLSigner
+	mediaService *services.MediaService
+	albums       *services.AlbumService
+	renderer     *services.RenderService
+	urlImporter  *services.URLImportService
+	watermarks   *services.WatermarkService
+	uploadDir    string
 }
 
 // NewMediaHandler creates a new media handler
-func NewMediaHandler(conn *sql.DB, queries *db.Queries) *MediaHandler {
+func NewMediaHandler(conn *sql.DB, queries *db.Queries, signer *auth.URLSigner, mediaService *services.MediaService, albums *services.AlbumService, renderer *services.RenderService, urlImporter *services.URLImportService, watermarks *services.WatermarkService) *MediaHandler {
 	// Allow configuring upload directory via environment variable.
 	// In containers, prefer an absolute path like /app/uploads.
 	uploadDir := os.Getenv("UPLOAD_DIR")
 
-	if uploadDir == "" {
-		uploadDir = os.Getenv("UPLOAD_DIR")
-	}
-
-	// Ensure upload directory exists (fail loudly if it cannot be created)
+	// Ensure upload and quarantine directories exist (fail loudly if they cannot be created)
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		fmt.Printf("ERROR: failed to create upload directory %q: %v\n", uploadDir, err)
+	} else if err := os.MkdirAll(services.QuarantineDir(uploadDir), 0755); err != nil {
+		fmt.Printf("ERROR: failed to create quarantine directory: %v\n", err)
 	}
 
 	fmt.Printf("Media uploads directory: %s\n", uploadDir)
 
 	return &MediaHandler{
-		conn:      conn,
-		queries:   queries,
-		uploadDir: uploadDir,
+		conn:         conn,
+		queries:      queries,
+		signer:       signer,
+		mediaService: mediaService,
+		albums:       albums,
+		renderer:     renderer,
+		urlImporter:  urlImporter,
+		watermarks:   watermarks,
+		uploadDir:    uploadDir,
+	}
+}
+
+// signMedia fills in the signed, expiring URLs for a media item: the file,
+// its HLS stream once transcoded, and the thumbnails that exist on disk.
+// The same signature unlocks all of them. Files still in quarantine get none.
+// The owner and admins get links to the file as uploaded and thumbnails
+// without the owner's watermark; everyone else gets watermarked images.
+// viewer is nil for anonymous requests.
+func (mh *MediaHandler) signMedia(media *models.Media, viewer *models.User) {
+	if media.ScanStatus != "" && media.ScanStatus != services.ScanClean {
+		// Nothing is served until the file passed the malware scan
+		media.URL, media.StreamURL, media.Thumbnails = "", "", nil
+		media.ThumbnailStatus = services.ThumbnailNone
+		if media.ScanStatus == services.ScanPending || media.ScanStatus == services.ScanScanning {
+			media.ThumbnailStatus = services.ThumbnailPending
+		}
+		return
+	}
+
+	clean := viewer != nil && (viewer.ID == media.UserID || viewer.HasRole("admin"))
+	if clean {
+		media.URL = mh.signer.SignURL(mappers.GetMediaURL(media.StoredName)+"?clean=1", auth.CleanMediaKey(media.StoredName))
+	} else {
+		media.URL = mh.signer.SignURL(mappers.GetMediaURL(media.StoredName), auth.MediaKey(media.StoredName))
+	}
+	if media.TranscodeStatus == services.TranscodeReady {
+		media.StreamURL = mh.signer.SignURL(mappers.GetStreamURL(media.ID), auth.MediaKey(media.StoredName))
+	}
+
+	status, sizes := services.ThumbnailState(mh.uploadDir, media.StoredName, media.UpdatedAt)
+	media.ThumbnailStatus = status
+	if clean {
+		media.Thumbnails = mh.signThumbnails(media.StoredName, sizes, "?clean=1", auth.CleanMediaKey(media.StoredName))
+	} else {
+		media.Thumbnails = mh.signThumbnails(media.StoredName, sizes, "", auth.MediaKey(media.StoredName))
+	}
+}
+
+// signPreview fills in watermarked thumbnail links only, signed so they
+// cannot be used to fetch the file or its stream
+func (mh *MediaHandler) signPreview(media *models.Media) {
+	mh.signMedia(media, nil)
+	media.URL, media.StreamURL = "", ""
+
+	var sizes []string
+	for _, size := range services.ThumbnailSizes {
+		if _, ok := media.Thumbnails[services.ThumbnailSizeNames[size]]; ok {
+			sizes = append(sizes, size)
+		}
+	}
+	media.Thumbnails = mh.signThumbnails(media.StoredName, sizes, "?preview=1", auth.PreviewMediaKey(media.StoredName))
+}
+
+// signThumbnails returns the signed links to the given thumbnail sizes by
+// size name, or nil if there are none
+func (mh *MediaHandler) signThumbnails(storedName string, sizes []string, query, key string) map[string]string {
+	if len(sizes) == 0 {
+		return nil
+	}
+	thumbnails := make(map[string]string, len(sizes))
+	for _, size := range sizes {
+		thumbnails[services.ThumbnailSizeNames[size]] = mh.signer.SignURL(mappers.GetThumbnailURL(storedName, size)+query, key)
+	}
+	return thumbnails
+}
+
+// canAccessMedia reports whether a user may read a media item.
+// Owners and admins always can; everyone else only if it is public (directly,
+// or inherited from a public album). Unlisted media is only reached through
+// the signed URLs handed out with it.
+func (mh *MediaHandler) canAccessMedia(ctx context.Context, user *models.User, ownerID int64, storedName string) (bool, error) {
+	if user != nil && (int64(user.ID) == ownerID || user.HasRole("admin")) {
+		return true, nil
+	}
+	return mh.queries.IsStoredMediaPublic(ctx, auth.MediaKey(storedName))
+}
+
+// mediaFilePath returns the file of a media item to serve to a user: the file
+// as uploaded for the owner and admins, and a watermarked copy of images for
+// everyone else, as ServeFileHandler does for links without clean=1
+func (mh *MediaHandler) mediaFilePath(ctx context.Context, user *models.User, ownerID int64, storedName string) (string, error) {
+	if mh.watermarks == nil || (user != nil && (int64(user.ID) == ownerID || user.HasRole("admin"))) {
+		return filepath.Join(mh.uploadDir, storedName), nil
+	}
+	return mh.watermarks.Original(ctx, storedName)
+}
+
+// rejectUnscanned writes an error response and returns true when the file of
+// a media item has not passed the malware scan
+func rejectUnscanned(c *gin.Context, scanStatus string) bool {
+	switch scanStatus {
+	case services.ScanClean:
+		return false
+	case services.ScanPending, services.ScanScanning:
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "File is waiting for the malware scan"})
+	case services.ScanInfected:
+		c.JSON(http.StatusGone, ErrorResponse{Error: "File was rejected by the malware scan"})
+	default:
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "File could not be scanned for malware"})
 	}
+	return true
+}
+
+// authorizeFileRequest checks the signature on a file request, or falls back
+// to allowing unsigned access for public media. It writes the error response
+// itself and returns false when access is denied.
+func (mh *MediaHandler) authorizeFileRequest(c *gin.Context, key string) bool {
+	if sig := c.Query("sig"); sig != "" {
+		if err := mh.signer.Verify(key, c.Query("expires"), sig); err != nil {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Invalid or expired link"})
+			return false
+		}
+		return true
+	}
+
+	public, err := mh.queries.IsStoredMediaPublic(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return false
+	}
+	if !public {
+		// Same response as a missing file so stored names cannot be probed
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "File not found"})
+		return false
+	}
+	return true
 }
 
 // UploadHandler handles file uploads
 func (mh *MediaHandler) UploadHandler(c *gin.Context) {
 	// Get current user
-	authUser, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
-	user := authUser.(*models.User)
 
 	// Get file from request
 	file, err := c.FormFile("file")
@@ -62,46 +201,51 @@ func (mh *MediaHandler) UploadHandler(c *gin.Context) {
 		return
 	}
 
-	// Generate unique stored name
-	ext := filepath.Ext(file.Filename)
-	uniqueName := fmt.Sprintf("%d_%d%s", user.ID, time.Now().UnixNano(), ext)
-	dst := filepath.Join(mh.uploadDir, uniqueName)
-
-	// Save file
-	if err := c.SaveUploadedFile(file, dst); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save file"})
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Failed to read uploaded file"})
 		return
 	}
-
-	// Create media record
-	mediaRow, err := mh.queries.CreateMedia(c.Request.Context(), db.CreateMediaParams{
-		Filename:   file.Filename,
-		StoredName: uniqueName,
-		Type:       sql.NullString{String: "file", Valid: true},
-		MimeType:   sql.NullString{String: file.Header.Get("Content-Type"), Valid: true},
-		Size:       file.Size,
-		UserID:     int64(user.ID),
-	})
-
+	defer src.Close()
+
+	// Save the file and create the media record
+	mediaRow, err := mh.mediaService.CreateMedia(c.Request.Context(), services.NewMediaFile{
+		UserID:      user.ID,
+		Filename:    file.Filename,
+		MimeType:    file.Header.Get("Content-Type"),
+		Visibility:  c.DefaultPostForm("visibility", models.VisibilityInherit),
+		Description: c.PostForm("description"),
+	}, src)
 	if err != nil {
-		// Clean up file if DB save fails
-		_ = os.Remove(dst)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save media record"})
+		if errors.Is(err, services.ErrInvalidUpload) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save media"})
 		return
 	}
 
 	// Map to model
 	apiMedia := models.Media{
-		ID:         uint(mediaRow.ID),
-		Filename:   mediaRow.Filename,
-		StoredName: mediaRow.StoredName,
-		Type:       mediaRow.Type,
-		MimeType:   mediaRow.MimeType,
-		Size:       mediaRow.Size,
-		UserID:     uint(mediaRow.UserID),
-		CreatedAt:  mediaRow.CreatedAt,
-		UpdatedAt:  mediaRow.UpdatedAt,
+		ID:              uint(mediaRow.ID),
+		Filename:        mediaRow.Filename,
+		StoredName:      mediaRow.StoredName,
+		Type:            mediaRow.Type,
+		MimeType:        mediaRow.MimeType,
+		Size:            mediaRow.Size,
+		Visibility:      mediaRow.Visibility,
+		Description:     mediaRow.Description,
+		TranscodeStatus: mediaRow.TranscodeStatus,
+		ScanStatus:      mediaRow.ScanStatus,
+		ScanResult:      mediaRow.ScanResult,
+		BlurHash:        mediaRow.Blurhash,
+		DominantColor:   mediaRow.DominantColor,
+		AspectRatio:     mediaRow.AspectRatio,
+		UserID:          uint(mediaRow.UserID),
+		CreatedAt:       mediaRow.CreatedAt,
+		UpdatedAt:       mediaRow.UpdatedAt,
 	}
+	mh.signMedia(&apiMedia, user)
 
 	c.JSON(http.StatusCreated, SuccessResponse{Data: apiMedia})
 }
@@ -115,6 +259,12 @@ func (mh *MediaHandler) GetMediaHandler(c *gin.Context) {
 		return
 	}
 
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	mediaRow, err := mh.queries.GetMediaByID(c.Request.Context(), int64(mediaID))
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -125,7 +275,25 @@ func (mh *MediaHandler) GetMediaHandler(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(mh.uploadDir, mediaRow.StoredName)
+	allowed, err := mh.canAccessMedia(c.Request.Context(), user, mediaRow.UserID, mediaRow.StoredName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		return
+	}
+
+	if rejectUnscanned(c, mediaRow.ScanStatus) {
+		return
+	}
+
+	filePath, err := mh.mediaFilePath(c.Request.Context(), user, mediaRow.UserID, mediaRow.StoredName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to watermark file"})
+		return
+	}
 	c.File(filePath)
 }
 
@@ -138,6 +306,12 @@ func (mh *MediaHandler) GetMediaDetailsHandler(c *gin.Context) {
 		return
 	}
 
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	mediaRow, err := mh.queries.GetMediaByID(c.Request.Context(), int64(mediaID))
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -148,20 +322,45 @@ func (mh *MediaHandler) GetMediaDetailsHandler(c *gin.Context) {
 		return
 	}
 
-	// Fetch username
-	// userRow, _ := mh.queries.GetUserByID(c.Request.Context(), mediaRow.UserID)
+	allowed, err := mh.canAccessMedia(c.Request.Context(), user, mediaRow.UserID, mediaRow.StoredName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		return
+	}
 
 	apiMedia := models.Media{
-		ID:         uint(mediaRow.ID),
-		Filename:   mediaRow.Filename,
-		StoredName: mediaRow.StoredName,
-		// URL:        mh.GenMediaURL(mediaRow.StoredName),
-		Type:      mediaRow.Type,
-		MimeType:  mediaRow.MimeType,
-		Size:      mediaRow.Size,
-		UserID:    uint(mediaRow.UserID),
-		CreatedAt: mediaRow.CreatedAt,
-		UpdatedAt: mediaRow.UpdatedAt,
+		ID:              uint(mediaRow.ID),
+		Filename:        mediaRow.Filename,
+		StoredName:      mediaRow.StoredName,
+		Type:            mediaRow.Type,
+		MimeType:        mediaRow.MimeType,
+		Size:            mediaRow.Size,
+		Visibility:      mediaRow.Visibility,
+		Description:     mediaRow.Description,
+		TranscodeStatus: mediaRow.TranscodeStatus,
+		ScanStatus:      mediaRow.ScanStatus,
+		ScanResult:      mediaRow.ScanResult,
+		BlurHash:        mediaRow.Blurhash,
+		DominantColor:   mediaRow.DominantColor,
+		AspectRatio:     mediaRow.AspectRatio,
+		UserID:          uint(mediaRow.UserID),
+		CreatedAt:       mediaRow.CreatedAt,
+		UpdatedAt:       mediaRow.UpdatedAt,
+	}
+	mh.signMedia(&apiMedia, user)
+
+	tags, err := mh.queries.ListMediaTags(c.Request.Context(), mediaRow.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+	apiMedia.Tags = make([]string, len(tags))
+	for i, tag := range tags {
+		apiMedia.Tags[i] = tag.Name
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse{Data: apiMedia})
@@ -170,6 +369,9 @@ func (mh *MediaHandler) GetMediaDetailsHandler(c *gin.Context) {
 // ServeFileHandler serves files directly from the uploads directory for
 // development. Production should serve these via nginx or another static
 // file server for performance.
+// Requests must carry a valid signature unless the media is public.
+// Images carry the owner's watermark unless the link was signed for the owner
+// with clean=1.
 func (mh *MediaHandler) ServeFileHandler(c *gin.Context) {
 	name := c.Param("name")
 
@@ -180,6 +382,26 @@ func (mh *MediaHandler) ServeFileHandler(c *gin.Context) {
 	}
 
 	filePath := filepath.Join(mh.uploadDir, name)
+	if c.Query("clean") == "1" {
+		// Links to the file as uploaded are only ever signed for the owner
+		if err := mh.signer.Verify(auth.CleanMediaKey(name), c.Query("expires"), c.Query("sig")); err != nil {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Invalid or expired link"})
+			return
+		}
+	} else {
+		if !mh.authorizeFileRequest(c, auth.MediaKey(name)) {
+			return
+		}
+		if mh.watermarks != nil {
+			path, err := mh.watermarks.Original(c.Request.Context(), name)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to watermark file"})
+				return
+			}
+			filePath = path
+		}
+	}
+
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		c.JSON(http.StatusNotFound, ErrorResponse{Error: "File not found"})
 		return
@@ -202,8 +424,8 @@ func (mh *MediaHandler) ServeThumbnailHandler(c *gin.Context) {
 		return
 	}
 
-	// Validate filename is just a basename
-	if filepath.Base(name) != name {
+	// Validate size and filename are just basenames
+	if filepath.Base(size) != size || filepath.Base(name) != name {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid filename"})
 		return
 	}
@@ -211,6 +433,32 @@ func (mh *MediaHandler) ServeThumbnailHandler(c *gin.Context) {
 	// Construct the full path to the thumbnail file
 	thumbnailPath := filepath.Join(mh.uploadDir, size, name)
 
+	if c.Query("clean") == "1" {
+		// Links without the watermark are only ever signed for the owner
+		if err := mh.signer.Verify(auth.CleanMediaKey(name), c.Query("expires"), c.Query("sig")); err != nil {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Invalid or expired link"})
+			return
+		}
+	} else {
+		if c.Query("preview") == "1" {
+			// Signed for share links that do not allow downloads
+			if err := mh.signer.Verify(auth.PreviewMediaKey(name), c.Query("expires"), c.Query("sig")); err != nil {
+				c.JSON(http.StatusForbidden, ErrorResponse{Error: "Invalid or expired link"})
+				return
+			}
+		} else if !mh.authorizeFileRequest(c, auth.MediaKey(name)) {
+			return
+		}
+		if mh.watermarks != nil {
+			path, err := mh.watermarks.Thumbnail(c.Request.Context(), size, name)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to watermark thumbnail"})
+				return
+			}
+			thumbnailPath = path
+		}
+	}
+
 	if _, err := os.Stat(thumbnailPath); os.IsNotExist(err) {
 		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Thumbnail not found"})
 		return
@@ -222,7 +470,8 @@ func (mh *MediaHandler) ServeThumbnailHandler(c *gin.Context) {
 	c.File(thumbnailPath)
 }
 
-// ListPublicMediasHandler returns a paginated list of medias for public consumption
+// ListPublicMediasHandler returns a paginated list of medias for public consumption.
+// Only media that is effectively public is listed, with a redacted owner projection.
 func (mh *MediaHandler) ListPublicMediasHandler(c *gin.Context) {
 	limitStr := c.DefaultQuery("limit", "10")
 	offsetStr := c.DefaultQuery("offset", "0")
@@ -236,34 +485,53 @@ func (mh *MediaHandler) ListPublicMediasHandler(c *gin.Context) {
 		offset = 0
 	}
 
+	// Optional tag filter: ?tags=a,b&tag_mode=all|any
+	tags, minMatches, err := services.ParseTagFilter(c.Query("tags"), c.Query("tag_mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
 	mediaRows, err := mh.queries.ListPublicMedia(c.Request.Context(), db.ListPublicMediaParams{
-		Limit:  int32(limit),
-		Offset: int32(offset),
+		Tags:       tags,
+		MinMatches: minMatches,
+		PageLimit:  int32(limit),
+		PageOffset: int32(offset),
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error fetching media"})
 		return
 	}
 
-	total, _ := mh.queries.CountPublicMedia(c.Request.Context())
+	total, _ := mh.queries.CountPublicMedia(c.Request.Context(), db.CountPublicMediaParams{
+		Tags:       tags,
+		MinMatches: minMatches,
+	})
 
 	var medias []models.Media
 	for _, row := range mediaRows {
-		medias = append(medias, models.Media{
-			ID:         uint(row.ID),
-			Filename:   row.Filename,
-			StoredName: row.StoredName,
-			// URL:        mh.GenMediaURL(row.StoredName),
-			Type:      row.Type,
-			MimeType:  row.MimeType,
-			Size:      row.Size,
-			UserID:    uint(row.UserID),
-			UserName:  row.UserName,
-			UserTel:   row.UserTel.String,
-			UserEmail: row.UserEmail,
-			CreatedAt: row.CreatedAt,
-			UpdatedAt: row.UpdatedAt,
-		})
+		media := models.Media{
+			ID:              uint(row.ID),
+			Filename:        row.Filename,
+			StoredName:      row.StoredName,
+			Type:            row.Type,
+			MimeType:        row.MimeType,
+			Size:            row.Size,
+			Visibility:      row.Visibility,
+			Description:     row.Description,
+			TranscodeStatus: row.TranscodeStatus,
+			ScanStatus:      row.ScanStatus,
+			ScanResult:      row.ScanResult,
+			BlurHash:        row.Blurhash,
+			DominantColor:   row.DominantColor,
+			AspectRatio:     row.AspectRatio,
+			UserID:          uint(row.UserID),
+			UserName:        row.UserName,
+			CreatedAt:       row.CreatedAt,
+			UpdatedAt:       row.UpdatedAt,
+		}
+		mh.signMedia(&media, optionalUser(c))
+		medias = append(medias, media)
 	}
 
 	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
@@ -274,35 +542,103 @@ func (mh *MediaHandler) ListPublicMediasHandler(c *gin.Context) {
 	}})
 }
 
-// ListAlbumMediaHandler returns all media files for a specific album
+// ListMyMediaHandler returns a paginated list of the current user's own media,
+// regardless of visibility
+func (mh *MediaHandler) ListMyMediaHandler(c *gin.Context) {
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		limit = 10
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		offset = 0
+	}
+
+	// Optional tag filter: ?tags=a,b&tag_mode=all|any
+	tags, minMatches, err := services.ParseTagFilter(c.Query("tags"), c.Query("tag_mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	mediaRows, err := mh.queries.ListUserMedia(c.Request.Context(), db.ListUserMediaParams{
+		UserID:     int64(user.ID),
+		Tags:       tags,
+		MinMatches: minMatches,
+		PageLimit:  int32(limit),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error fetching media"})
+		return
+	}
+
+	total, _ := mh.queries.CountUserMedia(c.Request.Context(), db.CountUserMediaParams{
+		UserID:     int64(user.ID),
+		Tags:       tags,
+		MinMatches: minMatches,
+	})
+
+	medias := make([]models.Media, 0, len(mediaRows))
+	for _, row := range mediaRows {
+		media := mappers.MediaRowToModel(row)
+		media.UserName = user.Name
+		mh.signMedia(&media, user)
+		medias = append(medias, media)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"files":  medias,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	}})
+}
+
+// ListAlbumMediaHandler returns the media files of an album the caller may view
 func (mh *MediaHandler) ListAlbumMediaHandler(c *gin.Context) {
 	albumIDStr := c.Param("album_id")
-	albumID, err := strconv.ParseInt(albumIDStr, 10, 64)
+	albumID, err := strconv.ParseUint(albumIDStr, 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
 		return
 	}
 
-	mediaRows, err := mh.queries.GetAlbumMedia(c.Request.Context(), albumID)
+	mediaRows, err := mh.albums.ListAlbumMedia(c.Request.Context(), optionalUser(c), uint(albumID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error fetching album media"})
+		writeAlbumError(c, err)
 		return
 	}
 
 	var medias []models.Media
 	for _, row := range mediaRows {
-		medias = append(medias, models.Media{
-			ID:         uint(row.ID),
-			Filename:   row.Filename,
-			StoredName: row.StoredName,
-			// URL:        mh.GenMediaURL(row.StoredName),
-			Type:      row.Type.String,
-			MimeType:  row.MimeType.String,
-			Size:      row.Size,
-			UserID:    uint(row.UserID),
-			CreatedAt: row.CreatedAt,
-			UpdatedAt: row.UpdatedAt,
-		})
+		media := models.Media{
+			ID:              uint(row.ID),
+			Filename:        row.Filename,
+			StoredName:      row.StoredName,
+			Type:            row.Type.String,
+			MimeType:        row.MimeType.String,
+			Size:            row.Size,
+			Visibility:      row.Visibility,
+			Description:     row.Description.String,
+			TranscodeStatus: row.TranscodeStatus,
+			ScanStatus:      row.ScanStatus,
+			ScanResult:      row.ScanResult.String,
+			BlurHash:        row.Blurhash.String,
+			DominantColor:   row.DominantColor.String,
+			AspectRatio:     row.AspectRatio.Float64,
+			UserID:          uint(row.UserID),
+			CreatedAt:       row.CreatedAt,
+			UpdatedAt:       row.UpdatedAt,
+		}
+		mh.signMedia(&media, optionalUser(c))
+		medias = append(medias, media)
 	}
 
 	if medias == nil {
@@ -312,13 +648,11 @@ func (mh *MediaHandler) ListAlbumMediaHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, SuccessResponse{Data: medias})
 }
 
-func GenMediaURL(s string) {
-	panic("unimplemented")
-}
-
 // UpdateMediaRequest represents payload for updating media
 type UpdateMediaRequest struct {
-	Filename string `json:"filename"`
+	Filename    string  `json:"filename"`
+	Visibility  string  `json:"visibility"`
+	Description *string `json:"description"` // nil keeps the current description
 }
 
 // UpdateMediaHandler updates media metadata and optionally replaces the file
@@ -331,12 +665,10 @@ func (mh *MediaHandler) UpdateMediaHandler(c *gin.Context) {
 	}
 
 	// Get current user
-	authUser, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
-	user := authUser.(*models.User)
 
 	mediaRow, err := mh.queries.GetMediaByID(c.Request.Context(), int64(mediaID))
 	if err != nil {
@@ -357,6 +689,9 @@ func (mh *MediaHandler) UpdateMediaHandler(c *gin.Context) {
 	// Check if content type is JSON
 	contentType := c.GetHeader("Content-Type")
 	newFilename := mediaRow.Filename
+	newVisibility := mediaRow.Visibility
+	newDescription := mediaRow.Description
+	var replacement *services.ReplacementFile
 
 	if contentType == "application/json" {
 		var req UpdateMediaRequest
@@ -367,54 +702,77 @@ func (mh *MediaHandler) UpdateMediaHandler(c *gin.Context) {
 		if req.Filename != "" {
 			newFilename = req.Filename
 		}
+		if req.Visibility != "" {
+			newVisibility = req.Visibility
+		}
+		if req.Description != nil {
+			newDescription = *req.Description
+		}
+		if !models.IsValidVisibility(newVisibility) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid visibility"})
+			return
+		}
 	} else {
 		// Handle multipart/form-data
 		if f := c.PostForm("filename"); f != "" {
 			newFilename = f
 		}
+		if v := c.PostForm("visibility"); v != "" {
+			newVisibility = v
+		}
+		if d, ok := c.GetPostForm("description"); ok {
+			newDescription = d
+		}
+		if !models.IsValidVisibility(newVisibility) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid visibility"})
+			return
+		}
 
-		// Check for file replacement
+		// Check for file replacement. The new file is saved in quarantine under
+		// a new name first; the previous file is kept on disk as an earlier version.
 		file, err := c.FormFile("file")
 		if err == nil {
-			// 1. Delete old file from disk
-			oldPath := filepath.Join(mh.uploadDir, mediaRow.StoredName)
-			_ = os.Remove(oldPath)
-
-			// 2. Save new file
-			ext := filepath.Ext(file.Filename)
-			uniqueName := fmt.Sprintf("%d_%d%s", user.ID, time.Now().UnixNano(), ext)
-			dst := filepath.Join(mh.uploadDir, uniqueName)
+			uniqueName := services.NewStoredName(user.ID, file.Filename)
+			dst := services.QuarantinePath(mh.uploadDir, uniqueName)
 
 			if err := c.SaveUploadedFile(file, dst); err != nil {
 				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save new file"})
 				return
 			}
 
-			// Update row values
-			// Note: We need a specialized query for this or use conn.Exec
-			_, _ = mh.conn.ExecContext(c.Request.Context(), "UPDATE media SET stored_name = $2, mime_type = $3, size = $4 WHERE id = $1",
-				mediaRow.ID, uniqueName, file.Header.Get("Content-Type"), file.Size)
+			// The type comes from the new file, not from the one it replaces
+			replacement = &services.ReplacementFile{
+				StoredName: uniqueName,
+				MimeType:   services.DetectFileMimeType(dst, file.Filename),
+				Size:       file.Size,
+			}
 		}
 	}
 
-	// Update record
-	updatedRow, err := mh.queries.UpdateMedia(c.Request.Context(), db.UpdateMediaParams{
-		ID:       mediaRow.ID,
-		Filename: newFilename,
-		Type:     sql.NullString{String: mediaRow.Type, Valid: true},
-		MimeType: sql.NullString{String: mediaRow.MimeType, Valid: true},
-		Size:     mediaRow.Size,
-	})
-
+	// Metadata and file are saved together
+	media, err := mh.mediaService.UpdateMedia(c.Request.Context(), uint(mediaRow.ID), services.MediaUpdate{
+		Filename:    newFilename,
+		Visibility:  newVisibility,
+		Description: newDescription,
+	}, replacement)
 	if err != nil {
+		if replacement != nil {
+			mh.mediaService.RemoveFiles(replacement.StoredName)
+		}
+		if errors.Is(err, services.ErrMediaNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to update media"})
 		return
 	}
 
-	c.JSON(http.StatusOK, SuccessResponse{Data: updatedRow})
+	mh.signMedia(media, user)
+	c.JSON(http.StatusOK, SuccessResponse{Data: media})
 }
 
-// DeleteMediaHandler deletes media file and record
+// DeleteMediaHandler moves media to the trash. Files stay on disk until the
+// trash is emptied or the retention period expires.
 func (mh *MediaHandler) DeleteMediaHandler(c *gin.Context) {
 	mediaIDStr := c.Param("id")
 	mediaID, err := strconv.ParseInt(mediaIDStr, 10, 64)
@@ -424,12 +782,10 @@ func (mh *MediaHandler) DeleteMediaHandler(c *gin.Context) {
 	}
 
 	// Get current user
-	authUser, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
-	user := authUser.(*models.User)
 
 	mediaRow, err := mh.queries.GetMediaByID(c.Request.Context(), int64(mediaID))
 	if err != nil {
@@ -447,16 +803,651 @@ func (mh *MediaHandler) DeleteMediaHandler(c *gin.Context) {
 		return
 	}
 
-	// Delete file from disk
-	filePath := filepath.Join(mh.uploadDir, mediaRow.StoredName)
-	_ = os.Remove(filePath)
+	if err := mh.mediaService.MoveToTrash(c.Request.Context(), uint(mediaID)); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to move media to trash: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Media moved to trash"}})
+}
 
-	// Delete from DB (Hard delete since we also removed the file)
-	err = mh.queries.PermanentlyDeleteMedia(c.Request.Context(), int64(mediaID))
+// ListTrashHandler returns the current user's trashed media
+func (mh *MediaHandler) ListTrashHandler(c *gin.Context) {
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	medias, err := mh.mediaService.ListTrash(c.Request.Context(), user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete media record: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error fetching trash"})
 		return
 	}
 
-	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Media deleted successfully"}})
+	for i := range medias {
+		mh.signMedia(&medias[i], user)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: medias})
+}
+
+// RestoreMediaHandler takes media out of the trash (Owner or Admin)
+func (mh *MediaHandler) RestoreMediaHandler(c *gin.Context) {
+	mediaID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	mediaRow, err := mh.queries.GetMediaByIDWithDeleted(c.Request.Context(), mediaID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+
+	// Access Control: Owner or Admin
+	if uint64(mediaRow.UserID) != uint64(user.ID) && !user.HasRole("admin") {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		return
+	}
+
+	media, err := mh.mediaService.Restore(c.Request.Context(), uint(mediaID))
+	if err != nil {
+		if errors.Is(err, services.ErrMediaNotInTrash) || errors.Is(err, services.ErrMediaInfected) {
+			c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to restore media"})
+		return
+	}
+	mh.signMedia(media, user)
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: media})
+}
+
+// EmptyTrashHandler permanently deletes all of the current user's trashed media
+func (mh *MediaHandler) EmptyTrashHandler(c *gin.Context) {
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	removed, err := mh.mediaService.EmptyTrash(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to empty trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"message": "Trash emptied",
+		"removed": removed,
+	}})
+}
+
+// BatchMediaRequest represents a batch operation on several media items
+type BatchMediaRequest struct {
+	Action      string `json:"action" binding:"required"`
+	IDs         []uint `json:"ids" binding:"required"`
+	AlbumID     uint   `json:"album_id"`
+	FromAlbumID uint   `json:"from_album_id"`
+	Pattern     string `json:"pattern"`
+	Visibility  string `json:"visibility"`
+}
+
+// BatchMediaHandler applies one action to a list of media items in a single
+// transaction and reports the outcome per item
+func (mh *MediaHandler) BatchMediaHandler(c *gin.Context) {
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req BatchMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	results, err := mh.mediaService.ApplyBatch(c.Request.Context(), user, services.BatchOperation{
+		Action:      req.Action,
+		IDs:         req.IDs,
+		AlbumID:     req.AlbumID,
+		FromAlbumID: req.FromAlbumID,
+		Pattern:     req.Pattern,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidBatch):
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrForbidden):
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Batch operation failed"})
+		}
+		return
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Status != "ok" {
+			failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"action":    req.Action,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+		"results":   results,
+	}})
+}
+
+// ownedMediaFromParam loads the media item in the :id parameter and checks the
+// current user is its owner or an admin. It writes the error response itself
+// and returns false when the request cannot continue.
+func (mh *MediaHandler) ownedMediaFromParam(c *gin.Context) (db.GetMediaByIDRow, bool) {
+	mediaID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid ID"})
+		return db.GetMediaByIDRow{}, false
+	}
+
+	// Get current user
+	user, ok := currentUser(c)
+	if !ok {
+		return db.GetMediaByIDRow{}, false
+	}
+
+	mediaRow, err := mh.queries.GetMediaByID(c.Request.Context(), mediaID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			return db.GetMediaByIDRow{}, false
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return db.GetMediaByIDRow{}, false
+	}
+
+	// Access Control: Owner or Admin
+	if uint64(mediaRow.UserID) != uint64(user.ID) && !user.HasRole("admin") {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		return db.GetMediaByIDRow{}, false
+	}
+
+	return mediaRow, true
+}
+
+// ListMediaVersionsHandler lists the earlier versions of a media file (Owner or Admin)
+func (mh *MediaHandler) ListMediaVersionsHandler(c *gin.Context) {
+	mediaRow, ok := mh.ownedMediaFromParam(c)
+	if !ok {
+		return
+	}
+
+	versions, err := mh.mediaService.ListVersions(c.Request.Context(), uint(mediaRow.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list versions"})
+		return
+	}
+	for i := range versions {
+		versions[i].URL = mh.signer.SignURL(mappers.GetMediaURL(versions[i].StoredName)+"?clean=1", auth.CleanMediaKey(versions[i].StoredName))
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: versions})
+}
+
+// DownloadMediaVersionHandler downloads the file of an earlier version (Owner or Admin)
+func (mh *MediaHandler) DownloadMediaVersionHandler(c *gin.Context) {
+	mediaRow, ok := mh.ownedMediaFromParam(c)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid version"})
+		return
+	}
+
+	v, err := mh.mediaService.GetVersion(c.Request.Context(), uint(mediaRow.ID), version)
+	if err != nil {
+		if errors.Is(err, services.ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Version not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+
+	c.FileAttachment(filepath.Join(mh.uploadDir, v.StoredName), v.Filename)
+}
+
+// RevertMediaVersionHandler makes an earlier version the current file again (Owner or Admin)
+func (mh *MediaHandler) RevertMediaVersionHandler(c *gin.Context) {
+	mediaRow, ok := mh.ownedMediaFromParam(c)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid version"})
+		return
+	}
+
+	media, err := mh.mediaService.RevertToVersion(c.Request.Context(), uint(mediaRow.ID), version)
+	if err != nil {
+		if errors.Is(err, services.ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Version not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to revert media"})
+		return
+	}
+	mh.signMedia(media, optionalUser(c))
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: media})
+}
+
+// parseMaxDistance reads the ?max_distance= query parameter, falling back to
+// the default for missing or out-of-range values
+func parseMaxDistance(c *gin.Context) int {
+	d, err := strconv.Atoi(c.Query("max_distance"))
+	if err != nil || d < 0 || d > services.MaxSimilarDistance {
+		return services.DefaultSimilarDistance
+	}
+	return d
+}
+
+// ListSimilarMediaHandler lists media of the same owner that look like the
+// given item, closest first (Owner or Admin)
+func (mh *MediaHandler) ListSimilarMediaHandler(c *gin.Context) {
+	mediaRow, ok := mh.ownedMediaFromParam(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	similar, err := mh.mediaService.FindSimilar(c.Request.Context(), uint(mediaRow.ID), parseMaxDistance(c), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrHashPending) {
+			// Not an error: the indexer has not reached this item yet
+			c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+				"hash_pending": true,
+				"files":        []models.SimilarMedia{},
+			}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to find similar media"})
+		return
+	}
+	for i := range similar {
+		mh.signMedia(&similar[i].Media, optionalUser(c))
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"hash_pending": false,
+		"files":        similar,
+	}})
+}
+
+// ListDuplicatesHandler reports groups of the current user's media that look alike
+func (mh *MediaHandler) ListDuplicatesHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	groups, err := mh.mediaService.FindDuplicates(c.Request.Context(), user.ID, parseMaxDistance(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to find duplicates"})
+		return
+	}
+	for i := range groups {
+		for j := range groups[i].Items {
+			mh.signMedia(&groups[i].Items[j], user)
+		}
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: groups})
+}
+
+// MergeMediaRequest lists the duplicates to merge into the media item in the URL
+type MergeMediaRequest struct {
+	DuplicateIDs []uint `json:"duplicate_ids" binding:"required"`
+}
+
+// MergeMediaHandler keeps the media item in the URL and moves the given
+// duplicates into the trash, moving their album memberships and tags onto
+// the kept item (Owner or Admin)
+func (mh *MediaHandler) MergeMediaHandler(c *gin.Context) {
+	mediaRow, ok := mh.ownedMediaFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req MergeMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	media, err := mh.mediaService.MergeDuplicates(c.Request.Context(), uint(mediaRow.ID), req.DuplicateIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMerge):
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrMediaNotFound):
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrForbidden):
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to merge media"})
+		}
+		return
+	}
+	mh.signMedia(media, optionalUser(c))
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"media":  media,
+		"merged": len(req.DuplicateIDs),
+	}})
+}
+
+// RenderMediaHandler serves a resized derivative of a media item.
+// Sizes are limited to the configured presets; the format is taken from fmt
+// or negotiated from the Accept header. Anonymous users may render public media.
+func (mh *MediaHandler) RenderMediaHandler(c *gin.Context) {
+	mediaID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	user := optionalUser(c)
+
+	mediaRow, err := mh.queries.GetMediaByID(c.Request.Context(), mediaID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+
+	allowed, err := mh.canAccessMedia(c.Request.Context(), user, mediaRow.UserID, mediaRow.StoredName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+	if !allowed {
+		// Same response as a missing item so private media cannot be probed
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+		return
+	}
+	if rejectUnscanned(c, mediaRow.ScanStatus) {
+		return
+	}
+
+	width, errW := strconv.Atoi(c.DefaultQuery("w", "0"))
+	height, errH := strconv.Atoi(c.DefaultQuery("h", "0"))
+	quality, errQ := strconv.Atoi(c.DefaultQuery("q", strconv.Itoa(services.DefaultRenderQuality)))
+	if errW != nil || errH != nil || errQ != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "w, h and q must be numbers"})
+		return
+	}
+
+	req := services.RenderRequest{
+		Width:   width,
+		Height:  height,
+		Fit:     c.DefaultQuery("fit", services.RenderFitContain),
+		Format:  mh.renderer.NegotiateFormat(c.Query("fmt"), c.GetHeader("Accept"), mediaRow.MimeType),
+		Quality: quality,
+	}
+	if err := mh.renderer.Validate(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Everyone but the owner and admins sees the owner's watermark
+	if mh.watermarks != nil && (user == nil || (int64(user.ID) != mediaRow.UserID && !user.HasRole("admin"))) {
+		req.Watermark, req.WatermarkVersion, err = mh.watermarks.MarkForMedia(c.Request.Context(), mediaRow.StoredName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+			return
+		}
+	}
+
+	path, err := mh.renderer.Render(c.Request.Context(), mediaRow.StoredName, req)
+	if err != nil {
+		if errors.Is(err, services.ErrRenderUnsupported) {
+			c.JSON(http.StatusUnsupportedMediaType, ErrorResponse{Error: "This media cannot be rendered"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to render media"})
+		return
+	}
+
+	// The response depends on the Accept header when the format was negotiated
+	c.Header("Vary", "Accept")
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("ETag", `"`+filepath.Base(path)+`"`)
+	c.Header("Content-Type", services.ContentType(req.Format))
+	c.File(path)
+}
+
+// StreamMediaHandler serves the HLS stream of a video: the master playlist,
+// the rendition playlists and their segments. Playlists are rewritten so every
+// URI they reference carries a signature, which lets players without an
+// Authorization header (e.g. Safari's native player) follow them. Until the
+// video is transcoded, or if transcoding failed, the master playlist
+// redirects to the original file.
+func (mh *MediaHandler) StreamMediaHandler(c *gin.Context) {
+	mediaID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	mediaRow, err := mh.queries.GetMediaByID(c.Request.Context(), mediaID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+
+	key := auth.MediaKey(mediaRow.StoredName)
+	if sig := c.Query("sig"); sig != "" {
+		if err := mh.signer.Verify(key, c.Query("expires"), sig); err != nil {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "Invalid or expired link"})
+			return
+		}
+	} else {
+		user := optionalUser(c)
+		allowed, err := mh.canAccessMedia(c.Request.Context(), user, mediaRow.UserID, mediaRow.StoredName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			return
+		}
+	}
+
+	if rejectUnscanned(c, mediaRow.ScanStatus) {
+		return
+	}
+
+	name := strings.TrimPrefix(c.Param("path"), "/")
+	c.Header("X-Transcode-Status", mediaRow.TranscodeStatus)
+
+	if mediaRow.TranscodeStatus != services.TranscodeReady {
+		if name == services.HLSMasterPlaylist {
+			// Fall back to progressive download of the original
+			c.Redirect(http.StatusFound, mh.signer.SignURL(mappers.GetMediaURL(mediaRow.StoredName), key))
+			return
+		}
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Stream not available"})
+		return
+	}
+
+	// Only playlists and segments inside the stream directory are served
+	clean := filepath.Clean(name)
+	ext := filepath.Ext(clean)
+	if clean != name || strings.HasPrefix(clean, "..") || filepath.IsAbs(clean) || (ext != ".m3u8" && ext != ".ts") {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "File not found"})
+		return
+	}
+	path := filepath.Join(services.HLSDir(mh.uploadDir, mediaRow.StoredName), clean)
+
+	if ext == ".ts" {
+		c.Header("Content-Type", "video/mp2t")
+		c.File(path)
+		return
+	}
+
+	playlist, err := os.ReadFile(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "File not found"})
+		return
+	}
+	signed := services.RewritePlaylist(playlist, func(uri string) string {
+		return mh.signer.SignURL(uri, key)
+	})
+	// Signatures expire, so playlists must not be cached for long
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", signed)
+}
+
+// RetranscodeMediaHandler queues a video for transcoding again, e.g. after a
+// failure (Owner or Admin)
+func (mh *MediaHandler) RetranscodeMediaHandler(c *gin.Context) {
+	mediaRow, ok := mh.ownedMediaFromParam(c)
+	if !ok {
+		return
+	}
+
+	n, err := mh.queries.RequeueTranscode(c.Request.Context(), mediaRow.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Media is not a video or is already queued"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, SuccessResponse{Data: map[string]string{"transcode_status": services.TranscodePending}})
+}
+
+// ImportURLRequest is the body of ImportURLHandler
+type ImportURLRequest struct {
+	URL         string `json:"url" binding:"required"`
+	Visibility  string `json:"visibility"`
+	Description string `json:"description"`
+}
+
+// ImportURLHandler queues a remote image or video to be fetched into the
+// caller's library. The response is the import job; poll
+// GetURLImportHandler until it is done or failed.
+func (mh *MediaHandler) ImportURLHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req ImportURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	job, err := mh.urlImporter.Enqueue(c.Request.Context(), user.ID, req.URL, req.Visibility, req.Description)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidURL), errors.Is(err, services.ErrBlockedAddress), errors.Is(err, services.ErrInvalidUpload):
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyURLImports):
+			c.JSON(http.StatusTooManyRequests, ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to queue import"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, SuccessResponse{Data: job})
+}
+
+// ListURLImportsHandler returns the caller's most recent URL imports
+func (mh *MediaHandler) ListURLImportsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := mh.urlImporter.List(c.Request.Context(), user.ID, 50)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: jobs})
+}
+
+// GetURLImportHandler returns a URL import of the caller, with the created
+// media item once it is done (Owner or Admin)
+func (mh *MediaHandler) GetURLImportHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid import ID"})
+		return
+	}
+
+	job, err := mh.urlImporter.Get(c.Request.Context(), id, user.ID, user.HasRole("admin"))
+	if err != nil {
+		if errors.Is(err, services.ErrURLImportNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Import not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+
+	if job.MediaID != 0 {
+		if row, err := mh.queries.GetMediaByID(c.Request.Context(), int64(job.MediaID)); err == nil {
+			media := mappers.MediaRowToModel(row)
+			mh.signMedia(&media, user)
+			job.Media = &media
+		}
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: job})
 }
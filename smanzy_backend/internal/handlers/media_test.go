@@ -1,17 +1,31 @@
 package handlers
 
 import (
+	"bytes"
+	"database/sql/driver"
+	"image"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/auth"
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
 )
 
+func newTestSigner() *auth.URLSigner {
+	return auth.NewURLSigner("test-secret", time.Hour)
+}
+
 func TestServeFileHandler_ServesFile(t *testing.T) {
 	// Create temp dir and file
 	tmpDir, err := os.MkdirTemp("", "uploads_test")
@@ -27,7 +41,8 @@ func TestServeFileHandler_ServesFile(t *testing.T) {
 		t.Fatalf("failed to write test file: %v", err)
 	}
 
-	mh := NewMediaHandler(nil, nil)
+	signer := newTestSigner()
+	mh := NewMediaHandler(nil, nil, signer, nil, nil, nil, nil, nil)
 	mh.uploadDir = tmpDir
 
 	// Set up router
@@ -35,7 +50,8 @@ func TestServeFileHandler_ServesFile(t *testing.T) {
 	router := gin.New()
 	router.GET("/api/media/files/:name", mh.ServeFileHandler)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/media/files/"+filename, nil)
+	url := signer.SignURL("/api/media/files/"+filename, auth.MediaKey(filename))
+	req := httptest.NewRequest(http.MethodGet, url, nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -53,7 +69,7 @@ func TestServeFileHandler_ServesFile(t *testing.T) {
 }
 
 func TestServeFileHandler_InvalidFilename(t *testing.T) {
-	mh := NewMediaHandler(nil, nil)
+	mh := NewMediaHandler(nil, nil, newTestSigner(), nil, nil, nil, nil, nil)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -68,3 +84,332 @@ func TestServeFileHandler_InvalidFilename(t *testing.T) {
 		t.Fatalf("expected 400 Bad Request for invalid filename, got %d", w.Code)
 	}
 }
+
+func TestServeFileHandler_RejectsBadSignature(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "uploads_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	filename := "1_1765789611227708560.jpg"
+	if err := os.WriteFile(filepath.Join(tmpDir, filename), []byte("private"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	signer := newTestSigner()
+	mh := NewMediaHandler(nil, nil, signer, nil, nil, nil, nil, nil)
+	mh.uploadDir = tmpDir
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/media/files/:name", mh.ServeFileHandler)
+
+	path := "/api/media/files/" + filename
+	_, otherSig := signer.Sign(auth.MediaKey("2_1765789611227708560.jpg"))
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	cases := map[string]string{
+		"wrong file": path + "?expires=" + expires + "&sig=" + otherSig,
+		"expired":    auth.NewURLSigner("test-secret", -time.Minute).SignURL(path, auth.MediaKey(filename)),
+		"garbage":    path + "?expires=abc&sig=deadbeef",
+	}
+
+	for name, url := range cases {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 Forbidden, got %d", name, w.Code)
+		}
+	}
+}
+
+func TestServeThumbnailHandler_AcceptsOriginalSignature(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "uploads_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "320x200"), 0755); err != nil {
+		t.Fatalf("failed to create thumbnail dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "320x200", "1_1765789611227708560.jpg"), []byte("thumb"), 0644); err != nil {
+		t.Fatalf("failed to write test thumbnail: %v", err)
+	}
+
+	signer := newTestSigner()
+	mh := NewMediaHandler(nil, nil, signer, nil, nil, nil, nil, nil)
+	mh.uploadDir = tmpDir
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/media/thumbs/:size/:name", mh.ServeThumbnailHandler)
+
+	// A signature issued for the original video unlocks its JPEG thumbnail
+	signed := signer.SignURL("/api/media/files/1_1765789611227708560.mp4", auth.MediaKey("1_1765789611227708560.mp4"))
+	query := signed[strings.Index(signed, "?"):]
+
+	req := httptest.NewRequest(http.MethodGet, "/api/media/thumbs/320x200/1_1765789611227708560.jpg"+query, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSignMedia_ThumbnailURLs(t *testing.T) {
+	tmpDir := t.TempDir()
+	storedName := "1_1765789611227708560.mp4"
+
+	signer := newTestSigner()
+	mh := NewMediaHandler(nil, nil, signer, nil, nil, nil, nil, nil)
+	mh.uploadDir = tmpDir
+
+	media := models.Media{ID: 7, StoredName: storedName, UpdatedAt: time.Now().UnixMilli()}
+	mh.signMedia(&media, nil)
+	if media.ThumbnailStatus != services.ThumbnailPending || media.Thumbnails != nil {
+		t.Fatalf("before thumbgen: status %q, thumbnails %v", media.ThumbnailStatus, media.Thumbnails)
+	}
+	if !strings.HasPrefix(media.URL, "/api/media/files/"+storedName+"?") {
+		t.Errorf("unexpected file URL %q", media.URL)
+	}
+
+	for _, size := range services.ThumbnailSizes {
+		path := services.ThumbnailPath(tmpDir, size, storedName)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("thumb"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mh.signMedia(&media, nil)
+	if media.ThumbnailStatus != services.ThumbnailReady {
+		t.Fatalf("expected ready, got %q", media.ThumbnailStatus)
+	}
+	medium := media.Thumbnails["medium"]
+	if !strings.HasPrefix(medium, "/api/media/thumbs/320x200/1_1765789611227708560.jpg?") {
+		t.Fatalf("unexpected medium thumbnail URL %q", medium)
+	}
+
+	// The thumbnail URL must be accepted by the thumbnail route
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/media/thumbs/:size/:name", mh.ServeThumbnailHandler)
+
+	req := httptest.NewRequest(http.MethodGet, medium, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSignMedia_QuarantinedMediaHasNoURLs(t *testing.T) {
+	mh := NewMediaHandler(nil, nil, newTestSigner(), nil, nil, nil, nil, nil)
+	mh.uploadDir = t.TempDir()
+
+	media := models.Media{ID: 7, StoredName: "1_1765789611227708560.jpg", ScanStatus: services.ScanPending}
+	mh.signMedia(&media, nil)
+	if media.URL != "" || media.Thumbnails != nil || media.ThumbnailStatus != services.ThumbnailPending {
+		t.Errorf("pending scan: url %q, thumbnails %v, status %q", media.URL, media.Thumbnails, media.ThumbnailStatus)
+	}
+
+	media.ScanStatus = services.ScanClean
+	mh.signMedia(&media, nil)
+	if media.URL == "" {
+		t.Error("clean media should get a signed URL")
+	}
+}
+
+func TestSignMedia_OwnerGetsCleanThumbnails(t *testing.T) {
+	tmpDir := t.TempDir()
+	storedName := "1_1765789611227708560.jpg"
+	path := services.ThumbnailPath(tmpDir, "320x200", storedName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("thumb"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mh := NewMediaHandler(nil, nil, newTestSigner(), nil, nil, nil, nil, nil)
+	mh.uploadDir = tmpDir
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/media/thumbs/:size/:name", mh.ServeThumbnailHandler)
+	get := func(url string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w.Code
+	}
+
+	media := models.Media{ID: 7, StoredName: storedName, UserID: 1, UpdatedAt: time.Now().UnixMilli()}
+	mh.signMedia(&media, &models.User{ID: 1})
+	owner := media.Thumbnails["medium"]
+	if !strings.Contains(owner, "clean=1") {
+		t.Fatalf("owner thumbnail URL %q should skip the watermark", owner)
+	}
+	if code := get(owner); code != http.StatusOK {
+		t.Errorf("owner thumbnail: got %d, want 200", code)
+	}
+
+	mh.signMedia(&media, &models.User{ID: 2})
+	other := media.Thumbnails["medium"]
+	if strings.Contains(other, "clean=1") {
+		t.Fatalf("other users must not get clean thumbnails: %q", other)
+	}
+
+	// A viewer's signature does not unlock the clean thumbnail
+	if code := get(other + "&clean=1"); code != http.StatusForbidden {
+		t.Errorf("forged clean thumbnail: got %d, want 403", code)
+	}
+}
+
+func TestSignMedia_OnlyOwnerGetsCleanOriginal(t *testing.T) {
+	tmpDir := t.TempDir()
+	storedName := "1_1765789611227708560.jpg"
+	if err := os.WriteFile(filepath.Join(tmpDir, storedName), []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mh := NewMediaHandler(nil, nil, newTestSigner(), nil, nil, nil, nil, nil)
+	mh.uploadDir = tmpDir
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/media/files/:name", mh.ServeFileHandler)
+	get := func(url string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w.Code
+	}
+
+	media := models.Media{ID: 7, StoredName: storedName, UserID: 1, UpdatedAt: time.Now().UnixMilli()}
+	mh.signMedia(&media, &models.User{ID: 1})
+	if !strings.Contains(media.URL, "clean=1") {
+		t.Fatalf("owner file URL %q should skip the watermark", media.URL)
+	}
+	if code := get(media.URL); code != http.StatusOK {
+		t.Errorf("owner file: got %d, want 200", code)
+	}
+
+	mh.signMedia(&media, &models.User{ID: 2})
+	if strings.Contains(media.URL, "clean=1") {
+		t.Fatalf("other users must not get the clean original: %q", media.URL)
+	}
+
+	// A viewer's signature does not unlock the clean original
+	if code := get(media.URL + "&clean=1"); code != http.StatusForbidden {
+		t.Errorf("forged clean original: got %d, want 403", code)
+	}
+}
+
+func TestSignPreview_OnlyUnlocksThumbnails(t *testing.T) {
+	tmpDir := t.TempDir()
+	storedName := "1_1765789611227708560.jpg"
+	if err := os.WriteFile(filepath.Join(tmpDir, storedName), []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := services.ThumbnailPath(tmpDir, "320x200", storedName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("thumb"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mh := NewMediaHandler(nil, nil, newTestSigner(), nil, nil, nil, nil, nil)
+	mh.uploadDir = tmpDir
+
+	media := models.Media{ID: 7, StoredName: storedName, UserID: 1, UpdatedAt: time.Now().UnixMilli()}
+	mh.signPreview(&media)
+	if media.URL != "" || media.StreamURL != "" {
+		t.Fatalf("preview should have no file or stream URL: %q %q", media.URL, media.StreamURL)
+	}
+	medium := media.Thumbnails["medium"]
+	if !strings.Contains(medium, "preview=1") {
+		t.Fatalf("unexpected preview thumbnail URL %q", medium)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/media/thumbs/:size/:name", mh.ServeThumbnailHandler)
+	router.GET("/api/media/files/:name", mh.ServeFileHandler)
+	get := func(url string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w.Code
+	}
+
+	if code := get(medium); code != http.StatusOK {
+		t.Errorf("preview thumbnail: got %d, want 200", code)
+	}
+
+	// The preview signature must not unlock the original file
+	query := medium[strings.Index(medium, "?"):]
+	if code := get("/api/media/files/" + storedName + query); code != http.StatusForbidden {
+		t.Errorf("original with a preview signature: got %d, want 403", code)
+	}
+}
+
+func TestGetMediaHandler_WatermarksForOthers(t *testing.T) {
+	tmpDir := t.TempDir()
+	storedName := "1_1765789611227708560.png"
+
+	var original bytes.Buffer
+	if err := png.Encode(&original, image.NewRGBA(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, storedName), original.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := newFakeDB(t, map[string]fakeRows{
+		"GetMediaByID": {
+			columns: []string{"id", "filename", "stored_name", "type", "mime_type", "size", "user_id", "visibility",
+				"description", "transcode_status", "scan_status", "scan_result", "blurhash", "dominant_color",
+				"aspect_ratio", "created_at", "updated_at", "deleted_at"},
+			rows: [][]driver.Value{{int64(7), "photo.png", storedName, "image", "image/png", int64(original.Len()), int64(1),
+				models.VisibilityPublic, "", services.TranscodeNone, services.ScanClean, "", "", "", 2.0, int64(0), int64(0), nil}},
+		},
+		"IsStoredMediaPublic": {columns: []string{"exists"}, rows: [][]driver.Value{{true}}},
+		"GetWatermarkForMediaKey": {
+			columns: []string{"user_id", "enabled", "text", "logo_name", "position", "opacity", "scale", "updated_at"},
+			rows:    [][]driver.Value{{int64(1), true, "© Owner", nil, "bottom-right", 1.0, 0.5, int64(0)}},
+		},
+	})
+	queries := db.New(conn)
+
+	mh := NewMediaHandler(conn, queries, newTestSigner(), nil, nil, nil, nil, services.NewWatermarkService(queries, tmpDir))
+	mh.uploadDir = tmpDir
+
+	gin.SetMode(gin.TestMode)
+	get := func(user *models.User) []byte {
+		router := gin.New()
+		router.GET("/api/media/:id", func(c *gin.Context) { c.Set("user", user) }, mh.GetMediaHandler)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/media/7", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("user %d: got %d: %s", user.ID, w.Code, w.Body.String())
+		}
+		return w.Body.Bytes()
+	}
+
+	if body := get(&models.User{ID: 1}); !bytes.Equal(body, original.Bytes()) {
+		t.Error("the owner should get the file as uploaded")
+	}
+
+	body := get(&models.User{ID: 2})
+	if bytes.Equal(body, original.Bytes()) {
+		t.Fatal("other users must not get the unwatermarked original")
+	}
+	if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+		t.Errorf("watermarked copy should stay a PNG: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// ListPublicAlbumsHandler returns a paginated list of public albums for
+// anonymous visitors, with a redacted owner projection
+func (mh *MediaHandler) ListPublicAlbumsHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	albums, total, err := mh.albums.ListPublicAlbums(c.Request.Context(), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error fetching albums"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"albums": albums,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	}})
+}
+
+// GetPublicAlbumHandler returns a public or shared album with its media for
+// anonymous visitors. Media that is private or has not passed the malware
+// scan is left out; thumbnails carry the owner's watermark.
+func (mh *MediaHandler) GetPublicAlbumHandler(c *gin.Context) {
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	album, err := mh.albums.GetAlbumByID(c.Request.Context(), nil, uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+	mediaRows, err := mh.albums.ListAlbumMedia(c.Request.Context(), nil, uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	medias := make([]models.Media, 0, len(mediaRows))
+	for _, row := range mediaRows {
+		if row.ScanStatus != services.ScanClean {
+			continue
+		}
+		media := mappers.MediaRowToModel(row)
+		mh.signMedia(&media, nil)
+		medias = append(medias, media)
+	}
+	album.MediaCount = int64(len(medias))
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"album": album,
+		"files": medias,
+	}})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/auth"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// SearchHandler handles full-text search requests
+type SearchHandler struct {
+	searchService *services.SearchService
+	signer        *auth.URLSigner
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(conn *sql.DB, signer *auth.URLSigner) *SearchHandler {
+	return &SearchHandler{
+		searchService: services.NewSearchService(conn),
+		signer:        signer,
+	}
+}
+
+// SearchAllHandler searches media, albums and videos.
+// Anonymous users only see public results; logged-in users also see their
+// own media and albums, and admins see everything.
+func (sh *SearchHandler) SearchAllHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	params := services.SearchParams{
+		Query:  c.Query("q"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if types := c.Query("type"); types != "" {
+		params.Types = strings.Split(types, ",")
+	}
+
+	// The user is only set when a valid token was sent
+	user := optionalUser(c)
+	if user != nil {
+		params.UserID = user.ID
+		params.IsAdmin = user.HasRole("admin")
+	}
+
+	hits, facets, err := sh.searchService.Search(c.Request.Context(), params)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSearch) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Search failed"})
+		return
+	}
+
+	for i := range hits {
+		hit := &hits[i]
+		if hit.Type != services.SearchTypeMedia || hit.ScanStatus != services.ScanClean {
+			continue
+		}
+		// As with signMedia, only the owner and admins skip the watermark
+		if user != nil && (user.ID == hit.OwnerID || user.HasRole("admin")) {
+			hit.URL = sh.signer.SignURL(mappers.GetMediaURL(hit.StoredName)+"?clean=1", auth.CleanMediaKey(hit.StoredName))
+		} else {
+			hit.URL = sh.signer.SignURL(mappers.GetMediaURL(hit.StoredName), auth.MediaKey(hit.StoredName))
+		}
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"query":   params.Query,
+		"results": hits,
+		"facets":  facets,
+		"limit":   limit,
+		"offset":  offset,
+	}})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// SharePasswordHeader carries the password of a protected share link
+const SharePasswordHeader = "X-Share-Password"
+
+// CreateShareLinkRequest represents a request to create a share link
+type CreateShareLinkRequest struct {
+	Password      string `json:"password"`       // Optional
+	ExpiresAt     int64  `json:"expires_at"`     // Unix ms; 0 never expires
+	MaxViews      int    `json:"max_views"`      // 0 for unlimited views
+	AllowDownload *bool  `json:"allow_download"` // Defaults to true
+}
+
+// CreateShareLinkHandler creates a share link for an album (Owner or Admin)
+func (ah *AlbumHandler) CreateShareLinkHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	link, err := ah.albumService.CreateShareLink(c.Request.Context(), user, uint(albumID), services.ShareLinkOptions{
+		Password:      req.Password,
+		ExpiresAt:     req.ExpiresAt,
+		MaxViews:      req.MaxViews,
+		AllowDownload: req.AllowDownload == nil || *req.AllowDownload,
+	})
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{Data: link})
+}
+
+// ListShareLinksHandler lists the share links of an album with their view
+// counts (Owner or Admin)
+func (ah *AlbumHandler) ListShareLinksHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	links, err := ah.albumService.ListShareLinks(c.Request.Context(), user, uint(albumID))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: links})
+}
+
+// RevokeShareLinkHandler revokes a share link (Owner or Admin)
+func (ah *AlbumHandler) RevokeShareLinkHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+	linkID, err := strconv.ParseUint(c.Param("share_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid share link ID"})
+		return
+	}
+
+	if err := ah.albumService.RevokeShareLink(c.Request.Context(), user, uint(albumID), uint(linkID)); err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Share link revoked"}})
+}
+
+// OpenShareLinkHandler returns the album behind a share link, with signed
+// links to its media, for anonymous visitors. The password of a protected
+// link goes in the X-Share-Password header. Every successful request counts
+// as a view. Without downloads, only watermarked thumbnails are linked.
+func (mh *MediaHandler) OpenShareLinkHandler(c *gin.Context) {
+	shared, err := mh.albums.OpenShareLink(c.Request.Context(), c.Param("slug"), c.GetHeader(SharePasswordHeader))
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	medias := make([]models.Media, 0, len(shared.Media))
+	for _, row := range shared.Media {
+		if row.ScanStatus != services.ScanClean {
+			continue
+		}
+		media := mappers.MediaRowToModel(row)
+		if shared.AllowDownload {
+			mh.signMedia(&media, nil)
+		} else {
+			mh.signPreview(&media)
+		}
+		medias = append(medias, media)
+	}
+	shared.Album.MediaCount = int64(len(medias))
+
+	c.Header("Cache-Control", "private, no-store")
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"album":          shared.Album,
+		"files":          medias,
+		"allow_download": shared.AllowDownload,
+	}})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// SmartRulesRequest carries a smart album rule definition
+type SmartRulesRequest struct {
+	Rules json.RawMessage `json:"rules" binding:"required"`
+}
+
+// SetSmartRulesHandler turns an album into a smart album, or changes its
+// rules (Owner or Admin)
+func (ah *AlbumHandler) SetSmartRulesHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	var req SmartRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	album, err := ah.albumService.SetSmartRules(c.Request.Context(), user, uint(albumID), req.Rules)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// ClearSmartRulesHandler turns a smart album back into a regular album with
+// the media added to it by hand (Owner or Admin)
+func (ah *AlbumHandler) ClearSmartRulesHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	album, err := ah.albumService.SetSmartRules(c.Request.Context(), user, uint(albumID), nil)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, album)
+}
+
+// PreviewSmartRulesHandler returns the first media a rule definition would
+// list in an album, and how many it matches, without saving it (Owner or
+// Admin)
+func (mh *MediaHandler) PreviewSmartRulesHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return
+	}
+
+	var req SmartRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	preview, err := mh.albums.PreviewSmartRules(c.Request.Context(), user, uint(albumID), req.Rules)
+	if err != nil {
+		writeAlbumError(c, err)
+		return
+	}
+
+	medias := make([]models.Media, len(preview.Media))
+	for i, row := range preview.Media {
+		medias[i] = mappers.MediaRowToModel(row)
+		mh.signMedia(&medias[i], user)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]interface{}{
+		"media": medias,
+		"total": preview.Total,
+	}})
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// TagHandler handles tag-related HTTP requests
+type TagHandler struct {
+	queries      *db.Queries
+	tagService   *services.TagService
+	albumService *services.AlbumService
+}
+
+// NewTagHandler creates a new tag handler
+func NewTagHandler(conn *sql.DB, queries *db.Queries, albumService *services.AlbumService) *TagHandler {
+	return &TagHandler{
+		queries:      queries,
+		tagService:   services.NewTagService(conn, queries),
+		albumService: albumService,
+	}
+}
+
+// TagsRequest represents a request to attach tags
+type TagsRequest struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+// writeTagError maps tag service errors to HTTP responses
+func writeTagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTag), errors.Is(err, services.ErrTooManyTags):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrTagNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Tag not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+	}
+}
+
+// ownedMediaID parses the :id parameter and checks the current user owns the
+// media item or is an admin. It writes the error response itself.
+func (th *TagHandler) ownedMediaID(c *gin.Context, user *models.User) (uint, bool) {
+	mediaID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid ID"})
+		return 0, false
+	}
+
+	mediaRow, err := th.queries.GetMediaByID(c.Request.Context(), mediaID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Media not found"})
+			return 0, false
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return 0, false
+	}
+
+	// Access Control: Owner or Admin
+	if uint64(mediaRow.UserID) != uint64(user.ID) && !user.HasRole("admin") {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Forbidden"})
+		return 0, false
+	}
+	return uint(mediaRow.ID), true
+}
+
+// authorizedAlbumID parses the :id parameter and checks the current user has
+// the needed access to the album. It writes the error response itself.
+func (th *TagHandler) authorizedAlbumID(c *gin.Context, user *models.User, need services.AlbumAccess) (uint, bool) {
+	albumID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid album ID"})
+		return 0, false
+	}
+
+	album, _, err := th.albumService.Authorize(c.Request.Context(), user, uint(albumID), need)
+	if err != nil {
+		writeAlbumError(c, err)
+		return 0, false
+	}
+	return uint(album.ID), true
+}
+
+// AddMediaTagsHandler attaches tags to a media item (Owner or Admin)
+func (th *TagHandler) AddMediaTagsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	mediaID, ok := th.ownedMediaID(c, user)
+	if !ok {
+		return
+	}
+
+	var req TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tags, err := th.tagService.AddMediaTags(c.Request.Context(), mediaID, user.ID, req.Tags)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// RemoveMediaTagHandler detaches a tag from a media item (Owner or Admin)
+func (th *TagHandler) RemoveMediaTagHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	mediaID, ok := th.ownedMediaID(c, user)
+	if !ok {
+		return
+	}
+
+	if err := th.tagService.RemoveMediaTag(c.Request.Context(), mediaID, c.Param("tag")); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Tag removed"}})
+}
+
+// ListMediaTagsHandler lists the tags of a media item (Owner or Admin)
+func (th *TagHandler) ListMediaTagsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	mediaID, ok := th.ownedMediaID(c, user)
+	if !ok {
+		return
+	}
+
+	tags, err := th.tagService.ListMediaTags(c.Request.Context(), mediaID)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// AddAlbumTagsHandler attaches tags to an album (Editor, Owner or Admin)
+func (th *TagHandler) AddAlbumTagsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	albumID, ok := th.authorizedAlbumID(c, user, services.AlbumAccessEdit)
+	if !ok {
+		return
+	}
+
+	var req TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tags, err := th.tagService.AddAlbumTags(c.Request.Context(), albumID, user.ID, req.Tags)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// RemoveAlbumTagHandler detaches a tag from an album (Editor, Owner or Admin)
+func (th *TagHandler) RemoveAlbumTagHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	albumID, ok := th.authorizedAlbumID(c, user, services.AlbumAccessEdit)
+	if !ok {
+		return
+	}
+
+	if err := th.tagService.RemoveAlbumTag(c.Request.Context(), albumID, c.Param("tag")); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Tag removed"}})
+}
+
+// ListAlbumTagsHandler lists the tags of an album (anyone who can see it)
+func (th *TagHandler) ListAlbumTagsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	albumID, ok := th.authorizedAlbumID(c, user, services.AlbumAccessView)
+	if !ok {
+		return
+	}
+
+	tags, err := th.tagService.ListAlbumTags(c.Request.Context(), albumID)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// AutocompleteTagsHandler suggests tags for a prefix: curated tags and tags
+// the current user has used
+func (th *TagHandler) AutocompleteTagsHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	tags, err := th.tagService.Autocomplete(c.Request.Context(), user.ID, c.Query("q"), limit)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// TagCloudHandler returns the most used tags on public media with their counts
+func (th *TagHandler) TagCloudHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	counts, err := th.tagService.Cloud(c.Request.Context(), limit)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: counts})
+}
+
+// ListCuratedTagsHandler lists all curated tags
+func (th *TagHandler) ListCuratedTagsHandler(c *gin.Context) {
+	tags, err := th.tagService.ListCurated(c.Request.Context())
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tags})
+}
+
+// CreateCuratedTagHandler creates a curated tag (Admin only)
+func (th *TagHandler) CreateCuratedTagHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tag, err := th.tagService.CreateCurated(c.Request.Context(), req.Name, user.ID)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{Data: tag})
+}
+
+// UpdateTagHandler marks a tag as curated or free-form (Admin only)
+func (th *TagHandler) UpdateTagHandler(c *gin.Context) {
+	tagID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid tag ID"})
+		return
+	}
+
+	var req struct {
+		IsCurated *bool `json:"is_curated" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tag, err := th.tagService.SetCurated(c.Request.Context(), uint(tagID), *req.IsCurated)
+	if err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: tag})
+}
+
+// DeleteTagHandler deletes a tag everywhere it is used (Admin only)
+func (th *TagHandler) DeleteTagHandler(c *gin.Context) {
+	tagID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid tag ID"})
+		return
+	}
+
+	if err := th.tagService.DeleteTag(c.Request.Context(), uint(tagID)); err != nil {
+		writeTagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: map[string]string{"message": "Tag deleted"}})
+}
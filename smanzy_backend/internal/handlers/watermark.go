@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ristep/smanzy_backend/internal/services"
+)
+
+// WatermarkHandler handles the watermark settings of the current user
+type WatermarkHandler struct {
+	watermarks *services.WatermarkService
+}
+
+// NewWatermarkHandler creates a new watermark handler
+func NewWatermarkHandler(watermarks *services.WatermarkService) *WatermarkHandler {
+	return &WatermarkHandler{watermarks: watermarks}
+}
+
+// UpdateWatermarkRequest represents a change to the watermark settings.
+// Omitted fields keep their current value.
+type UpdateWatermarkRequest struct {
+	Enabled  *bool    `json:"enabled"`
+	Text     *string  `json:"text"`
+	Position *string  `json:"position"`
+	Opacity  *float64 `json:"opacity"`
+	Scale    *float64 `json:"scale"`
+}
+
+// writeWatermarkError maps watermark service errors to HTTP responses
+func writeWatermarkError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidWatermark) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to save watermark"})
+}
+
+// GetWatermarkHandler returns the watermark settings of the current user
+func (wh *WatermarkHandler) GetWatermarkHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	settings, err := wh.watermarks.Get(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: settings})
+}
+
+// UpdateWatermarkHandler changes the watermark settings of the current user
+func (wh *WatermarkHandler) UpdateWatermarkHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateWatermarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	settings, err := wh.watermarks.Get(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Database error"})
+		return
+	}
+	if req.Enabled != nil {
+		settings.Enabled = *req.Enabled
+	}
+	if req.Text != nil {
+		settings.Text = *req.Text
+	}
+	if req.Position != nil {
+		settings.Position = *req.Position
+	}
+	if req.Opacity != nil {
+		settings.Opacity = *req.Opacity
+	}
+	if req.Scale != nil {
+		settings.Scale = *req.Scale
+	}
+
+	settings, err = wh.watermarks.Update(c.Request.Context(), user.ID, settings)
+	if err != nil {
+		writeWatermarkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: settings})
+}
+
+// UploadWatermarkLogoHandler sets the watermark logo of the current user from
+// a PNG sent as the multipart field "logo"
+func (wh *WatermarkHandler) UploadWatermarkLogoHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("logo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No logo provided"})
+		return
+	}
+	if fileHeader.Size > services.MaxWatermarkLogoSize {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: "Logo is too large"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to read logo"})
+		return
+	}
+	defer file.Close()
+
+	settings, err := wh.watermarks.SetLogo(c.Request.Context(), user.ID, file)
+	if err != nil {
+		writeWatermarkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: settings})
+}
+
+// DeleteWatermarkLogoHandler removes the watermark logo of the current user
+func (wh *WatermarkHandler) DeleteWatermarkLogoHandler(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	settings, err := wh.watermarks.RemoveLogo(c.Request.Context(), user.ID)
+	if err != nil {
+		writeWatermarkError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{Data: settings})
+}
@@ -1,6 +1,9 @@
 package mappers
 
 import (
+	"database/sql"
+	"encoding/json"
+
 	"github.com/ristep/smanzy_backend/internal/db"
 	"github.com/ristep/smanzy_backend/internal/models"
 )
@@ -10,28 +13,77 @@ func AlbumRowToModel(row interface{}) models.Album {
 	switch r := row.(type) {
 	case db.Album:
 		return models.Album{
-			ID:          uint(r.ID),
-			Title:       r.Title,
-			Description: r.Description.String,
-			UserID:      uint(r.UserID),
-			IsPublic:    r.IsPublic.Bool,
-			IsShared:    r.IsShared.Bool,
-			CreatedAt:   r.CreatedAt,
-			UpdatedAt:   r.UpdatedAt,
+			ID:           uint(r.ID),
+			Title:        r.Title,
+			Description:  r.Description.String,
+			UserID:       uint(r.UserID),
+			IsPublic:     r.IsPublic.Bool,
+			IsShared:     r.IsShared.Bool,
+			SortMode:     r.SortMode,
+			IsSmart:      r.SmartRules.Valid,
+			Rules:        smartRules(r.SmartRules),
+			ParentID:     uint(r.ParentID.Int64),
+			CoverMediaID: uint(r.CoverMediaID.Int64),
+			CreatedAt:    r.CreatedAt,
+			UpdatedAt:    r.UpdatedAt,
 		}
 	case db.ListUserAlbumsRow:
 		return models.Album{
-			ID:          uint(r.ID),
-			Title:       r.Title,
-			Description: r.Description.String,
-			UserID:      uint(r.UserID),
-			UserName:    r.UserName,
-			IsPublic:    r.IsPublic.Bool,
-			IsShared:    r.IsShared.Bool,
-			CreatedAt:   r.CreatedAt,
-			UpdatedAt:   r.UpdatedAt,
+			ID:              uint(r.ID),
+			Title:           r.Title,
+			Description:     r.Description.String,
+			UserID:          uint(r.UserID),
+			UserName:        r.UserName,
+			IsPublic:        r.IsPublic.Bool,
+			IsShared:        r.IsShared.Bool,
+			Role:            r.Role,
+			SortMode:        r.SortMode,
+			IsSmart:         r.SmartRules.Valid,
+			Rules:           smartRules(r.SmartRules),
+			ParentID:        uint(r.ParentID.Int64),
+			CoverMediaID:    uint(r.CoverID.Int64),
+			CoverStoredName: r.CoverStoredName.String,
+			CoverOwnerID:    uint(r.CoverUserID.Int64),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
 		}
 	case db.ListAllAlbumsRow:
+		return models.Album{
+			ID:              uint(r.ID),
+			Title:           r.Title,
+			Description:     r.Description.String,
+			UserID:          uint(r.UserID),
+			UserName:        r.UserName,
+			IsPublic:        r.IsPublic.Bool,
+			IsShared:        r.IsShared.Bool,
+			SortMode:        r.SortMode,
+			IsSmart:         r.SmartRules.Valid,
+			Rules:           smartRules(r.SmartRules),
+			ParentID:        uint(r.ParentID.Int64),
+			CoverMediaID:    uint(r.CoverID.Int64),
+			CoverStoredName: r.CoverStoredName.String,
+			CoverOwnerID:    uint(r.CoverUserID.Int64),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
+		}
+	case db.ListAlbumTreeRow:
+		return models.Album{
+			ID:           uint(r.ID),
+			Title:        r.Title,
+			Description:  r.Description.String,
+			UserID:       uint(r.UserID),
+			IsPublic:     r.IsPublic.Bool,
+			IsShared:     r.IsShared.Bool,
+			MediaCount:   r.MediaCount,
+			SortMode:     r.SortMode,
+			IsSmart:      r.SmartRules.Valid,
+			Rules:        smartRules(r.SmartRules),
+			CoverMediaID: uint(r.CoverMediaID.Int64),
+			ParentID:     uint(r.ParentID.Int64),
+			CreatedAt:    r.CreatedAt,
+			UpdatedAt:    r.UpdatedAt,
+		}
+	case db.ListPublicAlbumsRow:
 		return models.Album{
 			ID:          uint(r.ID),
 			Title:       r.Title,
@@ -40,6 +92,10 @@ func AlbumRowToModel(row interface{}) models.Album {
 			UserName:    r.UserName,
 			IsPublic:    r.IsPublic.Bool,
 			IsShared:    r.IsShared.Bool,
+			MediaCount:  r.MediaCount,
+			SortMode:    r.SortMode,
+			IsSmart:     r.SmartRules.Valid,
+			Rules:       smartRules(r.SmartRules),
 			CreatedAt:   r.CreatedAt,
 			UpdatedAt:   r.UpdatedAt,
 		}
@@ -48,6 +104,14 @@ func AlbumRowToModel(row interface{}) models.Album {
 	}
 }
 
+// smartRules returns the stored rules of a smart album, or nil
+func smartRules(rules sql.NullString) json.RawMessage {
+	if !rules.Valid {
+		return nil
+	}
+	return json.RawMessage(rules.String)
+}
+
 // ListUserAlbumsRowsToModels converts multiple album rows to Album models
 func ListUserAlbumsRowsToModels(rows []db.ListUserAlbumsRow) []models.Album {
 	albums := make([]models.Album, len(rows))
@@ -65,3 +129,41 @@ func ListAllAlbumsRowsToModels(rows []db.ListAllAlbumsRow) []models.Album {
 	}
 	return albums
 }
+
+// ListPublicAlbumsRowsToModels converts multiple album rows to Album models
+func ListPublicAlbumsRowsToModels(rows []db.ListPublicAlbumsRow) []models.Album {
+	albums := make([]models.Album, len(rows))
+	for i, row := range rows {
+		albums[i] = AlbumRowToModel(row)
+	}
+	return albums
+}
+
+// ShareLinkToModel converts a share link row to a ShareLink model
+func ShareLinkToModel(l db.AlbumShareLink) models.ShareLink {
+	return models.ShareLink{
+		ID:            uint(l.ID),
+		AlbumID:       uint(l.AlbumID),
+		Slug:          l.Slug,
+		HasPassword:   l.PasswordHash.Valid,
+		ExpiresAt:     l.ExpiresAt.Int64,
+		MaxViews:      int(l.MaxViews.Int32),
+		ViewCount:     int(l.ViewCount),
+		AllowDownload: l.AllowDownload,
+		CreatedBy:     uint(l.CreatedBy),
+		CreatedAt:     l.CreatedAt,
+		LastViewedAt:  l.LastViewedAt.Int64,
+		RevokedAt:     l.RevokedAt.Int64,
+	}
+}
+
+// AlbumMemberToModel converts an album member row to an AlbumMember model
+func AlbumMemberToModel(r db.ListAlbumMembersRow) models.AlbumMember {
+	return models.AlbumMember{
+		AlbumID:   uint(r.AlbumID),
+		UserID:    uint(r.UserID),
+		UserName:  r.UserName,
+		Role:      r.Role,
+		CreatedAt: r.CreatedAt,
+	}
+}
@@ -3,6 +3,7 @@ package mappers
 import (
 	"database/sql"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -11,16 +12,21 @@ func GetMediaURL(storedName string) string {
 	return "/api/media/files/" + storedName
 }
 
+// GetStreamURL constructs the URL of the HLS master playlist of a media item
+func GetStreamURL(mediaID uint) string {
+	return "/api/media/" + strconv.FormatUint(uint64(mediaID), 10) + "/stream/master.m3u8"
+}
+
 // GetThumbnailURL constructs the public URL for a media thumbnail.
 // It assumes the thumbnail format is JPEG.
-// second is optional
+// size is optional and defaults to 320x200.
 func GetThumbnailURL(storedName string, size string) string {
 	ext := filepath.Ext(storedName)
 	nameWithoutExt := strings.TrimSuffix(storedName, ext)
 	if size == "" {
 		size = "320x200"
 	}
-	return "/api/media/" + size + "/" + nameWithoutExt + ".jpg"
+	return "/api/media/thumbs/" + size + "/" + nameWithoutExt + ".jpg"
 }
 
 // NullStringToString safely converts sql.NullString to string.
@@ -15,12 +15,20 @@ func MediaRowToModel(row any) models.Media {
 			Filename:   r.Filename,
 			StoredName: r.StoredName,
 			// URL:        GetMediaURL(r.StoredName),
-			Type:       r.Type,
-			MimeType:   r.MimeType,
-			Size:       r.Size,
-			UserID:     uint(r.UserID),
-			CreatedAt:  r.CreatedAt,
-			UpdatedAt:  r.UpdatedAt,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
 		}
 	case db.ListPublicMediaRow:
 		return models.Media{
@@ -28,15 +36,21 @@ func MediaRowToModel(row any) models.Media {
 			Filename:   r.Filename,
 			StoredName: r.StoredName,
 			// URL:        GetMediaURL(r.StoredName),
-			Type:       r.Type,
-			MimeType:   r.MimeType,
-			Size:       r.Size,
-			UserID:     uint(r.UserID),
-			UserName:   r.UserName,
-			UserTel:    r.UserTel.String,
-			UserEmail:  r.UserEmail,
-			CreatedAt:  r.CreatedAt,
-			UpdatedAt:  r.UpdatedAt,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			UserName:        r.UserName,
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
 		}
 	case db.ListUserMediaRow:
 		return models.Media{
@@ -44,12 +58,20 @@ func MediaRowToModel(row any) models.Media {
 			Filename:   r.Filename,
 			StoredName: r.StoredName,
 			// URL:        GetMediaURL(r.StoredName),
-			Type:       r.Type,
-			MimeType:   r.MimeType,
-			Size:       r.Size,
-			UserID:     uint(r.UserID),
-			CreatedAt:  r.CreatedAt,
-			UpdatedAt:  r.UpdatedAt,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
 		}
 	case db.CreateMediaRow:
 		return models.Media{
@@ -57,12 +79,20 @@ func MediaRowToModel(row any) models.Media {
 			Filename:   r.Filename,
 			StoredName: r.StoredName,
 			// URL:        GetMediaURL(r.StoredName),
-			Type:       r.Type,
-			MimeType:   r.MimeType,
-			Size:       r.Size,
-			UserID:     uint(r.UserID),
-			CreatedAt:  r.CreatedAt,
-			UpdatedAt:  r.UpdatedAt,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
 		}
 	case db.UpdateMediaRow:
 		return models.Media{
@@ -70,12 +100,109 @@ func MediaRowToModel(row any) models.Media {
 			Filename:   r.Filename,
 			StoredName: r.StoredName,
 			// URL:        GetMediaURL(r.StoredName),
-			Type:       r.Type,
-			MimeType:   r.MimeType,
-			Size:       r.Size,
-			UserID:     uint(r.UserID),
-			CreatedAt:  r.CreatedAt,
-			UpdatedAt:  r.UpdatedAt,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
+		}
+	case db.GetMediaByIDWithDeletedRow:
+		media := models.Media{
+			ID:              uint(r.ID),
+			Filename:        r.Filename,
+			StoredName:      r.StoredName,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
+		}
+		if r.DeletedAt.Valid {
+			media.DeletedAt = &r.DeletedAt.Time
+		}
+		return media
+	case db.ListTrashedMediaRow:
+		media := models.Media{
+			ID:              uint(r.ID),
+			Filename:        r.Filename,
+			StoredName:      r.StoredName,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
+		}
+		// Trashed media carries its deletion time so clients can show when it will be purged
+		if r.DeletedAt.Valid {
+			media.DeletedAt = &r.DeletedAt.Time
+		}
+		return media
+	case db.ReplaceMediaFileRow:
+		return models.Media{
+			ID:              uint(r.ID),
+			Filename:        r.Filename,
+			StoredName:      r.StoredName,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
+		}
+	case db.ListSimilarMediaRow:
+		return models.Media{
+			ID:              uint(r.ID),
+			Filename:        r.Filename,
+			StoredName:      r.StoredName,
+			Type:            r.Type,
+			MimeType:        r.MimeType,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult,
+			BlurHash:        r.Blurhash,
+			DominantColor:   r.DominantColor,
+			AspectRatio:     r.AspectRatio,
+			UserID:          uint(r.UserID),
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
 		}
 	case db.Medium:
 		return models.Media{
@@ -83,12 +210,21 @@ func MediaRowToModel(row any) models.Media {
 			Filename:   r.Filename,
 			StoredName: r.StoredName,
 			// URL:        GetMediaURL(r.StoredName),
-			Type:       r.Type.String,
-			MimeType:   r.MimeType.String,
-			Size:       r.Size,
-			UserID:     uint(r.UserID),
-			CreatedAt:  r.CreatedAt,
-			UpdatedAt:  r.UpdatedAt,
+			Type:            r.Type.String,
+			MimeType:        r.MimeType.String,
+			Size:            r.Size,
+			Visibility:      r.Visibility,
+			Description:     r.Description.String,
+			TranscodeStatus: r.TranscodeStatus,
+			ScanStatus:      r.ScanStatus,
+			ScanResult:      r.ScanResult.String,
+			BlurHash:        r.Blurhash.String,
+			DominantColor:   r.DominantColor.String,
+			AspectRatio:     r.AspectRatio.Float64,
+			UserID:          uint(r.UserID),
+			CapturedAt:      r.CapturedAt.Int64,
+			CreatedAt:       r.CreatedAt,
+			UpdatedAt:       r.UpdatedAt,
 		}
 	default:
 		return models.Media{}
@@ -103,3 +239,30 @@ func ListPublicMediaRowsToModels(rows []db.ListPublicMediaRow) []models.Media {
 	}
 	return medias
 }
+
+// MediaVersionToModel converts a database media version row to a MediaVersion model
+func MediaVersionToModel(v db.MediaVersion) models.MediaVersion {
+	return models.MediaVersion{
+		ID:         uint(v.ID),
+		MediaID:    uint(v.MediaID),
+		Version:    int(v.Version),
+		Filename:   v.Filename,
+		StoredName: v.StoredName,
+		MimeType:   v.MimeType,
+		Size:       v.Size,
+		CreatedAt:  v.CreatedAt,
+	}
+}
+
+// URLImportToModel converts a URL import job to its model
+func URLImportToModel(row db.MediaUrlImport) models.URLImport {
+	return models.URLImport{
+		ID:        uint(row.ID),
+		URL:       row.Url,
+		Status:    row.Status,
+		Error:     row.Error.String,
+		MediaID:   uint(row.MediaID.Int64),
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
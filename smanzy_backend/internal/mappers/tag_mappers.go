@@ -0,0 +1,25 @@
+package mappers
+
+import (
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// TagToModel converts a database tag row to a Tag model
+func TagToModel(t db.Tag) models.Tag {
+	return models.Tag{
+		ID:        uint(t.ID),
+		Name:      t.Name,
+		IsCurated: t.IsCurated,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+// TagsToModels converts multiple tag rows to Tag models
+func TagsToModels(rows []db.Tag) []models.Tag {
+	tags := make([]models.Tag, len(rows))
+	for i, row := range rows {
+		tags[i] = TagToModel(row)
+	}
+	return tags
+}
@@ -105,3 +105,16 @@ func ListUsersRowsToModels(rows []db.ListUsersRow) []models.User {
 	}
 	return users
 }
+
+// WatermarkToModel converts a user's watermark settings to their model
+func WatermarkToModel(w db.UserWatermark) models.WatermarkSettings {
+	return models.WatermarkSettings{
+		Enabled:   w.Enabled,
+		Text:      w.Text,
+		HasLogo:   w.LogoName.Valid,
+		Position:  w.Position,
+		Opacity:   w.Opacity,
+		Scale:     w.Scale,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
@@ -145,3 +145,17 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalAuthMiddleware authenticates the request when an Authorization
+// header is present and lets anonymous requests through otherwise.
+// A header with an invalid token is still rejected, so clients know to refresh it.
+func OptionalAuthMiddleware(jwtService *auth.JWTService, queries *db.Queries) gin.HandlerFunc {
+	authenticate := AuthMiddleware(jwtService, queries)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authenticate(c)
+	}
+}
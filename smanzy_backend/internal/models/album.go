@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // MediaAlbum represents a collection/album of media files
 type Album struct {
@@ -14,7 +17,54 @@ type Album struct {
 	IsPublic bool `json:"is_public"`
 	IsShared bool `json:"is_shared"`
 
+	MediaCount int64  `json:"media_count,omitempty"` // Set by listings that count media
+	Role       string `json:"role,omitempty"`        // The caller's role in "my albums": owner, viewer, contributor or editor
+
+	// Nesting: the parent album (0 at the top level) and, for single-album
+	// views, the parents the caller can see, top level first
+	ParentID    uint         `json:"parent_id,omitempty"`
+	Breadcrumbs []AlbumCrumb `json:"breadcrumbs,omitempty"`
+
+	// Smart albums list the owner's media matching their rules instead of
+	// media added by hand
+	IsSmart bool            `json:"is_smart"`
+	Rules   json.RawMessage `json:"rules,omitempty"`
+
+	// Order of the album's media: manual, captured, uploaded or name
+	SortMode string `json:"sort_mode"`
+
+	// Cover: the chosen cover media, or in listings the first media the
+	// caller sees when none is chosen, with a signed medium thumbnail
+	CoverMediaID    uint   `json:"cover_media_id,omitempty"`
+	CoverThumbnail  string `json:"cover_thumbnail_url,omitempty"`
+	CoverStoredName string `json:"-"`
+	CoverOwnerID    uint   `json:"-"`
+
 	CreatedAt int64      `json:"created_at"`
 	UpdatedAt int64      `json:"updated_at"`
 	DeletedAt *time.Time `json:"-"`
 }
+
+// AlbumCrumb is a parent album in breadcrumbs
+type AlbumCrumb struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+}
+
+// AlbumNode is an album in a tree of sub-albums. MediaCount is the media in
+// the album itself.
+type AlbumNode struct {
+	Album
+	TotalMediaCount int64        `json:"total_media_count"` // Media in the album and all its sub-albums
+	AlbumCount      int          `json:"album_count"`       // Sub-albums at any depth
+	Children        []*AlbumNode `json:"children"`
+}
+
+// AlbumMember is a collaborator on an album
+type AlbumMember struct {
+	AlbumID   uint   `json:"album_id"`
+	UserID    uint   `json:"user_id"`
+	UserName  string `json:"user_name"`
+	Role      string `json:"role"` // viewer, contributor or editor
+	CreatedAt int64  `json:"created_at"`
+}
@@ -9,18 +9,106 @@ type Media struct {
 	StoredName string `json:"stored_name"` // Unique name on disk (to prevent overwrites)
 	URL        string `json:"url"`         // Public URL to access the file
 
-	Type     string `json:"type"`      // General category (e.g., "image", "video")
-	MimeType string `json:"mime_type"` // Specific MIME type (e.g., "image/jpeg", "application/pdf")
-	Size     int64  `json:"size"`      // File size in bytes
-
-	UserID    uint   `json:"user_id"`
-	UserName  string `json:"user_name"`
-	UserTel   string `json:"user_tel"`
-	UserEmail string `json:"user_email"` // Add this field
-
-	CreatedAt int64      `json:"created_at"`
-	UpdatedAt int64      `json:"updated_at"`
-	DeletedAt *time.Time `json:"-"`
+	Type       string `json:"type"`       // General category (e.g., "image", "video")
+	MimeType   string `json:"mime_type"`  // Specific MIME type (e.g., "image/jpeg", "application/pdf")
+	Size       int64  `json:"size"`       // File size in bytes
+	Visibility string `json:"visibility"` // One of the Visibility* constants
+
+	Description string `json:"description"` // Free text, indexed for search
+
+	// Video streaming: HLS transcoding status (none, pending, processing, ready
+	// or failed) and the signed master playlist URL once it is ready
+	TranscodeStatus string `json:"transcode_status"`
+	StreamURL       string `json:"stream_url,omitempty"`
+
+	// Malware scan: status (pending, scanning, clean, infected or failed) and
+	// the signature found or why the scan failed. Files are only served once clean.
+	ScanStatus string `json:"scan_status"`
+	ScanResult string `json:"scan_result,omitempty"`
+
+	// Thumbnails: status (none, pending, ready or failed) and signed URLs of
+	// the sizes that exist, keyed small, medium, large and xl
+	ThumbnailStatus string            `json:"thumbnail_status"`
+	Thumbnails      map[string]string `json:"thumbnails,omitempty"`
+
+	// Placeholders for painting the item before its thumbnail loads; empty
+	// until the thumbnail indexer has processed it
+	BlurHash      string  `json:"blurhash,omitempty"`
+	DominantColor string  `json:"dominant_color,omitempty"` // #rrggbb
+	AspectRatio   float64 `json:"aspect_ratio,omitempty"`   // Width / height
+
+	// Owner projection: only the public display name, never contact details
+	UserID   uint   `json:"user_id"`
+	UserName string `json:"user_name,omitempty"`
+
+	Tags []string `json:"tags,omitempty"` // Tag names, filled in for detail views
+
+	CapturedAt int64      `json:"captured_at,omitempty"` // When the photo was taken (EXIF), filled in for album media
+	CreatedAt  int64      `json:"created_at"`
+	UpdatedAt  int64      `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Set only for media in the trash
 }
 
 // end of Media struct
+
+// Media visibility levels
+const (
+	VisibilityPrivate  = "private"  // Owner (and admins) only
+	VisibilityUnlisted = "unlisted" // Anyone with the link, never listed in feeds
+	VisibilityPublic   = "public"   // Listed in the public feed
+	VisibilityInherit  = "inherit"  // Public when the media is in a public album
+)
+
+// IsValidVisibility reports whether v is a known visibility level
+func IsValidVisibility(v string) bool {
+	switch v {
+	case VisibilityPrivate, VisibilityUnlisted, VisibilityPublic, VisibilityInherit:
+		return true
+	}
+	return false
+}
+
+// MediaBatchResult reports the outcome of a batch operation for one media item
+type MediaBatchResult struct {
+	ID       uint   `json:"id"`
+	Status   string `json:"status"` // "ok" or "error"
+	Error    string `json:"error,omitempty"`
+	Filename string `json:"filename,omitempty"` // Filename after the operation
+}
+
+// MediaVersion is an earlier file of a media item, kept when the file was replaced
+type MediaVersion struct {
+	ID         uint   `json:"id"`
+	MediaID    uint   `json:"media_id"`
+	Version    int    `json:"version"`
+	Filename   string `json:"filename"`
+	StoredName string `json:"stored_name"`
+	URL        string `json:"url"` // Signed URL to the archived file
+	MimeType   string `json:"mime_type"`
+	Size       int64  `json:"size"`
+	CreatedAt  int64  `json:"created_at"` // When the version was archived
+}
+
+// URLImport is a background job fetching a media file from a remote URL
+type URLImport struct {
+	ID        uint   `json:"id"`
+	URL       string `json:"url"`
+	Status    string `json:"status"` // pending, fetching, done or failed
+	Error     string `json:"error,omitempty"`
+	MediaID   uint   `json:"media_id,omitempty"`
+	Media     *Media `json:"media,omitempty"` // The created media item, once done
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// SimilarMedia is a media item with its perceptual hash distance to another item
+type SimilarMedia struct {
+	Media
+	Distance int `json:"distance"` // Differing hash bits, 0 = visually identical
+}
+
+// DuplicateGroup is a set of media items that look alike
+type DuplicateGroup struct {
+	Items       []Media `json:"items"`        // Oldest first
+	MaxDistance int     `json:"max_distance"` // Largest distance between linked items
+}
@@ -0,0 +1,18 @@
+package models
+
+// ShareLink opens an album to anyone who has the link, without an account.
+// Only the album's owner and admins see share links and their counts.
+type ShareLink struct {
+	ID            uint   `json:"id"`
+	AlbumID       uint   `json:"album_id"`
+	Slug          string `json:"slug"`
+	HasPassword   bool   `json:"has_password"`
+	ExpiresAt     int64  `json:"expires_at,omitempty"` // Unix ms; omitted if the link never expires
+	MaxViews      int    `json:"max_views,omitempty"`  // Omitted for unlimited views
+	ViewCount     int    `json:"view_count"`
+	AllowDownload bool   `json:"allow_download"`
+	CreatedBy     uint   `json:"created_by"`
+	CreatedAt     int64  `json:"created_at"`
+	LastViewedAt  int64  `json:"last_viewed_at,omitempty"`
+	RevokedAt     int64  `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// Tag is a label attached to media and albums.
+// Free-form tags are created on first use; curated tags are managed by admins.
+type Tag struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	IsCurated bool   `json:"is_curated"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// TagCount is a tag with the number of public media using it, for tag clouds
+type TagCount struct {
+	Name      string `json:"name"`
+	IsCurated bool   `json:"is_curated"`
+	Count     int64  `json:"count"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// WatermarkSettings is a user's watermark, stamped on the thumbnails and
+// renders of their media that are shown to other people
+type WatermarkSettings struct {
+	Enabled   bool    `json:"enabled"`
+	Text      string  `json:"text"`
+	HasLogo   bool    `json:"has_logo"` // A PNG logo was uploaded; it is drawn instead of the text
+	Position  string  `json:"position"` // top-left, top-right, bottom-left, bottom-right or center
+	Opacity   float64 `json:"opacity"`  // 0.05 to 1
+	Scale     float64 `json:"scale"`    // Width of the mark as a fraction of the image width
+	UpdatedAt int64   `json:"updated_at"`
+}
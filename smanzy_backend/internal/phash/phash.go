@@ -0,0 +1,68 @@
+// Package phash computes perceptual image hashes for near-duplicate detection.
+package phash
+
+import (
+	"image"
+	"math/bits"
+)
+
+// hashWidth and hashHeight are the size of the grayscale grid the image is
+// reduced to. Comparing horizontal neighbours yields 8x8 = 64 bits.
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// DHash returns the 64-bit difference hash of an image. The image is reduced
+// to a 9x8 grayscale grid and each bit records whether a cell is brighter than
+// its right-hand neighbour, so resizing and re-encoding barely change the hash.
+func DHash(img image.Image) uint64 {
+	grid := grayscaleGrid(img)
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if grid[y][x] < grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the Hamming distance between two hashes (0 = identical, 64 = opposite)
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// grayscaleGrid averages the luminance of the image over a hashWidth x hashHeight grid
+func grayscaleGrid(img image.Image) [hashHeight][hashWidth]float64 {
+	var sums, counts [hashHeight][hashWidth]float64
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return sums
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		gy := (y - b.Min.Y) * hashHeight / h
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gx := (x - b.Min.X) * hashWidth / w
+			r, g, bl, _ := img.At(x, y).RGBA()
+			// ITU-R BT.601 luma
+			sums[gy][gx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			counts[gy][gx]++
+		}
+	}
+
+	for y := range sums {
+		for x := range sums[y] {
+			if counts[y][x] > 0 {
+				sums[y][x] /= counts[y][x]
+			}
+		}
+	}
+	return sums
+}
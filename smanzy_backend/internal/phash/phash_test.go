@@ -0,0 +1,66 @@
+package phash
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// gradientImage draws a diagonal gradient with a bright square, scaled to w x h
+func gradientImage(w, h int, invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*200/w + y*55/h) % 256)
+			if x > w/4 && x < w/2 && y > h/4 && y < h/2 {
+				v = 250
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestDHash_ResizedAndReencodedImagesAreClose(t *testing.T) {
+	original := gradientImage(640, 400, false)
+	resized := gradientImage(320, 200, false)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, original, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	reencoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	h := DHash(original)
+	if d := Distance(h, DHash(resized)); d > 6 {
+		t.Errorf("resized distance = %d, want <= 6", d)
+	}
+	if d := Distance(h, DHash(reencoded)); d > 6 {
+		t.Errorf("re-encoded distance = %d, want <= 6", d)
+	}
+}
+
+func TestDHash_DifferentImagesAreFar(t *testing.T) {
+	a := DHash(gradientImage(320, 200, false))
+	b := DHash(gradientImage(320, 200, true))
+	if d := Distance(a, b); d < 20 {
+		t.Errorf("distance between different images = %d, want >= 20", d)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance(0, 0); d != 0 {
+		t.Errorf("Distance(0, 0) = %d", d)
+	}
+	if d := Distance(0, ^uint64(0)); d != 64 {
+		t.Errorf("Distance(0, max) = %d", d)
+	}
+}
@@ -0,0 +1,213 @@
+// Package placeholder computes the data clients need to paint an image before
+// it loads: a BlurHash, the dominant color and the aspect ratio.
+package placeholder
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// maxGridSide is the longest side of the grid the image is averaged down to
+// before encoding. A BlurHash only keeps a few low frequencies, so a small
+// grid gives the same result as the full image at a fraction of the cost.
+const maxGridSide = 32
+
+// base83 is the BlurHash alphabet
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// AspectRatio returns the width of an image divided by its height, or 0 for
+// an empty image
+func AspectRatio(img image.Image) float64 {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return 0
+	}
+	return float64(b.Dx()) / float64(b.Dy())
+}
+
+// BlurHash encodes an image as a BlurHash string with 4 components along its
+// longer side and 3 along the shorter one. It returns "" for an empty image.
+func BlurHash(img image.Image) string {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return ""
+	}
+	xComponents, yComponents := 4, 3
+	if b.Dy() > b.Dx() {
+		xComponents, yComponents = 3, 4
+	}
+	return encode(linearGrid(img), xComponents, yComponents)
+}
+
+// DominantColor returns the most common color of an image as #rrggbb.
+// Colors are bucketed to 3 bits per channel and the most populated bucket is
+// averaged, so noise and gradients do not split one color into many.
+// Mostly transparent pixels are ignored. It returns "" if nothing is opaque.
+func DominantColor(img image.Image) string {
+	type bucket struct {
+		r, g, b, n uint64
+	}
+	var buckets [512]bucket
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			// Undo premultiplied alpha so translucent pixels keep their hue
+			r, g, bl = r*0xffff/a>>8, g*0xffff/a>>8, bl*0xffff/a>>8
+			bk := &buckets[(r>>5)<<6|(g>>5)<<3|bl>>5]
+			bk.r += uint64(r)
+			bk.g += uint64(g)
+			bk.b += uint64(bl)
+			bk.n++
+		}
+	}
+
+	best := -1
+	for i := range buckets {
+		if buckets[i].n > 0 && (best < 0 || buckets[i].n > buckets[best].n) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	bk := buckets[best]
+	return fmt.Sprintf("#%02x%02x%02x", bk.r/bk.n, bk.g/bk.n, bk.b/bk.n)
+}
+
+// grid holds an image averaged down to at most maxGridSide cells per side,
+// as linear RGB
+type grid struct {
+	w, h int
+	rgb  [][3]float64 // Row-major, w*h cells
+}
+
+// linearGrid averages the image down to a grid in linear RGB
+func linearGrid(img image.Image) grid {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	gw, gh := w, h
+	if gw > maxGridSide || gh > maxGridSide {
+		if w >= h {
+			gw, gh = maxGridSide, max(1, h*maxGridSide/w)
+		} else {
+			gw, gh = max(1, w*maxGridSide/h), maxGridSide
+		}
+	}
+
+	g := grid{w: gw, h: gh, rgb: make([][3]float64, gw*gh)}
+	counts := make([]float64, gw*gh)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		gy := (y - b.Min.Y) * gh / h
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gx := (x - b.Min.X) * gw / w
+			r, gr, bl, _ := img.At(x, y).RGBA()
+			cell := &g.rgb[gy*gw+gx]
+			cell[0] += srgbToLinear(r >> 8)
+			cell[1] += srgbToLinear(gr >> 8)
+			cell[2] += srgbToLinear(bl >> 8)
+			counts[gy*gw+gx]++
+		}
+	}
+	for i := range g.rgb {
+		if counts[i] > 0 {
+			for c := range g.rgb[i] {
+				g.rgb[i][c] /= counts[i]
+			}
+		}
+	}
+	return g
+}
+
+// encode implements the BlurHash encoding of a linear RGB grid
+// (https://github.com/woltapp/blurhash/blob/master/Algorithm.md)
+func encode(g grid, xComponents, yComponents int) string {
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var f [3]float64
+			for y := 0; y < g.h; y++ {
+				for x := 0; x < g.w; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(g.w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(g.h))
+					cell := g.rgb[y*g.w+x]
+					f[0] += basis * cell[0]
+					f[1] += basis * cell[1]
+					f[2] += basis * cell[2]
+				}
+			}
+			scale := 1 / float64(g.w*g.h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	encode83(&sb, (xComponents-1)+(yComponents-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := clamp(int(math.Floor(actualMax*166-0.5)), 0, 82)
+		maximumValue = float64(quantisedMax+1) / 166
+		encode83(&sb, quantisedMax, 1)
+	} else {
+		encode83(&sb, 0, 1)
+	}
+
+	encode83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return clamp(int(math.Floor(signPow(v/maximumValue, 0.5)*9+9.5)), 0, 18)
+		}
+		encode83(&sb, quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+	return sb.String()
+}
+
+// encode83 appends value as length base-83 digits
+func encode83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		sb.WriteByte(base83[digit])
+	}
+}
+
+func srgbToLinear(v uint32) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(hi, v))
+}
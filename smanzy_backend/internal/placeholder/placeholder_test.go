@@ -0,0 +1,99 @@
+package placeholder
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+// decode83 reads a base-83 number
+func decode83(s string) int {
+	v := 0
+	for _, c := range s {
+		v = v*83 + strings.IndexRune(base83, c)
+	}
+	return v
+}
+
+func solidImage(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestBlurHash_SolidColor(t *testing.T) {
+	hash := BlurHash(solidImage(320, 200, color.RGBA{200, 60, 20, 255}))
+
+	// Size flag + max AC + 4-digit DC + 2 digits per AC component (4x3 - 1)
+	if len(hash) != 1+1+4+2*11 {
+		t.Fatalf("unexpected length %d: %q", len(hash), hash)
+	}
+	if x, y := decode83(hash[:1])%9+1, decode83(hash[:1])/9+1; x != 4 || y != 3 {
+		t.Errorf("components = %dx%d, want 4x3", x, y)
+	}
+	if dc := decode83(hash[2:6]); dc>>16 != 200 || dc>>8&0xff != 60 || dc&0xff != 20 {
+		t.Errorf("DC color = #%06x, want #c83c14", dc)
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune(base83, c) {
+			t.Fatalf("hash %q contains %q outside the base-83 alphabet", hash, c)
+		}
+	}
+}
+
+func TestBlurHash_Portrait(t *testing.T) {
+	hash := BlurHash(solidImage(200, 320, color.White))
+	if x, y := decode83(hash[:1])%9+1, decode83(hash[:1])/9+1; x != 3 || y != 4 {
+		t.Errorf("components = %dx%d, want 3x4", x, y)
+	}
+}
+
+func TestBlurHash_DetailChangesHash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 320; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / 320), 0, uint8(y * 255 / 200), 255})
+		}
+	}
+	gradient := BlurHash(img)
+	if gradient == BlurHash(solidImage(320, 200, color.RGBA{128, 0, 128, 255})) {
+		t.Error("a gradient should not encode like a flat image")
+	}
+}
+
+func TestDominantColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			switch {
+			case x < 70:
+				img.Set(x, y, color.RGBA{20, 120, 200, 255}) // Sky
+			case y < 10:
+				img.Set(x, y, color.Transparent)
+			default:
+				img.Set(x, y, color.RGBA{240, 240, 230, 255})
+			}
+		}
+	}
+	if got := DominantColor(img); got != "#1478c8" {
+		t.Errorf("DominantColor = %q, want #1478c8", got)
+	}
+	if got := DominantColor(solidImage(10, 10, color.Transparent)); got != "" {
+		t.Errorf("transparent image should have no dominant color, got %q", got)
+	}
+}
+
+func TestAspectRatio(t *testing.T) {
+	if got := AspectRatio(solidImage(320, 200, color.Black)); math.Abs(got-1.6) > 1e-9 {
+		t.Errorf("AspectRatio = %v, want 1.6", got)
+	}
+	if got := AspectRatio(image.NewRGBA(image.Rect(0, 0, 0, 0))); got != 0 {
+		t.Errorf("empty image AspectRatio = %v, want 0", got)
+	}
+}
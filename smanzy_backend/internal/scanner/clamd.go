@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultClamdTimeout bounds a single scan by default
+	DefaultClamdTimeout = 2 * time.Minute
+	// clamdChunkSize is the size of the chunks streamed to clamd. It must stay
+	// below clamd's StreamMaxLength.
+	clamdChunkSize = 64 << 10
+)
+
+// ErrClamd is returned when clamd reports an error instead of a verdict,
+// for example when the file exceeds its StreamMaxLength
+var ErrClamd = errors.New("clamd error")
+
+// Clamd scans files with a ClamAV daemon, or anything speaking its protocol,
+// using the INSTREAM command so the daemon does not need access to the files
+type Clamd struct {
+	network string // "tcp" or "unix"
+	address string
+	timeout time.Duration
+}
+
+// NewClamd creates a clamd scanner. address is "tcp://host:port",
+// "unix:///path/to/clamd.sock" or a plain "host:port".
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network, addr := "tcp", address
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		addr = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("invalid clamd address %q", address)
+	}
+	if network == "tcp" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid clamd address %q: %w", address, err)
+		}
+	}
+	if timeout <= 0 {
+		timeout = DefaultClamdTimeout
+	}
+	return &Clamd{network: network, address: addr, timeout: timeout}, nil
+}
+
+// Scan implements Scanner. The file is streamed to clamd in chunks, each
+// prefixed with its length, and terminated by an empty chunk.
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("connecting to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	// Close the connection on cancellation so a blocked read or write returns
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	reply, err := instream(conn, r)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Result{}, fmt.Errorf("clamd: %w", ctxErr)
+		}
+		return Result{}, fmt.Errorf("clamd: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// instream sends r with the INSTREAM command and returns clamd's reply
+func instream(conn net.Conn, r io.Reader) (string, error) {
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, werr := w.Write(size[:]); werr != nil {
+				return "", werr
+			}
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return "", werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading file: %w", err)
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		// clamd closes the connection as soon as a stream is too large;
+		// its reply explains why
+		if reply, rerr := bufio.NewReader(conn).ReadString(0); rerr == nil {
+			return reply, nil
+		}
+		return "", err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", err
+	}
+	return reply, nil
+}
+
+// parseClamdReply interprets a reply such as "stream: OK",
+// "stream: Eicar-Signature FOUND" or "... ERROR"
+func parseClamdReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	case strings.HasSuffix(verdict, " ERROR"):
+		return Result{}, fmt.Errorf("%w: %s", ErrClamd, strings.TrimSuffix(verdict, " ERROR"))
+	default:
+		return Result{}, fmt.Errorf("%w: unexpected reply %q", ErrClamd, reply)
+	}
+}
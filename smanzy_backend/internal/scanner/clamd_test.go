@@ -0,0 +1,164 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd accepts one INSTREAM session and answers like clamd, reporting
+// the EICAR test file as infected
+func fakeClamd(t *testing.T) (string, <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		cmd, err := r.ReadString(0)
+		if err != nil || cmd != "zINSTREAM\x00" {
+			conn.Write([]byte("UNKNOWN COMMAND\x00"))
+			return
+		}
+		var data bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+				return
+			}
+		}
+		received <- data.Bytes()
+
+		if bytes.Contains(data.Bytes(), []byte(EICAR)) {
+			conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+		} else {
+			conn.Write([]byte("stream: OK\x00"))
+		}
+	}()
+	return "tcp://" + ln.Addr().String(), received
+}
+
+func TestClamd_Clean(t *testing.T) {
+	addr, received := fakeClamd(t)
+	c, err := NewClamd(addr, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Larger than a chunk, so the file is streamed in several parts
+	data := bytes.Repeat([]byte("smanzy"), clamdChunkSize/3)
+	res, err := c.Scan(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if res.Infected {
+		t.Errorf("clean file reported infected: %+v", res)
+	}
+	if got := <-received; !bytes.Equal(got, data) {
+		t.Errorf("clamd received %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestClamd_Infected(t *testing.T) {
+	addr, _ := fakeClamd(t)
+	c, err := NewClamd(addr, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.Scan(context.Background(), strings.NewReader(EICAR))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if !res.Infected || res.Signature != "Win.Test.EICAR_HDB-1" {
+		t.Errorf("got %+v, want infected with Win.Test.EICAR_HDB-1", res)
+	}
+}
+
+func TestClamd_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c, err := NewClamd(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Error("expected an error when clamd is not running")
+	}
+}
+
+func TestNewClamd_Address(t *testing.T) {
+	tests := []struct {
+		address, network, addr string
+	}{
+		{"tcp://clamav:3310", "tcp", "clamav:3310"},
+		{"clamav:3310", "tcp", "clamav:3310"},
+		{"unix:///run/clamav/clamd.sock", "unix", "/run/clamav/clamd.sock"},
+	}
+	for _, tt := range tests {
+		c, err := NewClamd(tt.address, 0)
+		if err != nil {
+			t.Errorf("NewClamd(%q): %v", tt.address, err)
+			continue
+		}
+		if c.network != tt.network || c.address != tt.addr {
+			t.Errorf("NewClamd(%q) = %s %s, want %s %s", tt.address, c.network, c.address, tt.network, tt.addr)
+		}
+	}
+
+	for _, bad := range []string{"", "tcp://", "clamav"} {
+		if _, err := NewClamd(bad, 0); err == nil {
+			t.Errorf("NewClamd(%q) accepted an invalid address", bad)
+		}
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	if res, err := parseClamdReply("stream: OK\x00"); err != nil || res.Infected {
+		t.Errorf("OK reply: %+v, %v", res, err)
+	}
+	if res, err := parseClamdReply("stream: Eicar-Signature FOUND\x00"); err != nil || !res.Infected || res.Signature != "Eicar-Signature" {
+		t.Errorf("FOUND reply: %+v, %v", res, err)
+	}
+	if _, err := parseClamdReply("INSTREAM size limit exceeded. ERROR\x00"); !errors.Is(err, ErrClamd) {
+		t.Errorf("ERROR reply: got %v, want ErrClamd", err)
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake()
+	if res, _ := f.Scan(context.Background(), strings.NewReader("prefix "+EICAR)); !res.Infected {
+		t.Error("Fake did not detect the EICAR test file")
+	}
+	if res, _ := f.Scan(context.Background(), strings.NewReader("harmless")); res.Infected {
+		t.Error("Fake reported a harmless file infected")
+	}
+}
@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// EICAR is the standard anti-virus test file. Real scanners report it as
+// malware, so it can be used to check the whole pipeline end to end.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// Fake is a Scanner for tests. It reports a file infected when its contents
+// contain one of the patterns of Signatures, and fails with Err when set.
+type Fake struct {
+	Signatures map[string]string // Pattern -> signature name
+	Err        error
+}
+
+// NewFake returns a Fake that detects the EICAR test file
+func NewFake() *Fake {
+	return &Fake{Signatures: map[string]string{EICAR: "Eicar-Test-Signature"}}
+}
+
+// Scan implements Scanner
+func (f *Fake) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if f.Err != nil {
+		return Result{}, f.Err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	for pattern, signature := range f.Signatures {
+		if bytes.Contains(data, []byte(pattern)) {
+			return Result{Infected: true, Signature: signature}, nil
+		}
+	}
+	return Result{}, nil
+}
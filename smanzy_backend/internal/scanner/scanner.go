@@ -0,0 +1,28 @@
+// Package scanner checks files for malware before they are served
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+// Result is the outcome of a scan
+type Result struct {
+	Infected  bool
+	Signature string // Name of the malware found, when infected
+}
+
+// Scanner scans the contents of a file. It returns an error when the file
+// could not be scanned, in which case it must be treated as unscanned.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Nop is a Scanner that reports every file clean without reading it.
+// It is used when no malware scanner is configured.
+type Nop struct{}
+
+// Scan implements Scanner
+func (Nop) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return Result{}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/ristep/smanzy_backend/internal/db"
 	"github.com/ristep/smanzy_backend/internal/mappers"
@@ -27,7 +28,7 @@ func NewAlbumService(conn *sql.DB, queries *db.Queries) *AlbumService {
 // CreateAlbum creates a new album for a user
 func (as *AlbumService) CreateAlbum(ctx context.Context, userID uint, title, description, userName string) (*models.Album, error) {
 	if title == "" {
-		return nil, errors.New("album title is required")
+		return nil, fmt.Errorf("%w: album title is required", ErrInvalidAlbum)
 	}
 
 	albumRow, err := as.queries.CreateAlbum(ctx, db.CreateAlbumParams{
@@ -51,33 +52,33 @@ func (as *AlbumService) CreateAlbum(ctx context.Context, userID uint, title, des
 	}, nil
 }
 
-// GetAlbumByID retrieves an album by its ID
-func (as *AlbumService) GetAlbumByID(ctx context.Context, albumID uint) (*models.Album, error) {
-	albumRow, err := as.queries.GetAlbumByID(ctx, int64(albumID))
+// GetAlbumByID retrieves an album the user may view, with breadcrumbs of the
+// parent albums they can see
+func (as *AlbumService) GetAlbumByID(ctx context.Context, user *models.User, albumID uint) (*models.Album, error) {
+	albumRow, _, err := as.Authorize(ctx, user, albumID, AlbumAccessView)
+	if err != nil {
+		return nil, err
+	}
+	album, err := as.withOwnerName(ctx, albumRow)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("album not found")
-		}
 		return nil, err
 	}
+	if album.Breadcrumbs, err = as.breadcrumbs(ctx, user, albumID); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
 
-	// Fetch username
+// withOwnerName converts an album row to a model with its owner's name
+func (as *AlbumService) withOwnerName(ctx context.Context, albumRow db.Album) (*models.Album, error) {
 	userRow, err := as.queries.GetUserByID(ctx, albumRow.UserID)
 	if err != nil {
 		return nil, errors.New("failed to fetch album owner information")
 	}
 
-	return &models.Album{
-		ID:          uint(albumRow.ID),
-		Title:       albumRow.Title,
-		Description: albumRow.Description.String,
-		UserID:      uint(albumRow.UserID),
-		UserName:    userRow.Name,
-		IsPublic:    albumRow.IsPublic.Bool,
-		IsShared:    albumRow.IsShared.Bool,
-		CreatedAt:   albumRow.CreatedAt,
-		UpdatedAt:   albumRow.UpdatedAt,
-	}, nil
+	album := mappers.AlbumRowToModel(albumRow)
+	album.UserName = userRow.Name
+	return &album, nil
 }
 
 // GetUserAlbums retrieves all albums for a user
@@ -100,60 +101,229 @@ func (as *AlbumService) GetAllAlbums(ctx context.Context) ([]models.Album, error
 	return mappers.ListAllAlbumsRowsToModels(albumRows), nil
 }
 
-// UpdateAlbum updates an album's title and description
-func (as *AlbumService) UpdateAlbum(ctx context.Context, albumID uint, title, description string) (*models.Album, error) {
-	albumRaw, err := as.queries.GetAlbumByID(ctx, int64(albumID))
+// ListPublicAlbums returns a page of public albums, most recently updated
+// first, and the number of public albums
+func (as *AlbumService) ListPublicAlbums(ctx context.Context, limit, offset int) ([]models.Album, int64, error) {
+	rows, err := as.queries.ListPublicAlbums(ctx, db.ListPublicAlbumsParams{
+		PageLimit:  int32(limit),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := as.queries.CountPublicAlbums(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return mappers.ListPublicAlbumsRowsToModels(rows), total, nil
+}
+
+// AlbumUpdate is a change to an album. Nil fields keep their current value.
+type AlbumUpdate struct {
+	Title        string  // Empty keeps the current title
+	Description  *string // Empty clears the description
+	IsPublic     *bool   // Owner or Admin
+	IsShared     *bool   // Owner or Admin
+	SortMode     *string // One of the AlbumSort* modes
+	CoverMediaID *uint   // Must be in the album, or match a smart album's rules; 0 clears the cover
+}
+
+// UpdateAlbum updates an album's title, description, sort mode and cover
+// (Editor, Owner or Admin). Changing who can see it needs Owner or Admin.
+func (as *AlbumService) UpdateAlbum(ctx context.Context, user *models.User, albumID uint, update AlbumUpdate) (*models.Album, error) {
+	albumRaw, access, err := as.Authorize(ctx, user, albumID, AlbumAccessEdit)
 	if err != nil {
 		return nil, err
 	}
 
-	updatedRow, err := as.queries.UpdateAlbum(ctx, db.UpdateAlbumParams{
-		ID: int64(albumID),
-		Title: func() string {
-			if title != "" {
-				return title
-			} else {
-				return albumRaw.Title
-			}
-		}(),
-		Description: sql.NullString{String: description, Valid: true},
-		IsPublic:    albumRaw.IsPublic,
-		IsShared:    albumRaw.IsShared,
-	})
+	if (update.IsPublic != nil || update.IsShared != nil) && access < AlbumAccessManage {
+		return nil, ErrForbidden
+	}
+	if update.SortMode != nil && !ValidSortMode(*update.SortMode) {
+		return nil, fmt.Errorf("%w: sort_mode must be manual, captured, uploaded or name", ErrInvalidAlbum)
+	}
+	if update.CoverMediaID != nil && *update.CoverMediaID != 0 {
+		var inAlbum bool
+		if albumRaw.SmartRules.Valid {
+			inAlbum, err = as.smartAlbumHas(ctx, user, albumRaw, int64(*update.CoverMediaID))
+		} else {
+			inAlbum, err = as.queries.IsMediaInAlbum(ctx, db.IsMediaInAlbumParams{
+				AlbumID: int64(albumID),
+				MediaID: int64(*update.CoverMediaID),
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !inAlbum {
+			return nil, fmt.Errorf("%w: the cover must be in the album", ErrInvalidAlbum)
+		}
+	}
 
+	updatedRow, err := as.queries.UpdateAlbum(ctx, albumUpdateParams(albumRaw, update))
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.Album{
-		ID:          uint(updatedRow.ID),
-		Title:       updatedRow.Title,
-		Description: updatedRow.Description.String,
-		UserID:      uint(updatedRow.UserID),
-		CreatedAt:   updatedRow.CreatedAt,
-		UpdatedAt:   updatedRow.UpdatedAt,
-	}, nil
+	album := mappers.AlbumRowToModel(updatedRow)
+	return &album, nil
+}
+
+// albumUpdateParams applies an update, already checked, to an album. Fields
+// the update leaves out keep the album's current value.
+func albumUpdateParams(album db.Album, update AlbumUpdate) db.UpdateAlbumParams {
+	params := db.UpdateAlbumParams{
+		ID:           album.ID,
+		Title:        album.Title,
+		Description:  album.Description,
+		IsPublic:     album.IsPublic,
+		IsShared:     album.IsShared,
+		SortMode:     album.SortMode,
+		CoverMediaID: album.CoverMediaID,
+	}
+	if update.Title != "" {
+		params.Title = update.Title
+	}
+	if update.Description != nil {
+		params.Description = sql.NullString{String: *update.Description, Valid: true}
+	}
+	if update.IsPublic != nil {
+		params.IsPublic = sql.NullBool{Bool: *update.IsPublic, Valid: true}
+	}
+	if update.IsShared != nil {
+		params.IsShared = sql.NullBool{Bool: *update.IsShared, Valid: true}
+	}
+	if update.SortMode != nil {
+		params.SortMode = *update.SortMode
+	}
+	if update.CoverMediaID != nil {
+		params.CoverMediaID = sql.NullInt64{Int64: int64(*update.CoverMediaID), Valid: *update.CoverMediaID != 0}
+	}
+	return params
+}
+
+// ListAlbumMedia returns the media of an album the user may view, in the
+// album's sort order. Viewers and contributors do not see other people's
+// private media.
+func (as *AlbumService) ListAlbumMedia(ctx context.Context, user *models.User, albumID uint) ([]db.Medium, error) {
+	album, access, err := as.Authorize(ctx, user, albumID, AlbumAccessView)
+	if err != nil {
+		return nil, err
+	}
+	return as.visibleAlbumMedia(ctx, user, access, album)
+}
+
+// visibleAlbumMedia returns the media of an album seen by a user with the
+// given access, in the album's sort order
+func (as *AlbumService) visibleAlbumMedia(ctx context.Context, user *models.User, access AlbumAccess, album db.Album) ([]db.Medium, error) {
+	var (
+		rows []db.Medium
+		err  error
+	)
+	if album.SmartRules.Valid {
+		rows, err = as.smartAlbumMedia(ctx, user, album)
+	} else {
+		rows, err = as.queries.GetAlbumMedia(ctx, db.GetAlbumMediaParams{
+			AlbumID:  album.ID,
+			SortMode: album.SortMode,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	visible := rows[:0]
+	for _, row := range rows {
+		if canSeeAlbumMedia(user, access, row.UserID, row.Visibility) {
+			visible = append(visible, row)
+		}
+	}
+	return visible, nil
 }
 
-// AddMediaToAlbum adds a media file to an album
-func (as *AlbumService) AddMediaToAlbum(ctx context.Context, albumID, mediaID uint) error {
+// AddMediaToAlbum adds a media file to an album. Contributors, editors and
+// the owner can only add their own media; admins can add any.
+func (as *AlbumService) AddMediaToAlbum(ctx context.Context, user *models.User, albumID, mediaID uint) error {
+	album, _, err := as.Authorize(ctx, user, albumID, AlbumAccessContribute)
+	if err != nil {
+		return err
+	}
+	if album.SmartRules.Valid {
+		return ErrSmartAlbum
+	}
+
+	mediaRow, err := as.queries.GetMediaByID(ctx, int64(mediaID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMediaNotFound
+		}
+		return err
+	}
+	if mediaRow.UserID != int64(user.ID) && !user.HasRole("admin") {
+		return ErrForbidden
+	}
+
 	return as.queries.AddMediaToAlbum(ctx, db.AddMediaToAlbumParams{
 		AlbumID: int64(albumID),
 		MediaID: int64(mediaID),
 	})
 }
 
-// RemoveMediaFromAlbum removes a media file from an album
-func (as *AlbumService) RemoveMediaFromAlbum(ctx context.Context, albumID, mediaID uint) error {
+// RemoveMediaFromAlbum removes a media file from an album. Editors, the owner
+// and admins can remove any media; contributors only their own.
+func (as *AlbumService) RemoveMediaFromAlbum(ctx context.Context, user *models.User, albumID, mediaID uint) error {
+	album, access, err := as.Authorize(ctx, user, albumID, AlbumAccessContribute)
+	if err != nil {
+		return err
+	}
+	if album.SmartRules.Valid {
+		return ErrSmartAlbum
+	}
+
+	if access < AlbumAccessEdit {
+		mediaRow, err := as.queries.GetMediaByIDWithDeleted(ctx, int64(mediaID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrMediaNotFound
+			}
+			return err
+		}
+		if mediaRow.UserID != int64(user.ID) {
+			return ErrForbidden
+		}
+	}
+
 	return as.queries.RemoveMediaFromAlbum(ctx, db.RemoveMediaFromAlbumParams{
 		AlbumID: int64(albumID),
 		MediaID: int64(mediaID),
 	})
 }
 
-// DeleteAlbum performs a soft delete on an album
-func (as *AlbumService) DeleteAlbum(ctx context.Context, albumID uint) error {
-	return as.queries.SoftDeleteAlbum(ctx, int64(albumID))
+// DeleteAlbum performs a soft delete on an album (Owner or Admin). Its
+// sub-albums move up to its parent.
+func (as *AlbumService) DeleteAlbum(ctx context.Context, user *models.User, albumID uint) error {
+	album, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage)
+	if err != nil {
+		return err
+	}
+
+	tx, err := as.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := as.queries.WithTx(tx)
+	if err := qtx.SoftDeleteAlbum(ctx, album.ID); err != nil {
+		return err
+	}
+	if err := qtx.ReparentChildAlbums(ctx, db.ReparentChildAlbumsParams{
+		NewParentID: album.ParentID,
+		OldParentID: album.ID,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // PermanentlyDeleteAlbum permanently deletes an album from the database
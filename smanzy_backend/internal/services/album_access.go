@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// AlbumAccess is what a user may do with an album. Each level includes the
+// ones before it.
+type AlbumAccess int
+
+const (
+	AlbumAccessNone       AlbumAccess = iota
+	AlbumAccessView                   // See the album and its media
+	AlbumAccessContribute             // Add their own media and remove media they own
+	AlbumAccessEdit                   // Change the title and description, add and remove any media
+	AlbumAccessManage                 // Delete the album and change who can see it
+)
+
+// Collaborator roles on an album
+const (
+	AlbumRoleViewer      = "viewer"
+	AlbumRoleContributor = "contributor"
+	AlbumRoleEditor      = "editor"
+	// AlbumRoleOwner is inherited by the sub-albums of a user's albums
+	AlbumRoleOwner = "owner"
+)
+
+// albumRoleRanks orders roles from least to most access
+var albumRoleRanks = map[string]int{
+	AlbumRoleViewer:      1,
+	AlbumRoleContributor: 2,
+	AlbumRoleEditor:      3,
+	AlbumRoleOwner:       4,
+}
+
+// betterRole returns whichever of two roles gives more access
+func betterRole(a, b string) string {
+	if albumRoleRanks[b] > albumRoleRanks[a] {
+		return b
+	}
+	return a
+}
+
+var (
+	// ErrAlbumNotFound is returned for missing albums and for albums the user
+	// may not see, so private albums cannot be probed
+	ErrAlbumNotFound = errors.New("album not found")
+	// ErrInvalidAlbum is returned when an album request is rejected
+	ErrInvalidAlbum = errors.New("invalid album request")
+)
+
+// AlbumAccessFor is the album access policy. The owner and admins manage an
+// album; collaborators get the access of their role (empty if the user is not
+// one; "owner" if they own a parent album); anyone, including anonymous users
+// (nil), may view public and shared albums.
+func AlbumAccessFor(user *models.User, album db.Album, role string) AlbumAccess {
+	if user != nil && (album.UserID == int64(user.ID) || user.HasRole("admin") || role == AlbumRoleOwner) {
+		return AlbumAccessManage
+	}
+	switch {
+	case role == AlbumRoleEditor:
+		return AlbumAccessEdit
+	case role == AlbumRoleContributor:
+		return AlbumAccessContribute
+	case role == AlbumRoleViewer, album.IsPublic.Bool, album.IsShared.Bool:
+		return AlbumAccessView
+	}
+	return AlbumAccessNone
+}
+
+// inheritedRole returns a user's best role on an album from the album and
+// its parents, nearest first: owning any of them counts as "owner", and a
+// collaborator role on a parent carries over to its sub-albums
+func inheritedRole(user *models.User, chain []db.ListAlbumAncestorsRow) string {
+	role := ""
+	for _, a := range chain {
+		if a.UserID == int64(user.ID) {
+			return AlbumRoleOwner
+		}
+		role = betterRole(role, a.Role)
+	}
+	return role
+}
+
+// albumAccess applies the access policy to an album, looking up the user's
+// role on it and its parents when it matters. Only people inherit access;
+// the public and shared settings of a parent do not carry over.
+func albumAccess(ctx context.Context, q *db.Queries, user *models.User, album db.Album) (AlbumAccess, error) {
+	if user == nil || album.UserID == int64(user.ID) || user.HasRole("admin") {
+		return AlbumAccessFor(user, album, ""), nil
+	}
+	chain, err := q.ListAlbumAncestors(ctx, db.ListAlbumAncestorsParams{
+		AlbumID: album.ID,
+		UserID:  int64(user.ID),
+	})
+	if err != nil {
+		return AlbumAccessNone, err
+	}
+	return AlbumAccessFor(user, album, inheritedRole(user, chain)), nil
+}
+
+// Authorize loads an album and checks the user has at least the needed
+// access. It returns ErrAlbumNotFound if the user may not see the album at
+// all, and ErrForbidden if they may see it but not do this.
+func (as *AlbumService) Authorize(ctx context.Context, user *models.User, albumID uint, need AlbumAccess) (db.Album, AlbumAccess, error) {
+	return authorizeAlbum(ctx, as.queries, user, albumID, need)
+}
+
+// authorizeAlbum is Authorize on the given queries, so it can run inside a
+// transaction
+func authorizeAlbum(ctx context.Context, q *db.Queries, user *models.User, albumID uint, need AlbumAccess) (db.Album, AlbumAccess, error) {
+	album, err := q.GetAlbumByID(ctx, int64(albumID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.Album{}, AlbumAccessNone, ErrAlbumNotFound
+		}
+		return db.Album{}, AlbumAccessNone, err
+	}
+
+	access, err := albumAccess(ctx, q, user, album)
+	if err != nil {
+		return db.Album{}, AlbumAccessNone, err
+	}
+	if access == AlbumAccessNone {
+		return db.Album{}, access, ErrAlbumNotFound
+	}
+	if access < need {
+		return db.Album{}, access, ErrForbidden
+	}
+	return album, access, nil
+}
+
+// canSeeAlbumMedia reports whether a user who may only view or contribute to
+// an album sees one of its media items. Albums share what they contain,
+// except private media, which only its owner sees.
+func canSeeAlbumMedia(user *models.User, access AlbumAccess, ownerID int64, visibility string) bool {
+	if access >= AlbumAccessEdit {
+		return true
+	}
+	return visibility != "private" || (user != nil && ownerID == int64(user.ID))
+}
+
+// canSeeSmartPrivate reports whether a user sees the private media matched
+// by a smart album's rules. The rules search the owner's whole library, not
+// media someone put in the album, so only the owner and admins see private
+// matches, whatever the user's access to the album.
+func canSeeSmartPrivate(user *models.User, album db.Album) bool {
+	return user != nil && (album.UserID == int64(user.ID) || user.HasRole("admin"))
+}
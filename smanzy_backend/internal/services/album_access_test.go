@@ -0,0 +1,94 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+func TestAlbumAccessFor(t *testing.T) {
+	owner := &models.User{ID: 1}
+	other := &models.User{ID: 2}
+	admin := &models.User{ID: 3, Roles: []models.Role{{Name: "admin"}}}
+
+	private := db.Album{ID: 10, UserID: 1}
+	public := db.Album{ID: 11, UserID: 1, IsPublic: sql.NullBool{Bool: true, Valid: true}}
+	shared := db.Album{ID: 12, UserID: 1, IsShared: sql.NullBool{Bool: true, Valid: true}}
+
+	tests := []struct {
+		name  string
+		user  *models.User
+		album db.Album
+		role  string
+		want  AlbumAccess
+	}{
+		{"owner", owner, private, "", AlbumAccessManage},
+		{"admin", admin, private, "", AlbumAccessManage},
+		{"stranger, private", other, private, "", AlbumAccessNone},
+		{"anonymous, private", nil, private, "", AlbumAccessNone},
+		{"stranger, public", other, public, "", AlbumAccessView},
+		{"anonymous, shared", nil, shared, "", AlbumAccessView},
+		{"viewer", other, private, AlbumRoleViewer, AlbumAccessView},
+		{"contributor", other, private, AlbumRoleContributor, AlbumAccessContribute},
+		{"editor", other, public, AlbumRoleEditor, AlbumAccessEdit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AlbumAccessFor(tt.user, tt.album, tt.role); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanSeeAlbumMedia(t *testing.T) {
+	viewer := &models.User{ID: 2}
+
+	if !canSeeAlbumMedia(viewer, AlbumAccessView, 1, "inherit") {
+		t.Error("viewers should see media shared through the album")
+	}
+	if canSeeAlbumMedia(viewer, AlbumAccessView, 1, "private") {
+		t.Error("viewers should not see someone else's private media")
+	}
+	if canSeeAlbumMedia(nil, AlbumAccessView, 1, "private") {
+		t.Error("anonymous users should not see private media")
+	}
+	if !canSeeAlbumMedia(viewer, AlbumAccessContribute, 2, "private") {
+		t.Error("contributors should see their own private media")
+	}
+	if !canSeeAlbumMedia(viewer, AlbumAccessEdit, 1, "private") {
+		t.Error("editors should see all media in the album")
+	}
+}
+
+func TestCanSeeSmartPrivate(t *testing.T) {
+	smart := db.Album{ID: 10, UserID: 1, SmartRules: sql.NullString{String: `{"field":"visibility","op":"eq","value":"private"}`, Valid: true}}
+
+	if !canSeeSmartPrivate(&models.User{ID: 1}, smart) {
+		t.Error("the owner should see their private matches")
+	}
+	if !canSeeSmartPrivate(&models.User{ID: 3, Roles: []models.Role{{Name: "admin"}}}, smart) {
+		t.Error("admins should see private matches")
+	}
+	if canSeeSmartPrivate(&models.User{ID: 2}, smart) {
+		t.Error("editors and other collaborators should not see the owner's private matches")
+	}
+	if canSeeSmartPrivate(nil, smart) {
+		t.Error("anonymous users should not see private matches")
+	}
+}
+
+func TestValidAlbumRole(t *testing.T) {
+	for _, role := range []string{AlbumRoleViewer, AlbumRoleContributor, AlbumRoleEditor} {
+		if !ValidAlbumRole(role) {
+			t.Errorf("%q should be a valid role", role)
+		}
+	}
+	for _, role := range []string{"", "owner", "admin", "Editor"} {
+		if ValidAlbumRole(role) {
+			t.Errorf("%q should not be a valid role", role)
+		}
+	}
+}
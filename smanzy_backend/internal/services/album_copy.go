@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// MaxMergeAlbums caps the number of albums merged into another at once
+const MaxMergeAlbums = 50
+
+// AlbumDuplicate describes a copy of an album
+type AlbumDuplicate struct {
+	Title   string // Defaults to the original title with " (copy)"
+	OwnerID uint   // Who gets the copy: the user, or anyone for admins
+}
+
+// DuplicateAlbum copies an album the user may view: its title, description,
+// sort mode, cover, smart rules, tags and media. The copy starts private, at
+// the top level, without share links or sub-albums. When the owner, or an
+// admin, duplicates an album within the owner's account, the copy holds all
+// its media and collaborators. Anyone else only copies the media owned by
+// whoever gets the copy, since they could otherwise publish other people's
+// media. Smart albums can only be copied within the owner's account.
+func (as *AlbumService) DuplicateAlbum(ctx context.Context, user *models.User, albumID uint, dup AlbumDuplicate) (*models.Album, error) {
+	source, access, err := as.Authorize(ctx, user, albumID, AlbumAccessView)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerID, ownerName := int64(user.ID), user.Name
+	if dup.OwnerID != 0 && dup.OwnerID != user.ID {
+		if !user.HasRole("admin") {
+			return nil, ErrForbidden
+		}
+		owner, err := as.queries.GetUserByID(ctx, int64(dup.OwnerID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("%w: user not found", ErrInvalidAlbum)
+			}
+			return nil, err
+		}
+		ownerID, ownerName = owner.ID, owner.Name
+	}
+
+	// Smart rules match the owner's library, so they would list other media
+	if source.SmartRules.Valid && ownerID != source.UserID {
+		return nil, fmt.Errorf("%w: smart albums can only be duplicated within their owner's account", ErrInvalidAlbum)
+	}
+
+	title := dup.Title
+	if title == "" {
+		title = source.Title + " (copy)"
+	}
+
+	tx, err := as.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := as.queries.WithTx(tx)
+
+	copyAll := copyAllMedia(access, source, ownerID)
+	mediaIDs, err := mediaToCopy(ctx, qtx, access, source, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	albumRow, err := qtx.CreateAlbum(ctx, db.CreateAlbumParams{
+		Title:       title,
+		Description: source.Description,
+		UserID:      ownerID,
+		IsPublic:    sql.NullBool{Bool: false, Valid: true},
+		IsShared:    sql.NullBool{Bool: false, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := qtx.CopyAlbumMedia(ctx, db.CopyAlbumMediaParams{
+		TargetID: albumRow.ID,
+		SourceID: source.ID,
+		MediaIds: mediaIDs,
+	}); err != nil {
+		return nil, err
+	}
+	if err := qtx.CopyAlbumTags(ctx, db.CopyAlbumTagsParams{TargetID: albumRow.ID, SourceID: source.ID}); err != nil {
+		return nil, err
+	}
+	if copyAll {
+		if err := qtx.CopyAlbumMembers(ctx, db.CopyAlbumMembersParams{
+			TargetID: albumRow.ID,
+			AddedBy:  sql.NullInt64{Int64: int64(user.ID), Valid: true},
+			SourceID: source.ID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// The cover stays if it made it into the copy. Smart albums are only
+	// copied within the same account, so they match the same media.
+	cover := source.CoverMediaID
+	if cover.Valid && !source.SmartRules.Valid {
+		inAlbum, err := qtx.IsMediaInAlbum(ctx, db.IsMediaInAlbumParams{AlbumID: albumRow.ID, MediaID: cover.Int64})
+		if err != nil {
+			return nil, err
+		}
+		cover.Valid = inAlbum
+	}
+
+	albumRow, err = qtx.UpdateAlbum(ctx, db.UpdateAlbumParams{
+		ID:           albumRow.ID,
+		Title:        albumRow.Title,
+		Description:  albumRow.Description,
+		IsPublic:     albumRow.IsPublic,
+		IsShared:     albumRow.IsShared,
+		SortMode:     source.SortMode,
+		CoverMediaID: cover,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if source.SmartRules.Valid {
+		if err := qtx.SetAlbumSmartRules(ctx, db.SetAlbumSmartRulesParams{
+			ID:         albumRow.ID,
+			SmartRules: source.SmartRules,
+		}); err != nil {
+			return nil, err
+		}
+		albumRow.SmartRules = source.SmartRules
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	album := mappers.AlbumRowToModel(albumRow)
+	album.UserName = ownerName
+	return &album, nil
+}
+
+// copyAllMedia reports whether all the media of the source album may go into
+// an album of ownerID, by duplicating or merging: only when someone who
+// manages the source keeps its media within the source owner's account.
+// Anything else only takes the media ownerID owns, so nobody moves other
+// people's media into an account where they could publish it.
+func copyAllMedia(access AlbumAccess, source db.Album, ownerID int64) bool {
+	return access >= AlbumAccessManage && ownerID == source.UserID
+}
+
+// mediaToCopy returns the media_ids argument of CopyAlbumMedia for copying the
+// source album into an album of ownerID: NULL for all its media, otherwise
+// the media ownerID owns (see copyAllMedia)
+func mediaToCopy(ctx context.Context, q *db.Queries, access AlbumAccess, source db.Album, ownerID int64) (sql.NullString, error) {
+	if copyAllMedia(access, source, ownerID) {
+		return sql.NullString{}, nil
+	}
+	rows, err := q.GetAlbumMedia(ctx, db.GetAlbumMediaParams{
+		AlbumID:  source.ID,
+		SortMode: AlbumSortManual,
+	})
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: ownedMediaIDs(rows, ownerID), Valid: true}, nil
+}
+
+// ownedMediaIDs lists the IDs of the media owned by a user, comma-separated
+// for CopyAlbumMedia
+func ownedMediaIDs(media []db.Medium, ownerID int64) string {
+	ids := make([]string, 0, len(media))
+	for _, m := range media {
+		if m.UserID == ownerID {
+			ids = append(ids, strconv.FormatInt(m.ID, 10))
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// MergeAlbums moves the media, tags and sub-albums of other albums into an
+// album, then deletes them (soft delete). Media already in the album is not
+// added twice; the rest follows in each source's manual order. The user must
+// be able to edit the album and manage every source. Media of a source owned
+// by someone else than the album's owner only moves when both albums are in
+// the same account, as with DuplicateAlbum. Smart albums cannot be merged.
+func (as *AlbumService) MergeAlbums(ctx context.Context, user *models.User, targetID uint, sourceIDs []uint) (*models.Album, error) {
+	if err := checkMergeSources(targetID, sourceIDs); err != nil {
+		return nil, err
+	}
+
+	target, _, err := as.Authorize(ctx, user, targetID, AlbumAccessEdit)
+	if err != nil {
+		return nil, err
+	}
+	if target.SmartRules.Valid {
+		return nil, ErrSmartAlbum
+	}
+
+	sources := make([]db.Album, 0, len(sourceIDs))
+	access := make([]AlbumAccess, 0, len(sourceIDs))
+	for _, id := range sourceIDs {
+		source, sourceAccess, err := as.Authorize(ctx, user, id, AlbumAccessManage)
+		if err != nil {
+			return nil, err
+		}
+		if source.SmartRules.Valid {
+			return nil, fmt.Errorf("%w: album %d is a smart album", ErrInvalidAlbum, id)
+		}
+		sources = append(sources, source)
+		access = append(access, sourceAccess)
+	}
+
+	tx, err := as.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := as.queries.WithTx(tx)
+	if err := qtx.LockAlbumTree(ctx); err != nil {
+		return nil, err
+	}
+
+	chain, err := qtx.ListAlbumAncestors(ctx, db.ListAlbumAncestorsParams{AlbumID: target.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, source := range sources {
+		// The source's sub-albums move under the album
+		subtree, err := qtx.ListAlbumTree(ctx, db.ListAlbumTreeParams{AlbumID: source.ID})
+		if err != nil {
+			return nil, err
+		}
+		height := 0
+		for _, row := range subtree {
+			height = max(height, int(row.Depth))
+		}
+		if err := checkMergeInto(chain, source.ID, height); err != nil {
+			return nil, err
+		}
+
+		mediaIDs, err := mediaToCopy(ctx, qtx, access[i], source, target.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := qtx.CopyAlbumMedia(ctx, db.CopyAlbumMediaParams{
+			TargetID: target.ID,
+			SourceID: source.ID,
+			MediaIds: mediaIDs,
+		}); err != nil {
+			return nil, err
+		}
+		if err := qtx.CopyAlbumTags(ctx, db.CopyAlbumTagsParams{TargetID: target.ID, SourceID: source.ID}); err != nil {
+			return nil, err
+		}
+		if err := qtx.ReparentChildAlbums(ctx, db.ReparentChildAlbumsParams{
+			NewParentID: sql.NullInt64{Int64: target.ID, Valid: true},
+			OldParentID: source.ID,
+		}); err != nil {
+			return nil, err
+		}
+		if err := qtx.SoftDeleteAlbum(ctx, source.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return as.GetAlbumByID(ctx, user, targetID)
+}
+
+// checkMergeSources checks the albums to merge into targetID: 1 to
+// MaxMergeAlbums of them, each listed once, and not the target itself
+func checkMergeSources(targetID uint, sourceIDs []uint) error {
+	if len(sourceIDs) == 0 || len(sourceIDs) > MaxMergeAlbums {
+		return fmt.Errorf("%w: give 1 to %d albums to merge", ErrInvalidAlbum, MaxMergeAlbums)
+	}
+	seen := make(map[uint]bool, len(sourceIDs))
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return fmt.Errorf("%w: an album cannot be merged into itself", ErrInvalidAlbum)
+		}
+		if seen[id] {
+			return fmt.Errorf("%w: album %d is listed twice", ErrInvalidAlbum, id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// checkMergeInto checks that the sub-albums of a source album, height levels
+// deep, can move under the target album, whose ancestors are chain
+func checkMergeInto(chain []db.ListAlbumAncestorsRow, sourceID int64, height int) error {
+	for _, a := range chain {
+		if a.ID == sourceID {
+			return fmt.Errorf("%w: an album cannot be merged into one of its sub-albums", ErrInvalidAlbum)
+		}
+	}
+	if len(chain)+height > MaxAlbumDepth {
+		return fmt.Errorf("%w: albums can be nested at most %d deep", ErrInvalidAlbum, MaxAlbumDepth)
+	}
+	return nil
+}
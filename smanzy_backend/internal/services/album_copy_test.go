@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+func TestMergeAlbums_SourceCount(t *testing.T) {
+	as := &AlbumService{}
+	user := &models.User{ID: 1}
+
+	if _, err := as.MergeAlbums(context.Background(), user, 1, nil); !errors.Is(err, ErrInvalidAlbum) {
+		t.Errorf("no sources: got %v, want ErrInvalidAlbum", err)
+	}
+
+	many := make([]uint, MaxMergeAlbums+1)
+	for i := range many {
+		many[i] = uint(i + 2)
+	}
+	if _, err := as.MergeAlbums(context.Background(), user, 1, many); !errors.Is(err, ErrInvalidAlbum) {
+		t.Errorf("too many sources: got %v, want ErrInvalidAlbum", err)
+	}
+}
+
+func TestCopyAllMedia(t *testing.T) {
+	source := db.Album{ID: 10, UserID: 1}
+
+	tests := []struct {
+		name    string
+		access  AlbumAccess
+		ownerID int64
+		want    bool
+	}{
+		// DuplicateAlbum: ownerID gets the copy
+		{"duplicate by the owner", AlbumAccessManage, 1, true},
+		{"duplicate by an admin into the owner's account", AlbumAccessManage, 1, true},
+		{"duplicate by an admin into another account", AlbumAccessManage, 3, false},
+		{"duplicate by an editor", AlbumAccessEdit, 2, false},
+		{"duplicate by a contributor", AlbumAccessContribute, 2, false},
+		{"duplicate by a viewer", AlbumAccessView, 2, false},
+		// MergeAlbums: ownerID owns the target; sources need Manage
+		{"merge into an album of the same account", AlbumAccessManage, 1, true},
+		{"merge into an album of another account", AlbumAccessManage, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := copyAllMedia(tt.access, source, tt.ownerID); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaToCopy_All(t *testing.T) {
+	// Copying everything is a NULL list and needs no query
+	ids, err := mediaToCopy(context.Background(), nil, AlbumAccessManage, db.Album{ID: 10, UserID: 1}, 1)
+	if err != nil || ids.Valid {
+		t.Errorf("got %v, %v; want NULL", ids, err)
+	}
+}
+
+func TestOwnedMediaIDs(t *testing.T) {
+	media := []db.Medium{
+		{ID: 4, UserID: 2},
+		{ID: 5, UserID: 1},
+		{ID: 6, UserID: 2, Visibility: "private"},
+		{ID: 7, UserID: 3, Visibility: "inherit"},
+	}
+
+	if got := ownedMediaIDs(media, 2); got != "4,6" {
+		t.Errorf("got %q, want %q", got, "4,6")
+	}
+	// Nothing to copy is an empty list, not NULL, which would copy everything
+	if got := ownedMediaIDs(media, 9); got != "" {
+		t.Errorf("got %q, want an empty list", got)
+	}
+}
+
+func TestCheckMergeSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []uint
+		valid   bool
+	}{
+		{"one", []uint{2}, true},
+		{"several", []uint{2, 3, 4}, true},
+		{"itself", []uint{2, 1}, false},
+		{"twice", []uint{2, 3, 2}, false},
+	}
+	for _, tt := range tests {
+		err := checkMergeSources(1, tt.sources)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidAlbum) {
+			t.Errorf("%s: got %v, want ErrInvalidAlbum", tt.name, err)
+		}
+	}
+}
+
+func TestCheckMergeInto(t *testing.T) {
+	// The target is album 1, under 5, under 6
+	chain := []db.ListAlbumAncestorsRow{{ID: 1}, {ID: 5}, {ID: 6}}
+
+	if err := checkMergeInto(chain, 2, 1); err != nil {
+		t.Errorf("unrelated album: unexpected error %v", err)
+	}
+	if err := checkMergeInto(chain, 5, 0); !errors.Is(err, ErrInvalidAlbum) {
+		t.Errorf("merging a parent into its sub-album: got %v, want ErrInvalidAlbum", err)
+	}
+	if err := checkMergeInto(chain, 2, MaxAlbumDepth); !errors.Is(err, ErrInvalidAlbum) {
+		t.Errorf("too deep: got %v, want ErrInvalidAlbum", err)
+	}
+}
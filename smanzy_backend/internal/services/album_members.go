@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// ErrAlbumMemberNotFound is returned when a user is not a collaborator on an album
+var ErrAlbumMemberNotFound = errors.New("album member not found")
+
+// ValidAlbumRole reports whether role is a collaborator role
+func ValidAlbumRole(role string) bool {
+	switch role {
+	case AlbumRoleViewer, AlbumRoleContributor, AlbumRoleEditor:
+		return true
+	}
+	return false
+}
+
+// ListMembers returns the collaborators on an album (Owner or Admin)
+func (as *AlbumService) ListMembers(ctx context.Context, user *models.User, albumID uint) ([]models.AlbumMember, error) {
+	if _, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage); err != nil {
+		return nil, err
+	}
+
+	rows, err := as.queries.ListAlbumMembers(ctx, int64(albumID))
+	if err != nil {
+		return nil, err
+	}
+	members := make([]models.AlbumMember, len(rows))
+	for i, row := range rows {
+		members[i] = mappers.AlbumMemberToModel(row)
+	}
+	return members, nil
+}
+
+// AddMember invites a user, given by ID or else by email, to collaborate on an
+// album, or changes their role if they already do (Owner or Admin)
+func (as *AlbumService) AddMember(ctx context.Context, user *models.User, albumID, memberID uint, email, role string) (models.AlbumMember, error) {
+	album, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage)
+	if err != nil {
+		return models.AlbumMember{}, err
+	}
+	if !ValidAlbumRole(role) {
+		return models.AlbumMember{}, fmt.Errorf("%w: role must be viewer, contributor or editor", ErrInvalidAlbum)
+	}
+
+	var (
+		memberUserID int64
+		memberName   string
+	)
+	if memberID != 0 {
+		member, err := as.queries.GetUserByID(ctx, int64(memberID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.AlbumMember{}, fmt.Errorf("%w: user not found", ErrInvalidAlbum)
+			}
+			return models.AlbumMember{}, err
+		}
+		memberUserID, memberName = member.ID, member.Name
+	} else {
+		member, err := as.queries.GetUserByEmail(ctx, email)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.AlbumMember{}, fmt.Errorf("%w: user not found", ErrInvalidAlbum)
+			}
+			return models.AlbumMember{}, err
+		}
+		memberUserID, memberName = member.ID, member.Name
+	}
+	if memberUserID == album.UserID {
+		return models.AlbumMember{}, fmt.Errorf("%w: the owner cannot be a collaborator", ErrInvalidAlbum)
+	}
+
+	row, err := as.queries.UpsertAlbumMember(ctx, db.UpsertAlbumMemberParams{
+		AlbumID: album.ID,
+		UserID:  memberUserID,
+		Role:    role,
+		AddedBy: sql.NullInt64{Int64: int64(user.ID), Valid: true},
+	})
+	if err != nil {
+		return models.AlbumMember{}, err
+	}
+	return models.AlbumMember{
+		AlbumID:   uint(row.AlbumID),
+		UserID:    uint(row.UserID),
+		UserName:  memberName,
+		Role:      row.Role,
+		CreatedAt: row.CreatedAt,
+	}, nil
+}
+
+// RemoveMember removes a collaborator from an album (Owner or Admin).
+// Collaborators may also remove themselves to leave an album.
+func (as *AlbumService) RemoveMember(ctx context.Context, user *models.User, albumID, memberID uint) error {
+	need := AlbumAccessManage
+	if memberID == user.ID {
+		need = AlbumAccessView
+	}
+	if _, _, err := as.Authorize(ctx, user, albumID, need); err != nil {
+		return err
+	}
+
+	n, err := as.queries.RemoveAlbumMember(ctx, db.RemoveAlbumMemberParams{
+		AlbumID: int64(albumID),
+		UserID:  int64(memberID),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlbumMemberNotFound
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// Album sort modes
+const (
+	AlbumSortManual   = "manual"   // The order set with ReorderAlbumMedia; new media goes last
+	AlbumSortCaptured = "captured" // When photos were taken, oldest first
+	AlbumSortUploaded = "uploaded" // When media was uploaded, oldest first
+	AlbumSortName     = "name"     // Filename, case-insensitive
+)
+
+// ValidSortMode reports whether mode is an album sort mode
+func ValidSortMode(mode string) bool {
+	switch mode {
+	case AlbumSortManual, AlbumSortCaptured, AlbumSortUploaded, AlbumSortName:
+		return true
+	}
+	return false
+}
+
+// applyOrder returns the album's media in their new manual order: the listed
+// media first, in the given order, then the rest in their current order. A
+// full ordering lists every media item; a partial one moves some to the front.
+func applyOrder(current []int64, wanted []uint) ([]int64, error) {
+	inAlbum := make(map[int64]bool, len(current))
+	for _, id := range current {
+		inAlbum[id] = true
+	}
+
+	order := make([]int64, 0, len(current))
+	placed := make(map[int64]bool, len(wanted))
+	for _, id := range wanted {
+		mediaID := int64(id)
+		if !inAlbum[mediaID] {
+			return nil, fmt.Errorf("%w: media %d is not in the album", ErrInvalidAlbum, id)
+		}
+		if placed[mediaID] {
+			return nil, fmt.Errorf("%w: media %d is listed twice", ErrInvalidAlbum, id)
+		}
+		placed[mediaID] = true
+		order = append(order, mediaID)
+	}
+	for _, id := range current {
+		if !placed[id] {
+			order = append(order, id)
+		}
+	}
+	return order, nil
+}
+
+// ReorderAlbumMedia sets the manual order of an album's media (Editor, Owner
+// or Admin). The listed media come first, in the given order, followed by the
+// rest in their current order. The order is shown when the album's sort mode
+// is manual.
+func (as *AlbumService) ReorderAlbumMedia(ctx context.Context, user *models.User, albumID uint, mediaIDs []uint) error {
+	album, _, err := as.Authorize(ctx, user, albumID, AlbumAccessEdit)
+	if err != nil {
+		return err
+	}
+	if album.SmartRules.Valid {
+		return ErrSmartAlbum
+	}
+	if len(mediaIDs) == 0 {
+		return fmt.Errorf("%w: media_ids is required", ErrInvalidAlbum)
+	}
+
+	tx, err := as.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := as.queries.WithTx(tx)
+
+	current, err := qtx.ListAlbumMediaOrder(ctx, int64(albumID))
+	if err != nil {
+		return err
+	}
+	order, err := applyOrder(current, mediaIDs)
+	if err != nil {
+		return err
+	}
+
+	for i, mediaID := range order {
+		if err := qtx.SetAlbumMediaPosition(ctx, db.SetAlbumMediaPositionParams{
+			AlbumID:  int64(albumID),
+			MediaID:  mediaID,
+			Position: int32(i + 1),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+)
+
+func TestApplyOrder(t *testing.T) {
+	current := []int64{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name   string
+		wanted []uint
+		want   []int64
+	}{
+		{"full", []uint{5, 4, 3, 2, 1}, []int64{5, 4, 3, 2, 1}},
+		{"partial moves to the front", []uint{4, 2}, []int64{4, 2, 1, 3, 5}},
+		{"unchanged", []uint{1}, []int64{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyOrder(current, tt.wanted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrder_Rejects(t *testing.T) {
+	current := []int64{1, 2, 3}
+
+	if _, err := applyOrder(current, []uint{2, 9}); !errors.Is(err, ErrInvalidAlbum) {
+		t.Errorf("media not in the album: got %v, want ErrInvalidAlbum", err)
+	}
+	if _, err := applyOrder(current, []uint{2, 2}); !errors.Is(err, ErrInvalidAlbum) {
+		t.Errorf("duplicate media: got %v, want ErrInvalidAlbum", err)
+	}
+}
+
+func TestValidSortMode(t *testing.T) {
+	for _, mode := range []string{AlbumSortManual, AlbumSortCaptured, AlbumSortUploaded, AlbumSortName} {
+		if !ValidSortMode(mode) {
+			t.Errorf("%q should be a valid sort mode", mode)
+		}
+	}
+	if ValidSortMode("random") || ValidSortMode("") {
+		t.Error("unknown sort modes should be rejected")
+	}
+}
+
+func TestAlbumUpdateParams_SortAndCover(t *testing.T) {
+	album := db.Album{
+		ID:           3,
+		Title:        "Summer",
+		Description:  sql.NullString{String: "Beach days", Valid: true},
+		SortMode:     AlbumSortManual,
+		CoverMediaID: sql.NullInt64{Int64: 9, Valid: true},
+	}
+
+	mode, cover := AlbumSortCaptured, uint(12)
+	params := albumUpdateParams(album, AlbumUpdate{SortMode: &mode, CoverMediaID: &cover})
+	if params.SortMode != AlbumSortCaptured || params.CoverMediaID != (sql.NullInt64{Int64: 12, Valid: true}) {
+		t.Errorf("sort mode or cover not applied: %+v", params)
+	}
+	if params.Description != album.Description || params.Title != album.Title {
+		t.Errorf("changing the order must keep the title and description: %+v", params)
+	}
+
+	cover = 0
+	if params := albumUpdateParams(album, AlbumUpdate{CoverMediaID: &cover}); params.CoverMediaID.Valid {
+		t.Errorf("cover 0 should clear the cover, got %+v", params.CoverMediaID)
+	}
+}
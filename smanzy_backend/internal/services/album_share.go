@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+const (
+	// shareSlugBytes is the randomness in a share link slug (22 characters)
+	shareSlugBytes = 16
+	// MaxSharePasswordLength is the longest password bcrypt can check
+	MaxSharePasswordLength = 72
+)
+
+var (
+	// ErrShareLinkNotFound is returned for unknown and revoked share links
+	ErrShareLinkNotFound = errors.New("share link not found")
+	// ErrShareLinkExpired is returned when a share link expired or used up its views
+	ErrShareLinkExpired = errors.New("share link expired")
+	// ErrSharePassword is returned when a share link needs a password that
+	// was not given or is wrong
+	ErrSharePassword = errors.New("share link password required or wrong")
+)
+
+// ShareLinkOptions configures a new share link
+type ShareLinkOptions struct {
+	Password      string // Empty for no password
+	ExpiresAt     int64  // Unix ms; 0 never expires
+	MaxViews      int    // 0 for unlimited views
+	AllowDownload bool   // Whether visitors get links to the original files
+}
+
+// SharedAlbum is what a share link opens
+type SharedAlbum struct {
+	Album         *models.Album
+	Media         []db.Medium
+	AllowDownload bool
+}
+
+// newShareSlug returns a random, URL-safe slug
+func newShareSlug() (string, error) {
+	b := make([]byte, shareSlugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateShareLink creates a share link for an album (Owner or Admin)
+func (as *AlbumService) CreateShareLink(ctx context.Context, user *models.User, albumID uint, opts ShareLinkOptions) (models.ShareLink, error) {
+	if _, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage); err != nil {
+		return models.ShareLink{}, err
+	}
+
+	switch {
+	case opts.ExpiresAt != 0 && opts.ExpiresAt <= time.Now().UnixMilli():
+		return models.ShareLink{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAlbum)
+	case opts.MaxViews < 0:
+		return models.ShareLink{}, fmt.Errorf("%w: max_views cannot be negative", ErrInvalidAlbum)
+	case len(opts.Password) > MaxSharePasswordLength:
+		return models.ShareLink{}, fmt.Errorf("%w: password is longer than %d bytes", ErrInvalidAlbum, MaxSharePasswordLength)
+	}
+
+	var passwordHash sql.NullString
+	if opts.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return models.ShareLink{}, err
+		}
+		passwordHash = sql.NullString{String: string(hash), Valid: true}
+	}
+
+	slug, err := newShareSlug()
+	if err != nil {
+		return models.ShareLink{}, err
+	}
+
+	link, err := as.queries.CreateShareLink(ctx, db.CreateShareLinkParams{
+		AlbumID:       int64(albumID),
+		Slug:          slug,
+		PasswordHash:  passwordHash,
+		ExpiresAt:     sql.NullInt64{Int64: opts.ExpiresAt, Valid: opts.ExpiresAt != 0},
+		MaxViews:      sql.NullInt32{Int32: int32(opts.MaxViews), Valid: opts.MaxViews != 0},
+		AllowDownload: opts.AllowDownload,
+		CreatedBy:     int64(user.ID),
+	})
+	if err != nil {
+		return models.ShareLink{}, err
+	}
+	return mappers.ShareLinkToModel(link), nil
+}
+
+// ListShareLinks returns the share links of an album with their view counts,
+// revoked ones included (Owner or Admin)
+func (as *AlbumService) ListShareLinks(ctx context.Context, user *models.User, albumID uint) ([]models.ShareLink, error) {
+	if _, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage); err != nil {
+		return nil, err
+	}
+
+	rows, err := as.queries.ListAlbumShareLinks(ctx, int64(albumID))
+	if err != nil {
+		return nil, err
+	}
+	links := make([]models.ShareLink, len(rows))
+	for i, row := range rows {
+		links[i] = mappers.ShareLinkToModel(row)
+	}
+	return links, nil
+}
+
+// RevokeShareLink stops a share link from working (Owner or Admin). File
+// links already handed out keep working until their signatures expire.
+func (as *AlbumService) RevokeShareLink(ctx context.Context, user *models.User, albumID, linkID uint) error {
+	if _, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage); err != nil {
+		return err
+	}
+
+	n, err := as.queries.RevokeShareLink(ctx, db.RevokeShareLinkParams{
+		ID:      int64(linkID),
+		AlbumID: int64(albumID),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrShareLinkNotFound
+	}
+	return nil
+}
+
+// OpenShareLink checks a share link and its password, counts the view and
+// returns the album with the media a public visitor may see
+func (as *AlbumService) OpenShareLink(ctx context.Context, slug, password string) (*SharedAlbum, error) {
+	link, err := as.queries.GetShareLinkBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrShareLinkNotFound
+		}
+		return nil, err
+	}
+
+	if link.ExpiresAt.Valid && link.ExpiresAt.Int64 <= time.Now().UnixMilli() {
+		return nil, ErrShareLinkExpired
+	}
+	if link.MaxViews.Valid && link.ViewCount >= link.MaxViews.Int32 {
+		return nil, ErrShareLinkExpired
+	}
+	if link.PasswordHash.Valid {
+		if password == "" || bcrypt.CompareHashAndPassword([]byte(link.PasswordHash.String), []byte(password)) != nil {
+			return nil, ErrSharePassword
+		}
+	}
+
+	// The view is only counted if the link is still valid at this point
+	n, err := as.queries.RecordShareLinkView(ctx, link.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrShareLinkExpired
+	}
+
+	albumRow, err := as.queries.GetAlbumByID(ctx, link.AlbumID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrShareLinkNotFound
+		}
+		return nil, err
+	}
+	album, err := as.withOwnerName(ctx, albumRow)
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := as.visibleAlbumMedia(ctx, nil, AlbumAccessView, albumRow)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedAlbum{Album: album, Media: media, AllowDownload: link.AllowDownload}, nil
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewShareSlug(t *testing.T) {
+	urlSafe := regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		slug, err := newShareSlug()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !urlSafe.MatchString(slug) {
+			t.Fatalf("slug %q is not 22 URL-safe characters", slug)
+		}
+		if seen[slug] {
+			t.Fatalf("duplicate slug %q", slug)
+		}
+		seen[slug] = true
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+)
+
+func TestAlbumUpdateParams_Description(t *testing.T) {
+	album := db.Album{
+		ID:          3,
+		Title:       "Summer",
+		Description: sql.NullString{String: "Beach days", Valid: true},
+		IsPublic:    sql.NullBool{Bool: false, Valid: true},
+	}
+
+	public := true
+	params := albumUpdateParams(album, AlbumUpdate{IsPublic: &public})
+	if params.Description != album.Description || params.Title != "Summer" {
+		t.Errorf("a visibility toggle changed the album: %+v", params)
+	}
+	if !params.IsPublic.Bool {
+		t.Error("is_public was not applied")
+	}
+
+	empty := ""
+	params = albumUpdateParams(album, AlbumUpdate{Description: &empty})
+	if params.Description.String != "" {
+		t.Errorf("an empty description should clear it, got %q", params.Description.String)
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// MaxAlbumDepth is how deeply albums may be nested, counting the top level
+const MaxAlbumDepth = 16
+
+// ancestorAlbum is the part of an album the access policy needs
+func ancestorAlbum(a db.ListAlbumAncestorsRow) db.Album {
+	return db.Album{ID: a.ID, UserID: a.UserID, IsPublic: a.IsPublic, IsShared: a.IsShared}
+}
+
+// breadcrumbsFor returns the parents in an album's chain (nearest first,
+// starting with the album itself) that the user can see, top level first.
+// They stop at the first parent the user cannot see.
+func breadcrumbsFor(user *models.User, chain []db.ListAlbumAncestorsRow) []models.AlbumCrumb {
+	var crumbs []models.AlbumCrumb
+	for i := 1; i < len(chain); i++ {
+		role := ""
+		if user != nil {
+			role = inheritedRole(user, chain[i:])
+		}
+		if AlbumAccessFor(user, ancestorAlbum(chain[i]), role) == AlbumAccessNone {
+			break
+		}
+		crumbs = append(crumbs, models.AlbumCrumb{ID: uint(chain[i].ID), Title: chain[i].Title})
+	}
+	for i, j := 0, len(crumbs)-1; i < j; i, j = i+1, j-1 {
+		crumbs[i], crumbs[j] = crumbs[j], crumbs[i]
+	}
+	return crumbs
+}
+
+// roleUserID is the ID used to look up a user's roles; anonymous users have none
+func roleUserID(user *models.User) int64 {
+	if user == nil {
+		return 0
+	}
+	return int64(user.ID)
+}
+
+// breadcrumbs returns the parents of an album the user can see, top level first
+func (as *AlbumService) breadcrumbs(ctx context.Context, user *models.User, albumID uint) ([]models.AlbumCrumb, error) {
+	chain, err := as.queries.ListAlbumAncestors(ctx, db.ListAlbumAncestorsParams{
+		AlbumID: int64(albumID),
+		UserID:  roleUserID(user),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return breadcrumbsFor(user, chain), nil
+}
+
+// buildAlbumTree turns the rows of an album tree (parents before children)
+// into nodes, leaving out the sub-albums the user cannot see and everything
+// below them. rootRole is the user's role on the root from its parents.
+func buildAlbumTree(user *models.User, rows []db.ListAlbumTreeRow, rootRole string) *models.AlbumNode {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	nodes := make(map[int64]*models.AlbumNode, len(rows))
+	roles := make(map[int64]string, len(rows))
+	order := make([]*models.AlbumNode, 0, len(rows))
+	for i, row := range rows {
+		role := rootRole
+		if i > 0 {
+			parentRole, ok := roles[row.ParentID.Int64]
+			if !ok {
+				continue
+			}
+			role = parentRole
+		}
+		if user != nil {
+			if row.UserID == int64(user.ID) {
+				role = AlbumRoleOwner
+			}
+			role = betterRole(role, row.Role)
+		}
+		album := db.Album{ID: row.ID, UserID: row.UserID, IsPublic: row.IsPublic, IsShared: row.IsShared}
+		if i > 0 && AlbumAccessFor(user, album, role) == AlbumAccessNone {
+			continue
+		}
+
+		node := &models.AlbumNode{
+			Album:           mappers.AlbumRowToModel(row),
+			TotalMediaCount: row.MediaCount,
+			Children:        []*models.AlbumNode{},
+		}
+		nodes[row.ID], roles[row.ID] = node, role
+		order = append(order, node)
+		if i > 0 {
+			parent := nodes[row.ParentID.Int64]
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	// Children come after their parents, so totals add up from the end
+	for i := len(order) - 1; i > 0; i-- {
+		node := order[i]
+		parent := nodes[int64(node.ParentID)]
+		parent.TotalMediaCount += node.TotalMediaCount
+		parent.AlbumCount += node.AlbumCount + 1
+	}
+	return order[0]
+}
+
+// GetAlbumTree returns an album with all the sub-albums the user can see,
+// nested, with media counts for each album and its sub-albums
+func (as *AlbumService) GetAlbumTree(ctx context.Context, user *models.User, albumID uint) (*models.AlbumNode, error) {
+	if _, _, err := as.Authorize(ctx, user, albumID, AlbumAccessView); err != nil {
+		return nil, err
+	}
+
+	rootRole := ""
+	if user != nil {
+		chain, err := as.queries.ListAlbumAncestors(ctx, db.ListAlbumAncestorsParams{
+			AlbumID: int64(albumID),
+			UserID:  int64(user.ID),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(chain) > 1 {
+			rootRole = inheritedRole(user, chain[1:])
+		}
+	}
+
+	rows, err := as.queries.ListAlbumTree(ctx, db.ListAlbumTreeParams{
+		AlbumID: int64(albumID),
+		UserID:  roleUserID(user),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree := buildAlbumTree(user, rows, rootRole)
+	if tree == nil {
+		return nil, ErrAlbumNotFound
+	}
+	return tree, nil
+}
+
+// CreateAlbumIn creates a sub-album of an album the user may contribute to.
+// The sub-album belongs to the user; the owner and collaborators of the
+// parent get access to it too.
+func (as *AlbumService) CreateAlbumIn(ctx context.Context, user *models.User, parentID uint, title, description string) (*models.Album, error) {
+	if title == "" {
+		return nil, fmt.Errorf("%w: album title is required", ErrInvalidAlbum)
+	}
+	if _, _, err := as.Authorize(ctx, user, parentID, AlbumAccessContribute); err != nil {
+		return nil, err
+	}
+
+	chain, err := as.queries.ListAlbumAncestors(ctx, db.ListAlbumAncestorsParams{AlbumID: int64(parentID)})
+	if err != nil {
+		return nil, err
+	}
+	if len(chain)+1 > MaxAlbumDepth {
+		return nil, fmt.Errorf("%w: albums can be nested at most %d deep", ErrInvalidAlbum, MaxAlbumDepth)
+	}
+
+	albumRow, err := as.queries.CreateAlbum(ctx, db.CreateAlbumParams{
+		Title:       title,
+		Description: sql.NullString{String: description, Valid: true},
+		UserID:      int64(user.ID),
+		ParentID:    sql.NullInt64{Int64: int64(parentID), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	album := mappers.AlbumRowToModel(albumRow)
+	album.UserName = user.Name
+	return &album, nil
+}
+
+// MoveAlbum moves an album, with its sub-albums, under another album, or to
+// the top level when parentID is 0. The user must manage the album and be
+// able to contribute to the new parent. Albums cannot be moved under
+// themselves or their own sub-albums.
+func (as *AlbumService) MoveAlbum(ctx context.Context, user *models.User, albumID, parentID uint) (*models.Album, error) {
+	if _, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage); err != nil {
+		return nil, err
+	}
+	if parentID == albumID {
+		return nil, fmt.Errorf("%w: an album cannot be its own parent", ErrInvalidAlbum)
+	}
+	if parentID != 0 {
+		if _, _, err := as.Authorize(ctx, user, parentID, AlbumAccessContribute); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := as.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := as.queries.WithTx(tx)
+	if err := qtx.LockAlbumTree(ctx); err != nil {
+		return nil, err
+	}
+
+	if parentID != 0 {
+		chain, err := qtx.ListAlbumAncestors(ctx, db.ListAlbumAncestorsParams{AlbumID: int64(parentID)})
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range chain {
+			if a.ID == int64(albumID) {
+				return nil, fmt.Errorf("%w: an album cannot be moved into one of its sub-albums", ErrInvalidAlbum)
+			}
+		}
+
+		subtree, err := qtx.ListAlbumTree(ctx, db.ListAlbumTreeParams{AlbumID: int64(albumID)})
+		if err != nil {
+			return nil, err
+		}
+		height := 0
+		for _, row := range subtree {
+			height = max(height, int(row.Depth))
+		}
+		if len(chain)+1+height > MaxAlbumDepth {
+			return nil, fmt.Errorf("%w: albums can be nested at most %d deep", ErrInvalidAlbum, MaxAlbumDepth)
+		}
+	}
+
+	if err := qtx.SetAlbumParent(ctx, db.SetAlbumParentParams{
+		ID:       int64(albumID),
+		ParentID: sql.NullInt64{Int64: int64(parentID), Valid: parentID != 0},
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return as.GetAlbumByID(ctx, user, albumID)
+}
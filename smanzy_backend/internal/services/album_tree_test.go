@@ -0,0 +1,101 @@
+package services
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+func TestInheritedRole(t *testing.T) {
+	user := &models.User{ID: 2}
+
+	// Day (1) > Event (2) > Year (3), nearest first
+	chain := []db.ListAlbumAncestorsRow{
+		{ID: 1, UserID: 1},
+		{ID: 2, UserID: 1, Role: AlbumRoleViewer},
+		{ID: 3, UserID: 1, Role: AlbumRoleEditor},
+	}
+	if got := inheritedRole(user, chain); got != AlbumRoleEditor {
+		t.Errorf("got %q, want the editor role from the top level", got)
+	}
+
+	chain[2].UserID = 2
+	if got := inheritedRole(user, chain); got != AlbumRoleOwner {
+		t.Errorf("got %q, want owner of a parent", got)
+	}
+
+	if got := inheritedRole(user, chain[:1]); got != "" {
+		t.Errorf("got %q, want no role", got)
+	}
+}
+
+func TestAlbumAccessFor_InheritedOwner(t *testing.T) {
+	user := &models.User{ID: 2}
+	if got := AlbumAccessFor(user, db.Album{UserID: 1}, AlbumRoleOwner); got != AlbumAccessManage {
+		t.Errorf("got %d, want owners of a parent to manage its sub-albums", got)
+	}
+	if got := AlbumAccessFor(nil, db.Album{UserID: 1}, AlbumRoleOwner); got != AlbumAccessNone {
+		t.Errorf("got %d, anonymous users never own albums", got)
+	}
+}
+
+func TestBreadcrumbsFor(t *testing.T) {
+	public := sql.NullBool{Bool: true, Valid: true}
+	chain := []db.ListAlbumAncestorsRow{
+		{ID: 4, Title: "Day 1", UserID: 1, IsPublic: public},
+		{ID: 3, Title: "Wedding", UserID: 1, IsPublic: public},
+		{ID: 2, Title: "2024", UserID: 1, IsPublic: public},
+		{ID: 1, Title: "Private archive", UserID: 1},
+	}
+
+	want := []models.AlbumCrumb{{ID: 2, Title: "2024"}, {ID: 3, Title: "Wedding"}}
+	if got := breadcrumbsFor(nil, chain); !reflect.DeepEqual(got, want) {
+		t.Errorf("anonymous: got %v, want %v", got, want)
+	}
+
+	owner := &models.User{ID: 1}
+	if got := breadcrumbsFor(owner, chain); len(got) != 3 || got[0].ID != 1 {
+		t.Errorf("owner: got %v, want all three parents, top level first", got)
+	}
+
+	if got := breadcrumbsFor(owner, chain[:1]); got != nil {
+		t.Errorf("top-level album: got %v, want no breadcrumbs", got)
+	}
+}
+
+func TestBuildAlbumTree(t *testing.T) {
+	parent := func(id int64) sql.NullInt64 { return sql.NullInt64{Int64: id, Valid: true} }
+	viewer := &models.User{ID: 2}
+
+	// Year (1) > Wedding (2) > Day 1 (4), Year > Private (3) > Hidden (5)
+	rows := []db.ListAlbumTreeRow{
+		{ID: 1, Title: "2024", UserID: 1, MediaCount: 1},
+		{ID: 2, Title: "Wedding", UserID: 1, ParentID: parent(1), Depth: 1, Role: AlbumRoleViewer, MediaCount: 2},
+		{ID: 3, Title: "Private", UserID: 1, ParentID: parent(1), Depth: 1, MediaCount: 7},
+		{ID: 4, Title: "Day 1", UserID: 1, ParentID: parent(2), Depth: 2, MediaCount: 3},
+		{ID: 5, Title: "Hidden", UserID: 1, ParentID: parent(3), Depth: 2, Role: AlbumRoleEditor, MediaCount: 9},
+	}
+
+	tree := buildAlbumTree(viewer, rows, "")
+	if tree.ID != 1 || len(tree.Children) != 1 || tree.Children[0].ID != 2 {
+		t.Fatalf("want only the Wedding sub-album, got %+v", tree.Children)
+	}
+	wedding := tree.Children[0]
+	if len(wedding.Children) != 1 || wedding.Children[0].ID != 4 {
+		t.Errorf("Day 1 should inherit the viewer role on Wedding, got %+v", wedding.Children)
+	}
+	if tree.TotalMediaCount != 6 || tree.AlbumCount != 2 {
+		t.Errorf("root totals: got %d media in %d sub-albums, want 6 in 2", tree.TotalMediaCount, tree.AlbumCount)
+	}
+	if wedding.TotalMediaCount != 5 || wedding.AlbumCount != 1 {
+		t.Errorf("Wedding totals: got %d media in %d sub-albums, want 5 in 1", wedding.TotalMediaCount, wedding.AlbumCount)
+	}
+
+	owner := &models.User{ID: 1}
+	if tree := buildAlbumTree(owner, rows, ""); tree.AlbumCount != 4 || tree.TotalMediaCount != 22 {
+		t.Errorf("owner: got %d media in %d sub-albums, want 22 in 4", tree.TotalMediaCount, tree.AlbumCount)
+	}
+}
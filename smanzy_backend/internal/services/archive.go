@@ -0,0 +1,226 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+)
+
+// ManifestName is the name of the manifest in download archives
+const ManifestName = "manifest.json"
+
+// MaxDownloadMedia caps the number of media IDs in one download request
+const MaxDownloadMedia = 1000
+
+// Reasons a media file is left out of a download archive
+const (
+	SkipNotScanned = "not_scanned" // Waiting for, failed or rejected by the malware scan
+	SkipMissing    = "missing"     // The file is gone from disk
+)
+
+// ArchiveOptions describes a download archive
+type ArchiveOptions struct {
+	AlbumID    uint   // Album downloads only
+	AlbumTitle string // Album downloads only
+	Manifest   bool   // Add manifest.json
+
+	// Source returns the path of the file to add for a media item, e.g. a
+	// watermarked copy. The stored original is added when it is nil.
+	Source func(m db.Medium) (string, error)
+}
+
+// ArchiveManifest lists the contents of a download archive
+type ArchiveManifest struct {
+	AlbumID    uint                   `json:"album_id,omitempty"`
+	AlbumTitle string                 `json:"album_title,omitempty"`
+	CreatedAt  int64                  `json:"created_at"`
+	Files      []ArchiveManifestFile  `json:"files"`
+	Skipped    []ArchiveManifestEntry `json:"skipped,omitempty"`
+}
+
+// ArchiveManifestFile is a media file in a download archive
+type ArchiveManifestFile struct {
+	Name        string `json:"name"` // Name in the archive
+	MediaID     int64  `json:"media_id"`
+	Filename    string `json:"filename"` // Original filename
+	MimeType    string `json:"mime_type,omitempty"`
+	Size        int64  `json:"size"`
+	Description string `json:"description,omitempty"`
+	UploadedAt  int64  `json:"uploaded_at"`
+	CapturedAt  int64  `json:"captured_at,omitempty"`
+}
+
+// ArchiveManifestEntry is a media file left out of a download archive
+type ArchiveManifestEntry struct {
+	MediaID  int64  `json:"media_id"`
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+// SafeFileName turns a name into a safe filename for downloads and flat
+// archives: no directories, control characters or leading dots. Empty names
+// become fallback.
+func SafeFileName(name, fallback string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\' || r == ':':
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// ArchiveFileNames returns a unique archive name for each media file, based
+// on its original filename. Repeated names get a counter before the
+// extension: photo.jpg, photo (2).jpg. Names are compared ignoring case, as
+// on Windows and macOS, and reserved names are never used.
+func ArchiveFileNames(media []db.Medium, reserved ...string) []string {
+	taken := make(map[string]bool, len(media)+len(reserved))
+	for _, name := range reserved {
+		taken[strings.ToLower(name)] = true
+	}
+
+	names := make([]string, len(media))
+	for i, m := range media {
+		name := SafeFileName(m.Filename, fmt.Sprintf("media-%d", m.ID))
+		ext := path.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		taken[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// storedCompressed reports whether a MIME type is already compressed, so
+// zipping it again would only cost time
+func storedCompressed(mimeType string) bool {
+	for _, prefix := range []string{"image/", "video/", "audio/"} {
+		if strings.HasPrefix(mimeType, prefix) {
+			return mimeType != "image/bmp" && mimeType != "image/svg+xml"
+		}
+	}
+	return mimeType == "application/zip" || mimeType == "application/pdf"
+}
+
+// WriteArchive streams a ZIP of media files from uploadDir, or from
+// opts.Source, to w, reading one file at a time. Files that have not passed the malware scan or are missing
+// from disk are left out and listed in the manifest.
+func WriteArchive(w io.Writer, uploadDir string, media []db.Medium, opts ArchiveOptions) error {
+	var reserved []string
+	if opts.Manifest {
+		reserved = append(reserved, ManifestName)
+	}
+	names := ArchiveFileNames(media, reserved...)
+
+	manifest := ArchiveManifest{
+		AlbumID:    opts.AlbumID,
+		AlbumTitle: opts.AlbumTitle,
+		CreatedAt:  time.Now().UnixMilli(),
+		Files:      []ArchiveManifestFile{},
+	}
+
+	zw := zip.NewWriter(w)
+	for i, m := range media {
+		skip := ArchiveManifestEntry{MediaID: m.ID, Filename: m.Filename}
+		if m.ScanStatus != ScanClean {
+			skip.Reason = SkipNotScanned
+			manifest.Skipped = append(manifest.Skipped, skip)
+			continue
+		}
+
+		filePath := filepath.Join(uploadDir, m.StoredName)
+		if opts.Source != nil {
+			var err error
+			if filePath, err = opts.Source(m); err != nil {
+				return err
+			}
+		}
+
+		written, err := addArchiveFile(zw, filePath, names[i], m)
+		if errors.Is(err, fs.ErrNotExist) {
+			skip.Reason = SkipMissing
+			manifest.Skipped = append(manifest.Skipped, skip)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		manifest.Files = append(manifest.Files, ArchiveManifestFile{
+			Name:        names[i],
+			MediaID:     m.ID,
+			Filename:    m.Filename,
+			MimeType:    m.MimeType.String,
+			Size:        written,
+			Description: m.Description.String,
+			UploadedAt:  m.CreatedAt,
+			CapturedAt:  m.CapturedAt.Int64,
+		})
+	}
+
+	if opts.Manifest {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     ManifestName,
+			Method:   zip.Deflate,
+			Modified: time.UnixMilli(manifest.CreatedAt).UTC(),
+		})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(manifest); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// addArchiveFile copies one media file into the archive and returns its size
+func addArchiveFile(zw *zip.Writer, filePath, name string, m db.Medium) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	modified := m.CreatedAt
+	if m.CapturedAt.Valid {
+		modified = m.CapturedAt.Int64
+	}
+	method := zip.Deflate
+	if storedCompressed(m.MimeType.String) {
+		method = zip.Store
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: time.UnixMilli(modified).UTC(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(f, file)
+}
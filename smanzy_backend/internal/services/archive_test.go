@@ -0,0 +1,136 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+)
+
+func TestArchiveFileNames(t *testing.T) {
+	media := []db.Medium{
+		{ID: 1, Filename: "photo.jpg"},
+		{ID: 2, Filename: "Photo.JPG"},
+		{ID: 3, Filename: "photo.jpg"},
+		{ID: 4, Filename: "../../etc/passwd"},
+		{ID: 5, Filename: " .hidden"},
+		{ID: 6, Filename: ""},
+		{ID: 7, Filename: "manifest.json"},
+		{ID: 8, Filename: "photo (2).jpg"},
+	}
+	want := []string{
+		"photo.jpg",
+		"Photo (2).JPG",
+		"photo (3).jpg",
+		"_.._etc_passwd",
+		"hidden",
+		"media-6",
+		"manifest (2).json",
+		"photo (2) (2).jpg",
+	}
+
+	got := ArchiveFileNames(media, ManifestName)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("first"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("second"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	media := []db.Medium{
+		{ID: 1, Filename: "beach.jpg", StoredName: "a.jpg", ScanStatus: ScanClean, MimeType: sql.NullString{String: "image/jpeg", Valid: true}},
+		{ID: 2, Filename: "beach.jpg", StoredName: "b.txt", ScanStatus: ScanClean},
+		{ID: 3, Filename: "virus.exe", StoredName: "c.exe", ScanStatus: ScanInfected},
+		{ID: 4, Filename: "gone.png", StoredName: "d.png", ScanStatus: ScanClean},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, dir, media, ArchiveOptions{AlbumID: 9, AlbumTitle: "Summer", Manifest: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+
+	if files["beach.jpg"] != "first" || files["beach (2).jpg"] != "second" || len(files) != 3 {
+		t.Fatalf("unexpected archive contents: %v", files)
+	}
+
+	var manifest ArchiveManifest
+	if err := json.Unmarshal([]byte(files[ManifestName]), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.AlbumID != 9 || len(manifest.Files) != 2 || manifest.Files[1].Name != "beach (2).jpg" || manifest.Files[1].Size != 6 {
+		t.Errorf("unexpected manifest files: %+v", manifest)
+	}
+	wantSkipped := []ArchiveManifestEntry{
+		{MediaID: 3, Filename: "virus.exe", Reason: SkipNotScanned},
+		{MediaID: 4, Filename: "gone.png", Reason: SkipMissing},
+	}
+	if !reflect.DeepEqual(manifest.Skipped, wantSkipped) {
+		t.Errorf("skipped: got %+v, want %+v", manifest.Skipped, wantSkipped)
+	}
+}
+
+func TestWriteArchive_Source(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	marked := filepath.Join(dir, "marked.jpg")
+	if err := os.WriteFile(marked, []byte("watermarked"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	media := []db.Medium{{ID: 1, Filename: "beach.jpg", StoredName: "a.jpg", ScanStatus: ScanClean}}
+	var buf bytes.Buffer
+	err := WriteArchive(&buf, dir, media, ArchiveOptions{
+		Source: func(m db.Medium) (string, error) { return marked, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(zr.File))
+	}
+	r, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "watermarked" {
+		t.Errorf("got %q, want the file from Source", data)
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// Import outcomes reported per file
+const (
+	ImportImported = "imported" // Added to the library (or would be, in a dry run)
+	ImportSkipped  = "skipped"  // The same contents are already in the library
+	ImportFailed   = "failed"
+)
+
+// ImportOptions configures a directory import
+type ImportOptions struct {
+	UserID         uint
+	UserName       string
+	Dir            string
+	Visibility     string // Visibility of the new media; defaults to inherit
+	AlbumPerFolder bool   // Put the files of each folder in an album named after it
+	DryRun         bool   // Report what would happen without changing anything
+}
+
+// ImportProgress reports the outcome of one file
+type ImportProgress struct {
+	Index   int // 1-based position of the file
+	Total   int
+	Path    string // Relative to the import directory
+	Status  string // One of the Import* outcomes
+	MediaID uint   // New or existing media item; 0 in a dry run or on failure
+	Err     error
+}
+
+// ImportSummary totals the outcome of an import
+type ImportSummary struct {
+	Files         int
+	Imported      int
+	Skipped       int
+	Failed        int
+	AlbumsCreated int
+	Bytes         int64 // Total size of the imported files
+	Duration      time.Duration
+}
+
+// ImportService adds the files of a server-side directory to a user's library
+type ImportService struct {
+	queries      *db.Queries
+	mediaService *MediaService
+	albumService *AlbumService
+}
+
+// NewImportService creates a new import service
+func NewImportService(queries *db.Queries, mediaService *MediaService, albumService *AlbumService) *ImportService {
+	return &ImportService{
+		queries:      queries,
+		mediaService: mediaService,
+		albumService: albumService,
+	}
+}
+
+// Import walks opts.Dir and adds every regular file to the user's library
+// through the same validation as uploads. Hidden files and folders are
+// ignored. Files whose contents are already in the library are skipped (but
+// still added to their folder's album), so an interrupted import can simply
+// be run again. Media keep the modification time of their file as creation
+// time. progress, if not nil, is called after every file.
+//
+// A failing file does not stop the import; it is counted in the summary.
+// An error is returned only if the import could not run or was cancelled.
+func (is *ImportService) Import(ctx context.Context, opts ImportOptions, progress func(ImportProgress)) (ImportSummary, error) {
+	start := time.Now()
+	summary := ImportSummary{}
+
+	if opts.Visibility == "" {
+		opts.Visibility = models.VisibilityInherit
+	}
+	if !models.IsValidVisibility(opts.Visibility) {
+		return summary, fmt.Errorf("%w: invalid visibility %q", ErrInvalidUpload, opts.Visibility)
+	}
+
+	root, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return summary, err
+	}
+	files, err := listImportFiles(root)
+	if err != nil {
+		return summary, err
+	}
+	summary.Files = len(files)
+
+	albums := make(map[string]int64) // Folder -> album ID
+	for i, rel := range files {
+		if err := ctx.Err(); err != nil {
+			summary.Duration = time.Since(start)
+			return summary, err
+		}
+
+		p := ImportProgress{Index: i + 1, Total: len(files), Path: rel}
+		var size int64
+		p.MediaID, p.Status, size, p.Err = is.importFile(ctx, root, rel, opts)
+		if p.Err == nil && opts.AlbumPerFolder && !opts.DryRun {
+			created, err := is.addToFolderAlbum(ctx, albums, root, rel, p.MediaID, opts)
+			if created {
+				summary.AlbumsCreated++
+			}
+			if err != nil {
+				p.Status, p.Err = ImportFailed, fmt.Errorf("adding to album: %w", err)
+			}
+		}
+
+		switch p.Status {
+		case ImportImported:
+			summary.Imported++
+			summary.Bytes += size
+		case ImportSkipped:
+			summary.Skipped++
+		default:
+			summary.Failed++
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	summary.Duration = time.Since(start)
+	return summary, nil
+}
+
+// importFile adds one file unless its contents are already in the library.
+// It returns the media ID, the outcome and the size of an imported file.
+func (is *ImportService) importFile(ctx context.Context, root, rel string, opts ImportOptions) (uint, string, int64, error) {
+	path := filepath.Join(root, rel)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, ImportFailed, 0, err
+	}
+
+	hash, err := HashFile(path)
+	if err != nil {
+		return 0, ImportFailed, 0, err
+	}
+	existingID, err := is.queries.GetMediaIDByContentHash(ctx, db.GetMediaIDByContentHashParams{
+		UserID:      int64(opts.UserID),
+		ContentHash: sql.NullString{String: hash, Valid: true},
+	})
+	if err == nil {
+		return uint(existingID), ImportSkipped, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, ImportFailed, 0, err
+	}
+
+	newFile := NewMediaFile{
+		UserID:     opts.UserID,
+		Filename:   filepath.Base(rel),
+		Visibility: opts.Visibility,
+	}
+	if opts.DryRun {
+		if err := ValidateNewMedia(newFile); err != nil {
+			return 0, ImportFailed, 0, err
+		}
+		return 0, ImportImported, info.Size(), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, ImportFailed, 0, err
+	}
+	defer f.Close()
+
+	row, err := is.mediaService.CreateMedia(ctx, newFile, f)
+	if err != nil {
+		return 0, ImportFailed, 0, err
+	}
+
+	// Keep the original timestamps, on the record and on the stored file
+	modTime := info.ModTime()
+	if err := is.queries.SetMediaCreatedAt(ctx, db.SetMediaCreatedAtParams{
+		ID:        row.ID,
+		CreatedAt: modTime.UnixMilli(),
+	}); err != nil {
+		return uint(row.ID), ImportFailed, 0, err
+	}
+	_ = os.Chtimes(QuarantinePath(is.mediaService.uploadDir, row.StoredName), modTime, modTime)
+
+	return uint(row.ID), ImportImported, row.Size, nil
+}
+
+// addToFolderAlbum adds a media item to the album of its folder, reusing an
+// album of the user with the same title or creating one. Files at the top of
+// the import directory go to an album named after the directory itself.
+// It reports whether a new album was created.
+func (is *ImportService) addToFolderAlbum(ctx context.Context, albums map[string]int64, root, rel string, mediaID uint, opts ImportOptions) (bool, error) {
+	title := filepath.ToSlash(filepath.Dir(rel))
+	if title == "." {
+		title = filepath.Base(root)
+	}
+
+	created := false
+	albumID, ok := albums[title]
+	if !ok {
+		id, err := is.queries.GetUserAlbumIDByTitle(ctx, db.GetUserAlbumIDByTitleParams{
+			UserID: int64(opts.UserID),
+			Title:  title,
+		})
+		switch {
+		case err == nil:
+			albumID = id
+		case errors.Is(err, sql.ErrNoRows):
+			album, err := is.albumService.CreateAlbum(ctx, opts.UserID, title, "Imported from "+title, opts.UserName)
+			if err != nil {
+				return false, err
+			}
+			albumID, created = int64(album.ID), true
+		default:
+			return false, err
+		}
+		albums[title] = albumID
+	}
+
+	return created, is.queries.AddMediaToAlbum(ctx, db.AddMediaToAlbumParams{
+		AlbumID: albumID,
+		MediaID: int64(mediaID),
+	})
+}
+
+// listImportFiles returns the regular files under root, relative to it, in
+// lexical order. Hidden files and folders are skipped and symlinks are not
+// followed.
+func listImportFiles(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	var files []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListImportFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"b.jpg",
+		"a.png",
+		"2019/trip/beach.mp4",
+		"2019/.thumbs/beach.jpg",
+		".DS_Store",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "a.png"), filepath.Join(root, "link.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listImportFiles(root)
+	if err != nil {
+		t.Fatalf("listImportFiles: %v", err)
+	}
+	want := []string{filepath.Join("2019", "trip", "beach.mp4"), "a.png", "b.jpg"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %v, want %v", files, want)
+	}
+
+	if _, err := listImportFiles(filepath.Join(root, "a.png")); err == nil {
+		t.Error("expected an error for a file instead of a directory")
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// DefaultMaxVersions is how many earlier versions of a file are kept by default
+const DefaultMaxVersions = 10
+
+// ThumbnailSizes lists the thumbnail directories produced by smanzy_thumbgen
+var ThumbnailSizes = []string{"160x100", "320x200", "640x400", "800x600"}
+
+var (
+	// ErrMediaNotFound is returned when a media item does not exist
+	ErrMediaNotFound = errors.New("media not found")
+	// ErrMediaNotInTrash is returned when restoring media that is not trashed
+	ErrMediaNotInTrash = errors.New("media is not in the trash")
+	// ErrVersionNotFound is returned when a media version does not exist
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// MediaService handles business logic for media files on disk and in the database
+type MediaService struct {
+	conn        *sql.DB
+	queries     *db.Queries
+	uploadDir   string
+	maxVersions int           // Earlier versions kept per media item when a file is replaced
+	scanWake    chan struct{} // Signals the scan service that a file entered quarantine
+}
+
+// NewMediaService creates a new media service
+func NewMediaService(conn *sql.DB, queries *db.Queries, uploadDir string, maxVersions int) *MediaService {
+	return &MediaService{
+		conn:        conn,
+		queries:     queries,
+		uploadDir:   uploadDir,
+		maxVersions: maxVersions,
+		scanWake:    make(chan struct{}, 1),
+	}
+}
+
+// queueScan starts the scan service right away instead of waiting for its
+// next tick
+func (ms *MediaService) queueScan() {
+	select {
+	case ms.scanWake <- struct{}{}:
+	default:
+	}
+}
+
+// MediaUpdate is the new metadata of a media item
+type MediaUpdate struct {
+	Filename    string
+	Visibility  string
+	Description string
+}
+
+// UpdateMedia saves the metadata of a media item. If file is not nil it also
+// replaces the item's file, as ReplaceFile does, in the same transaction: the
+// item keeps both its old metadata and its old file if anything fails.
+func (ms *MediaService) UpdateMedia(ctx context.Context, mediaID uint, update MediaUpdate, file *ReplacementFile) (*models.Media, error) {
+	tx, err := ms.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := ms.queries.WithTx(tx)
+
+	done := func() {}
+	if file != nil {
+		if _, done, err = ms.replaceFile(ctx, qtx, mediaID, *file); err != nil {
+			return nil, err
+		}
+	}
+
+	// Type, MIME type and size are those of the file now in place
+	current, err := qtx.GetMediaByID(ctx, int64(mediaID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, err
+	}
+
+	updated, err := qtx.UpdateMedia(ctx, db.UpdateMediaParams{
+		ID:          current.ID,
+		Filename:    update.Filename,
+		Type:        sql.NullString{String: current.Type, Valid: true},
+		MimeType:    sql.NullString{String: current.MimeType, Valid: true},
+		Size:        current.Size,
+		Visibility:  update.Visibility,
+		Description: sql.NullString{String: update.Description, Valid: update.Description != ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	done()
+
+	media := mappers.MediaRowToModel(updated)
+	return &media, nil
+}
+
+// MoveToTrash soft deletes a media item. Album memberships are kept so a
+// restore puts the media back where it was.
+func (ms *MediaService) MoveToTrash(ctx context.Context, mediaID uint) error {
+	return ms.queries.SoftDeleteMedia(ctx, int64(mediaID))
+}
+
+// Restore takes a media item out of the trash
+func (ms *MediaService) Restore(ctx context.Context, mediaID uint) (*models.Media, error) {
+	row, err := ms.queries.GetMediaByIDWithDeleted(ctx, int64(mediaID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, err
+	}
+	if err := checkRestorable(row); err != nil {
+		return nil, err
+	}
+
+	if err := ms.queries.RestoreMedia(ctx, row.ID); err != nil {
+		return nil, err
+	}
+
+	media := mappers.MediaRowToModel(row)
+	media.DeletedAt = nil
+	return &media, nil
+}
+
+// checkRestorable reports why a media item cannot leave the trash: it is
+// not in the trash, or the malware scan rejected it
+func checkRestorable(row db.GetMediaByIDWithDeletedRow) error {
+	if !row.DeletedAt.Valid {
+		return ErrMediaNotInTrash
+	}
+	if row.ScanStatus == ScanInfected {
+		return ErrMediaInfected
+	}
+	return nil
+}
+
+// ListTrash returns the trashed media of a user, most recently deleted first
+func (ms *MediaService) ListTrash(ctx context.Context, userID uint) ([]models.Media, error) {
+	rows, err := ms.queries.ListTrashedMedia(ctx, int64(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	medias := make([]models.Media, len(rows))
+	for i, row := range rows {
+		medias[i] = mappers.MediaRowToModel(row)
+	}
+	return medias, nil
+}
+
+// EmptyTrash permanently deletes all trashed media of a user and their files.
+// It returns the number of items removed.
+func (ms *MediaService) EmptyTrash(ctx context.Context, userID uint) (int, error) {
+	rows, err := ms.queries.PurgeUserTrash(ctx, int64(userID))
+	if err != nil {
+		return 0, err
+	}
+
+	// Rows list the files of every version, so count the items separately
+	removed := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		removed[row.MediaID] = true
+		ms.RemoveFiles(row.StoredName)
+	}
+	return len(removed), nil
+}
+
+// PurgeExpired permanently deletes media that has been in the trash longer
+// than the retention period. It returns the number of items removed.
+func (ms *MediaService) PurgeExpired(ctx context.Context, retention time.Duration) (int, error) {
+	rows, err := ms.queries.PurgeExpiredTrash(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		removed[row.MediaID] = true
+		ms.RemoveFiles(row.StoredName)
+	}
+	return len(removed), nil
+}
+
+// RunTrashPurger purges expired trash every interval until ctx is cancelled
+func (ms *MediaService) RunTrashPurger(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := ms.PurgeExpired(ctx, retention); err != nil {
+			log.Printf("Trash purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Trash purge removed %d media item(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RemoveFiles deletes a stored file, its thumbnails (watermarked or not) and its HLS stream from disk,
+// or the file in quarantine if it was not released yet. Missing files are ignored.
+func (ms *MediaService) RemoveFiles(storedName string) {
+	if storedName == "" || filepath.Base(storedName) != storedName {
+		return
+	}
+
+	_ = os.Remove(filepath.Join(ms.uploadDir, storedName))
+	_ = os.Remove(QuarantinePath(ms.uploadDir, storedName))
+
+	for _, size := range ThumbnailSizes {
+		_ = os.Remove(ThumbnailPath(ms.uploadDir, size, storedName))
+		_ = os.Remove(WatermarkedThumbnailPath(ms.uploadDir, size, storedName))
+	}
+	_ = os.Remove(WatermarkedOriginalPath(ms.uploadDir, storedName))
+	_ = os.RemoveAll(HLSDir(ms.uploadDir, storedName))
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// Batch actions supported by ApplyBatch
+const (
+	BatchDelete     = "delete"
+	BatchRestore    = "restore"
+	BatchRename     = "rename"
+	BatchMove       = "move"
+	BatchCopy       = "copy"
+	BatchVisibility = "visibility"
+)
+
+// MaxBatchSize caps the number of media IDs in a single batch request
+const MaxBatchSize = 500
+
+var (
+	// ErrInvalidBatch is returned when a batch request is malformed
+	ErrInvalidBatch = errors.New("invalid batch request")
+	// ErrForbidden is returned when the user may not touch a resource
+	ErrForbidden = errors.New("forbidden")
+
+	errInvalidRename = errors.New("pattern produced an invalid filename")
+)
+
+// batchItemErrors are the item errors reported to the client as they are.
+// Anything else is logged and reported as a plain failure.
+var batchItemErrors = []error{
+	ErrMediaNotFound,
+	ErrForbidden,
+	ErrMediaNotInTrash,
+	ErrMediaInfected,
+	errInvalidRename,
+}
+
+// batchItemError turns the error of one batch item into its message for the
+// client, so database errors are not passed on
+func batchItemError(action string, id uint, err error) string {
+	for _, known := range batchItemErrors {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	log.Printf("Batch %s of media %d failed: %v", action, id, err)
+	return "failed"
+}
+
+// BatchOperation describes one action applied to a list of media items
+type BatchOperation struct {
+	Action      string
+	IDs         []uint
+	AlbumID     uint   // Target album for move and copy
+	FromAlbumID uint   // Source album for move
+	Pattern     string // Filename pattern for rename
+	Visibility  string // New visibility for visibility
+}
+
+// MaxRenamePadding caps the width of a zero-padded {n:3} placeholder
+const MaxRenamePadding = 9
+
+// renameToken matches {name}, {ext}, {id}, {n} and zero-padded {n:3} placeholders
+var renameToken = regexp.MustCompile(`\{(name|ext|id|n)(?::(\d+))?\}`)
+
+// RenameFromPattern builds a filename from a rename pattern.
+// {name} is the original name without extension, {ext} its extension
+// (including the dot), {id} the media ID and {n} the 1-based position in
+// the batch; {n:3} pads the position with zeros to 3 digits, at most
+// MaxRenamePadding.
+func RenameFromPattern(pattern, original string, id uint, n int) string {
+	ext := filepath.Ext(original)
+	name := strings.TrimSuffix(original, ext)
+
+	return renameToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		m := renameToken.FindStringSubmatch(token)
+		switch m[1] {
+		case "name":
+			return name
+		case "ext":
+			return ext
+		case "id":
+			return strconv.FormatUint(uint64(id), 10)
+		default:
+			width, _ := strconv.Atoi(m[2])
+			width = min(width, MaxRenamePadding)
+			return fmt.Sprintf("%0*d", width, n)
+		}
+	})
+}
+
+// validate checks the operation is well formed before any work is done
+func (op BatchOperation) validate() error {
+	if len(op.IDs) == 0 {
+		return fmt.Errorf("%w: no media IDs given", ErrInvalidBatch)
+	}
+	if len(op.IDs) > MaxBatchSize {
+		return fmt.Errorf("%w: at most %d media IDs per batch", ErrInvalidBatch, MaxBatchSize)
+	}
+
+	switch op.Action {
+	case BatchDelete, BatchRestore:
+	case BatchRename:
+		if strings.TrimSpace(op.Pattern) == "" {
+			return fmt.Errorf("%w: rename needs a pattern", ErrInvalidBatch)
+		}
+		for _, m := range renameToken.FindAllStringSubmatch(op.Pattern, -1) {
+			if width, err := strconv.Atoi(m[2]); m[2] != "" && (err != nil || width > MaxRenamePadding) {
+				return fmt.Errorf("%w: {n} is padded to at most %d digits", ErrInvalidBatch, MaxRenamePadding)
+			}
+		}
+	case BatchCopy:
+		if op.AlbumID == 0 {
+			return fmt.Errorf("%w: copy needs an album_id", ErrInvalidBatch)
+		}
+	case BatchMove:
+		if op.AlbumID == 0 || op.FromAlbumID == 0 {
+			return fmt.Errorf("%w: move needs album_id and from_album_id", ErrInvalidBatch)
+		}
+	case BatchVisibility:
+		if !models.IsValidVisibility(op.Visibility) {
+			return fmt.Errorf("%w: invalid visibility", ErrInvalidBatch)
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidBatch, op.Action)
+	}
+	return nil
+}
+
+// ApplyBatch applies one action to many media items inside a single transaction.
+// Every item is checked for ownership (owner or admin) and runs under its own
+// savepoint, so a failing item is reported without undoing the others.
+func (ms *MediaService) ApplyBatch(ctx context.Context, user *models.User, op BatchOperation) ([]models.MediaBatchResult, error) {
+	if err := op.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := ms.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := ms.queries.WithTx(tx)
+
+	// Album targets are checked once, up front, as for AddMediaToAlbum and
+	// RemoveMediaFromAlbum
+	for _, albumID := range []uint{op.AlbumID, op.FromAlbumID} {
+		if albumID == 0 {
+			continue
+		}
+		album, _, err := authorizeAlbum(ctx, qtx, user, albumID, AlbumAccessContribute)
+		if err != nil {
+			if errors.Is(err, ErrAlbumNotFound) {
+				return nil, fmt.Errorf("%w: album %d not found", ErrInvalidBatch, albumID)
+			}
+			return nil, err
+		}
+		if album.SmartRules.Valid {
+			return nil, fmt.Errorf("%w: album %d is a smart album", ErrInvalidBatch, albumID)
+		}
+	}
+
+	results := make([]models.MediaBatchResult, 0, len(op.IDs))
+	for i, id := range op.IDs {
+		result := models.MediaBatchResult{ID: id, Status: "ok"}
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+			return nil, err
+		}
+
+		filename, err := ms.applyBatchItem(ctx, qtx, user, op, id, i+1)
+		if err != nil {
+			if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item"); rbErr != nil {
+				return nil, rbErr
+			}
+			result.Status = "error"
+			result.Error = batchItemError(op.Action, id, err)
+		} else {
+			result.Filename = filename
+		}
+
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_item"); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// applyBatchItem applies the operation to a single media item.
+// It returns the item's filename after the operation.
+func (ms *MediaService) applyBatchItem(ctx context.Context, qtx *db.Queries, user *models.User, op BatchOperation, id uint, n int) (string, error) {
+	row, err := qtx.GetMediaByIDWithDeleted(ctx, int64(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrMediaNotFound
+		}
+		return "", err
+	}
+
+	// Access Control: Owner or Admin
+	if row.UserID != int64(user.ID) && !user.HasRole("admin") {
+		return "", ErrForbidden
+	}
+
+	// Only restore works on trashed media
+	if row.DeletedAt.Valid && op.Action != BatchRestore {
+		return "", ErrMediaNotFound
+	}
+
+	switch op.Action {
+	case BatchDelete:
+		err = qtx.SoftDeleteMedia(ctx, row.ID)
+	case BatchRestore:
+		if err := checkRestorable(row); err != nil {
+			return "", err
+		}
+		err = qtx.RestoreMedia(ctx, row.ID)
+	case BatchRename:
+		newName := RenameFromPattern(op.Pattern, row.Filename, id, n)
+		if !ValidFilename(newName) || strings.TrimSpace(newName) == "" || strings.ContainsAny(newName, `/\`) {
+			return "", errInvalidRename
+		}
+		if err := qtx.RenameMedia(ctx, db.RenameMediaParams{ID: row.ID, Filename: newName}); err != nil {
+			return "", err
+		}
+		return newName, nil
+	case BatchCopy:
+		err = qtx.AddMediaToAlbum(ctx, db.AddMediaToAlbumParams{AlbumID: int64(op.AlbumID), MediaID: row.ID})
+	case BatchMove:
+		if err := qtx.AddMediaToAlbum(ctx, db.AddMediaToAlbumParams{AlbumID: int64(op.AlbumID), MediaID: row.ID}); err != nil {
+			return "", err
+		}
+		err = qtx.RemoveMediaFromAlbum(ctx, db.RemoveMediaFromAlbumParams{AlbumID: int64(op.FromAlbumID), MediaID: row.ID})
+	case BatchVisibility:
+		err = qtx.SetMediaVisibility(ctx, db.SetMediaVisibilityParams{ID: row.ID, Visibility: op.Visibility})
+	}
+	if err != nil {
+		return "", err
+	}
+	return row.Filename, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+)
+
+func TestRenameFromPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"{name}{ext}", "beach.jpg"},
+		{"holiday-{n:3}{ext}", "holiday-007.jpg"},
+		{"{id}_{name}", "42_beach"},
+		{"{n}-{unknown}", "7-{unknown}"},
+		{"{n:40}", "000000007"},
+	}
+
+	for _, tt := range tests {
+		if got := RenameFromPattern(tt.pattern, "beach.jpg", 42, 7); got != tt.want {
+			t.Errorf("RenameFromPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestValidFilename(t *testing.T) {
+	tests := map[string]bool{
+		"beach.jpg":                       true,
+		"":                                false,
+		".":                               false,
+		"..":                              false,
+		"trip/beach.jpg":                  false,
+		strings.Repeat("a", 252) + ".jpg": false,
+		strings.Repeat("a", 251) + ".jpg": true,
+	}
+	for name, want := range tests {
+		if got := ValidFilename(name); got != want {
+			t.Errorf("ValidFilename(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestBatchOperationValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    BatchOperation
+		valid bool
+	}{
+		{"delete", BatchOperation{Action: BatchDelete, IDs: []uint{1}}, true},
+		{"no ids", BatchOperation{Action: BatchDelete}, false},
+		{"too many ids", BatchOperation{Action: BatchDelete, IDs: make([]uint, MaxBatchSize+1)}, false},
+		{"unknown action", BatchOperation{Action: "explode", IDs: []uint{1}}, false},
+		{"rename without pattern", BatchOperation{Action: BatchRename, IDs: []uint{1}}, false},
+		{"rename padded", BatchOperation{Action: BatchRename, IDs: []uint{1}, Pattern: "{n:9}{ext}"}, true},
+		{"rename padded too wide", BatchOperation{Action: BatchRename, IDs: []uint{1}, Pattern: "{n:10}{ext}"}, false},
+		{"rename padded huge", BatchOperation{Action: BatchRename, IDs: []uint{1}, Pattern: "{n:99999999999999999999}"}, false},
+		{"move without source", BatchOperation{Action: BatchMove, IDs: []uint{1}, AlbumID: 2}, false},
+		{"copy", BatchOperation{Action: BatchCopy, IDs: []uint{1}, AlbumID: 2}, true},
+		{"bad visibility", BatchOperation{Action: BatchVisibility, IDs: []uint{1}, Visibility: "secret"}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.op.validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidBatch) {
+			t.Errorf("%s: expected ErrInvalidBatch, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestBatchItemError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrForbidden, "forbidden"},
+		{ErrMediaInfected, ErrMediaInfected.Error()},
+		{fmt.Errorf("restoring: %w", ErrMediaNotInTrash), ErrMediaNotInTrash.Error()},
+		{errors.New(`pq: duplicate key value violates unique constraint "album_media_pkey"`), "failed"},
+	}
+
+	for _, tt := range tests {
+		if got := batchItemError(BatchRestore, 1, tt.err); got != tt.want {
+			t.Errorf("batchItemError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRestorable(t *testing.T) {
+	trashed := sql.NullTime{Time: time.Now(), Valid: true}
+	tests := []struct {
+		name string
+		row  db.GetMediaByIDWithDeletedRow
+		want error
+	}{
+		{"trashed", db.GetMediaByIDWithDeletedRow{DeletedAt: trashed, ScanStatus: ScanClean}, nil},
+		{"not trashed", db.GetMediaByIDWithDeletedRow{ScanStatus: ScanClean}, ErrMediaNotInTrash},
+		{"infected", db.GetMediaByIDWithDeletedRow{DeletedAt: trashed, ScanStatus: ScanInfected}, ErrMediaInfected},
+	}
+
+	for _, tt := range tests {
+		if err := checkRestorable(tt.row); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
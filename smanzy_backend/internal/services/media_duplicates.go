@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/phash"
+)
+
+const (
+	// DefaultSimilarDistance is the largest hash distance, in bits, at which
+	// two items are considered near-duplicates
+	DefaultSimilarDistance = 10
+	// MaxSimilarDistance caps the distance a client may ask for; above this
+	// unrelated images start to match
+	MaxSimilarDistance = 20
+	// MaxDuplicatePairs caps the pairs considered for one duplicates report
+	MaxDuplicatePairs = 500
+	// MaxMergeSize caps the duplicates merged in one request
+	MaxMergeSize = 100
+
+	// hashThumbnailSize is the thumbnail the hash is computed from. Hashing the
+	// thumbnail rather than the original covers images, video keyframes and
+	// HEIC files the same way, since smanzy_thumbgen already extracted them.
+	hashThumbnailSize = "320x200"
+	hashBatchSize     = 100
+)
+
+var (
+	// ErrHashPending is returned when a media item has not been hashed yet
+	ErrHashPending = errors.New("perceptual hash not computed yet")
+	// ErrInvalidMerge is returned when a merge request is malformed
+	ErrInvalidMerge = errors.New("invalid merge")
+)
+
+// IndexHashes computes the perceptual hash of every image and video that has
+// a thumbnail but no hash yet. Items whose thumbnail is not generated yet are
+// skipped and picked up on a later run. It returns the number of items hashed.
+func (ms *MediaService) IndexHashes(ctx context.Context) (int, error) {
+	hashed := 0
+	var afterID int64
+
+	for {
+		rows, err := ms.queries.ListMediaMissingHash(ctx, db.ListMediaMissingHashParams{
+			AfterID:    afterID,
+			MaxResults: hashBatchSize,
+		})
+		if err != nil {
+			return hashed, err
+		}
+
+		for _, row := range rows {
+			afterID = row.ID
+
+			hash, err := ms.hashThumbnail(row.StoredName)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					log.Printf("Hashing media %d failed: %v", row.ID, err)
+				}
+				continue
+			}
+
+			if err := ms.queries.SetMediaHash(ctx, db.SetMediaHashParams{
+				ID:    row.ID,
+				Phash: sql.NullInt64{Int64: int64(hash), Valid: true},
+			}); err != nil {
+				return hashed, err
+			}
+			hashed++
+		}
+
+		if len(rows) < hashBatchSize {
+			return hashed, nil
+		}
+	}
+}
+
+// hashThumbnail computes the perceptual hash of a stored file from its thumbnail
+func (ms *MediaService) hashThumbnail(storedName string) (uint64, error) {
+	f, err := os.Open(ThumbnailPath(ms.uploadDir, hashThumbnailSize, storedName))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		return 0, err
+	}
+	return phash.DHash(img), nil
+}
+
+// RunThumbnailIndexer computes perceptual hashes and placeholders of new media
+// every interval, once their thumbnails exist, until ctx is cancelled
+func (ms *MediaService) RunThumbnailIndexer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := ms.IndexHashes(ctx); err != nil {
+			log.Printf("Hash indexing failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Hash indexing hashed %d media item(s)", n)
+		}
+		if n, err := ms.IndexPlaceholders(ctx); err != nil {
+			log.Printf("Placeholder indexing failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Placeholder indexing processed %d media item(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FindSimilar returns media of the same owner that look like the given item,
+// closest first
+func (ms *MediaService) FindSimilar(ctx context.Context, mediaID uint, maxDistance, limit int) ([]models.SimilarMedia, error) {
+	row, err := ms.queries.GetMediaByID(ctx, int64(mediaID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, err
+	}
+
+	hash, err := ms.queries.GetMediaHash(ctx, row.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !hash.Valid {
+		return nil, ErrHashPending
+	}
+
+	rows, err := ms.queries.ListSimilarMedia(ctx, db.ListSimilarMediaParams{
+		Phash:       hash.Int64,
+		UserID:      row.UserID,
+		ID:          row.ID,
+		MaxDistance: int32(maxDistance),
+		MaxResults:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	similar := make([]models.SimilarMedia, len(rows))
+	for i, r := range rows {
+		similar[i] = models.SimilarMedia{
+			Media:    mappers.MediaRowToModel(r),
+			Distance: int(r.Distance),
+		}
+	}
+	return similar, nil
+}
+
+// duplicateCluster is a group of media IDs linked by near-duplicate pairs
+type duplicateCluster struct {
+	ids         []int64
+	maxDistance int
+}
+
+// groupDuplicatePairs joins pairs that share an item into clusters, so three
+// copies of a photo are reported as one group rather than three pairs.
+// Clusters keep the order in which they were first seen (closest pairs first).
+func groupDuplicatePairs(pairs []db.ListDuplicatePairsRow) []duplicateCluster {
+	parent := make(map[int64]int64)
+	var find func(id int64) int64
+	find = func(id int64) int64 {
+		p, ok := parent[id]
+		if !ok {
+			parent[id] = id
+			return id
+		}
+		if p == id {
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+
+	var order []int64
+	seen := make(map[int64]bool)
+	for _, p := range pairs {
+		for _, id := range []int64{p.MediaID, p.DuplicateID} {
+			if !seen[id] {
+				seen[id] = true
+				order = append(order, id)
+			}
+		}
+		if a, b := find(p.MediaID), find(p.DuplicateID); a != b {
+			parent[b] = a
+		}
+	}
+
+	index := make(map[int64]int)
+	var clusters []duplicateCluster
+	for _, id := range order {
+		root := find(id)
+		i, ok := index[root]
+		if !ok {
+			i = len(clusters)
+			index[root] = i
+			clusters = append(clusters, duplicateCluster{})
+		}
+		clusters[i].ids = append(clusters[i].ids, id)
+	}
+	for _, p := range pairs {
+		c := &clusters[index[find(p.MediaID)]]
+		if int(p.Distance) > c.maxDistance {
+			c.maxDistance = int(p.Distance)
+		}
+	}
+	return clusters
+}
+
+// FindDuplicates reports groups of a user's media that look alike
+func (ms *MediaService) FindDuplicates(ctx context.Context, userID uint, maxDistance int) ([]models.DuplicateGroup, error) {
+	pairs, err := ms.queries.ListDuplicatePairs(ctx, db.ListDuplicatePairsParams{
+		UserID:      int64(userID),
+		MaxDistance: int32(maxDistance),
+		MaxResults:  MaxDuplicatePairs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []models.DuplicateGroup{}
+	for _, cluster := range groupDuplicatePairs(pairs) {
+		group := models.DuplicateGroup{MaxDistance: cluster.maxDistance}
+		for _, id := range cluster.ids {
+			row, err := ms.queries.GetMediaByID(ctx, id)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					continue // Trashed since the pairs were listed
+				}
+				return nil, err
+			}
+			group.Items = append(group.Items, mappers.MediaRowToModel(row))
+		}
+		if len(group.Items) < 2 {
+			continue
+		}
+		// Oldest first, the usual choice to keep
+		sort.SliceStable(group.Items, func(i, j int) bool {
+			return group.Items[i].CreatedAt < group.Items[j].CreatedAt
+		})
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// MergeDuplicates keeps one media item and moves the duplicates into the
+// trash. Album memberships and tags of the duplicates are moved onto the kept
+// item first. All duplicates must belong to the owner of the kept item.
+// It runs in one transaction and returns the kept item.
+func (ms *MediaService) MergeDuplicates(ctx context.Context, keepID uint, duplicateIDs []uint) (*models.Media, error) {
+	if len(duplicateIDs) == 0 {
+		return nil, fmt.Errorf("%w: no duplicates given", ErrInvalidMerge)
+	}
+	if len(duplicateIDs) > MaxMergeSize {
+		return nil, fmt.Errorf("%w: at most %d duplicates per request", ErrInvalidMerge, MaxMergeSize)
+	}
+
+	tx, err := ms.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := ms.queries.WithTx(tx)
+
+	keep, err := qtx.GetMediaByID(ctx, int64(keepID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, err
+	}
+
+	for _, id := range duplicateIDs {
+		if id == keepID {
+			return nil, fmt.Errorf("%w: media %d cannot be merged into itself", ErrInvalidMerge, id)
+		}
+
+		dup, err := qtx.GetMediaByID(ctx, int64(id))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("%w: %d", ErrMediaNotFound, id)
+			}
+			return nil, err
+		}
+		if dup.UserID != keep.UserID {
+			return nil, fmt.Errorf("%w: media %d belongs to another user", ErrForbidden, id)
+		}
+
+		if err := qtx.MoveAlbumMemberships(ctx, db.MoveAlbumMembershipsParams{KeepID: keep.ID, DuplicateID: dup.ID}); err != nil {
+			return nil, err
+		}
+		if err := qtx.CopyMediaTags(ctx, db.CopyMediaTagsParams{KeepID: keep.ID, DuplicateID: dup.ID}); err != nil {
+			return nil, err
+		}
+		if err := qtx.SoftDeleteMedia(ctx, dup.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	media := mappers.MediaRowToModel(keep)
+	return &media, nil
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+)
+
+func TestGroupDuplicatePairs(t *testing.T) {
+	pairs := []db.ListDuplicatePairsRow{
+		{MediaID: 1, DuplicateID: 2, Distance: 0},
+		{MediaID: 5, DuplicateID: 6, Distance: 2},
+		{MediaID: 2, DuplicateID: 3, Distance: 4},
+		{MediaID: 3, DuplicateID: 9, Distance: 7},
+	}
+
+	clusters := groupDuplicatePairs(pairs)
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2", len(clusters))
+	}
+
+	if want := []int64{1, 2, 3, 9}; !reflect.DeepEqual(clusters[0].ids, want) {
+		t.Errorf("first cluster = %v, want %v", clusters[0].ids, want)
+	}
+	if clusters[0].maxDistance != 7 {
+		t.Errorf("first cluster max distance = %d, want 7", clusters[0].maxDistance)
+	}
+
+	if want := []int64{5, 6}; !reflect.DeepEqual(clusters[1].ids, want) {
+		t.Errorf("second cluster = %v, want %v", clusters[1].ids, want)
+	}
+	if clusters[1].maxDistance != 2 {
+		t.Errorf("second cluster max distance = %d, want 2", clusters[1].maxDistance)
+	}
+}
+
+func TestGroupDuplicatePairs_Empty(t *testing.T) {
+	if clusters := groupDuplicatePairs(nil); len(clusters) != 0 {
+		t.Errorf("got %d clusters for no pairs", len(clusters))
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"os"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/placeholder"
+)
+
+// placeholderBatchSize is how many media items are read per indexer query
+const placeholderBatchSize = 100
+
+// IndexPlaceholders computes the BlurHash, dominant color and aspect ratio of
+// every image and video that has thumbnails but no placeholder yet. They are
+// computed from the largest thumbnail, which keeps the source's aspect ratio
+// and already has video keyframes and HEIC files decoded. Items whose
+// thumbnail is not generated yet are skipped and picked up on a later run.
+// It returns the number of items indexed.
+func (ms *MediaService) IndexPlaceholders(ctx context.Context) (int, error) {
+	indexed := 0
+	var afterID int64
+
+	for {
+		rows, err := ms.queries.ListMediaMissingPlaceholder(ctx, db.ListMediaMissingPlaceholderParams{
+			AfterID:    afterID,
+			MaxResults: placeholderBatchSize,
+		})
+		if err != nil {
+			return indexed, err
+		}
+
+		for _, row := range rows {
+			afterID = row.ID
+
+			params, err := ms.placeholderFromThumbnail(row.StoredName)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					log.Printf("Computing placeholder for media %d failed: %v", row.ID, err)
+				}
+				continue
+			}
+
+			params.ID = row.ID
+			if err := ms.queries.SetMediaPlaceholder(ctx, params); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+
+		if len(rows) < placeholderBatchSize {
+			return indexed, nil
+		}
+	}
+}
+
+// placeholderFromThumbnail computes the placeholder of a stored file from its
+// largest thumbnail
+func (ms *MediaService) placeholderFromThumbnail(storedName string) (db.SetMediaPlaceholderParams, error) {
+	largest := ThumbnailSizes[len(ThumbnailSizes)-1]
+	f, err := os.Open(ThumbnailPath(ms.uploadDir, largest, storedName))
+	if err != nil {
+		return db.SetMediaPlaceholderParams{}, err
+	}
+	defer f.Close()
+
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		return db.SetMediaPlaceholderParams{}, err
+	}
+
+	hash := placeholder.BlurHash(img)
+	if hash == "" {
+		return db.SetMediaPlaceholderParams{}, fmt.Errorf("empty thumbnail")
+	}
+	color := placeholder.DominantColor(img)
+	return db.SetMediaPlaceholderParams{
+		Blurhash:      sql.NullString{String: hash, Valid: true},
+		DominantColor: sql.NullString{String: color, Valid: color != ""},
+		AspectRatio:   sql.NullFloat64{Float64: placeholder.AspectRatio(img), Valid: true},
+	}, nil
+}
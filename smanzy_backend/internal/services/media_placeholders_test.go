@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlaceholderFromThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	ms := NewMediaService(nil, nil, dir, DefaultMaxVersions)
+
+	if _, err := ms.placeholderFromThumbnail("1_missing.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist for a missing thumbnail, got %v", err)
+	}
+
+	// smanzy_thumbgen fits a 4:3 photo into 800x600
+	img := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			img.Set(x, y, color.RGBA{30, 140, 60, 255})
+		}
+	}
+	path := ThumbnailPath(dir, "800x600", "1_photo.png")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	params, err := ms.placeholderFromThumbnail("1_photo.png")
+	if err != nil {
+		t.Fatalf("placeholderFromThumbnail: %v", err)
+	}
+	if !params.Blurhash.Valid || len(params.Blurhash.String) != 28 {
+		t.Errorf("unexpected blurhash %+v", params.Blurhash)
+	}
+	if !params.DominantColor.Valid || params.DominantColor.String[0] != '#' || len(params.DominantColor.String) != 7 {
+		t.Errorf("unexpected dominant color %+v", params.DominantColor)
+	}
+	if !params.AspectRatio.Valid || params.AspectRatio.Float64 < 1.33 || params.AspectRatio.Float64 > 1.34 {
+		t.Errorf("unexpected aspect ratio %+v", params.AspectRatio)
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveFiles_DeletesOriginalAndThumbnails(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	storedName := "1_1765789611227708560.mp4"
+	paths := []string{filepath.Join(tmpDir, storedName)}
+	for _, size := range ThumbnailSizes {
+		if err := os.MkdirAll(filepath.Join(tmpDir, size), 0755); err != nil {
+			t.Fatalf("failed to create thumbnail dir: %v", err)
+		}
+		paths = append(paths, filepath.Join(tmpDir, size, "1_1765789611227708560.jpg"))
+	}
+	for _, p := range paths {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", p, err)
+		}
+	}
+
+	// Unrelated file that must survive
+	other := filepath.Join(tmpDir, "2_1765789611227708560.mp4")
+	if err := os.WriteFile(other, []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", other, err)
+	}
+
+	ms := NewMediaService(nil, nil, tmpDir, DefaultMaxVersions)
+	ms.RemoveFiles(storedName)
+
+	for _, p := range paths {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", p)
+		}
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+}
+
+func TestRemoveFiles_IgnoresPathTraversal(t *testing.T) {
+	tmpDir := t.TempDir()
+	uploadDir := filepath.Join(tmpDir, "uploads")
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		t.Fatalf("failed to create upload dir: %v", err)
+	}
+
+	secret := filepath.Join(tmpDir, "secret.txt")
+	if err := os.WriteFile(secret, []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", secret, err)
+	}
+
+	NewMediaService(nil, nil, uploadDir, DefaultMaxVersions).RemoveFiles("../secret.txt")
+
+	if _, err := os.Stat(secret); err != nil {
+		t.Errorf("file outside upload dir was removed: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/exif"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// MaxFilenameLength caps the original file name kept with a media item
+const MaxFilenameLength = 255
+
+// ErrInvalidUpload is returned when a new file fails validation
+var ErrInvalidUpload = errors.New("invalid upload")
+
+// NewMediaFile describes a file being added to a user's library
+type NewMediaFile struct {
+	UserID      uint
+	Filename    string // Original file name, without directories
+	MimeType    string // Detected from the name and contents when empty
+	Visibility  string
+	Description string
+	SourceURL   string // Where the file was fetched from, for URL imports
+}
+
+// ValidFilename reports whether a name can be kept as the original file name
+// of a media item: a single path element of at most MaxFilenameLength bytes
+func ValidFilename(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		filepath.Base(name) == name && len(name) <= MaxFilenameLength
+}
+
+// ValidateNewMedia checks a new file. Uploads and imports go through the same checks.
+func ValidateNewMedia(f NewMediaFile) error {
+	if !ValidFilename(f.Filename) {
+		return fmt.Errorf("%w: invalid file name %q", ErrInvalidUpload, f.Filename)
+	}
+	if !models.IsValidVisibility(f.Visibility) {
+		return fmt.Errorf("%w: invalid visibility %q", ErrInvalidUpload, f.Visibility)
+	}
+	return nil
+}
+
+// NewStoredName returns a unique name on disk for a user's file, keeping its extension
+func NewStoredName(userID uint, filename string) string {
+	return fmt.Sprintf("%d_%d%s", userID, time.Now().UnixNano(), filepath.Ext(filename))
+}
+
+// DetectMimeType guesses the MIME type of a file from its extension, falling
+// back to sniffing the first bytes of its contents
+func DetectMimeType(filename string, head []byte) string {
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); byExt != "" {
+		if mediaType, _, err := mime.ParseMediaType(byExt); err == nil {
+			return mediaType
+		}
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return sniffed
+}
+
+// DetectFileMimeType is DetectMimeType for a file already on disk, read from
+// path; filename is its original name
+func DetectFileMimeType(path, filename string) string {
+	var head []byte
+	if f, err := os.Open(path); err == nil {
+		head, _ = io.ReadAll(io.LimitReader(f, 512))
+		f.Close()
+	}
+	return DetectMimeType(filename, head)
+}
+
+// MediaTypeFor returns the general category of a MIME type, as stored with
+// a media item: image, video or audio, and file for anything else
+func MediaTypeFor(mimeType string) string {
+	if kind, _, ok := strings.Cut(mimeType, "/"); ok {
+		switch kind {
+		case "image", "video", "audio":
+			return kind
+		}
+	}
+	return "file"
+}
+
+// HashFile returns the hex-encoded SHA-256 of a file's contents
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CreateMedia stores a new file under a unique name and records it for the
+// user, along with its SHA-256 so imports can skip files already in the
+// library. The file is written to quarantine, where it stays until the scan
+// service releases it to the upload directory. It is removed again if the
+// record cannot be created.
+func (ms *MediaService) CreateMedia(ctx context.Context, f NewMediaFile, r io.Reader) (db.CreateMediaRow, error) {
+	if err := ValidateNewMedia(f); err != nil {
+		return db.CreateMediaRow{}, err
+	}
+
+	br := bufio.NewReader(r)
+	if f.MimeType == "" {
+		head, _ := br.Peek(512)
+		f.MimeType = DetectMimeType(f.Filename, head)
+	}
+
+	if err := os.MkdirAll(QuarantineDir(ms.uploadDir), 0755); err != nil {
+		return db.CreateMediaRow{}, err
+	}
+	storedName := NewStoredName(f.UserID, f.Filename)
+	dst := QuarantinePath(ms.uploadDir, storedName)
+	size, hash, err := writeHashed(dst, br)
+	if err != nil {
+		return db.CreateMediaRow{}, err
+	}
+
+	row, err := ms.queries.CreateMedia(ctx, db.CreateMediaParams{
+		Filename:    f.Filename,
+		StoredName:  storedName,
+		Type:        sql.NullString{String: MediaTypeFor(f.MimeType), Valid: true},
+		MimeType:    sql.NullString{String: f.MimeType, Valid: f.MimeType != ""},
+		Size:        size,
+		UserID:      int64(f.UserID),
+		Visibility:  f.Visibility,
+		Description: sql.NullString{String: f.Description, Valid: f.Description != ""},
+		ContentHash: sql.NullString{String: hash, Valid: true},
+		SourceUrl:   sql.NullString{String: f.SourceURL, Valid: f.SourceURL != ""},
+		CapturedAt:  captureTime(dst),
+	})
+	if err != nil {
+		_ = os.Remove(dst)
+		return db.CreateMediaRow{}, err
+	}
+	ms.queueScan()
+	return row, nil
+}
+
+// captureTime returns when the photo at path was taken, if its EXIF records it
+func captureTime(path string) sql.NullInt64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return sql.NullInt64{}
+	}
+	defer f.Close()
+
+	t, ok := exif.DateTaken(f)
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: ok}
+}
+
+// writeHashed copies r to dst through a temporary file and returns the number
+// of bytes written and their hex-encoded SHA-256
+func writeHashed(dst string, r io.Reader) (int64, string, error) {
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp)
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, h), r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
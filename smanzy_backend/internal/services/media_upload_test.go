@@ -0,0 +1,94 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+func TestValidateNewMedia(t *testing.T) {
+	valid := NewMediaFile{UserID: 1, Filename: "photo.jpg", Visibility: models.VisibilityInherit}
+	if err := ValidateNewMedia(valid); err != nil {
+		t.Fatalf("valid file rejected: %v", err)
+	}
+
+	tests := map[string]NewMediaFile{
+		"empty name":        {Filename: "", Visibility: models.VisibilityPublic},
+		"directory in name": {Filename: "../photo.jpg", Visibility: models.VisibilityPublic},
+		"dot dot":           {Filename: "..", Visibility: models.VisibilityPublic},
+		"name too long":     {Filename: strings.Repeat("a", MaxFilenameLength) + ".jpg", Visibility: models.VisibilityPublic},
+		"bad visibility":    {Filename: "photo.jpg", Visibility: "everyone"},
+	}
+	for name, f := range tests {
+		if err := ValidateNewMedia(f); !errors.Is(err, ErrInvalidUpload) {
+			t.Errorf("%s: expected ErrInvalidUpload, got %v", name, err)
+		}
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	if got := DetectMimeType("Photo.JPG", nil); got != "image/jpeg" {
+		t.Errorf("by extension: got %q", got)
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if got := DetectMimeType("scan", png); got != "image/png" {
+		t.Errorf("by contents: got %q", got)
+	}
+	if got := DetectMimeType("notes", []byte("plain text")); got != "text/plain" {
+		t.Errorf("text without extension: got %q", got)
+	}
+}
+
+func TestDetectFileMimeType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1_1")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := DetectFileMimeType(path, "scan"); got != "image/png" {
+		t.Errorf("got %q, want image/png", got)
+	}
+}
+
+func TestMediaTypeFor(t *testing.T) {
+	tests := map[string]string{
+		"image/jpeg":      "image",
+		"video/mp4":       "video",
+		"audio/mpeg":      "audio",
+		"application/pdf": "file",
+		"":                "file",
+	}
+	for mimeType, want := range tests {
+		if got := MediaTypeFor(mimeType); got != want {
+			t.Errorf("MediaTypeFor(%q) = %q, want %q", mimeType, got, want)
+		}
+	}
+}
+
+func TestWriteHashed(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "1_1.txt")
+	content := "hello import"
+
+	size, hash, err := writeHashed(dst, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("writeHashed: %v", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if size != int64(len(content)) || hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("got size %d hash %s", size, hash)
+	}
+	if _, err := os.Stat(dst + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	fileHash, err := HashFile(dst)
+	if err != nil || fileHash != hash {
+		t.Errorf("HashFile = %s, %v; want %s", fileHash, err, hash)
+	}
+}
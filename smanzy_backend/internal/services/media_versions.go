@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// ReplacementFile is a new file for a media item, already saved in quarantine
+type ReplacementFile struct {
+	StoredName string
+	MimeType   string
+	Size       int64
+}
+
+// ReplaceFile points a media item at a new stored file that is already in
+// quarantine, waiting for the malware scan. The previous file is archived as a version in the same transaction, and
+// versions beyond the retention limit are pruned once the transaction commits.
+// If anything fails the media item still points at its previous file.
+func (ms *MediaService) ReplaceFile(ctx context.Context, mediaID uint, storedName, mimeType string, size int64) (*models.Media, error) {
+	tx, err := ms.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	updated, done, err := ms.replaceFile(ctx, ms.queries.WithTx(tx), mediaID, ReplacementFile{
+		StoredName: storedName,
+		MimeType:   mimeType,
+		Size:       size,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	done()
+
+	media := mappers.MediaRowToModel(updated)
+	return &media, nil
+}
+
+// replaceFile does the work of ReplaceFile inside the caller's transaction.
+// The returned function cleans up the files the database no longer
+// references; call it once the transaction has committed.
+func (ms *MediaService) replaceFile(ctx context.Context, qtx *db.Queries, mediaID uint, file ReplacementFile) (db.ReplaceMediaFileRow, func(), error) {
+	current, err := qtx.GetMediaByID(ctx, int64(mediaID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.ReplaceMediaFileRow{}, nil, ErrMediaNotFound
+		}
+		return db.ReplaceMediaFileRow{}, nil, err
+	}
+
+	if _, err := qtx.CreateMediaVersion(ctx, db.CreateMediaVersionParams{
+		MediaID:    current.ID,
+		Filename:   current.Filename,
+		StoredName: current.StoredName,
+		MimeType:   current.MimeType,
+		Size:       current.Size,
+	}); err != nil {
+		return db.ReplaceMediaFileRow{}, nil, fmt.Errorf("archive current version: %w", err)
+	}
+
+	updated, err := qtx.ReplaceMediaFile(ctx, db.ReplaceMediaFileParams{
+		ID:         current.ID,
+		StoredName: file.StoredName,
+		MimeType:   sql.NullString{String: file.MimeType, Valid: true},
+		Size:       file.Size,
+		Type:       sql.NullString{String: MediaTypeFor(file.MimeType), Valid: true},
+	})
+	if err != nil {
+		return db.ReplaceMediaFileRow{}, nil, fmt.Errorf("replace media file: %w", err)
+	}
+
+	pruned, err := qtx.PruneMediaVersions(ctx, db.PruneMediaVersionsParams{
+		MediaID: current.ID,
+		Keep:    int32(ms.maxVersions),
+	})
+	if err != nil {
+		return db.ReplaceMediaFileRow{}, nil, fmt.Errorf("prune versions: %w", err)
+	}
+
+	done := func() {
+		// Files are only removed once the database no longer references them
+		for _, name := range pruned {
+			ms.RemoveFiles(name)
+		}
+		// Earlier versions are not streamed; the new file is queued for transcoding
+		_ = os.RemoveAll(HLSDir(ms.uploadDir, current.StoredName))
+		ms.queueScan()
+	}
+	return updated, done, nil
+}
+
+// ListVersions returns the earlier versions of a media item, newest first
+func (ms *MediaService) ListVersions(ctx context.Context, mediaID uint) ([]models.MediaVersion, error) {
+	rows, err := ms.queries.ListMediaVersions(ctx, int64(mediaID))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]models.MediaVersion, len(rows))
+	for i, row := range rows {
+		versions[i] = mappers.MediaVersionToModel(row)
+	}
+	return versions, nil
+}
+
+// GetVersion returns one earlier version of a media item
+func (ms *MediaService) GetVersion(ctx context.Context, mediaID uint, version int) (*models.MediaVersion, error) {
+	row, err := ms.queries.GetMediaVersion(ctx, db.GetMediaVersionParams{
+		MediaID: int64(mediaID),
+		Version: int32(version),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, err
+	}
+
+	v := mappers.MediaVersionToModel(row)
+	return &v, nil
+}
+
+// RevertToVersion makes an earlier version the current file again.
+// The version's file is copied into quarantine like a new upload, so the
+// history stays intact and the current file becomes a version of its own.
+func (ms *MediaService) RevertToVersion(ctx context.Context, mediaID uint, version int) (*models.Media, error) {
+	current, err := ms.queries.GetMediaByID(ctx, int64(mediaID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, err
+	}
+
+	v, err := ms.GetVersion(ctx, mediaID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	storedName := fmt.Sprintf("%d_%d%s", current.UserID, time.Now().UnixNano(), filepath.Ext(v.StoredName))
+	if err := ms.copyToQuarantine(v.StoredName, storedName); err != nil {
+		return nil, err
+	}
+
+	media, err := ms.ReplaceFile(ctx, mediaID, storedName, v.MimeType, v.Size)
+	if err != nil {
+		ms.RemoveFiles(storedName)
+		return nil, err
+	}
+	return media, nil
+}
+
+// copyToQuarantine copies a stored file from the upload directory into quarantine
+func (ms *MediaService) copyToQuarantine(src, dst string) error {
+	in, err := os.Open(filepath.Join(ms.uploadDir, src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(QuarantineDir(ms.uploadDir), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(QuarantinePath(ms.uploadDir, dst))
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(out.Name())
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/ristep/smanzy_backend/internal/watermark"
+)
+
+// Render output formats
+const (
+	RenderFormatAuto = "auto" // Negotiated from the Accept header
+	RenderFormatJPEG = "jpeg"
+	RenderFormatPNG  = "png"
+	RenderFormatWebP = "webp"
+	RenderFormatAVIF = "avif"
+)
+
+// Render fit modes
+const (
+	RenderFitContain = "contain" // Scale down to fit inside the box, keeping the aspect ratio
+	RenderFitCover   = "cover"   // Fill the box, cropping the overflow around the center
+)
+
+const (
+	// DefaultRenderPresets are the sizes that may be rendered unless RENDER_PRESETS
+	// says otherwise. A 0 leaves that side free, keeping the aspect ratio.
+	DefaultRenderPresets = "160x100,320x200,640x400,800x600,1280x720,1920x1080,1280x0,0x720"
+	// DefaultRenderQuality is used when the request does not ask for a quality
+	DefaultRenderQuality = 80
+	// DefaultRenderCacheSize is the default render cache budget in bytes
+	DefaultRenderCacheSize = 1 << 30 // 1 GiB
+)
+
+// RenderQualities are the encoder qualities a request may ask for
+var RenderQualities = []int{50, 65, 80, 90}
+
+// renderContentTypes maps output formats to their MIME type
+var renderContentTypes = map[string]string{
+	RenderFormatJPEG: "image/jpeg",
+	RenderFormatPNG:  "image/png",
+	RenderFormatWebP: "image/webp",
+	RenderFormatAVIF: "image/avif",
+}
+
+// renderFFmpegEncoders are the ffmpeg encoders used for formats the Go image
+// libraries cannot write
+var renderFFmpegEncoders = map[string]string{
+	RenderFormatWebP: "libwebp",
+	RenderFormatAVIF: "libaom-av1",
+}
+
+var (
+	// ErrInvalidRender is returned when a render request is not allowed
+	ErrInvalidRender = errors.New("invalid render request")
+	// ErrRenderUnsupported is returned when a media item cannot be rendered
+	ErrRenderUnsupported = errors.New("media cannot be rendered")
+)
+
+// RenderPreset is an allowed output size. A zero side is left free.
+type RenderPreset struct {
+	Width  int
+	Height int
+}
+
+// ParseRenderPresets parses a comma-separated list of WxH sizes, e.g. "320x200,1280x0"
+func ParseRenderPresets(s string) ([]RenderPreset, error) {
+	var presets []RenderPreset
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, h, ok := strings.Cut(part, "x")
+		width, errW := strconv.Atoi(w)
+		height, errH := strconv.Atoi(h)
+		if !ok || errW != nil || errH != nil || width < 0 || height < 0 || width+height == 0 ||
+			width > 8192 || height > 8192 {
+			return nil, fmt.Errorf("invalid render preset %q", part)
+		}
+		presets = append(presets, RenderPreset{Width: width, Height: height})
+	}
+	if len(presets) == 0 {
+		return nil, errors.New("no render presets")
+	}
+	return presets, nil
+}
+
+// RenderRequest describes a derivative of a media item
+type RenderRequest struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string // A concrete format; negotiate RenderFormatAuto first
+	Quality int
+
+	// Watermark is stamped on the derivative when set. WatermarkVersion
+	// changes with the watermark settings so stale renders are not reused.
+	Watermark        *watermark.Mark
+	WatermarkVersion int64
+}
+
+// cacheName is the file name of the rendered derivative in the cache
+func (r RenderRequest) cacheName(storedName string) string {
+	quality := r.Quality
+	if r.Format == RenderFormatPNG {
+		quality = 0 // Lossless, the quality has no effect
+	}
+	key := fmt.Sprintf("%s|%d|%d|%s|%d", storedName, r.Width, r.Height, r.Fit, quality)
+	if r.Watermark != nil {
+		key += fmt.Sprintf("|wm%d", r.WatermarkVersion)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16]) + "." + r.Format
+}
+
+// RenderConfig configures the render service
+type RenderConfig struct {
+	CacheDir   string
+	CacheSize  int64 // Cache budget in bytes
+	Presets    []RenderPreset
+	FFmpegPath string // Used for WebP and AVIF output; empty disables them
+}
+
+// RenderService produces resized derivatives of media files on demand and
+// caches them on disk
+type RenderService struct {
+	uploadDir  string
+	presets    []RenderPreset
+	ffmpegPath string
+	formats    map[string]bool // Output formats this server can produce
+	cache      *RenderCache
+	slots      chan struct{} // Limits concurrent renders to the number of CPUs
+}
+
+// NewRenderService creates a render service. WebP and AVIF output are enabled
+// when ffmpeg is available with the matching encoder.
+func NewRenderService(uploadDir string, cfg RenderConfig) (*RenderService, error) {
+	cache, err := NewRenderCache(cfg.CacheDir, cfg.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &RenderService{
+		uploadDir:  uploadDir,
+		presets:    cfg.Presets,
+		ffmpegPath: cfg.FFmpegPath,
+		formats:    map[string]bool{RenderFormatJPEG: true, RenderFormatPNG: true},
+		cache:      cache,
+		slots:      make(chan struct{}, runtime.NumCPU()),
+	}
+
+	if cfg.FFmpegPath != "" {
+		out, err := exec.Command(cfg.FFmpegPath, "-hide_banner", "-encoders").Output()
+		if err != nil {
+			log.Printf("Render: ffmpeg not available (%v), WebP and AVIF output disabled", err)
+		} else {
+			available := parseFFmpegEncoders(out)
+			for format, encoder := range renderFFmpegEncoders {
+				rs.formats[format] = available[encoder]
+			}
+		}
+	}
+	return rs, nil
+}
+
+// parseFFmpegEncoders returns the encoder names listed by `ffmpeg -encoders`
+func parseFFmpegEncoders(out []byte) map[string]bool {
+	encoders := make(map[string]bool)
+	listing := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// The list starts after the legend, which ends with a "------" line
+		if strings.HasPrefix(line, "---") {
+			listing = true
+			continue
+		}
+		if fields := strings.Fields(line); listing && len(fields) >= 2 {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// SupportsFormat reports whether the server can produce a format
+func (rs *RenderService) SupportsFormat(format string) bool {
+	return rs.formats[format]
+}
+
+// Validate checks a render request against the allowed presets, fit modes and qualities
+func (rs *RenderService) Validate(r RenderRequest) error {
+	allowed := false
+	for _, p := range rs.presets {
+		if p.Width == r.Width && p.Height == r.Height {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: size %dx%d is not an allowed preset", ErrInvalidRender, r.Width, r.Height)
+	}
+
+	switch r.Fit {
+	case RenderFitContain:
+	case RenderFitCover:
+		if r.Width == 0 || r.Height == 0 {
+			return fmt.Errorf("%w: fit=cover needs both w and h", ErrInvalidRender)
+		}
+	default:
+		return fmt.Errorf("%w: unknown fit %q", ErrInvalidRender, r.Fit)
+	}
+
+	qualityAllowed := false
+	for _, q := range RenderQualities {
+		if q == r.Quality {
+			qualityAllowed = true
+			break
+		}
+	}
+	if !qualityAllowed {
+		return fmt.Errorf("%w: quality must be one of %v", ErrInvalidRender, RenderQualities)
+	}
+
+	if !rs.formats[r.Format] {
+		return fmt.Errorf("%w: format %q is not available", ErrInvalidRender, r.Format)
+	}
+	return nil
+}
+
+// NegotiateFormat picks the output format. An explicit format is returned as
+// is; for RenderFormatAuto the best format listed in the Accept header is
+// chosen (AVIF, then WebP), falling back to PNG for sources that may have
+// transparency and JPEG otherwise.
+func (rs *RenderService) NegotiateFormat(requested, accept, sourceMime string) string {
+	if requested != "" && requested != RenderFormatAuto {
+		if requested == "jpg" {
+			return RenderFormatJPEG
+		}
+		return requested
+	}
+
+	accepted := acceptedTypes(accept)
+	for _, format := range []string{RenderFormatAVIF, RenderFormatWebP} {
+		if rs.formats[format] && accepted[renderContentTypes[format]] {
+			return format
+		}
+	}
+
+	switch sourceMime {
+	case "image/png", "image/gif", "image/webp":
+		return RenderFormatPNG
+	}
+	return RenderFormatJPEG
+}
+
+// acceptedTypes returns the media types listed in an Accept header, skipping
+// those explicitly refused with q=0
+func acceptedTypes(accept string) map[string]bool {
+	types := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if mediaType == "" {
+			continue
+		}
+
+		refused := false
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					refused = true
+				}
+			}
+		}
+		if !refused {
+			types[mediaType] = true
+		}
+	}
+	return types
+}
+
+// ContentType returns the MIME type of a render output format
+func ContentType(format string) string {
+	return renderContentTypes[format]
+}
+
+// Render returns the path of the rendered derivative, rendering it into the
+// cache if needed. The request must have been validated.
+func (rs *RenderService) Render(ctx context.Context, storedName string, r RenderRequest) (string, error) {
+	name := r.cacheName(storedName)
+	if rs.cache.Get(name) {
+		return rs.cache.Path(name), nil
+	}
+
+	select {
+	case rs.slots <- struct{}{}:
+		defer func() { <-rs.slots }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	// Another request may have rendered it while this one waited
+	if rs.cache.Get(name) {
+		return rs.cache.Path(name), nil
+	}
+
+	src, err := rs.openSource(storedName)
+	if err != nil {
+		return "", err
+	}
+
+	img := resizeForRender(src, r)
+	if r.Watermark != nil {
+		img = watermark.Apply(img, *r.Watermark)
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(rs.cache.Path(name)), "render-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := rs.encode(ctx, tmp, img, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, rs.cache.Path(name)); err != nil {
+		return "", err
+	}
+	rs.cache.Put(name, info.Size())
+
+	return rs.cache.Path(name), nil
+}
+
+// openSource decodes the original file. Files Go cannot decode (videos,
+// HEIC) fall back to the largest thumbnail made by smanzy_thumbgen.
+func (rs *RenderService) openSource(storedName string) (image.Image, error) {
+	if storedName == "" || filepath.Base(storedName) != storedName {
+		return nil, ErrRenderUnsupported
+	}
+
+	img, err := imaging.Open(filepath.Join(rs.uploadDir, storedName), imaging.AutoOrientation(true))
+	if err == nil {
+		return img, nil
+	}
+
+	largest := ThumbnailSizes[len(ThumbnailSizes)-1]
+	img, err = imaging.Open(ThumbnailPath(rs.uploadDir, largest, storedName))
+	if err != nil {
+		return nil, ErrRenderUnsupported
+	}
+	return img, nil
+}
+
+// resizeForRender scales the image to the requested box. Images are never
+// enlarged when fitting inside the box.
+func resizeForRender(img image.Image, r RenderRequest) image.Image {
+	if r.Fit == RenderFitCover {
+		return imaging.Fill(img, r.Width, r.Height, imaging.Center, imaging.Lanczos)
+	}
+
+	b := img.Bounds()
+	switch {
+	case r.Height == 0:
+		if b.Dx() <= r.Width {
+			return img
+		}
+		return imaging.Resize(img, r.Width, 0, imaging.Lanczos)
+	case r.Width == 0:
+		if b.Dy() <= r.Height {
+			return img
+		}
+		return imaging.Resize(img, 0, r.Height, imaging.Lanczos)
+	default:
+		return imaging.Fit(img, r.Width, r.Height, imaging.Lanczos)
+	}
+}
+
+// encode writes the image in the requested format
+func (rs *RenderService) encode(ctx context.Context, f *os.File, img image.Image, r RenderRequest) error {
+	switch r.Format {
+	case RenderFormatJPEG:
+		return imaging.Encode(f, img, imaging.JPEG, imaging.JPEGQuality(r.Quality))
+	case RenderFormatPNG:
+		return imaging.Encode(f, img, imaging.PNG)
+	}
+	return rs.encodeFFmpeg(ctx, f, img, r)
+}
+
+// encodeFFmpeg pipes the image into ffmpeg as PNG and writes WebP or AVIF
+func (rs *RenderService) encodeFFmpeg(ctx context.Context, f *os.File, img image.Image, r RenderRequest) error {
+	var input bytes.Buffer
+	if err := imaging.Encode(&input, img, imaging.PNG); err != nil {
+		return err
+	}
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-f", "png_pipe", "-i", "pipe:0"}
+	switch r.Format {
+	case RenderFormatWebP:
+		args = append(args, "-c:v", "libwebp", "-quality", strconv.Itoa(r.Quality), "-f", "webp")
+	case RenderFormatAVIF:
+		// libaom uses a 0-63 CRF scale where lower is better
+		crf := 63 - r.Quality*63/100
+		args = append(args, "-c:v", "libaom-av1", "-still-picture", "1", "-crf", strconv.Itoa(crf),
+			"-pix_fmt", "yuv420p", "-f", "avif")
+	default:
+		return fmt.Errorf("%w: format %q is not available", ErrInvalidRender, r.Format)
+	}
+	// AVIF needs a seekable output, so ffmpeg writes the file itself
+	args = append(args, f.Name())
+
+	cmd := exec.CommandContext(ctx, rs.ffmpegPath, args...)
+	cmd.Stdin = &input
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg %s encode failed: %v: %s", r.Format, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
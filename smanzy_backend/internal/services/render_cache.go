@@ -0,0 +1,138 @@
+package services
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RenderCache is a size-bounded, least-recently-used cache of rendered files
+// on disk. Access times are kept in the file modification times, so the LRU
+// order survives restarts.
+type RenderCache struct {
+	dir    string
+	budget int64 // Maximum total size in bytes
+
+	mu      sync.Mutex
+	order   *list.List               // Front is the most recently used
+	entries map[string]*list.Element // File name -> element holding *renderCacheEntry
+	size    int64
+}
+
+type renderCacheEntry struct {
+	name string
+	size int64
+}
+
+// NewRenderCache opens the cache directory, creating it if needed, and loads
+// the files already in it. Files over the budget are evicted right away.
+func NewRenderCache(dir string, budget int64) (*RenderCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	rc := &RenderCache{
+		dir:     dir,
+		budget:  budget,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type existing struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var found []existing
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) == ".tmp" {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, existing{name: f.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+
+	// Oldest first, so the most recently used file ends up at the front
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.Before(found[j].modTime) })
+	for _, f := range found {
+		rc.entries[f.name] = rc.order.PushFront(&renderCacheEntry{name: f.name, size: f.size})
+		rc.size += f.size
+	}
+
+	rc.mu.Lock()
+	rc.evictLocked()
+	rc.mu.Unlock()
+
+	return rc, nil
+}
+
+// Path returns where a cached file with the given name is stored
+func (rc *RenderCache) Path(name string) string {
+	return filepath.Join(rc.dir, name)
+}
+
+// Get reports whether a file is cached and marks it as recently used
+func (rc *RenderCache) Get(name string) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	el, ok := rc.entries[name]
+	if !ok {
+		return false
+	}
+	rc.order.MoveToFront(el)
+
+	now := time.Now()
+	_ = os.Chtimes(rc.Path(name), now, now)
+	return true
+}
+
+// Put records a file that was written to Path(name) and evicts the least
+// recently used files until the cache fits its budget again
+func (rc *RenderCache) Put(name string, size int64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if el, ok := rc.entries[name]; ok {
+		entry := el.Value.(*renderCacheEntry)
+		rc.size += size - entry.size
+		entry.size = size
+		rc.order.MoveToFront(el)
+	} else {
+		rc.entries[name] = rc.order.PushFront(&renderCacheEntry{name: name, size: size})
+		rc.size += size
+	}
+	rc.evictLocked()
+}
+
+// Size returns the total size of the cached files in bytes
+func (rc *RenderCache) Size() int64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.size
+}
+
+// evictLocked removes least recently used files while the cache is over budget.
+// The most recent file is always kept, even if it alone exceeds the budget.
+func (rc *RenderCache) evictLocked() {
+	for rc.size > rc.budget && rc.order.Len() > 1 {
+		el := rc.order.Back()
+		entry := el.Value.(*renderCacheEntry)
+
+		rc.order.Remove(el)
+		delete(rc.entries, entry.name)
+		rc.size -= entry.size
+		_ = os.Remove(rc.Path(entry.name))
+	}
+}
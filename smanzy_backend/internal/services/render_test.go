@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/ristep/smanzy_backend/internal/watermark"
+)
+
+func newTestRenderService(t *testing.T) *RenderService {
+	t.Helper()
+	presets, err := ParseRenderPresets("320x200,1280x0")
+	if err != nil {
+		t.Fatalf("ParseRenderPresets: %v", err)
+	}
+	rs, err := NewRenderService(t.TempDir(), RenderConfig{
+		CacheDir:  t.TempDir(),
+		CacheSize: DefaultRenderCacheSize,
+		Presets:   presets,
+	})
+	if err != nil {
+		t.Fatalf("NewRenderService: %v", err)
+	}
+	return rs
+}
+
+func TestParseRenderPresets(t *testing.T) {
+	presets, err := ParseRenderPresets(" 320x200, 1280x0 ,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(presets) != 2 || presets[1] != (RenderPreset{Width: 1280}) {
+		t.Errorf("got %v", presets)
+	}
+
+	for _, bad := range []string{"", "320", "0x0", "axb", "-1x10", "10000x10"} {
+		if _, err := ParseRenderPresets(bad); err == nil {
+			t.Errorf("ParseRenderPresets(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestRenderService_Validate(t *testing.T) {
+	rs := newTestRenderService(t)
+
+	tests := []struct {
+		name  string
+		req   RenderRequest
+		valid bool
+	}{
+		{"preset", RenderRequest{Width: 320, Height: 200, Fit: RenderFitContain, Format: RenderFormatJPEG, Quality: 80}, true},
+		{"width only", RenderRequest{Width: 1280, Height: 0, Fit: RenderFitContain, Format: RenderFormatPNG, Quality: 80}, true},
+		{"not a preset", RenderRequest{Width: 321, Height: 200, Fit: RenderFitContain, Format: RenderFormatJPEG, Quality: 80}, false},
+		{"cover needs both sides", RenderRequest{Width: 1280, Height: 0, Fit: RenderFitCover, Format: RenderFormatJPEG, Quality: 80}, false},
+		{"unknown fit", RenderRequest{Width: 320, Height: 200, Fit: "stretch", Format: RenderFormatJPEG, Quality: 80}, false},
+		{"quality", RenderRequest{Width: 320, Height: 200, Fit: RenderFitContain, Format: RenderFormatJPEG, Quality: 81}, false},
+		{"unavailable format", RenderRequest{Width: 320, Height: 200, Fit: RenderFitContain, Format: "gif", Quality: 80}, false},
+	}
+
+	for _, tt := range tests {
+		err := rs.Validate(tt.req)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidRender) {
+			t.Errorf("%s: expected ErrInvalidRender, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestRenderService_NegotiateFormat(t *testing.T) {
+	rs := newTestRenderService(t)
+	rs.formats[RenderFormatWebP] = true
+
+	tests := []struct {
+		requested, accept, mime, want string
+	}{
+		{"png", "image/webp", "image/jpeg", RenderFormatPNG},
+		{"jpg", "", "image/png", RenderFormatJPEG},
+		{"", "image/avif,image/webp,*/*", "image/jpeg", RenderFormatWebP}, // AVIF not available
+		{"auto", "image/webp;q=0, image/*", "image/jpeg", RenderFormatJPEG},
+		{"", "*/*", "image/png", RenderFormatPNG},
+		{"", "", "video/mp4", RenderFormatJPEG},
+	}
+
+	for _, tt := range tests {
+		if got := rs.NegotiateFormat(tt.requested, tt.accept, tt.mime); got != tt.want {
+			t.Errorf("NegotiateFormat(%q, %q, %q) = %q, want %q", tt.requested, tt.accept, tt.mime, got, tt.want)
+		}
+	}
+}
+
+func TestParseFFmpegEncoders(t *testing.T) {
+	out := []byte(`Encoders:
+ V..... = Video
+ ------
+ V....D libwebp_anim         libwebp WebP image (codec webp)
+ V....D libwebp              libwebp WebP image (codec webp)
+ A....D aac                  AAC (Advanced Audio Coding)
+`)
+	encoders := parseFFmpegEncoders(out)
+	if !encoders["libwebp"] || !encoders["aac"] {
+		t.Errorf("missing encoders: %v", encoders)
+	}
+	if encoders["libaom-av1"] || encoders["Video"] {
+		t.Errorf("unexpected encoders: %v", encoders)
+	}
+}
+
+func TestRenderService_Render(t *testing.T) {
+	rs := newTestRenderService(t)
+
+	src := imaging.New(800, 400, color.NRGBA{200, 100, 50, 255})
+	if err := imaging.Save(src, filepath.Join(rs.uploadDir, "photo.png")); err != nil {
+		t.Fatalf("save source: %v", err)
+	}
+
+	req := RenderRequest{Width: 320, Height: 200, Fit: RenderFitCover, Format: RenderFormatJPEG, Quality: 80}
+	path, err := rs.Render(context.Background(), "photo.png", req)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	img, err := imaging.Open(path)
+	if err != nil {
+		t.Fatalf("open rendered file: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(320, 200) {
+		t.Errorf("rendered size = %v, want 320x200", got)
+	}
+
+	// Served from the cache the second time
+	again, err := rs.Render(context.Background(), "photo.png", req)
+	if err != nil || again != path {
+		t.Errorf("second Render = %q, %v; want cached %q", again, err, path)
+	}
+
+	if _, err := rs.Render(context.Background(), "missing.png", req); !errors.Is(err, ErrRenderUnsupported) {
+		t.Errorf("missing source: expected ErrRenderUnsupported, got %v", err)
+	}
+
+	// A watermarked render is cached apart from the clean one, per settings version
+	req.Watermark = &watermark.Mark{Text: "© Me", Position: watermark.Center, Opacity: 1, Scale: 0.5}
+	req.WatermarkVersion = 1
+	marked, err := rs.Render(context.Background(), "photo.png", req)
+	if err != nil || marked == path {
+		t.Fatalf("watermarked Render = %q, %v; want a new file", marked, err)
+	}
+	req.WatermarkVersion = 2
+	if again, err := rs.Render(context.Background(), "photo.png", req); err != nil || again == marked {
+		t.Errorf("new watermark version reused %q (%v)", again, err)
+	}
+}
+
+func TestResizeForRender_DoesNotEnlarge(t *testing.T) {
+	src := imaging.New(200, 100, color.NRGBA{0, 0, 0, 255})
+	img := resizeForRender(src, RenderRequest{Width: 1280, Fit: RenderFitContain})
+	if got := img.Bounds().Size(); got != image.Pt(200, 100) {
+		t.Errorf("size = %v, want 200x100", got)
+	}
+}
+
+func TestRenderCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	rc, err := NewRenderCache(dir, 25)
+	if err != nil {
+		t.Fatalf("NewRenderCache: %v", err)
+	}
+
+	put := func(name string) {
+		if err := os.WriteFile(rc.Path(name), make([]byte, 10), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		rc.Put(name, 10)
+	}
+
+	put("a.jpeg")
+	put("b.jpeg")
+	rc.Get("a.jpeg") // a is now more recent than b
+	put("c.jpeg")    // over budget: b goes
+
+	if rc.Get("b.jpeg") {
+		t.Error("b.jpeg should have been evicted")
+	}
+	if _, err := os.Stat(rc.Path("b.jpeg")); !os.IsNotExist(err) {
+		t.Error("b.jpeg should have been removed from disk")
+	}
+	if !rc.Get("a.jpeg") || !rc.Get("c.jpeg") {
+		t.Error("a.jpeg and c.jpeg should still be cached")
+	}
+	if rc.Size() != 20 {
+		t.Errorf("size = %d, want 20", rc.Size())
+	}
+
+	// A new cache over the same directory picks the files up again
+	reopened, err := NewRenderCache(dir, 25)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Size() != 20 {
+		t.Errorf("reopened size = %d, want 20", reopened.Size())
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/scanner"
+)
+
+// Scan statuses stored on media records
+const (
+	ScanPending  = "pending"  // In quarantine, waiting for the scanner
+	ScanScanning = "scanning" // Being scanned
+	ScanClean    = "clean"    // Released to the upload directory
+	ScanInfected = "infected" // Malware found; the file was removed and the media trashed
+	ScanFailed   = "failed"   // The quarantined file could not be read
+)
+
+// staleScanAfter is how long a file may stay in scanning before it is taken
+// to be left behind by a stopped server. It is well above CLAMD_TIMEOUT.
+const staleScanAfter = 30 * time.Minute
+
+var (
+	// ErrNoScanJob is returned when no file is waiting in quarantine
+	ErrNoScanJob = errors.New("no scan job")
+	// ErrMediaInfected is returned when restoring media rejected by the scanner
+	ErrMediaInfected = errors.New("media was rejected by the malware scan")
+)
+
+// QuarantineDir returns the directory where new files wait for the malware
+// scan. smanzy_thumbgen only watches the top of the upload directory, so it
+// does not see them until they are released.
+func QuarantineDir(uploadDir string) string {
+	return filepath.Join(uploadDir, "quarantine")
+}
+
+// QuarantinePath returns the path of a stored file while it is in quarantine
+func QuarantinePath(uploadDir, storedName string) string {
+	return filepath.Join(QuarantineDir(uploadDir), storedName)
+}
+
+// ScanService scans quarantined files in the background, releasing clean
+// files to the upload directory and removing infected ones
+type ScanService struct {
+	queries      *db.Queries
+	mediaService *MediaService
+	scanner      scanner.Scanner
+}
+
+// NewScanService creates a new scan service
+func NewScanService(queries *db.Queries, mediaService *MediaService, s scanner.Scanner) *ScanService {
+	return &ScanService{
+		queries:      queries,
+		mediaService: mediaService,
+		scanner:      s,
+	}
+}
+
+// Run scans quarantined files one at a time until ctx is cancelled. It wakes
+// up when a file is stored on this instance, and every interval to pick up
+// files stored elsewhere or left unscanned because the scanner was down.
+// Files left scanning for longer than staleScanAfter, by a server that
+// stopped mid-scan, are requeued on each round.
+func (ss *ScanService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := ss.queries.ResetStaleScans(ctx, time.Now().Add(-staleScanAfter).UnixMilli()); err != nil {
+			log.Printf("Requeueing stale scans failed: %v", err)
+		}
+		for {
+			err := ss.ProcessNext(ctx)
+			if errors.Is(err, ErrNoScanJob) {
+				break
+			}
+			if err != nil {
+				log.Printf("Malware scan failed: %v", err)
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-ss.mediaService.scanWake:
+		}
+	}
+}
+
+// ProcessNext scans the oldest quarantined file. It returns ErrNoScanJob when
+// the queue is empty. When the scanner cannot be reached the file is put back
+// in the queue and the error is returned, so the caller can wait before
+// trying again.
+func (ss *ScanService) ProcessNext(ctx context.Context) error {
+	job, err := ss.queries.ClaimScanJob(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoScanJob
+		}
+		return err
+	}
+
+	// The outcome is recorded even if ctx is cancelled mid-scan
+	dbCtx := context.WithoutCancel(ctx)
+
+	status, result, err := ss.scanFile(ctx, job.StoredName)
+	if err != nil {
+		if reqErr := ss.queries.RequeueScanJob(dbCtx, db.RequeueScanJobParams{
+			ScanResult: sql.NullString{String: truncate(err.Error(), 500), Valid: true},
+			ID:         job.ID,
+			StoredName: job.StoredName,
+		}); reqErr != nil {
+			return reqErr
+		}
+		return fmt.Errorf("media %d: %w", job.ID, err)
+	}
+
+	uploadDir := ss.mediaService.uploadDir
+	released := filepath.Join(uploadDir, job.StoredName)
+	switch status {
+	case ScanClean:
+		if err := os.Rename(QuarantinePath(uploadDir, job.StoredName), released); err != nil {
+			status, result = ScanFailed, "releasing file: "+err.Error()
+		}
+	case ScanInfected:
+		log.Printf("Media %d is infected (%s); removing it", job.ID, result)
+	}
+
+	n, err := ss.queries.FinishScanJob(dbCtx, db.FinishScanJobParams{
+		ScanStatus: status,
+		ScanResult: sql.NullString{String: truncate(result, 500), Valid: result != ""},
+		ID:         job.ID,
+		StoredName: job.StoredName,
+	})
+	if err != nil {
+		if status == ScanClean {
+			// Back to quarantine, so the file is not served unrecorded
+			_ = os.Rename(released, QuarantinePath(uploadDir, job.StoredName))
+		}
+		return err
+	}
+	if n == 0 {
+		// The file was replaced meanwhile. It is still referenced as an
+		// earlier version, so it stays wherever it is now.
+		log.Printf("Media %d changed while being scanned", job.ID)
+	}
+	return nil
+}
+
+// scanFile scans a quarantined file and returns its scan status with the
+// signature found or why it could not be read. Infected files are deleted.
+// An error means the scanner failed and the file should be scanned again.
+func (ss *ScanService) scanFile(ctx context.Context, storedName string) (string, string, error) {
+	if storedName == "" || filepath.Base(storedName) != storedName {
+		return ScanFailed, fmt.Sprintf("invalid stored name %q", storedName), nil
+	}
+
+	path := QuarantinePath(ss.mediaService.uploadDir, storedName)
+	f, err := os.Open(path)
+	if err != nil {
+		return ScanFailed, err.Error(), nil
+	}
+	res, err := ss.scanner.Scan(ctx, f)
+	f.Close()
+	if err != nil {
+		return "", "", err
+	}
+
+	if res.Infected {
+		if err := os.Remove(path); err != nil {
+			log.Printf("Removing infected file %s failed: %v", storedName, err)
+		}
+		return ScanInfected, res.Signature, nil
+	}
+	return ScanClean, "", nil
+}
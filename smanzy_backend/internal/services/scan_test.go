@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/scanner"
+)
+
+// quarantineFile writes a file into the quarantine directory of uploadDir
+func quarantineFile(t *testing.T, uploadDir, storedName, contents string) string {
+	t.Helper()
+	if err := os.MkdirAll(QuarantineDir(uploadDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := QuarantinePath(uploadDir, storedName)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestScanFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	ss := NewScanService(nil, NewMediaService(nil, nil, tmpDir, DefaultMaxVersions), scanner.NewFake())
+
+	clean := quarantineFile(t, tmpDir, "1_1.jpg", "a harmless image")
+	status, result, err := ss.scanFile(context.Background(), "1_1.jpg")
+	if err != nil || status != ScanClean || result != "" {
+		t.Errorf("clean file: got %q %q %v", status, result, err)
+	}
+	if _, err := os.Stat(clean); err != nil {
+		t.Errorf("clean file should stay in quarantine until released: %v", err)
+	}
+
+	infected := quarantineFile(t, tmpDir, "1_2.jpg", "header "+scanner.EICAR)
+	status, result, err = ss.scanFile(context.Background(), "1_2.jpg")
+	if err != nil || status != ScanInfected || result != "Eicar-Test-Signature" {
+		t.Errorf("infected file: got %q %q %v", status, result, err)
+	}
+	if _, err := os.Stat(infected); !os.IsNotExist(err) {
+		t.Error("infected file was not removed")
+	}
+
+	status, _, err = ss.scanFile(context.Background(), "1_3.jpg")
+	if err != nil || status != ScanFailed {
+		t.Errorf("missing file: got %q %v, want failed", status, err)
+	}
+
+	status, _, err = ss.scanFile(context.Background(), "../1_1.jpg")
+	if err != nil || status != ScanFailed {
+		t.Errorf("path traversal: got %q %v, want failed", status, err)
+	}
+}
+
+func TestScanFile_ScannerDown(t *testing.T) {
+	tmpDir := t.TempDir()
+	fake := &scanner.Fake{Err: errors.New("connection refused")}
+	ss := NewScanService(nil, NewMediaService(nil, nil, tmpDir, DefaultMaxVersions), fake)
+
+	path := quarantineFile(t, tmpDir, "1_1.jpg", "data")
+	if _, _, err := ss.scanFile(context.Background(), "1_1.jpg"); err == nil {
+		t.Error("expected the scanner error to be returned so the file is scanned again")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file should stay in quarantine: %v", err)
+	}
+}
+
+func TestRemoveFiles_DeletesQuarantinedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := quarantineFile(t, tmpDir, "1_1.jpg", "data")
+
+	NewMediaService(nil, nil, tmpDir, DefaultMaxVersions).RemoveFiles("1_1.jpg")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("quarantined file was not removed")
+	}
+}
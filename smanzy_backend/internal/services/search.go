@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Search result types
+const (
+	SearchTypeMedia = "media"
+	SearchTypeAlbum = "album"
+	SearchTypeVideo = "video"
+)
+
+// MaxSearchQueryLength caps the length of a search query, in characters
+const MaxSearchQueryLength = 200
+
+// ErrInvalidSearch is returned when a search request is malformed
+var ErrInvalidSearch = errors.New("invalid search")
+
+// Document vectors. These must stay identical to the expression indexes
+// idx_media_search, idx_album_search and idx_videos_search, or PostgreSQL
+// will fall back to a sequential scan.
+const (
+	mediaSearchVector = `(setweight(to_tsvector('simple', regexp_replace(m.filename, '[._-]+', ' ', 'g')), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(m.description, '')), 'B'))`
+	albumSearchVector = `(setweight(to_tsvector('simple', a.title), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(a.description, '')), 'B'))`
+	videoSearchVector = `(setweight(to_tsvector('simple', v.title), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(v.description, '')), 'B'))`
+)
+
+// searchDocuments matches all searchable documents the user may see.
+// $1 is the query text, $2 whether the user is an admin, $3 the user ID (0 for anonymous).
+// Media follows the public feed rules (unlisted media is never listed) and
+// must have passed the malware scan; albums must be public; owners and
+// admins see everything of theirs.
+const searchDocuments = `
+q AS (
+	SELECT websearch_to_tsquery('simple', $1::TEXT) AS query
+),
+docs AS (
+	SELECT 'media' AS result_type, m.id, m.filename AS title,
+		COALESCE(NULLIF(m.description, ''), m.filename) AS body,
+		ts_rank(` + mediaSearchVector + `, q.query)::FLOAT8 AS rank,
+		m.stored_name, COALESCE(m.mime_type, '') AS mime_type,
+		'' AS video_id, '' AS thumbnail_url, m.created_at,
+		m.user_id AS owner_id, m.scan_status
+	FROM media m, q
+	WHERE m.deleted_at IS NULL
+	  AND m.scan_status = 'clean'
+	  AND ` + mediaSearchVector + ` @@ q.query
+	  AND (
+		$2::BOOLEAN
+		OR m.user_id = $3::BIGINT
+		OR m.visibility = 'public'
+		OR (m.visibility = 'inherit' AND EXISTS (
+			SELECT 1 FROM album_media am
+			JOIN album pa ON pa.id = am.album_id
+			WHERE am.media_id = m.id AND pa.is_public = TRUE AND pa.deleted_at IS NULL
+		))
+	  )
+	UNION ALL
+	SELECT 'album', a.id, a.title,
+		COALESCE(NULLIF(a.description, ''), a.title),
+		ts_rank(` + albumSearchVector + `, q.query)::FLOAT8,
+		'', '', '', '', a.created_at, a.user_id, ''
+	FROM album a, q
+	WHERE a.deleted_at IS NULL
+	  AND ` + albumSearchVector + ` @@ q.query
+	  AND ($2::BOOLEAN OR a.user_id = $3::BIGINT OR a.is_public = TRUE)
+	UNION ALL
+	SELECT 'video', v.id, v.title,
+		COALESCE(NULLIF(v.description, ''), v.title),
+		ts_rank(` + videoSearchVector + `, q.query)::FLOAT8,
+		'', '', v.video_id, COALESCE(v.thumbnail_url, ''), v.created_at, 0, ''
+	FROM videos v, q
+	WHERE v.deleted_at IS NULL
+	  AND ` + videoSearchVector + ` @@ q.query
+)`
+
+// Highlight markers are plain text so the snippet can be HTML-escaped
+// before they are turned into <mark> tags
+const (
+	highlightStart = "{{mark}}"
+	highlightStop  = "{{/mark}}"
+)
+
+const searchQuery = `WITH ` + searchDocuments + `
+SELECT d.result_type, d.id, d.title,
+	ts_headline('simple', d.title, q.query, 'HighlightAll=true, StartSel=` + highlightStart + `, StopSel=` + highlightStop + `'),
+	ts_headline('simple', d.body, q.query, 'MaxWords=35, MinWords=15, StartSel=` + highlightStart + `, StopSel=` + highlightStop + `'),
+	d.rank, d.stored_name, d.mime_type, d.video_id, d.thumbnail_url, d.created_at,
+	d.owner_id, d.scan_status
+FROM docs d, q
+WHERE $4::TEXT = '' OR d.result_type = ANY(string_to_array($4::TEXT, ','))
+ORDER BY d.rank DESC, d.created_at DESC
+LIMIT $5 OFFSET $6`
+
+const searchFacetsQuery = `WITH ` + searchDocuments + `
+SELECT result_type, COUNT(*) FROM docs
+GROUP BY result_type`
+
+// SearchParams describes a search request
+type SearchParams struct {
+	Query   string
+	Types   []string // Result types to return; empty for all
+	UserID  uint     // 0 for anonymous users
+	IsAdmin bool
+	Limit   int
+	Offset  int
+}
+
+// SearchHit is a single ranked search result.
+// TitleHTML and SnippetHTML are HTML-escaped with matches wrapped in <mark>.
+type SearchHit struct {
+	Type         string  `json:"type"`
+	ID           uint    `json:"id"`
+	Title        string  `json:"title"`
+	TitleHTML    string  `json:"title_html"`
+	SnippetHTML  string  `json:"snippet_html"`
+	Rank         float64 `json:"rank"`
+	StoredName   string  `json:"-"`
+	OwnerID      uint    `json:"-"`             // Owner of a media item or album
+	ScanStatus   string  `json:"-"`             // Malware scan status of a media item
+	URL          string  `json:"url,omitempty"` // Signed file URL for media
+	MimeType     string  `json:"mime_type,omitempty"`
+	VideoID      string  `json:"video_id,omitempty"`
+	ThumbnailURL string  `json:"thumbnail_url,omitempty"`
+	CreatedAt    int64   `json:"created_at"`
+}
+
+// SearchService runs full-text search across media, albums and videos
+type SearchService struct {
+	conn *sql.DB
+}
+
+// NewSearchService creates a new search service
+func NewSearchService(conn *sql.DB) *SearchService {
+	return &SearchService{conn: conn}
+}
+
+// validate checks the search request and normalizes the type filter
+func (p *SearchParams) validate() error {
+	p.Query = strings.TrimSpace(p.Query)
+	if p.Query == "" {
+		return fmt.Errorf("%w: empty query", ErrInvalidSearch)
+	}
+	if len([]rune(p.Query)) > MaxSearchQueryLength {
+		return fmt.Errorf("%w: query is longer than %d characters", ErrInvalidSearch, MaxSearchQueryLength)
+	}
+
+	for _, t := range p.Types {
+		switch t {
+		case SearchTypeMedia, SearchTypeAlbum, SearchTypeVideo:
+		default:
+			return fmt.Errorf("%w: unknown type %q", ErrInvalidSearch, t)
+		}
+	}
+	return nil
+}
+
+// Search returns ranked, highlighted results the user may see, plus the
+// number of matches per result type (ignoring the type filter)
+func (ss *SearchService) Search(ctx context.Context, p SearchParams) ([]SearchHit, map[string]int64, error) {
+	if err := p.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := ss.conn.QueryContext(ctx, searchQuery,
+		p.Query, p.IsAdmin, int64(p.UserID), strings.Join(p.Types, ","), p.Limit, p.Offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		var h SearchHit
+		var id, ownerID int64
+		if err := rows.Scan(&h.Type, &id, &h.Title, &h.TitleHTML, &h.SnippetHTML, &h.Rank,
+			&h.StoredName, &h.MimeType, &h.VideoID, &h.ThumbnailURL, &h.CreatedAt,
+			&ownerID, &h.ScanStatus); err != nil {
+			return nil, nil, err
+		}
+		h.ID, h.OwnerID = uint(id), uint(ownerID)
+		h.TitleHTML = HighlightHTML(h.TitleHTML)
+		h.SnippetHTML = HighlightHTML(h.SnippetHTML)
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	facets := map[string]int64{SearchTypeMedia: 0, SearchTypeAlbum: 0, SearchTypeVideo: 0}
+	facetRows, err := ss.conn.QueryContext(ctx, searchFacetsQuery, p.Query, p.IsAdmin, int64(p.UserID))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer facetRows.Close()
+
+	for facetRows.Next() {
+		var resultType string
+		var count int64
+		if err := facetRows.Scan(&resultType, &count); err != nil {
+			return nil, nil, err
+		}
+		facets[resultType] = count
+	}
+	if err := facetRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return hits, facets, nil
+}
+
+// HighlightHTML escapes a ts_headline result and turns its highlight
+// markers into <mark> tags, so it is safe to render as HTML
+func HighlightHTML(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
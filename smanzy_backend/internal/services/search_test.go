@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHighlightHTML(t *testing.T) {
+	got := HighlightHTML("<b>sunny</b> {{mark}}beach{{/mark}} & more")
+	want := "&lt;b&gt;sunny&lt;/b&gt; <mark>beach</mark> &amp; more"
+	if got != want {
+		t.Errorf("HighlightHTML = %q, want %q", got, want)
+	}
+}
+
+func TestSearchParamsValidate(t *testing.T) {
+	p := SearchParams{Query: "  beach  ", Types: []string{SearchTypeMedia, SearchTypeVideo}}
+	if err := p.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Query != "beach" {
+		t.Errorf("query not trimmed: %q", p.Query)
+	}
+
+	invalid := []SearchParams{
+		{Query: "   "},
+		{Query: strings.Repeat("a", MaxSearchQueryLength+1)},
+		{Query: "beach", Types: []string{"users"}},
+	}
+	for _, p := range invalid {
+		if err := p.validate(); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("validate(%+v): expected ErrInvalidSearch, got %v", p, err)
+		}
+	}
+}
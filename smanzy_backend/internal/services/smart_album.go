@@ -0,0 +1,409 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// ErrSmartAlbum is returned when adding, removing or ordering media by hand in
+// a smart album
+var ErrSmartAlbum = fmt.Errorf("%w: smart albums list media by their rules", ErrInvalidAlbum)
+
+// Smart album limits
+const (
+	MaxSmartRuleDepth      = 4    // Nesting of rule groups, counting the top level
+	MaxSmartRuleConditions = 32   // Conditions in one rule definition
+	MaxSmartRuleText       = 200  // Characters in a text value
+	MaxSmartAlbumMedia     = 2000 // Media listed in a smart album
+	SmartPreviewLimit      = 50   // Media returned by a rule preview
+)
+
+// Smart rule matching modes of a group
+const (
+	SmartMatchAll = "all"
+	SmartMatchAny = "any"
+)
+
+// SmartRule is a smart album rule: either a group of rules (Match and Rules)
+// or a condition on one field of the media (Field, Op and Value). The rule
+// definition of an album is a group.
+type SmartRule struct {
+	Match string      `json:"match,omitempty"` // "all" (default) or "any"
+	Rules []SmartRule `json:"rules,omitempty"`
+
+	Field string          `json:"field,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SmartPreview is what a rule definition matches
+type SmartPreview struct {
+	Media []db.Medium // The first SmartPreviewLimit matches
+	Total int64       // All matches
+}
+
+// smartMediaColumns lists the media columns in the order of db.Medium
+const smartMediaColumns = `m.id, m.filename, m.stored_name, m.type, m.mime_type, m.size, m.user_id,
+	m.created_at, m.updated_at, m.deleted_at, m.visibility, m.description, m.phash,
+	m.transcode_status, m.transcode_error, m.blurhash, m.dominant_color, m.aspect_ratio,
+	m.content_hash, m.source_url, m.scan_status, m.scan_result, m.scanned_at, m.captured_at`
+
+// smartOrder is the ORDER BY of each sort mode. Smart albums have no manual
+// order, so it lists them by upload time.
+var smartOrder = map[string]string{
+	AlbumSortManual:   "m.created_at, m.id",
+	AlbumSortCaptured: "COALESCE(m.captured_at, m.created_at), m.id",
+	AlbumSortUploaded: "m.created_at, m.id",
+	AlbumSortName:     "LOWER(m.filename), m.id",
+}
+
+// smartDateFields are the time fields rules can match, in Unix ms
+var smartDateFields = map[string]string{
+	"captured": "COALESCE(m.captured_at, m.created_at)", // Photos without a capture time count as taken when uploaded
+	"uploaded": "m.created_at",
+}
+
+// smartTextFields are the text fields rules can search
+var smartTextFields = map[string]string{
+	"filename":    "m.filename",
+	"description": "COALESCE(m.description, '')",
+}
+
+// smartTypes matches the kinds of media by MIME type
+var smartTypes = map[string]string{
+	"image": "COALESCE(m.mime_type, '') LIKE 'image/%'",
+	"video": "COALESCE(m.mime_type, '') LIKE 'video/%'",
+	"audio": "COALESCE(m.mime_type, '') LIKE 'audio/%'",
+	"other": "COALESCE(m.mime_type, '') !~ '^(image|video|audio)/'",
+}
+
+// ruleCompiler turns rules into a SQL condition on media m and its arguments
+type ruleCompiler struct {
+	args       []any
+	conditions int
+}
+
+// arg adds a query argument and returns its placeholder
+func (rc *ruleCompiler) arg(v any) string {
+	rc.args = append(rc.args, v)
+	return fmt.Sprintf("$%d", len(rc.args))
+}
+
+// ruleError reports a problem with a rule definition
+func ruleError(format string, a ...any) error {
+	return fmt.Errorf("%w: rules: %s", ErrInvalidAlbum, fmt.Sprintf(format, a...))
+}
+
+// group compiles a group of rules
+func (rc *ruleCompiler) group(r SmartRule, depth int) (string, error) {
+	if depth > MaxSmartRuleDepth {
+		return "", ruleError("groups can be nested at most %d deep", MaxSmartRuleDepth)
+	}
+	if r.Field != "" || r.Op != "" || len(r.Value) > 0 {
+		return "", ruleError("a rule is either a group or a condition, not both")
+	}
+	join := " AND "
+	switch r.Match {
+	case "", SmartMatchAll:
+	case SmartMatchAny:
+		join = " OR "
+	default:
+		return "", ruleError("match must be all or any, not %q", r.Match)
+	}
+	if len(r.Rules) == 0 {
+		return "", ruleError("a group needs at least one rule")
+	}
+
+	parts := make([]string, len(r.Rules))
+	for i, rule := range r.Rules {
+		var err error
+		if rule.Match != "" || len(rule.Rules) > 0 {
+			parts[i], err = rc.group(rule, depth+1)
+		} else {
+			parts[i], err = rc.condition(rule)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "(" + strings.Join(parts, join) + ")", nil
+}
+
+// condition compiles a condition on one field
+func (rc *ruleCompiler) condition(r SmartRule) (string, error) {
+	rc.conditions++
+	if rc.conditions > MaxSmartRuleConditions {
+		return "", ruleError("at most %d conditions are allowed", MaxSmartRuleConditions)
+	}
+
+	switch field := r.Field; {
+	case field == "type":
+		var kind string
+		if err := json.Unmarshal(r.Value, &kind); err != nil || smartTypes[kind] == "" {
+			return "", ruleError("type must be image, video, audio or other")
+		}
+		return negate(r, smartTypes[kind])
+
+	case field == "visibility":
+		var visibility string
+		if err := json.Unmarshal(r.Value, &visibility); err != nil || !models.IsValidVisibility(visibility) {
+			return "", ruleError("visibility must be private, unlisted, public or inherit")
+		}
+		return negate(r, "m.visibility = "+rc.arg(visibility))
+
+	case field == "tag":
+		var name string
+		if err := json.Unmarshal(r.Value, &name); err != nil {
+			return "", ruleError("tag must be a tag name")
+		}
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return "", ruleError("%v", err)
+		}
+		return negate(r, `EXISTS (
+			SELECT 1 FROM media_tags mt JOIN tags t ON t.id = mt.tag_id
+			WHERE mt.media_id = m.id AND t.name = `+rc.arg(tag)+`)`)
+
+	case smartTextFields[field] != "":
+		if r.Op != "contains" {
+			return "", ruleError("%s only supports contains", field)
+		}
+		var text string
+		if err := json.Unmarshal(r.Value, &text); err != nil || text == "" || len([]rune(text)) > MaxSmartRuleText {
+			return "", ruleError("%s needs text of 1 to %d characters", field, MaxSmartRuleText)
+		}
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+		return smartTextFields[field] + " ILIKE " + rc.arg("%"+escaped+"%"), nil
+
+	case smartDateFields[field] != "":
+		return rc.dateCondition(smartDateFields[field], r)
+	}
+	return "", ruleError("unknown field %q", r.Field)
+}
+
+// negate applies the eq and ne operators to a condition
+func negate(r SmartRule, cond string) (string, error) {
+	switch r.Op {
+	case "eq":
+		return cond, nil
+	case "ne":
+		return "NOT " + cond, nil
+	}
+	return "", ruleError("%s only supports eq and ne", r.Field)
+}
+
+// dateCondition compiles year, before, after and between on a time field.
+// Dates are YYYY-MM-DD in UTC; before and after exclude the date itself,
+// between includes both ends.
+func (rc *ruleCompiler) dateCondition(expr string, r SmartRule) (string, error) {
+	switch r.Op {
+	case "year":
+		var year int
+		if err := json.Unmarshal(r.Value, &year); err != nil || year < 1900 || year > 9999 {
+			return "", ruleError("year needs a year between 1900 and 9999")
+		}
+		from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return "(" + expr + " >= " + rc.arg(from.UnixMilli()) + " AND " + expr + " < " + rc.arg(from.AddDate(1, 0, 0).UnixMilli()) + ")", nil
+
+	case "before", "after":
+		var value string
+		if err := json.Unmarshal(r.Value, &value); err != nil {
+			return "", ruleError("%s needs a date as YYYY-MM-DD", r.Op)
+		}
+		day, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return "", ruleError("%s needs a date as YYYY-MM-DD", r.Op)
+		}
+		if r.Op == "before" {
+			return expr + " < " + rc.arg(day.UnixMilli()), nil
+		}
+		return expr + " >= " + rc.arg(day.AddDate(0, 0, 1).UnixMilli()), nil
+
+	case "between":
+		var values []string
+		if err := json.Unmarshal(r.Value, &values); err != nil || len(values) != 2 {
+			return "", ruleError("between needs two dates as YYYY-MM-DD")
+		}
+		from, err1 := time.Parse(time.DateOnly, values[0])
+		to, err2 := time.Parse(time.DateOnly, values[1])
+		if err1 != nil || err2 != nil || to.Before(from) {
+			return "", ruleError("between needs two dates as YYYY-MM-DD, the earlier first")
+		}
+		return "(" + expr + " >= " + rc.arg(from.UnixMilli()) + " AND " + expr + " < " + rc.arg(to.AddDate(0, 0, 1).UnixMilli()) + ")", nil
+	}
+	return "", ruleError("%s supports year, before, after and between", r.Field)
+}
+
+// ParseSmartRules parses and validates a rule definition and returns it as
+// compact JSON for storing
+func ParseSmartRules(raw []byte) (SmartRule, string, error) {
+	var rules SmartRule
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return SmartRule{}, "", ruleError("%v", err)
+	}
+	if _, _, err := compileSmartRules(rules, 0); err != nil {
+		return SmartRule{}, "", err
+	}
+	canonical, err := json.Marshal(rules)
+	if err != nil {
+		return SmartRule{}, "", err
+	}
+	return rules, string(canonical), nil
+}
+
+// compileSmartRules returns the SQL condition of a rule definition on media m
+// and its arguments, after the owner ID as $1
+func compileSmartRules(rules SmartRule, ownerID int64) (string, []any, error) {
+	rc := &ruleCompiler{args: []any{ownerID}}
+	where, err := rc.group(rules, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	return where, rc.args, nil
+}
+
+// smartMediaWhere returns the full condition of a smart album query: the
+// owner's live media that passed the virus scan and matches the rules.
+// Private media is left out unless includePrivate is set.
+func smartMediaWhere(rules SmartRule, ownerID int64, includePrivate bool) (string, []any, error) {
+	where, args, err := compileSmartRules(rules, ownerID)
+	if err != nil {
+		return "", nil, err
+	}
+	where = "m.user_id = $1 AND m.deleted_at IS NULL AND m.scan_status = 'clean' AND " + where
+	if !includePrivate {
+		where = "m.visibility <> 'private' AND " + where
+	}
+	return where, args, nil
+}
+
+// querySmartMedia returns up to limit of the owner's media matching the
+// rules, in the given sort mode, and the number of matches. Private media is
+// left out unless includePrivate is set.
+func (as *AlbumService) querySmartMedia(ctx context.Context, ownerID int64, rules SmartRule, sortMode string, limit int, includePrivate bool) ([]db.Medium, int64, error) {
+	where, args, err := smartMediaWhere(rules, ownerID, includePrivate)
+	if err != nil {
+		return nil, 0, err
+	}
+	order, ok := smartOrder[sortMode]
+	if !ok {
+		order = smartOrder[AlbumSortManual]
+	}
+
+	query := `SELECT ` + smartMediaColumns + `, COUNT(*) OVER () AS total
+		FROM media m
+		WHERE ` + where + `
+		ORDER BY ` + order + fmt.Sprintf(` LIMIT %d`, limit)
+
+	rows, err := as.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		media []db.Medium
+		total int64
+	)
+	for rows.Next() {
+		var m db.Medium
+		if err := rows.Scan(
+			&m.ID, &m.Filename, &m.StoredName, &m.Type, &m.MimeType, &m.Size, &m.UserID,
+			&m.CreatedAt, &m.UpdatedAt, &m.DeletedAt, &m.Visibility, &m.Description, &m.Phash,
+			&m.TranscodeStatus, &m.TranscodeError, &m.Blurhash, &m.DominantColor, &m.AspectRatio,
+			&m.ContentHash, &m.SourceUrl, &m.ScanStatus, &m.ScanResult, &m.ScannedAt, &m.CapturedAt,
+			&total,
+		); err != nil {
+			return nil, 0, err
+		}
+		media = append(media, m)
+	}
+	return media, total, rows.Err()
+}
+
+// smartAlbumMedia evaluates the rules of a smart album for a user
+func (as *AlbumService) smartAlbumMedia(ctx context.Context, user *models.User, album db.Album) ([]db.Medium, error) {
+	var rules SmartRule
+	if err := json.Unmarshal([]byte(album.SmartRules.String), &rules); err != nil {
+		return nil, err
+	}
+	media, _, err := as.querySmartMedia(ctx, album.UserID, rules, album.SortMode, MaxSmartAlbumMedia, canSeeSmartPrivate(user, album))
+	return media, err
+}
+
+// smartAlbumHas reports whether a media item of the owner matches the rules
+// of a smart album, as seen by a user
+func (as *AlbumService) smartAlbumHas(ctx context.Context, user *models.User, album db.Album, mediaID int64) (bool, error) {
+	var rules SmartRule
+	if err := json.Unmarshal([]byte(album.SmartRules.String), &rules); err != nil {
+		return false, err
+	}
+	where, args, err := smartMediaWhere(rules, album.UserID, canSeeSmartPrivate(user, album))
+	if err != nil {
+		return false, err
+	}
+	args = append(args, mediaID)
+
+	query := fmt.Sprintf(`SELECT EXISTS (
+		SELECT 1 FROM media m
+		WHERE m.id = $%d AND %s)`, len(args), where)
+	var matches bool
+	err = as.conn.QueryRowContext(ctx, query, args...).Scan(&matches)
+	return matches, err
+}
+
+// SetSmartRules turns an album into a smart album with the given rule
+// definition, or back into a curated album when raw is empty (Owner or
+// Admin, since the rules search the owner's whole library). Curated media
+// stays in album_media and shows again when the rules are removed.
+func (as *AlbumService) SetSmartRules(ctx context.Context, user *models.User, albumID uint, raw []byte) (*models.Album, error) {
+	if _, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage); err != nil {
+		return nil, err
+	}
+
+	var stored sql.NullString
+	if len(bytes.TrimSpace(raw)) > 0 && string(bytes.TrimSpace(raw)) != "null" {
+		_, canonical, err := ParseSmartRules(raw)
+		if err != nil {
+			return nil, err
+		}
+		stored = sql.NullString{String: canonical, Valid: true}
+	}
+
+	if err := as.queries.SetAlbumSmartRules(ctx, db.SetAlbumSmartRulesParams{
+		ID:         int64(albumID),
+		SmartRules: stored,
+	}); err != nil {
+		return nil, err
+	}
+	return as.GetAlbumByID(ctx, user, albumID)
+}
+
+// PreviewSmartRules returns what a rule definition would list in an album,
+// as seen by the user, without saving it (Owner or Admin)
+func (as *AlbumService) PreviewSmartRules(ctx context.Context, user *models.User, albumID uint, raw []byte) (*SmartPreview, error) {
+	album, _, err := as.Authorize(ctx, user, albumID, AlbumAccessManage)
+	if err != nil {
+		return nil, err
+	}
+	rules, _, err := ParseSmartRules(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	media, total, err := as.querySmartMedia(ctx, album.UserID, rules, album.SortMode, SmartPreviewLimit, canSeeSmartPrivate(user, album))
+	if err != nil {
+		return nil, err
+	}
+	return &SmartPreview{Media: media, Total: total}, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileSmartRules(t *testing.T) {
+	rules, canonical, err := ParseSmartRules([]byte(`{
+		"match": "all",
+		"rules": [
+			{"field": "type", "op": "eq", "value": "image"},
+			{"field": "tag", "op": "eq", "value": "Summer Trip"},
+			{"match": "any", "rules": [
+				{"field": "captured", "op": "year", "value": 2023},
+				{"field": "filename", "op": "contains", "value": "50%_off"}
+			]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(canonical, "\n\t") {
+		t.Errorf("canonical rules are not compact: %s", canonical)
+	}
+
+	where, args, err := compileSmartRules(rules, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{
+		"LIKE 'image/%'",
+		"t.name = $2",
+		"COALESCE(m.captured_at, m.created_at) >= $3",
+		"m.filename ILIKE $5",
+		" OR ",
+	} {
+		if !strings.Contains(where, part) {
+			t.Errorf("condition %q does not contain %q", where, part)
+		}
+	}
+
+	want := []any{int64(7), "summer-trip", int64(1672531200000), int64(1704067200000), `%50\%\_off%`}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args: got %v, want %v", args, want)
+	}
+}
+
+func TestCompileSmartRules_Dates(t *testing.T) {
+	rules, _, err := ParseSmartRules([]byte(`{"rules": [
+		{"field": "uploaded", "op": "between", "value": ["2024-03-01", "2024-03-31"]},
+		{"field": "captured", "op": "after", "value": "2024-03-10"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, args, err := compileSmartRules(rules, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// between includes both days; after starts the day after
+	want := []any{int64(1), int64(1709251200000), int64(1711929600000), int64(1710115200000)}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args: got %v, want %v", args, want)
+	}
+}
+
+func TestSmartMediaWhere(t *testing.T) {
+	rules, _, err := ParseSmartRules([]byte(`{"rules": [{"field": "type", "op": "eq", "value": "image"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pending, failed and infected media never matches, whoever looks
+	for _, includePrivate := range []bool{true, false} {
+		where, args, err := smartMediaWhere(rules, 7, includePrivate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, part := range []string{"m.user_id = $1", "m.deleted_at IS NULL", "m.scan_status = 'clean'", "LIKE 'image/%'"} {
+			if !strings.Contains(where, part) {
+				t.Errorf("includePrivate=%v: condition %q does not contain %q", includePrivate, where, part)
+			}
+		}
+		if got := strings.Contains(where, "m.visibility <> 'private'"); got == includePrivate {
+			t.Errorf("includePrivate=%v: private filter present = %v", includePrivate, got)
+		}
+		if !reflect.DeepEqual(args, []any{int64(7)}) {
+			t.Errorf("args: got %v", args)
+		}
+	}
+}
+
+func TestParseSmartRules_Rejects(t *testing.T) {
+	deep := `{"field": "type", "op": "eq", "value": "video"}`
+	for i := 0; i <= MaxSmartRuleDepth; i++ {
+		deep = `{"rules": [` + deep + `]}`
+	}
+	many := strings.Repeat(`{"field": "type", "op": "eq", "value": "video"},`, MaxSmartRuleConditions+1)
+
+	tests := map[string]string{
+		"not json":             `{"rules": [`,
+		"unknown key":          `{"rules": [{"field": "type", "op": "eq", "value": "image", "extra": 1}]}`,
+		"condition at the top": `{"field": "type", "op": "eq", "value": "image"}`,
+		"empty group":          `{"match": "all", "rules": []}`,
+		"bad match":            `{"match": "some", "rules": [{"field": "type", "op": "eq", "value": "image"}]}`,
+		"unknown field":        `{"rules": [{"field": "size", "op": "eq", "value": 1}]}`,
+		"bad type":             `{"rules": [{"field": "type", "op": "eq", "value": "pdf"}]}`,
+		"bad op":               `{"rules": [{"field": "tag", "op": "contains", "value": "x"}]}`,
+		"bad tag":              `{"rules": [{"field": "tag", "op": "eq", "value": "a/b"}]}`,
+		"bad date":             `{"rules": [{"field": "captured", "op": "before", "value": "03/10/2024"}]}`,
+		"reversed between":     `{"rules": [{"field": "captured", "op": "between", "value": ["2024-02-01", "2024-01-01"]}]}`,
+		"empty text":           `{"rules": [{"field": "filename", "op": "contains", "value": ""}]}`,
+		"too deep":             deep,
+		"too many":             `{"rules": [` + strings.TrimSuffix(many, ",") + `]}`,
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := ParseSmartRules([]byte(raw)); !errors.Is(err, ErrInvalidAlbum) {
+				t.Errorf("got %v, want ErrInvalidAlbum", err)
+			}
+		})
+	}
+}
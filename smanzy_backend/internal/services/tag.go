@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+const (
+	// MaxTagLength is the longest allowed tag name, in characters
+	MaxTagLength = 50
+	// MaxTagsPerItem caps the number of tags on a single media item or album
+	MaxTagsPerItem = 30
+)
+
+// Tag filter modes for media listings
+const (
+	TagMatchAll = "all" // Media must have every tag (AND)
+	TagMatchAny = "any" // Media must have at least one tag (OR)
+)
+
+var (
+	// ErrInvalidTag is returned when a tag name is empty or has invalid characters
+	ErrInvalidTag = errors.New("invalid tag")
+	// ErrTooManyTags is returned when an item would exceed MaxTagsPerItem
+	ErrTooManyTags = errors.New("too many tags")
+	// ErrTagNotFound is returned when a tag does not exist or is not attached
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// NormalizeTag lowercases a tag name and joins words with dashes.
+// Tags may contain letters, digits, dashes and underscores.
+func NormalizeTag(name string) (string, error) {
+	tag := strings.Join(strings.Fields(strings.ToLower(name)), "-")
+	if tag == "" {
+		return "", fmt.Errorf("%w: empty name", ErrInvalidTag)
+	}
+	if len([]rune(tag)) > MaxTagLength {
+		return "", fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTag, tag, MaxTagLength)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", fmt.Errorf("%w: %q contains %q", ErrInvalidTag, tag, r)
+		}
+	}
+	return tag, nil
+}
+
+// ParseTagFilter turns a comma-separated tag list and match mode into the
+// arguments of the media listing queries: the normalized, de-duplicated tag
+// list and the number of tags each item must match. An empty list disables
+// the filter.
+func ParseTagFilter(raw, mode string) (string, int32, error) {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		tag, err := NormalizeTag(part)
+		if err != nil {
+			return "", 0, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	switch mode {
+	case "", TagMatchAll:
+		return strings.Join(tags, ","), int32(len(tags)), nil
+	case TagMatchAny:
+		return strings.Join(tags, ","), 1, nil
+	default:
+		return "", 0, fmt.Errorf("%w: unknown match mode %q", ErrInvalidTag, mode)
+	}
+}
+
+// TagService handles business logic for tags
+type TagService struct {
+	conn    *sql.DB
+	queries *db.Queries
+}
+
+// NewTagService creates a new tag service
+func NewTagService(conn *sql.DB, queries *db.Queries) *TagService {
+	return &TagService{
+		conn:    conn,
+		queries: queries,
+	}
+}
+
+// AddMediaTags attaches tags to a media item, creating free-form tags as needed.
+// It returns all tags of the item.
+func (ts *TagService) AddMediaTags(ctx context.Context, mediaID, userID uint, names []string) ([]models.Tag, error) {
+	return ts.addTags(ctx, userID, names,
+		func(qtx *db.Queries, tagID int64) error {
+			return qtx.AddMediaTag(ctx, db.AddMediaTagParams{MediaID: int64(mediaID), TagID: tagID})
+		},
+		func(qtx *db.Queries) ([]db.Tag, error) {
+			return qtx.ListMediaTags(ctx, int64(mediaID))
+		},
+	)
+}
+
+// AddAlbumTags attaches tags to an album, creating free-form tags as needed.
+// It returns all tags of the album.
+func (ts *TagService) AddAlbumTags(ctx context.Context, albumID, userID uint, names []string) ([]models.Tag, error) {
+	return ts.addTags(ctx, userID, names,
+		func(qtx *db.Queries, tagID int64) error {
+			return qtx.AddAlbumTag(ctx, db.AddAlbumTagParams{AlbumID: int64(albumID), TagID: tagID})
+		},
+		func(qtx *db.Queries) ([]db.Tag, error) {
+			return qtx.ListAlbumTags(ctx, int64(albumID))
+		},
+	)
+}
+
+// addTags runs the shared tagging flow in one transaction, so either all
+// tags are attached or none are
+func (ts *TagService) addTags(ctx context.Context, userID uint, names []string,
+	attach func(qtx *db.Queries, tagID int64) error,
+	list func(qtx *db.Queries) ([]db.Tag, error),
+) ([]models.Tag, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no tags given", ErrInvalidTag)
+	}
+
+	tx, err := ts.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := ts.queries.WithTx(tx)
+
+	for _, name := range names {
+		tagName, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+
+		tag, err := qtx.EnsureTag(ctx, db.EnsureTagParams{
+			Name:      tagName,
+			CreatedBy: sql.NullInt64{Int64: int64(userID), Valid: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := attach(qtx, tag.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := list(qtx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > MaxTagsPerItem {
+		return nil, fmt.Errorf("%w: at most %d tags per item", ErrTooManyTags, MaxTagsPerItem)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return mappers.TagsToModels(rows), nil
+}
+
+// RemoveMediaTag detaches a tag from a media item
+func (ts *TagService) RemoveMediaTag(ctx context.Context, mediaID uint, name string) error {
+	tagName, err := NormalizeTag(name)
+	if err != nil {
+		return err
+	}
+
+	n, err := ts.queries.RemoveMediaTag(ctx, db.RemoveMediaTagParams{MediaID: int64(mediaID), Name: tagName})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// RemoveAlbumTag detaches a tag from an album
+func (ts *TagService) RemoveAlbumTag(ctx context.Context, albumID uint, name string) error {
+	tagName, err := NormalizeTag(name)
+	if err != nil {
+		return err
+	}
+
+	n, err := ts.queries.RemoveAlbumTag(ctx, db.RemoveAlbumTagParams{AlbumID: int64(albumID), Name: tagName})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// ListMediaTags returns the tags of a media item, sorted by name
+func (ts *TagService) ListMediaTags(ctx context.Context, mediaID uint) ([]models.Tag, error) {
+	rows, err := ts.queries.ListMediaTags(ctx, int64(mediaID))
+	if err != nil {
+		return nil, err
+	}
+	return mappers.TagsToModels(rows), nil
+}
+
+// ListAlbumTags returns the tags of an album, sorted by name
+func (ts *TagService) ListAlbumTags(ctx context.Context, albumID uint) ([]models.Tag, error) {
+	rows, err := ts.queries.ListAlbumTags(ctx, int64(albumID))
+	if err != nil {
+		return nil, err
+	}
+	return mappers.TagsToModels(rows), nil
+}
+
+// Autocomplete returns curated tags and tags the user has used that start
+// with the given prefix. Curated tags come first.
+func (ts *TagService) Autocomplete(ctx context.Context, userID uint, prefix string, limit int) ([]models.Tag, error) {
+	prefix = strings.Join(strings.Fields(strings.ToLower(prefix)), "-")
+
+	rows, err := ts.queries.SearchTags(ctx, db.SearchTagsParams{
+		Prefix:     prefix,
+		UserID:     int64(userID),
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mappers.TagsToModels(rows), nil
+}
+
+// Cloud returns the most used tags on public media with their counts
+func (ts *TagService) Cloud(ctx context.Context, limit int) ([]models.TagCount, error) {
+	rows, err := ts.queries.TagCloud(ctx, int32(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]models.TagCount, len(rows))
+	for i, row := range rows {
+		counts[i] = models.TagCount{
+			Name:      row.Name,
+			IsCurated: row.IsCurated,
+			Count:     row.MediaCount,
+		}
+	}
+	return counts, nil
+}
+
+// ListCurated returns all curated tags, sorted by name
+func (ts *TagService) ListCurated(ctx context.Context) ([]models.Tag, error) {
+	rows, err := ts.queries.ListCuratedTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mappers.TagsToModels(rows), nil
+}
+
+// CreateCurated creates a curated tag, or promotes an existing free-form tag
+func (ts *TagService) CreateCurated(ctx context.Context, name string, adminID uint) (*models.Tag, error) {
+	tagName, err := NormalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := ts.queries.CreateCuratedTag(ctx, db.CreateCuratedTagParams{
+		Name:      tagName,
+		CreatedBy: sql.NullInt64{Int64: int64(adminID), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tag := mappers.TagToModel(row)
+	return &tag, nil
+}
+
+// SetCurated marks a tag as curated or free-form
+func (ts *TagService) SetCurated(ctx context.Context, tagID uint, curated bool) (*models.Tag, error) {
+	row, err := ts.queries.SetTagCurated(ctx, db.SetTagCuratedParams{ID: int64(tagID), IsCurated: curated})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+
+	tag := mappers.TagToModel(row)
+	return &tag, nil
+}
+
+// DeleteTag removes a tag from every media item and album
+func (ts *TagService) DeleteTag(ctx context.Context, tagID uint) error {
+	n, err := ts.queries.DeleteTag(ctx, int64(tagID))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Beach", "beach"},
+		{"  Summer   Holiday ", "summer-holiday"},
+		{"snow_2024", "snow_2024"},
+		{"Ölberg", "ölberg"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeTag(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeTag(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "   ", "a,b", "50%", strings.Repeat("x", MaxTagLength+1)} {
+		if _, err := NormalizeTag(bad); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("NormalizeTag(%q): expected ErrInvalidTag, got %v", bad, err)
+		}
+	}
+}
+
+func TestParseTagFilter(t *testing.T) {
+	tags, min, err := ParseTagFilter("Beach, sunset,beach,", "")
+	if err != nil || tags != "beach,sunset" || min != 2 {
+		t.Errorf("all: got %q, %d, %v", tags, min, err)
+	}
+
+	tags, min, err = ParseTagFilter("beach,sunset", TagMatchAny)
+	if err != nil || tags != "beach,sunset" || min != 1 {
+		t.Errorf("any: got %q, %d, %v", tags, min, err)
+	}
+
+	tags, min, err = ParseTagFilter("", TagMatchAll)
+	if err != nil || tags != "" || min != 0 {
+		t.Errorf("empty: got %q, %d, %v", tags, min, err)
+	}
+
+	if _, _, err := ParseTagFilter("beach", "some"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("bad mode: expected ErrInvalidTag, got %v", err)
+	}
+}
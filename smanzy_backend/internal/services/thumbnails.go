@@ -0,0 +1,70 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Thumbnail statuses reported with media records
+const (
+	ThumbnailNone    = "none"    // File type has no thumbnails
+	ThumbnailPending = "pending" // Waiting for smanzy_thumbgen
+	ThumbnailReady   = "ready"   // All thumbnail sizes exist
+	ThumbnailFailed  = "failed"  // Thumbnails were not produced in time
+)
+
+// ThumbnailGracePeriod is how long after an upload or edit missing thumbnails
+// are reported as pending rather than failed
+const ThumbnailGracePeriod = 10 * time.Minute
+
+// ThumbnailSizeNames maps thumbnail directories to the keys used in API responses
+var ThumbnailSizeNames = map[string]string{
+	"160x100": "small",
+	"320x200": "medium",
+	"640x400": "large",
+	"800x600": "xl",
+}
+
+// ThumbnailPath returns where smanzy_thumbgen writes the thumbnail of a stored
+// file in the given size
+func ThumbnailPath(uploadDir, size, storedName string) string {
+	return filepath.Join(uploadDir, size, strings.TrimSuffix(storedName, filepath.Ext(storedName))+".jpg")
+}
+
+// HasThumbnails reports whether smanzy_thumbgen makes thumbnails for a stored file
+func HasThumbnails(storedName string) bool {
+	switch strings.ToLower(filepath.Ext(storedName)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp", // Images
+		".mp4", ".mov", ".avi", ".mkv", ".webm", // Videos
+		".heic":
+		return true
+	}
+	return false
+}
+
+// ThumbnailState reports the thumbnail status of a stored file and the sizes
+// that exist on disk. updatedAt is the record's last update in milliseconds;
+// thumbnails still missing after ThumbnailGracePeriod are reported as failed.
+func ThumbnailState(uploadDir, storedName string, updatedAt int64) (string, []string) {
+	if storedName == "" || filepath.Base(storedName) != storedName || !HasThumbnails(storedName) {
+		return ThumbnailNone, nil
+	}
+
+	var sizes []string
+	for _, size := range ThumbnailSizes {
+		if _, err := os.Stat(ThumbnailPath(uploadDir, size, storedName)); err == nil {
+			sizes = append(sizes, size)
+		}
+	}
+
+	switch {
+	case len(sizes) == len(ThumbnailSizes):
+		return ThumbnailReady, sizes
+	case time.Since(time.UnixMilli(updatedAt)) < ThumbnailGracePeriod:
+		return ThumbnailPending, sizes
+	default:
+		return ThumbnailFailed, sizes
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThumbnailState(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UnixMilli()
+	old := time.Now().Add(-2 * ThumbnailGracePeriod).UnixMilli()
+
+	writeThumb := func(size, storedName string) {
+		t.Helper()
+		path := ThumbnailPath(dir, size, storedName)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("thumb"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, size := range ThumbnailSizes {
+		writeThumb(size, "1_ready.png")
+	}
+	writeThumb("160x100", "1_partial.mp4")
+
+	tests := []struct {
+		name       string
+		storedName string
+		updatedAt  int64
+		status     string
+		sizes      int
+	}{
+		{"all sizes", "1_ready.png", old, ThumbnailReady, len(ThumbnailSizes)},
+		{"recent upload", "1_new.jpg", now, ThumbnailPending, 0},
+		{"partially written", "1_partial.mp4", now, ThumbnailPending, 1},
+		{"never produced", "1_missing.jpg", old, ThumbnailFailed, 0},
+		{"unsupported type", "1_doc.pdf", now, ThumbnailNone, 0},
+		{"path traversal", "../1_ready.png", now, ThumbnailNone, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, sizes := ThumbnailState(dir, tt.storedName, tt.updatedAt)
+			if status != tt.status || len(sizes) != tt.sizes {
+				t.Errorf("ThumbnailState(%q) = %q, %v; want %q with %d sizes", tt.storedName, status, sizes, tt.status, tt.sizes)
+			}
+		})
+	}
+}
+
+func TestThumbnailSizeNames(t *testing.T) {
+	for _, size := range ThumbnailSizes {
+		if ThumbnailSizeNames[size] == "" {
+			t.Errorf("thumbnail size %s has no response name", size)
+		}
+	}
+}
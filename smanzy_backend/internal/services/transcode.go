@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+)
+
+// Transcode statuses stored on media records
+const (
+	TranscodeNone       = "none"       // Not a video
+	TranscodePending    = "pending"    // Waiting for the transcoder
+	TranscodeProcessing = "processing" // Being transcoded
+	TranscodeReady      = "ready"      // HLS stream available
+	TranscodeFailed     = "failed"     // Transcoding failed; the original is served instead
+)
+
+const (
+	// HLSMasterPlaylist is the name of the master playlist in a stream directory
+	HLSMasterPlaylist = "master.m3u8"
+	// hlsSegmentSeconds is the target HLS segment duration
+	hlsSegmentSeconds = 6
+	// transcodeTimeout bounds a single transcode so a broken file cannot block the queue
+	transcodeTimeout = 2 * time.Hour
+	// staleTranscodeAfter is how long a video may stay in processing before
+	// it is taken to be left behind by a stopped server
+	staleTranscodeAfter = transcodeTimeout + 30*time.Minute
+)
+
+// HLSRendition is one quality level of the HLS ladder
+type HLSRendition struct {
+	Height       int    // Output height; smaller sources are not upscaled
+	VideoBitrate string // e.g. "2800k"
+	AudioBitrate string
+}
+
+// DefaultHLSLadder is the set of renditions produced for every video
+var DefaultHLSLadder = []HLSRendition{
+	{Height: 360, VideoBitrate: "800k", AudioBitrate: "96k"},
+	{Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+	{Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k"},
+}
+
+// ErrNoTranscodeJob is returned when no video is waiting to be transcoded
+var ErrNoTranscodeJob = errors.New("no transcode job")
+
+// HLSDir returns the directory holding the HLS stream of a stored file
+func HLSDir(uploadDir, storedName string) string {
+	return filepath.Join(uploadDir, "hls", strings.TrimSuffix(storedName, filepath.Ext(storedName)))
+}
+
+// TranscodeService converts uploaded videos to adaptive HLS streams in the background
+type TranscodeService struct {
+	queries     *db.Queries
+	uploadDir   string
+	ffmpegPath  string
+	ffprobePath string
+	ladder      []HLSRendition
+}
+
+// NewTranscodeService creates a new transcode service
+func NewTranscodeService(queries *db.Queries, uploadDir, ffmpegPath, ffprobePath string) *TranscodeService {
+	return &TranscodeService{
+		queries:     queries,
+		uploadDir:   uploadDir,
+		ffmpegPath:  ffmpegPath,
+		ffprobePath: ffprobePath,
+		ladder:      DefaultHLSLadder,
+	}
+}
+
+// Run transcodes pending videos one at a time, checking for new ones every
+// interval, until ctx is cancelled. Videos left in processing for longer
+// than staleTranscodeAfter, by a server that stopped mid-transcode, are
+// requeued on each round.
+func (ts *TranscodeService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := ts.queries.ResetStaleTranscodes(ctx, time.Now().Add(-staleTranscodeAfter).UnixMilli()); err != nil {
+			log.Printf("Requeueing stale transcodes failed: %v", err)
+		}
+		for {
+			err := ts.ProcessNext(ctx)
+			if errors.Is(err, ErrNoTranscodeJob) {
+				break
+			}
+			if err != nil {
+				log.Printf("Transcode failed: %v", err)
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessNext transcodes the oldest pending video. It returns
+// ErrNoTranscodeJob when the queue is empty. A video that fails to transcode
+// is marked failed and keeps being served from the original file.
+func (ts *TranscodeService) ProcessNext(ctx context.Context) error {
+	job, err := ts.queries.ClaimTranscodeJob(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoTranscodeJob
+		}
+		return err
+	}
+
+	status, errMsg := TranscodeReady, sql.NullString{}
+	if err := ts.transcode(ctx, job.StoredName); err != nil {
+		log.Printf("Transcoding media %d failed: %v", job.ID, err)
+		status, errMsg = TranscodeFailed, sql.NullString{String: truncate(err.Error(), 500), Valid: true}
+	} else {
+		log.Printf("Transcoded media %d to HLS", job.ID)
+	}
+
+	n, err := ts.queries.FinishTranscodeJob(context.WithoutCancel(ctx), db.FinishTranscodeJobParams{
+		TranscodeStatus: status,
+		TranscodeError:  errMsg,
+		ID:              job.ID,
+		StoredName:      job.StoredName,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// The file was replaced or purged meanwhile; the stream is stale
+		_ = os.RemoveAll(HLSDir(ts.uploadDir, job.StoredName))
+	}
+	return nil
+}
+
+// transcode writes the HLS stream of a stored file. The stream is built in a
+// temporary directory and moved into place when complete, so a partial
+// stream is never served.
+func (ts *TranscodeService) transcode(ctx context.Context, storedName string) error {
+	if storedName == "" || filepath.Base(storedName) != storedName {
+		return fmt.Errorf("invalid stored name %q", storedName)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transcodeTimeout)
+	defer cancel()
+
+	input := filepath.Join(ts.uploadDir, storedName)
+	outDir := HLSDir(ts.uploadDir, storedName)
+	tmpDir := outDir + ".tmp"
+
+	_ = os.RemoveAll(tmpDir)
+	for i := range ts.ladder {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "v"+strconv.Itoa(i)), 0755); err != nil {
+			return err
+		}
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cmd := exec.CommandContext(ctx, ts.ffmpegPath, buildHLSArgs(input, tmpDir, ts.ladder, ts.hasAudio(ctx, input))...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, lastLine(stderr.String()))
+	}
+
+	_ = os.RemoveAll(outDir)
+	return os.Rename(tmpDir, outDir)
+}
+
+// hasAudio reports whether a video has an audio stream. Without ffprobe the
+// video is assumed to have one.
+func (ts *TranscodeService) hasAudio(ctx context.Context, input string) bool {
+	out, err := exec.CommandContext(ctx, ts.ffprobePath, "-v", "error", "-select_streams", "a",
+		"-show_entries", "stream=index", "-of", "csv=p=0", input).Output()
+	if err != nil {
+		return true
+	}
+	return strings.TrimSpace(string(out)) != ""
+}
+
+// buildHLSArgs returns the ffmpeg arguments that encode every rendition of the
+// ladder in one pass and write a master playlist with a media playlist per
+// rendition in v0/, v1/, ...
+func buildHLSArgs(input, outDir string, ladder []HLSRendition, hasAudio bool) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", input}
+
+	// Split the video once and scale each copy; never upscale, keep even heights
+	filters := fmt.Sprintf("[0:v]split=%d", len(ladder))
+	for i := range ladder {
+		filters += fmt.Sprintf("[s%d]", i)
+	}
+	for i, r := range ladder {
+		filters += fmt.Sprintf(";[s%d]scale=-2:'trunc(min(%d,ih)/2)*2',format=yuv420p[v%d]", i, r.Height, i)
+	}
+	args = append(args, "-filter_complex", filters)
+
+	var streamMap []string
+	for i, r := range ladder {
+		n := strconv.Itoa(i)
+		args = append(args,
+			"-map", "[v"+n+"]",
+			"-c:v:"+n, "libx264", "-preset", "veryfast", "-profile:v:"+n, "main",
+			"-b:v:"+n, r.VideoBitrate, "-maxrate:v:"+n, r.VideoBitrate, "-bufsize:v:"+n, r.VideoBitrate,
+		)
+		if hasAudio {
+			args = append(args, "-map", "0:a:0", "-c:a:"+n, "aac", "-b:a:"+n, r.AudioBitrate, "-ac:a:"+n, "2")
+			streamMap = append(streamMap, "v:"+n+",a:"+n)
+		} else {
+			streamMap = append(streamMap, "v:"+n)
+		}
+	}
+
+	args = append(args,
+		// Keyframes on segment boundaries so renditions can be switched cleanly
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentSeconds),
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(outDir, "v%v", "seg_%04d.ts"),
+		"-master_pl_name", HLSMasterPlaylist,
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(outDir, "v%v", "index.m3u8"),
+	)
+	return args
+}
+
+// RewritePlaylist passes every URI line of an m3u8 playlist through rewrite,
+// leaving tags and comments untouched
+func RewritePlaylist(playlist []byte, rewrite func(uri string) string) []byte {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			line = rewrite(line)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// lastLine returns the last non-empty line of ffmpeg's error output
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// argAfter returns the argument following flag, or "" if flag is missing
+func argAfter(args []string, flag string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestBuildHLSArgs(t *testing.T) {
+	args := buildHLSArgs("/up/clip.mp4", "/up/hls/clip.tmp", DefaultHLSLadder, true)
+
+	if got := argAfter(args, "-i"); got != "/up/clip.mp4" {
+		t.Errorf("input = %q", got)
+	}
+	if got := argAfter(args, "-var_stream_map"); got != "v:0,a:0 v:1,a:1 v:2,a:2" {
+		t.Errorf("var_stream_map = %q", got)
+	}
+	if got := argAfter(args, "-master_pl_name"); got != HLSMasterPlaylist {
+		t.Errorf("master playlist = %q", got)
+	}
+
+	filters := argAfter(args, "-filter_complex")
+	if !strings.HasPrefix(filters, "[0:v]split=3[s0][s1][s2];") {
+		t.Errorf("filter_complex = %q", filters)
+	}
+	if !strings.Contains(filters, "min(720,ih)") {
+		t.Errorf("filter_complex does not cap the 720p rendition: %q", filters)
+	}
+
+	if last := args[len(args)-1]; last != filepath.Join("/up/hls/clip.tmp", "v%v", "index.m3u8") {
+		t.Errorf("output = %q", last)
+	}
+}
+
+func TestBuildHLSArgs_NoAudio(t *testing.T) {
+	args := buildHLSArgs("in.mkv", "out", DefaultHLSLadder[:2], false)
+
+	if got := argAfter(args, "-var_stream_map"); got != "v:0 v:1" {
+		t.Errorf("var_stream_map = %q", got)
+	}
+	for _, a := range args {
+		if a == "0:a:0" {
+			t.Fatal("audio mapped for a video without audio")
+		}
+	}
+}
+
+func TestRewritePlaylist(t *testing.T) {
+	playlist := []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nv0/index.m3u8\n\n#EXTINF:6.0,\nseg_0000.ts\n")
+
+	got := string(RewritePlaylist(playlist, func(uri string) string { return uri + "?sig=x" }))
+	want := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nv0/index.m3u8?sig=x\n\n#EXTINF:6.0,\nseg_0000.ts?sig=x\n"
+	if got != want {
+		t.Errorf("RewritePlaylist =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHLSDir(t *testing.T) {
+	if got := HLSDir("/up", "abc.mov"); got != filepath.Join("/up", "hls", "abc") {
+		t.Errorf("HLSDir = %q", got)
+	}
+}
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+)
+
+// URL import statuses
+const (
+	URLImportPending  = "pending"  // Waiting for the importer
+	URLImportFetching = "fetching" // Being downloaded
+	URLImportDone     = "done"     // Media created
+	URLImportFailed   = "failed"   // See the error
+)
+
+const (
+	// DefaultURLImportMaxSize is the largest file fetched from a URL by default
+	DefaultURLImportMaxSize = 50 << 20
+	// DefaultURLImportTimeout bounds a whole download by default
+	DefaultURLImportTimeout = time.Minute
+	// MaxActiveURLImports caps the pending and running imports of one user
+	MaxActiveURLImports = 10
+	// MaxURLLength caps the length of an imported URL
+	MaxURLLength = 2048
+
+	maxURLImportRedirects = 5
+)
+
+var (
+	// ErrInvalidURL is returned when a URL cannot be imported from
+	ErrInvalidURL = errors.New("invalid URL")
+	// ErrBlockedAddress is returned when a URL points at a private, loopback or
+	// otherwise internal address
+	ErrBlockedAddress = errors.New("address not allowed")
+	// ErrTooManyURLImports is returned when a user has too many imports queued
+	ErrTooManyURLImports = errors.New("too many URL imports in progress")
+	// ErrURLImportNotFound is returned when an import does not exist or belongs to someone else
+	ErrURLImportNotFound = errors.New("URL import not found")
+	// ErrNoURLImportJob is returned when no import is waiting
+	ErrNoURLImportJob = errors.New("no URL import job")
+	// ErrFileTooLarge is returned when a remote file exceeds the size limit
+	ErrFileTooLarge = errors.New("file too large")
+)
+
+// blockedPrefixes are non-public ranges not covered by the netip.Addr predicates
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, can reach IPv4 private ranges
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+}
+
+// importExtensions is the file extension given to fetched files by MIME type,
+// so smanzy_thumbgen recognises them
+var importExtensions = map[string]string{
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/gif":        ".gif",
+	"image/bmp":        ".bmp",
+	"image/webp":       ".webp",
+	"image/heic":       ".heic",
+	"video/mp4":        ".mp4",
+	"video/webm":       ".webm",
+	"video/quicktime":  ".mov",
+	"video/x-matroska": ".mkv",
+	"video/x-msvideo":  ".avi",
+}
+
+// IsBlockedAddress reports whether an IP address must not be fetched from:
+// anything that is not a public unicast address
+func IsBlockedAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() {
+		return true
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateImportURL checks that a URL can be imported from: http or https,
+// without credentials, and not naming a blocked address directly. Host names
+// are checked again after DNS resolution when connecting.
+func ValidateImportURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: only http and https are supported", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials are not allowed", ErrInvalidURL)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && IsBlockedAddress(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
+
+// newImportHTTPClient returns an HTTP client for fetching user-supplied URLs.
+// guard is checked for every connection after DNS resolution, including
+// connections made for redirects, so host names resolving to blocked
+// addresses are caught too. Proxies from the environment are not used, since
+// they would connect on the client's behalf.
+func newImportHTTPClient(timeout time.Duration, guard func(netip.AddrPort) error) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return guard(addrPort)
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxURLImportRedirects {
+				return fmt.Errorf("%w: too many redirects", ErrInvalidURL)
+			}
+			return ValidateImportURL(req.URL)
+		},
+	}
+}
+
+// publicAddressGuard rejects connections to blocked addresses
+func publicAddressGuard(addr netip.AddrPort) error {
+	if IsBlockedAddress(addr.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr.Addr())
+	}
+	return nil
+}
+
+// URLImportService fetches media from remote URLs in the background
+type URLImportService struct {
+	queries      *db.Queries
+	mediaService *MediaService
+	client       *http.Client
+	maxSize      int64
+	wake         chan struct{}
+}
+
+// NewURLImportService creates a new URL import service. Files larger than
+// maxSize bytes, or taking longer than timeout to download, are rejected.
+func NewURLImportService(queries *db.Queries, mediaService *MediaService, maxSize int64, timeout time.Duration) *URLImportService {
+	return &URLImportService{
+		queries:      queries,
+		mediaService: mediaService,
+		client:       newImportHTTPClient(timeout, publicAddressGuard),
+		maxSize:      maxSize,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Enqueue validates a URL and queues it to be fetched for the user
+func (us *URLImportService) Enqueue(ctx context.Context, userID uint, rawURL, visibility, description string) (*models.URLImport, error) {
+	if len(rawURL) > MaxURLLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidURL, MaxURLLength)
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if err := ValidateImportURL(u); err != nil {
+		return nil, err
+	}
+
+	if visibility == "" {
+		visibility = models.VisibilityInherit
+	}
+	if !models.IsValidVisibility(visibility) {
+		return nil, fmt.Errorf("%w: invalid visibility %q", ErrInvalidUpload, visibility)
+	}
+
+	active, err := us.queries.CountActiveURLImports(ctx, int64(userID))
+	if err != nil {
+		return nil, err
+	}
+	if active >= MaxActiveURLImports {
+		return nil, ErrTooManyURLImports
+	}
+
+	row, err := us.queries.CreateURLImport(ctx, db.CreateURLImportParams{
+		UserID:      int64(userID),
+		Url:         u.String(),
+		Visibility:  visibility,
+		Description: description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Start the importer right away instead of waiting for its next tick
+	select {
+	case us.wake <- struct{}{}:
+	default:
+	}
+
+	job := mappers.URLImportToModel(row)
+	return &job, nil
+}
+
+// Get returns an import of the user. Admins can see every import.
+func (us *URLImportService) Get(ctx context.Context, id int64, userID uint, isAdmin bool) (*models.URLImport, error) {
+	row, err := us.queries.GetURLImport(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrURLImportNotFound
+		}
+		return nil, err
+	}
+	if row.UserID != int64(userID) && !isAdmin {
+		return nil, ErrURLImportNotFound
+	}
+	job := mappers.URLImportToModel(row)
+	return &job, nil
+}
+
+// List returns the user's most recent imports, newest first
+func (us *URLImportService) List(ctx context.Context, userID uint, limit int) ([]models.URLImport, error) {
+	rows, err := us.queries.ListUserURLImports(ctx, db.ListUserURLImportsParams{
+		UserID: int64(userID),
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]models.URLImport, len(rows))
+	for i, row := range rows {
+		jobs[i] = mappers.URLImportToModel(row)
+	}
+	return jobs, nil
+}
+
+// Run fetches queued URLs one at a time until ctx is cancelled. It wakes up
+// when an import is queued on this instance, and every interval to pick up
+// imports queued elsewhere. Imports left fetching by an earlier run are
+// requeued first.
+func (us *URLImportService) Run(ctx context.Context, interval time.Duration) {
+	if err := us.queries.ResetStaleURLImports(ctx); err != nil {
+		log.Printf("Requeueing stale URL imports failed: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			err := us.ProcessNext(ctx)
+			if errors.Is(err, ErrNoURLImportJob) {
+				break
+			}
+			if err != nil {
+				log.Printf("URL import failed: %v", err)
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-us.wake:
+		}
+	}
+}
+
+// ProcessNext fetches the oldest queued URL. It returns ErrNoURLImportJob when
+// the queue is empty. A URL that cannot be fetched marks its import failed.
+func (us *URLImportService) ProcessNext(ctx context.Context) error {
+	job, err := us.queries.ClaimURLImport(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoURLImportJob
+		}
+		return err
+	}
+
+	params := db.FinishURLImportParams{ID: job.ID, Status: URLImportDone}
+	row, err := us.fetch(ctx, job)
+	if err != nil {
+		log.Printf("Importing %s for user %d failed: %v", job.Url, job.UserID, err)
+		params.Status = URLImportFailed
+		params.Error = sql.NullString{String: truncate(err.Error(), 500), Valid: true}
+	} else {
+		params.MediaID = sql.NullInt64{Int64: row.ID, Valid: true}
+	}
+	return us.queries.FinishURLImport(context.WithoutCancel(ctx), params)
+}
+
+// fetch downloads an import's URL and creates the media item
+func (us *URLImportService) fetch(ctx context.Context, job db.MediaUrlImport) (db.CreateMediaRow, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.Url, nil)
+	if err != nil {
+		return db.CreateMediaRow{}, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	req.Header.Set("User-Agent", "smanzy-url-import/1.0")
+	req.Header.Set("Accept", "image/*,video/*;q=0.9,*/*;q=0.1")
+
+	resp, err := us.client.Do(req)
+	if err != nil {
+		return db.CreateMediaRow{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return db.CreateMediaRow{}, fmt.Errorf("remote server returned %s", resp.Status)
+	}
+	if resp.ContentLength > us.maxSize {
+		return db.CreateMediaRow{}, fmt.Errorf("%w: %d bytes, limit is %d", ErrFileTooLarge, resp.ContentLength, us.maxSize)
+	}
+
+	body := bufio.NewReader(&maxSizeReader{r: resp.Body, remaining: us.maxSize})
+	head, err := body.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return db.CreateMediaRow{}, err
+	}
+
+	name := remoteFilename(resp)
+	mimeType := sniffMediaType(head, resp.Header.Get("Content-Type"), name)
+	if mimeType == "" {
+		return db.CreateMediaRow{}, fmt.Errorf("%w: not an image or video", ErrInvalidUpload)
+	}
+
+	return us.mediaService.CreateMedia(ctx, NewMediaFile{
+		UserID:      uint(job.UserID),
+		Filename:    importFilename(name, mimeType),
+		MimeType:    mimeType,
+		Visibility:  job.Visibility,
+		Description: job.Description,
+		SourceURL:   job.Url,
+	}, body)
+}
+
+// maxSizeReader fails with ErrFileTooLarge once more than remaining bytes are read
+type maxSizeReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.remaining < 0 {
+		return 0, ErrFileTooLarge
+	}
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// sniffMediaType returns the type of a fetched file from its first bytes.
+// Only images and videos are accepted. Formats the sniffer does not know
+// (e.g. QuickTime or HEIC) fall back to the Content-Type header, then to the
+// file name. It returns "" for anything else, including HTML and SVG.
+func sniffMediaType(head []byte, contentType, filename string) string {
+	isMedia := func(t string) bool {
+		return strings.HasPrefix(t, "image/") || strings.HasPrefix(t, "video/")
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if isMedia(sniffed) {
+		return sniffed
+	}
+	if sniffed != "application/octet-stream" {
+		return ""
+	}
+	if declared, _, err := mime.ParseMediaType(contentType); err == nil && isMedia(declared) && declared != "image/svg+xml" {
+		return declared
+	}
+	if byName := DetectMimeType(filename, nil); isMedia(byName) && byName != "image/svg+xml" {
+		return byName
+	}
+	return ""
+}
+
+// remoteFilename returns the name of a fetched file from its
+// Content-Disposition header or the last segment of the final URL
+func remoteFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return filepath.Base(params["filename"])
+	}
+	return path.Base(resp.Request.URL.Path)
+}
+
+// importFilename cleans up a remote file name and makes sure its extension
+// matches the sniffed type
+func importFilename(name, mimeType string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = "import"
+	}
+
+	ext := importExtensions[mimeType]
+	if ext == "" {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	if ext != "" && DetectMimeType(name, nil) != mimeType {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ext
+	}
+
+	if len(name) > MaxFilenameLength {
+		ext := filepath.Ext(name)
+		name = name[:MaxFilenameLength-len(ext)] + ext
+	}
+	return name
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/ristep/smanzy_backend/internal/db"
+	"github.com/ristep/smanzy_backend/internal/mappers"
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/watermark"
+)
+
+const (
+	// DefaultWatermarkPosition, DefaultWatermarkOpacity and DefaultWatermarkScale
+	// are the settings of a user who has not configured a watermark
+	DefaultWatermarkPosition = watermark.BottomRight
+	DefaultWatermarkOpacity  = 0.5
+	DefaultWatermarkScale    = 0.2
+	// MaxWatermarkTextLength caps the text of a text watermark
+	MaxWatermarkTextLength = 100
+	// MaxWatermarkLogoSize caps the size of an uploaded logo
+	MaxWatermarkLogoSize = 2 << 20
+	// maxWatermarkLogoSide caps the width and height of an uploaded logo
+	maxWatermarkLogoSide = 2000
+	// watermarkedThumbnailQuality is the JPEG quality of watermarked thumbnails
+	watermarkedThumbnailQuality = 85
+	// watermarkedOriginalQuality is the JPEG quality of watermarked originals
+	watermarkedOriginalQuality = 92
+)
+
+// ErrInvalidWatermark is returned when watermark settings or a logo are rejected
+var ErrInvalidWatermark = errors.New("invalid watermark")
+
+// WatermarkLogoDir returns the directory holding uploaded watermark logos
+func WatermarkLogoDir(uploadDir string) string {
+	return filepath.Join(uploadDir, "watermarks")
+}
+
+// WatermarkedThumbnailPath returns where the watermarked copy of a thumbnail
+// is cached
+func WatermarkedThumbnailPath(uploadDir, size, storedName string) string {
+	base := strings.TrimSuffix(storedName, filepath.Ext(storedName))
+	return filepath.Join(uploadDir, "watermarked", size, base+".jpg")
+}
+
+// WatermarkedOriginalPath returns where the watermarked copy of an image is
+// cached. It keeps the image's format, so its extension does not change.
+func WatermarkedOriginalPath(uploadDir, storedName string) string {
+	return filepath.Join(uploadDir, "watermarked", "original", storedName)
+}
+
+// WatermarkService stores per-user watermark settings and stamps them on the
+// thumbnails of their media served to other people
+type WatermarkService struct {
+	queries   *db.Queries
+	uploadDir string
+
+	mu    sync.Mutex
+	logos map[string]image.Image // Decoded logos by file name; a new logo gets a new name
+}
+
+// NewWatermarkService creates a new watermark service
+func NewWatermarkService(queries *db.Queries, uploadDir string) *WatermarkService {
+	return &WatermarkService{
+		queries:   queries,
+		uploadDir: uploadDir,
+		logos:     make(map[string]image.Image),
+	}
+}
+
+// ValidateWatermark checks watermark settings
+func ValidateWatermark(s models.WatermarkSettings) error {
+	if len([]rune(s.Text)) > MaxWatermarkTextLength {
+		return fmt.Errorf("%w: text is longer than %d characters", ErrInvalidWatermark, MaxWatermarkTextLength)
+	}
+	if !watermark.IsValidPosition(s.Position) {
+		return fmt.Errorf("%w: position must be one of %s", ErrInvalidWatermark, strings.Join(watermark.Positions, ", "))
+	}
+	if s.Opacity < 0.05 || s.Opacity > 1 {
+		return fmt.Errorf("%w: opacity must be between 0.05 and 1", ErrInvalidWatermark)
+	}
+	if s.Scale < 0.05 || s.Scale > 1 {
+		return fmt.Errorf("%w: scale must be between 0.05 and 1", ErrInvalidWatermark)
+	}
+	if s.Enabled && strings.TrimSpace(s.Text) == "" && !s.HasLogo {
+		return fmt.Errorf("%w: upload a logo or set a text before enabling the watermark", ErrInvalidWatermark)
+	}
+	return nil
+}
+
+// Get returns the watermark settings of a user, or the defaults if they have
+// none
+func (ws *WatermarkService) Get(ctx context.Context, userID uint) (models.WatermarkSettings, error) {
+	row, err := ws.queries.GetUserWatermark(ctx, int64(userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WatermarkSettings{
+			Position: DefaultWatermarkPosition,
+			Opacity:  DefaultWatermarkOpacity,
+			Scale:    DefaultWatermarkScale,
+		}, nil
+	}
+	if err != nil {
+		return models.WatermarkSettings{}, err
+	}
+	return mappers.WatermarkToModel(row), nil
+}
+
+// Update saves the watermark settings of a user. The logo is managed
+// separately with SetLogo and RemoveLogo.
+func (ws *WatermarkService) Update(ctx context.Context, userID uint, s models.WatermarkSettings) (models.WatermarkSettings, error) {
+	current, err := ws.Get(ctx, userID)
+	if err != nil {
+		return models.WatermarkSettings{}, err
+	}
+	s.HasLogo = current.HasLogo
+	if err := ValidateWatermark(s); err != nil {
+		return models.WatermarkSettings{}, err
+	}
+
+	row, err := ws.queries.UpsertUserWatermark(ctx, db.UpsertUserWatermarkParams{
+		UserID:   int64(userID),
+		Enabled:  s.Enabled,
+		Text:     strings.TrimSpace(s.Text),
+		Position: s.Position,
+		Opacity:  s.Opacity,
+		Scale:    s.Scale,
+	})
+	if err != nil {
+		return models.WatermarkSettings{}, err
+	}
+	return mappers.WatermarkToModel(row), nil
+}
+
+// SetLogo stores a PNG logo for a user's watermark, replacing any earlier one.
+// Logos keep their transparency, so a PNG with an alpha channel works best.
+func (ws *WatermarkService) SetLogo(ctx context.Context, userID uint, r io.Reader) (models.WatermarkSettings, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxWatermarkLogoSize+1))
+	if err != nil {
+		return models.WatermarkSettings{}, err
+	}
+	if len(data) > MaxWatermarkLogoSize {
+		return models.WatermarkSettings{}, fmt.Errorf("%w: logo is larger than %d MiB", ErrInvalidWatermark, MaxWatermarkLogoSize>>20)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return models.WatermarkSettings{}, fmt.Errorf("%w: logo must be a PNG image", ErrInvalidWatermark)
+	}
+	if cfg.Width > maxWatermarkLogoSide || cfg.Height > maxWatermarkLogoSide {
+		return models.WatermarkSettings{}, fmt.Errorf("%w: logo is larger than %dx%d", ErrInvalidWatermark, maxWatermarkLogoSide, maxWatermarkLogoSide)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		return models.WatermarkSettings{}, fmt.Errorf("%w: logo must be a PNG image", ErrInvalidWatermark)
+	}
+
+	previous, err := ws.logoName(ctx, userID)
+	if err != nil {
+		return models.WatermarkSettings{}, err
+	}
+
+	dir := WatermarkLogoDir(ws.uploadDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return models.WatermarkSettings{}, err
+	}
+	name := fmt.Sprintf("%d_%d.png", userID, time.Now().UnixNano())
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return models.WatermarkSettings{}, err
+	}
+
+	row, err := ws.queries.SetUserWatermarkLogo(ctx, db.SetUserWatermarkLogoParams{
+		UserID:   int64(userID),
+		LogoName: sql.NullString{String: name, Valid: true},
+	})
+	if err != nil {
+		_ = os.Remove(filepath.Join(dir, name))
+		return models.WatermarkSettings{}, err
+	}
+	ws.forgetLogo(previous)
+	return mappers.WatermarkToModel(row), nil
+}
+
+// RemoveLogo deletes the logo of a user's watermark; the text is drawn instead
+func (ws *WatermarkService) RemoveLogo(ctx context.Context, userID uint) (models.WatermarkSettings, error) {
+	previous, err := ws.logoName(ctx, userID)
+	if err != nil {
+		return models.WatermarkSettings{}, err
+	}
+
+	row, err := ws.queries.SetUserWatermarkLogo(ctx, db.SetUserWatermarkLogoParams{UserID: int64(userID)})
+	if err != nil {
+		return models.WatermarkSettings{}, err
+	}
+	ws.forgetLogo(previous)
+	return mappers.WatermarkToModel(row), nil
+}
+
+// logoName returns the file name of a user's current logo, or ""
+func (ws *WatermarkService) logoName(ctx context.Context, userID uint) (string, error) {
+	row, err := ws.queries.GetUserWatermark(ctx, int64(userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return row.LogoName.String, nil
+}
+
+// forgetLogo deletes a replaced logo from disk and from the cache
+func (ws *WatermarkService) forgetLogo(name string) {
+	if name == "" || filepath.Base(name) != name {
+		return
+	}
+	ws.mu.Lock()
+	delete(ws.logos, name)
+	ws.mu.Unlock()
+	_ = os.Remove(filepath.Join(WatermarkLogoDir(ws.uploadDir), name))
+}
+
+// MarkForMedia returns the watermark of the owner of a stored file (or one of
+// its thumbnails), or nil if they have none enabled. The version changes
+// whenever the settings change, so derivatives can be cached by it.
+func (ws *WatermarkService) MarkForMedia(ctx context.Context, storedName string) (*watermark.Mark, int64, error) {
+	row, err := ws.queries.GetWatermarkForMediaKey(ctx, strings.TrimSuffix(storedName, filepath.Ext(storedName)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	mark := &watermark.Mark{
+		Text:     row.Text,
+		Position: row.Position,
+		Opacity:  row.Opacity,
+		Scale:    row.Scale,
+	}
+	if row.LogoName.Valid {
+		logo, err := ws.logo(row.LogoName.String)
+		if err != nil {
+			// Better the text than no watermark at all
+			log.Printf("Loading watermark logo %s failed: %v", row.LogoName.String, err)
+		}
+		mark.Logo = logo
+	}
+	return mark, row.UpdatedAt, nil
+}
+
+// logo returns a decoded logo, caching it in memory
+func (ws *WatermarkService) logo(name string) (image.Image, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if img, ok := ws.logos[name]; ok {
+		return img, nil
+	}
+	if filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid logo name %q", name)
+	}
+	img, err := imaging.Open(filepath.Join(WatermarkLogoDir(ws.uploadDir), name))
+	if err != nil {
+		return nil, err
+	}
+	ws.logos[name] = img
+	return img, nil
+}
+
+// Thumbnail returns the path of the thumbnail to serve to people other than
+// the owner: a watermarked copy if the owner has a watermark enabled, or the
+// thumbnail made by smanzy_thumbgen otherwise. Watermarked copies are cached
+// on disk and made again when the thumbnail or the settings change.
+func (ws *WatermarkService) Thumbnail(ctx context.Context, size, name string) (string, error) {
+	raw := ThumbnailPath(ws.uploadDir, size, name)
+	if !slices.Contains(ThumbnailSizes, size) {
+		return raw, nil
+	}
+	return ws.stamp(ctx, name, raw, WatermarkedThumbnailPath(ws.uploadDir, size, name), imaging.JPEG, watermarkedThumbnailQuality)
+}
+
+// Original returns the path of a stored file to serve to people other than
+// the owner: a watermarked copy of images if the owner has a watermark
+// enabled, or the file as uploaded otherwise. Videos and other files that are
+// not images cannot be watermarked and are served as they are. Watermarked
+// copies are cached like thumbnails.
+func (ws *WatermarkService) Original(ctx context.Context, name string) (string, error) {
+	raw := filepath.Join(ws.uploadDir, name)
+	format, err := imaging.FormatFromFilename(name)
+	if err != nil {
+		return raw, nil
+	}
+	return ws.stamp(ctx, name, raw, WatermarkedOriginalPath(ws.uploadDir, name), format, watermarkedOriginalQuality)
+}
+
+// stamp returns the path of the watermarked copy of raw at out, making it
+// if it is missing or older than raw or the owner's settings. It returns raw
+// if the owner of the stored file name has no watermark enabled.
+func (ws *WatermarkService) stamp(ctx context.Context, name, raw, out string, format imaging.Format, quality int) (string, error) {
+	mark, version, err := ws.MarkForMedia(ctx, name)
+	if err != nil || mark == nil {
+		return raw, err
+	}
+	rawInfo, err := os.Stat(raw)
+	if err != nil {
+		return raw, nil // Not made yet; the caller reports it missing
+	}
+
+	if info, err := os.Stat(out); err == nil &&
+		!info.ModTime().Before(rawInfo.ModTime()) && info.ModTime().UnixMilli() >= version {
+		return out, nil
+	}
+
+	img, err := imaging.Open(raw, imaging.AutoOrientation(true))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(out), "watermark-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = imaging.Encode(tmp, watermark.Apply(img, *mark), format, imaging.JPEGQuality(quality))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return out, os.Rename(tmp.Name(), out)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ristep/smanzy_backend/internal/models"
+	"github.com/ristep/smanzy_backend/internal/watermark"
+)
+
+func TestValidateWatermark(t *testing.T) {
+	valid := models.WatermarkSettings{
+		Enabled:  true,
+		Text:     "© Me",
+		Position: watermark.BottomRight,
+		Opacity:  DefaultWatermarkOpacity,
+		Scale:    DefaultWatermarkScale,
+	}
+	if err := ValidateWatermark(valid); err != nil {
+		t.Fatalf("valid settings rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*models.WatermarkSettings)
+	}{
+		{"bad position", func(s *models.WatermarkSettings) { s.Position = "middle" }},
+		{"opacity too low", func(s *models.WatermarkSettings) { s.Opacity = 0 }},
+		{"opacity too high", func(s *models.WatermarkSettings) { s.Opacity = 1.5 }},
+		{"scale too high", func(s *models.WatermarkSettings) { s.Scale = 2 }},
+		{"text too long", func(s *models.WatermarkSettings) { s.Text = string(make([]rune, MaxWatermarkTextLength+1)) }},
+		{"enabled without text or logo", func(s *models.WatermarkSettings) { s.Text = "  " }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.change(&s)
+			if err := ValidateWatermark(s); !errors.Is(err, ErrInvalidWatermark) {
+				t.Errorf("got %v, want ErrInvalidWatermark", err)
+			}
+		})
+	}
+
+	logoOnly := valid
+	logoOnly.Text, logoOnly.HasLogo = "", true
+	if err := ValidateWatermark(logoOnly); err != nil {
+		t.Errorf("logo without text rejected: %v", err)
+	}
+}
+
+func TestWatermarkedThumbnailPath(t *testing.T) {
+	got := WatermarkedThumbnailPath("/uploads", "320x200", "1_1.png")
+	if want := filepath.Join("/uploads", "watermarked", "320x200", "1_1.jpg"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWatermarkedOriginalPath(t *testing.T) {
+	got := WatermarkedOriginalPath("/uploads", "1_1.png")
+	if want := filepath.Join("/uploads", "watermarked", "original", "1_1.png"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+// Package watermark stamps a logo or a line of text onto images
+package watermark
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Positions of the mark on the image
+const (
+	TopLeft     = "top-left"
+	TopRight    = "top-right"
+	BottomLeft  = "bottom-left"
+	BottomRight = "bottom-right"
+	Center      = "center"
+)
+
+// Positions lists the valid positions
+var Positions = []string{TopLeft, TopRight, BottomLeft, BottomRight, Center}
+
+// Mark describes a watermark
+type Mark struct {
+	Logo     image.Image // Drawn when set, otherwise Text is
+	Text     string
+	Position string  // One of the Positions
+	Opacity  float64 // 0 (invisible) to 1 (opaque)
+	Scale    float64 // Width of the mark as a fraction of the image width
+}
+
+// IsValidPosition reports whether p is one of the Positions
+func IsValidPosition(p string) bool {
+	for _, pos := range Positions {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns a copy of img with the mark drawn on it. The mark keeps its
+// aspect ratio and is shrunk further if it would be taller than the image.
+// An empty mark returns an unmarked copy.
+func Apply(img image.Image, m Mark) *image.NRGBA {
+	dst := imaging.Clone(img)
+
+	mark := m.Logo
+	if mark == nil {
+		mark = renderText(m.Text)
+	}
+	if mark == nil || m.Opacity <= 0 || m.Scale <= 0 {
+		return dst
+	}
+
+	b, mb := dst.Bounds(), mark.Bounds()
+	w := int(float64(b.Dx()) * m.Scale)
+	h := w * mb.Dy() / mb.Dx()
+	if h > b.Dy() {
+		h = b.Dy()
+		w = h * mb.Dx() / mb.Dy()
+	}
+	if w < 1 || h < 1 {
+		return dst
+	}
+	scaled := imaging.Resize(mark, w, h, imaging.Lanczos)
+
+	margin := max(2, min(b.Dx(), b.Dy())/40)
+	return imaging.Overlay(dst, scaled, position(m.Position, b.Size(), image.Pt(w, h), margin), min(m.Opacity, 1))
+}
+
+// position returns the top-left corner of a mark of the given size
+func position(pos string, img, mark image.Point, margin int) image.Point {
+	left, top := margin, margin
+	right, bottom := img.X-mark.X-margin, img.Y-mark.Y-margin
+	switch pos {
+	case TopLeft:
+		return image.Pt(left, top)
+	case TopRight:
+		return image.Pt(right, top)
+	case BottomLeft:
+		return image.Pt(left, bottom)
+	case Center:
+		return image.Pt((img.X-mark.X)/2, (img.Y-mark.Y)/2)
+	default:
+		return image.Pt(right, bottom)
+	}
+}
+
+// renderText draws text in white with a dark shadow, so it reads on light
+// and dark images alike. It returns nil for empty text.
+func renderText(text string) image.Image {
+	if text == "" {
+		return nil
+	}
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil()
+	height := face.Metrics().Height.Ceil()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width+1, height+1))
+	for _, layer := range []struct {
+		c      color.Color
+		offset int
+	}{
+		{color.NRGBA{0, 0, 0, 160}, 1},
+		{color.White, 0},
+	} {
+		d := font.Drawer{
+			Dst:  img,
+			Src:  image.NewUniform(layer.c),
+			Face: face,
+			Dot:  fixed.P(layer.offset, face.Metrics().Ascent.Ceil()+layer.offset),
+		}
+		d.DrawString(text)
+	}
+	return img
+}
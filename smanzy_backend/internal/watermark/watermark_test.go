@@ -0,0 +1,97 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gray returns a solid mid-gray image
+func gray(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 128, 128, 128, 255
+	}
+	return img
+}
+
+// changed reports whether any pixel inside r differs from the source
+func changed(src, dst *image.NRGBA, r image.Rectangle) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if src.NRGBAAt(x, y) != dst.NRGBAAt(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestApply_LogoPosition(t *testing.T) {
+	src := gray(400, 300)
+	logo := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for i := 0; i < len(logo.Pix); i += 4 {
+		logo.Pix[i], logo.Pix[i+3] = 255, 255 // Opaque red
+	}
+
+	out := Apply(src, Mark{Logo: logo, Position: BottomRight, Opacity: 1, Scale: 0.25})
+	if out.Bounds() != src.Bounds() {
+		t.Fatalf("size changed: %v", out.Bounds())
+	}
+	if !changed(src, out, image.Rect(300, 250, 400, 300)) {
+		t.Error("bottom-right corner was not marked")
+	}
+	if changed(src, out, image.Rect(0, 0, 200, 150)) {
+		t.Error("top-left quarter should be untouched")
+	}
+	if c := out.NRGBAAt(380, 280); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("opaque logo pixel = %v", c)
+	}
+	if src.NRGBAAt(380, 280) != (color.NRGBA{128, 128, 128, 255}) {
+		t.Error("source image was modified")
+	}
+}
+
+func TestApply_Opacity(t *testing.T) {
+	src := gray(200, 200)
+	out := Apply(src, Mark{Logo: image.NewNRGBA(image.Rect(0, 0, 10, 10)), Position: Center, Opacity: 1, Scale: 0.5})
+	if changed(src, out, out.Bounds()) {
+		t.Error("a fully transparent logo should leave the image unchanged")
+	}
+
+	white := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+	out = Apply(src, Mark{Logo: white, Position: Center, Opacity: 0.5, Scale: 0.5})
+	c := out.NRGBAAt(100, 100)
+	if c.R <= 128 || c.R >= 255 {
+		t.Errorf("half-opaque white over gray = %v, want a blend", c)
+	}
+}
+
+func TestApply_Text(t *testing.T) {
+	src := gray(640, 400)
+	out := Apply(src, Mark{Text: "© Smanzy", Position: TopLeft, Opacity: 0.8, Scale: 0.3})
+	if !changed(src, out, image.Rect(0, 0, 320, 100)) {
+		t.Error("text mark was not drawn in the top-left corner")
+	}
+	if changed(src, out, image.Rect(320, 200, 640, 400)) {
+		t.Error("bottom-right quarter should be untouched")
+	}
+
+	if changed(src, Apply(src, Mark{Position: TopLeft, Opacity: 1, Scale: 0.3}), src.Bounds()) {
+		t.Error("an empty mark should leave the image unchanged")
+	}
+}
+
+func TestIsValidPosition(t *testing.T) {
+	for _, p := range Positions {
+		if !IsValidPosition(p) {
+			t.Errorf("%q should be valid", p)
+		}
+	}
+	if IsValidPosition("middle") {
+		t.Error(`"middle" should be invalid`)
+	}
+}
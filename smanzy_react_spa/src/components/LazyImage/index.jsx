@@ -4,6 +4,8 @@ import styles from "./index.module.scss";
 /**
  * LazyImage component with skeleton loading and error handling
  * Provides smooth loading experience with fade-in animation
+ * Pass a media item's `dominant_color` and `aspect_ratio` as placeholderColor
+ * and aspectRatio to reserve its space and tint the skeleton before it loads.
  */
 export default function LazyImage({
   src,
@@ -12,6 +14,8 @@ export default function LazyImage({
   skeletonClassName = "",
   onLoad,
   onError,
+  placeholderColor,
+  aspectRatio,
   ...props
 }) {
   const [isLoading, setIsLoading] = useState(true);
@@ -45,9 +49,15 @@ export default function LazyImage({
   };
 
   return (
-    <div className={styles.lazyImageWrapper}>
+    <div
+      className={styles.lazyImageWrapper}
+      style={aspectRatio ? { aspectRatio } : undefined}
+    >
       {isLoading && !hasError && (
-        <div className={`${styles.skeleton} ${skeletonClassName}`} />
+        <div
+          className={`${styles.skeleton} ${skeletonClassName}`}
+          style={placeholderColor ? { background: placeholderColor } : undefined}
+        />
       )}
       {hasError ? (
         <div className={styles.errorPlaceholder}>
@@ -8,6 +8,7 @@ import {
   getMediaUrl,
   getThumbnailUrl,
   isImageFile,
+  isThumbnailPending,
   isVideoFile,
 } from "@/utils/fileUtils";
 import styles from "./index.module.scss";
@@ -62,7 +63,11 @@ export default function MediaCard({
             onClick={() => isPreviewable && setShowPreview(true)}
           >
             {isPreviewable ? (
-              isImageFile(media.mime_type) ? (
+              isImageFile(media.mime_type) && isThumbnailPending(media) ? (
+                <div className={styles.largeThumbPlaceholder}>
+                  <FileIcon mimeType={media.mime_type} size={48} />
+                </div>
+              ) : isImageFile(media.mime_type) ? (
                 <img
                   onClick={() => handlePreview(media)}
                   src={thumbUrl}
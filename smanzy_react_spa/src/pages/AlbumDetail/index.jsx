@@ -44,7 +44,7 @@ export default function AlbumDetail() {
     // Fetch all media for adding to album (library)
     const { data: mediaData } = useQuery({
         queryKey: ['media', 'all'],
-        queryFn: () => api.get('/media?limit=1000').then((res) => res.data),
+        queryFn: () => api.get('/media/mine?limit=1000').then((res) => res.data),
         retry: false,
     });
 
@@ -247,6 +247,7 @@ export default function AlbumDetail() {
                                                             {media.mime_type.startsWith('image/') ? (
                                                                 <LazyImage
                                                                     src={getThumbnailUrl(media)}
+                                                                    placeholderColor={media.dominant_color}
                                                                     alt=""
                                                                     className={styles.itemThumbImage}
                                                                     onClick={() => setPreviewMedia(media)}
@@ -1,4 +1,5 @@
 import { useState, useEffect } from "react";
+import { Link } from "react-router-dom";
 import VideoCard from "@/components/VideoCard";
 import Pagination from "@/components/Pagination";
 import styles from "./index.module.scss";
@@ -9,12 +10,23 @@ export default function Home() {
     const [loading, setLoading] = useState(true);
     const [page, setPage] = useState(1);
     const [totalPages, setTotalPages] = useState(0);
+    const [tagCloud, setTagCloud] = useState([]);
     const limit = 3;
 
     useEffect(() => {
         fetchVideos(page);
     }, [page]);
 
+    useEffect(() => {
+        api.get("/tags/cloud?limit=30")
+            .then((res) => setTagCloud(res.data?.data || []))
+            .catch((error) => console.error("Error fetching tag cloud:", error));
+    }, []);
+
+    // Scale tag font size between 0.85rem and 1.6rem by usage
+    const maxCount = Math.max(1, ...tagCloud.map((t) => t.count));
+    const tagSize = (count) => `${0.85 + (0.75 * count) / maxCount}rem`;
+
     async function fetchVideos(pageNum) {
         setLoading(true);
         try {
@@ -79,6 +91,22 @@ export default function Home() {
                 </a>
             </header>
 
+            {tagCloud.length > 0 && (
+                <section className={styles.tagCloud}>
+                    {tagCloud.map((tag) => (
+                        <Link
+                            key={tag.name}
+                            to={`/mediacards?tags=${encodeURIComponent(tag.name)}`}
+                            className={tag.is_curated ? styles.curatedTag : styles.tag}
+                            style={{ fontSize: tagSize(tag.count) }}
+                            title={`${tag.count} items`}
+                        >
+                            #{tag.name}
+                        </Link>
+                    ))}
+                </section>
+            )}
+
             <section className={styles.videosSection}>
                 <div className={styles.videosGrid}>
                     {loading ? (
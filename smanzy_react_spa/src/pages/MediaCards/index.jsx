@@ -72,13 +72,18 @@ export default function MediaCards() {
   }, [calculateOptimalLimit]);
 
   const page = parseInt(searchParams.get("page")) || 1;
+  const tags = searchParams.get("tags") || "";
+
+  // Keep the tag filter when changing pages
+  const withTags = (params) => (tags ? { ...params, tags } : params);
 
   // Fetch media list (include limit in the query key so we refetch when it changes)
   const { isPending, error, data } = useQuery({
-    queryKey: ["media", page, limit],
+    queryKey: ["media", page, limit, tags],
     queryFn: () =>
       api
-        .get(`/media?limit=${limit}&offset=${(page - 1) * limit}`)
+        .get(`/media?limit=${limit}&offset=${(page - 1) * limit}`
+          + (tags ? `&tags=${encodeURIComponent(tags)}` : ""))
         .then((res) => res.data),
     keepPreviousData: true,
     retry: false,
@@ -91,14 +96,14 @@ export default function MediaCards() {
     const pages = Math.ceil(totalItems / (limit || 1));
 
     if (pages === 0 && page !== 1) {
-      setSearchParams({ page: 1 });
+      setSearchParams(withTags({ page: 1 }));
     } else if (pages > 0 && page > pages) {
-      setSearchParams({ page: pages });
+      setSearchParams(withTags({ page: pages }));
     }
   }, [data, limit, page, setSearchParams]);
 
   const handlePageChange = (newPage) => {
-    setSearchParams({ page: newPage });
+    setSearchParams(withTags({ page: newPage }));
     window.scrollTo({ top: 0, behavior: "smooth" });
   };
 
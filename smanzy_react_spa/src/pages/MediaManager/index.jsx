@@ -31,7 +31,7 @@ export default function MediaManager() {
     queryKey: ["media", page],
     queryFn: () =>
       api
-        .get(`/media?limit=${limit}&offset=${(page - 1) * limit}`)
+        .get(`/media/mine?limit=${limit}&offset=${(page - 1) * limit}`)
         .then((res) => {
           return res.data;
         }),
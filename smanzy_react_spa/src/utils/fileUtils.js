@@ -29,8 +29,23 @@ const mediaSize = (size = "medium") => {
   }
 };
 
+// The API returns a signed `url` for each media item. The same signature
+// also unlocks its thumbnails, so reuse its query string (?expires=&sig=).
+const signedQuery = (media) => {
+  const url = media.url || "";
+  const idx = url.indexOf("?");
+  return idx === -1 ? "" : url.slice(idx);
+};
+
+// Media responses carry signed thumbnail URLs (keyed small, medium, large,
+// xl) once the thumbnails exist, along with a `thumbnail_status`. Older
+// responses without them fall back to building the path from `stored_name`.
 export const getThumbnailUrl = (media, size = "medium") => {
   const apiBaseUrl = import.meta.env.VITE_API_BASE_URL || "";
+  const ready = media.thumbnails?.[size];
+  if (ready) {
+    return apiBaseUrl.replace("/api", "") + ready;
+  }
   const baseUrl = apiBaseUrl.replace("/api", "/api/media/thumbs/");
   // Construct thumbnail URL (no extra logging)
   return (
@@ -38,16 +53,18 @@ export const getThumbnailUrl = (media, size = "medium") => {
     mediaSize(size) +
     "/" +
     media.stored_name.replace(/\.[^/.]+$/, "") +
-    ".jpg"
+    ".jpg" +
+    signedQuery(media)
   );
 };
 
-// Thumbnail polling helpers removed — using a different approach for thumbnail availability checks.
+// Thumbnails are still being generated; callers can show a placeholder
+export const isThumbnailPending = (media) => media.thumbnail_status === "pending";
 
 export const getMediaUrl = (media) => {
   const apiBaseUrl = import.meta.env.VITE_API_BASE_URL || "";
   const baseUrl = apiBaseUrl.replace("/api", "/api/media/files/");
-  return baseUrl + media.stored_name;
+  return baseUrl + media.stored_name + signedQuery(media);
 };
 
 /**